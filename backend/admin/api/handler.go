@@ -1,8 +1,12 @@
 package adminapi
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_engine"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/relayer_pnl"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	"github.com/labstack/echo"
@@ -10,6 +14,7 @@ import (
 	"math/big"
 	"net/http"
 	"os"
+	"strconv"
 	"time"
 )
 
@@ -140,6 +145,96 @@ func DeleteOrderHandler(e echo.Context) (err error) {
 	return response(e, nil, err)
 }
 
+// GetLaunchLogHandler shows what happened to a launch log, every attempt to send it,
+// and the transaction and trades it settles.
+func GetLaunchLogHandler(e echo.Context) (err error) {
+	id, err := strconv.Atoi(e.Param("id"))
+	if err != nil {
+		return response(e, nil, fmt.Errorf("launch log id should be a number"))
+	}
+
+	launchLog := models.LaunchLogDao.FindLaunchLogByID(id)
+	if launchLog == nil || launchLog.ID == 0 {
+		return response(e, nil, fmt.Errorf("cannot find launch log by ID %d", id))
+	}
+
+	data := map[string]interface{}{
+		"launchLog": launchLog,
+		"attempts":  models.LaunchLogAttemptDao.FindByLaunchLogID(launchLog.ID),
+	}
+
+	if launchLog.ItemType == models.LaunchLogItemTypeTrade {
+		data["transaction"] = models.TransactionDao.FindTransactionByID(launchLog.ItemID)
+		data["trades"] = models.TradeDao.FindTradeByTransactionID(launchLog.ItemID)
+	}
+
+	return response(e, data, nil)
+}
+
+func ReconcileHandler(e echo.Context) (err error) {
+	var req struct {
+		MarketID string `json:"market_id" query:"market_id"`
+		Repair   string `json:"repair"    query:"repair"`
+	}
+
+	err = e.Bind(&req)
+	if err != nil {
+		return response(e, nil, err)
+	}
+
+	if req.Repair != dex_engine.ReconcileRepairNone && req.Repair != dex_engine.ReconcileRepairBook && req.Repair != dex_engine.ReconcileRepairDB {
+		err = fmt.Errorf("repair should be one of %s, %s or empty", dex_engine.ReconcileRepairBook, dex_engine.ReconcileRepairDB)
+		return response(e, nil, err)
+	}
+
+	event := dex_engine.ReconcileMarketEvent{
+		Event: common.Event{
+			Type:     dex_engine.EventReconcileMarket,
+			MarketID: req.MarketID,
+		},
+		Repair: req.Repair,
+	}
+
+	err = queueService.Push([]byte(utils.ToJsonString(event)))
+	return response(e, nil, err)
+}
+
+func GetReconcileReportsHandler(e echo.Context) (err error) {
+	marketID := e.QueryParam("market_id")
+
+	var marketIDs []string
+	if marketID != "" {
+		marketIDs = append(marketIDs, marketID)
+	} else {
+		for _, market := range models.MarketDao.FindPublishedMarkets() {
+			marketIDs = append(marketIDs, market.ID)
+		}
+	}
+
+	reports := []*dex_engine.ReconcileReport{}
+
+	for _, id := range marketIDs {
+		var res string
+		res, err = cacheService.Get(dex_engine.GetReconcileReportKey(id))
+		if err == common.KVStoreEmpty {
+			err = nil
+			continue
+		} else if err != nil {
+			return response(e, nil, err)
+		}
+
+		var report dex_engine.ReconcileReport
+		err = json.Unmarshal([]byte(res), &report)
+		if err != nil {
+			return response(e, nil, err)
+		}
+
+		reports = append(reports, &report)
+	}
+
+	return response(e, map[string]interface{}{"reports": reports}, err)
+}
+
 func ListMarketsHandler(e echo.Context) (err error) {
 	markets := models.MarketDao.FindAllMarkets()
 	return response(e, markets, err)
@@ -163,7 +258,7 @@ func EditMarketHandler(e echo.Context) (err error) {
 	var publishType string
 	if dbMarket.IsPublished == false && fields.IsPublished == "true" {
 		publishType = "publish"
-	} else if dbMarket.IsPublished == true && fields.IsPublished == "false" {
+	} else if dbMarket.Status != models.MarketStatusUnpublished && fields.IsPublished == "false" {
 		publishType = "unPublish"
 	}
 
@@ -188,37 +283,63 @@ func EditMarketHandler(e echo.Context) (err error) {
 	if len(fields.GasUsedEstimation) > 0 {
 		dbMarket.GasUsedEstimation = utils.ParseInt(fields.GasUsedEstimation, 0)
 	}
-	if fields.IsPublished == "true" {
-		dbMarket.IsPublished = true
-	} else if fields.IsPublished == "false" {
-		dbMarket.IsPublished = false
+
+	if publishType == "publish" {
+		err = publishMarket(dbMarket)
+	} else if publishType == "unPublish" {
+		err = unpublishMarket(dbMarket)
+	} else {
+		err = models.MarketDao.UpdateMarket(dbMarket)
 	}
 
-	err = models.MarketDao.UpdateMarket(dbMarket)
-	if err == nil {
-		if publishType == "publish" {
-			event := common.Event{
-				Type:     common.EventOpenMarket,
-				MarketID: dbMarket.ID,
-			}
+	return response(e, nil, err)
+}
 
-			err = approveMarket(dbMarket)
-			if err == nil {
-				err = queueService.Push([]byte(utils.ToJsonString(event)))
-			}
-		} else if publishType == "unPublish" {
-			event := common.CancelOrderEvent{
-				Event: common.Event{
-					Type:     common.EventCloseMarket,
-					MarketID: dbMarket.ID,
-				},
-			}
+// publishMarket publishes the market once the proxy is approved to spend both of its tokens from the relayer.
+// If an approval has to be mined first, the market is approving until the watcher publishes it.
+func publishMarket(market *models.Market) error {
+	awaiting, err := approveMarket(market)
+	if err != nil {
+		return err
+	}
 
-			err = queueService.Push([]byte(utils.ToJsonString(event)))
-		}
+	market.ApprovalError = ""
+
+	if awaiting {
+		market.SetStatus(models.MarketStatusApproving)
+		return models.MarketDao.UpdateMarket(market)
 	}
 
-	return response(e, nil, err)
+	market.SetStatus(models.MarketStatusPublished)
+	if err = models.MarketDao.UpdateMarket(market); err != nil {
+		return err
+	}
+
+	event := common.Event{
+		Type:     common.EventOpenMarket,
+		MarketID: market.ID,
+	}
+
+	return queueService.Push([]byte(utils.ToJsonString(event)))
+}
+
+// unpublishMarket closes the market in the engine if it is published, an approving market is just not published later.
+func unpublishMarket(market *models.Market) error {
+	wasPublished := market.IsPublished
+
+	market.SetStatus(models.MarketStatusUnpublished)
+	if err := models.MarketDao.UpdateMarket(market); err != nil || !wasPublished {
+		return err
+	}
+
+	event := common.CancelOrderEvent{
+		Event: common.Event{
+			Type:     common.EventCloseMarket,
+			MarketID: market.ID,
+		},
+	}
+
+	return queueService.Push([]byte(utils.ToJsonString(event)))
 }
 
 func ApproveMarketHandler(e echo.Context) (err error) {
@@ -230,7 +351,8 @@ func ApproveMarketHandler(e echo.Context) (err error) {
 		return response(e, nil, err)
 	}
 
-	return response(e, nil, approveMarket(dbMarket))
+	_, err = approveMarket(dbMarket)
+	return response(e, nil, err)
 }
 
 func CreateMarketHandler(e echo.Context) (err error) {
@@ -241,53 +363,168 @@ func CreateMarketHandler(e echo.Context) (err error) {
 		return response(e, nil, err)
 	}
 
+	// a market is published through its approvals like an edited one
+	publish := market.IsPublished
+	market.SetStatus(models.MarketStatusUnpublished)
+
 	err = models.MarketDao.InsertMarket(&market)
+	if err == nil && publish {
+		err = publishMarket(&market)
+	}
+
 	return response(e, nil, err)
 }
 
-func approveMarket(market *models.Market) (err error) {
-	err, quoteTokenAllowance := erc20Service.AllowanceOf(market.QuoteTokenAddress, os.Getenv("HSK_PROXY_ADDRESS"), os.Getenv("HSK_RELAYER_ADDRESS"))
-	if err != nil {
+// approveMarket approves the proxy to spend the tokens of the market which it can't spend from the relayer yet.
+// It tells if an approval is still to be mined, a token whose approval is in flight is not approved again.
+func approveMarket(market *models.Market) (awaiting bool, err error) {
+	// nothing is settled on the chain in paper trading mode
+	if models.IsPaperTrading() {
 		return
 	}
-	if quoteTokenAllowance.Cmp(big.NewInt(0)) <= 0 {
-		err = approveToken(market.QuoteTokenAddress)
+
+	proxyAddress := os.Getenv("HSK_PROXY_ADDRESS")
+	relayerAddress := os.Getenv("HSK_RELAYER_ADDRESS")
+
+	for _, tokenAddress := range []string{market.QuoteTokenAddress, market.BaseTokenAddress} {
+		var allowance *big.Int
+		err, allowance = erc20Service.AllowanceOf(tokenAddress, proxyAddress, relayerAddress)
 		if err != nil {
 			return
 		}
-	}
-	err, baseTokenAllowance := erc20Service.AllowanceOf(market.BaseTokenAddress, os.Getenv("HSK_PROXY_ADDRESS"), os.Getenv("HSK_RELAYER_ADDRESS"))
-	if err != nil {
-		return
-	}
-	if baseTokenAllowance.Cmp(big.NewInt(0)) <= 0 {
-		err = approveToken(market.BaseTokenAddress)
+
+		if allowance.Cmp(big.NewInt(0)) > 0 {
+			continue
+		}
+
+		awaiting = true
+
+		if approvals := models.LaunchLogDao.FindApprovals(relayerAddress, tokenAddress, 1); len(approvals) > 0 && models.IsApprovalInFlight(approvals[0]) {
+			continue
+		}
+
+		var approveLog *models.LaunchLog
+		approveLog, err = models.NewApproveLaunchLog(relayerAddress, proxyAddress, tokenAddress)
+		if err != nil {
+			return
+		}
+
+		err = models.LaunchLogDao.InsertLaunchLog(approveLog)
 		if err != nil {
 			return
 		}
 	}
+
 	return
 }
 
-func approveToken(tokenAddress string) error {
-	proxyAddress := os.Getenv("HSK_PROXY_ADDRESS")
-	if len(proxyAddress) != 42 {
-		return fmt.Errorf("HSK_PROXY_ADDRESS empty")
-	}
-	proxyAddress = proxyAddress[2:]
-	approveLog := models.LaunchLog{
-		ItemType:  "hydroApprove",
-		Status:    "created",
-		From:      os.Getenv("HSK_RELAYER_ADDRESS"),
-		To:        tokenAddress,
-		Value:     decimal.Zero,
-		GasLimit:  int64(200000),
-		Data:      fmt.Sprintf("0x095ea7b3000000000000000000000000%sf000000000000000000000000000000000000000000000000000000000000000", proxyAddress),
-		CreatedAt: time.Now().UTC(),
-		UpdatedAt: time.Now().UTC(),
-	}
-
-	return models.LaunchLogDao.InsertLaunchLog(&approveLog)
+// DepositHandler credits the ledger balance of an address in paper trading mode, the amount is in token units.
+func DepositHandler(e echo.Context) (err error) {
+	var req struct {
+		Address      string `json:"address"`
+		TokenAddress string `json:"token_address"`
+		Amount       string `json:"amount"`
+	}
+
+	err = e.Bind(&req)
+	if err != nil {
+		return response(e, nil, err)
+	}
+
+	if !models.IsPaperTrading() {
+		return response(e, nil, fmt.Errorf("deposits are only credited in paper trading mode, HSK_PAPER_TRADING=true"))
+	}
+
+	if len(req.Address) != 42 {
+		return response(e, nil, fmt.Errorf("invalid address %s", req.Address))
+	}
+
+	token := models.TokenDao.FindTokenByAddress(req.TokenAddress)
+	if token == nil {
+		return response(e, nil, fmt.Errorf("cannot find token by address %s", req.TokenAddress))
+	}
+
+	amount, err := decimal.NewFromString(req.Amount)
+	if err != nil || !amount.IsPositive() {
+		return response(e, nil, fmt.Errorf("amount should be a positive number"))
+	}
+
+	deposit := &models.LedgerEntry{
+		Kind:         models.LedgerEntryKindDeposit,
+		Address:      req.Address,
+		TokenAddress: token.Address,
+		Amount:       amount,
+	}
+
+	_, err = models.LedgerDao.Apply(models.DepositLedgerReference(req.Address, token.Address, time.Now()), []*models.LedgerEntry{deposit})
+	if err != nil {
+		return response(e, nil, err)
+	}
+
+	return response(e, map[string]interface{}{
+		"symbol":  token.Symbol,
+		"balance": models.LedgerDao.GetBalance(req.Address, token.Address),
+	}, nil)
+}
+
+// GetLedgerBalancesHandler shows the ledger balances of an address in paper trading mode.
+func GetLedgerBalancesHandler(e echo.Context) (err error) {
+	address := e.QueryParam("address")
+	if address == "" {
+		return response(e, nil, fmt.Errorf("address is required"))
+	}
+
+	return response(e, map[string]interface{}{"balances": models.LedgerDao.FindBalances(address)}, nil)
+}
+
+// GetRelayerPnLHandler reports the fees the relayer collected against the gas it paid, per market and day between the from and to days.
+// By default it covers the last 30 days, format=csv exports the daily results as a CSV file.
+func GetRelayerPnLHandler(e echo.Context) (err error) {
+	var from, to time.Time
+
+	today := time.Now().UTC().Truncate(24 * time.Hour)
+
+	if to, err = parseDay(e.QueryParam("to"), today); err != nil {
+		return response(e, nil, err)
+	}
+
+	if from, err = parseDay(e.QueryParam("from"), to.AddDate(0, 0, -29)); err != nil {
+		return response(e, nil, err)
+	}
+
+	if from.After(to) {
+		return response(e, nil, fmt.Errorf("from should not be after to"))
+	}
+
+	// the to day is included
+	pnls := models.RelayerPnLDao.FindDailyPnL(e.QueryParam("market_id"), from, to.AddDate(0, 0, 1))
+
+	if e.QueryParam("format") == "csv" {
+		var buf bytes.Buffer
+		if err = relayer_pnl.WriteCSV(&buf, pnls); err != nil {
+			return response(e, nil, err)
+		}
+
+		e.Response().Header().Set(echo.HeaderContentDisposition,
+			fmt.Sprintf("attachment; filename=relayer-pnl-%s-%s.csv", from.Format("20060102"), to.Format("20060102")))
+		return e.Blob(http.StatusOK, "text/csv", buf.Bytes())
+	}
+
+	return response(e, map[string]interface{}{"daily": pnls, "markets": relayer_pnl.MarketTotals(pnls)}, nil)
+}
+
+// parseDay parses a day like 2019-01-31 in UTC, an empty day is the default.
+func parseDay(day string, defaultDay time.Time) (time.Time, error) {
+	if day == "" {
+		return defaultDay, nil
+	}
+
+	t, err := time.Parse("2006-01-02", day)
+	if err != nil {
+		return t, fmt.Errorf("invalid day %s, it should be like 2019-01-31", day)
+	}
+
+	return t, nil
 }
 
 func response(e echo.Context, data interface{}, err error) error {
@@ -14,6 +14,7 @@ import (
 )
 
 var queueService common.IQueue
+var cacheService common.IKVStore
 var healthCheckService IHealthCheckMonitor
 var erc20Service ethereum.IErc20
 
@@ -28,6 +29,12 @@ func loadRoutes(e *echo.Echo) {
 	e.Add("GET", "/balances", GetBalancesHandler)
 	e.Add("GET", "/status", GetStatusHandler)
 	e.Add("POST", "/restart_engine", RestartEngineHandler)
+	e.Add("POST", "/reconcile", ReconcileHandler)
+	e.Add("GET", "/reconcile", GetReconcileReportsHandler)
+	e.Add("GET", "/launch_logs/:id", GetLaunchLogHandler)
+	e.Add("POST", "/ledger/deposits", DepositHandler)
+	e.Add("GET", "/ledger/balances", GetLedgerBalancesHandler)
+	e.Add("GET", "/pnl", GetRelayerPnLHandler)
 }
 
 func newEchoServer() *echo.Echo {
@@ -56,12 +63,21 @@ func StartServer(ctx context.Context) {
 	//init erc20 service
 	erc20Service = ethereum.NewErc20Service(nil)
 
+	redisClient := connection.NewRedisClient(os.Getenv("HSK_REDIS_URL"))
+
 	//init event queue
 	queueService, _ = common.InitQueue(
 		&common.RedisQueueConfig{
 			Name:   common.HYDRO_ENGINE_EVENTS_QUEUE_KEY,
 			Ctx:    ctx,
-			Client: connection.NewRedisClient(os.Getenv("HSK_REDIS_URL")),
+			Client: redisClient,
+		},
+	)
+
+	cacheService, _ = common.InitKVStore(
+		&common.RedisKVStoreConfig{
+			Ctx:    ctx,
+			Client: redisClient,
 		},
 	)
 
@@ -46,6 +46,15 @@ type IAdminApi interface {
 	CancelOrder(ID string) ([]byte, error)
 
 	RestartEngine() ([]byte, error)
+	Reconcile(marketID, repair string) ([]byte, error)
+	ReconcileReports(marketID string) ([]byte, error)
+
+	ShowLaunchLog(ID string) ([]byte, error)
+
+	Deposit(address, tokenAddress, amount string) ([]byte, error)
+	ListLedgerBalances(address string) ([]byte, error)
+
+	RelayerPnL(marketID, from, to, format string) ([]byte, error)
 }
 
 type Admin struct {
@@ -59,6 +68,10 @@ type Admin struct {
 	ListTradeUrl     string
 	RestartEngineUrl string
 	StatusUrl        string
+	ReconcileUrl     string
+	LaunchLogUrl     string
+	LedgerUrl        string
+	PnLUrl           string
 }
 
 func NewAdmin(adminApiUrl string, httpClient utils.IHttpClient, erc20 ethereum.IErc20) IAdminApi {
@@ -98,6 +111,10 @@ func NewAdmin(adminApiUrl string, httpClient utils.IHttpClient, erc20 ethereum.I
 	a.ListBalanceUrl = fmt.Sprintf("%s/%s", adminApiUrl, "balances")
 	a.RestartEngineUrl = fmt.Sprintf("%s/%s", adminApiUrl, "restart_engine")
 	a.StatusUrl = fmt.Sprintf("%s/%s", adminApiUrl, "status")
+	a.ReconcileUrl = fmt.Sprintf("%s/%s", adminApiUrl, "reconcile")
+	a.LaunchLogUrl = fmt.Sprintf("%s/%s", adminApiUrl, "launch_logs")
+	a.LedgerUrl = fmt.Sprintf("%s/%s", adminApiUrl, "ledger")
+	a.PnLUrl = fmt.Sprintf("%s/%s", adminApiUrl, "pnl")
 
 	return &a
 }
@@ -271,6 +288,47 @@ func (a *Admin) RestartEngine() (ret []byte, err error) {
 	return
 }
 
+func (a *Admin) Reconcile(marketID, repair string) (ret []byte, err error) {
+	var params []utils.KeyValue
+	params = append(params, utils.KeyValue{Key: "market_id", Value: marketID})
+	params = append(params, utils.KeyValue{Key: "repair", Value: repair})
+
+	err, _, ret = a.client.Post(a.ReconcileUrl, params, nil, nil)
+	return
+}
+
+func (a *Admin) ReconcileReports(marketID string) (ret []byte, err error) {
+	var params []utils.KeyValue
+	params = append(params, utils.KeyValue{Key: "market_id", Value: marketID})
+
+	err, _, ret = a.client.Get(a.ReconcileUrl, params, nil, nil)
+	return
+}
+
+func (a *Admin) ShowLaunchLog(ID string) (ret []byte, err error) {
+	err, _, ret = a.client.Get(fmt.Sprintf("%s/%s", a.LaunchLogUrl, ID), nil, nil, nil)
+	return
+}
+
+func (a *Admin) Deposit(address, tokenAddress, amount string) (ret []byte, err error) {
+	deposit := map[string]string{
+		"address":       strings.ToLower(address),
+		"token_address": strings.ToLower(tokenAddress),
+		"amount":        amount,
+	}
+
+	err, _, ret = a.client.Post(fmt.Sprintf("%s/%s", a.LedgerUrl, "deposits"), nil, deposit, nil)
+	return
+}
+
+func (a *Admin) ListLedgerBalances(address string) (ret []byte, err error) {
+	var params []utils.KeyValue
+	params = append(params, utils.KeyValue{Key: "address", Value: strings.ToLower(address)})
+
+	err, _, ret = a.client.Get(fmt.Sprintf("%s/%s", a.LedgerUrl, "balances"), params, nil, nil)
+	return
+}
+
 func DefaultIfNil(ori, dft string) string {
 	if len(ori) == 0 {
 		return dft
@@ -290,3 +348,14 @@ type marketFields struct {
 	GasUsedEstimation string `json:"gas_used_estimation"`
 	IsPublished       string `json:"is_published"`
 }
+
+func (a *Admin) RelayerPnL(marketID, from, to, format string) (ret []byte, err error) {
+	var params []utils.KeyValue
+	params = append(params, utils.KeyValue{Key: "market_id", Value: marketID})
+	params = append(params, utils.KeyValue{Key: "from", Value: from})
+	params = append(params, utils.KeyValue{Key: "to", Value: to})
+	params = append(params, utils.KeyValue{Key: "format", Value: format})
+
+	err, _, ret = a.client.Get(a.PnLUrl, params, nil, nil)
+	return
+}
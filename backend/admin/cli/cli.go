@@ -27,6 +27,10 @@ func NewDexCli() *cli.App {
 	var makerFeeRate string
 	var takerFeeRate string
 	var gasUsedEstimation string
+	var repair string
+	var from string
+	var to string
+	var format string
 
 	//var limit string
 	//var offset string
@@ -381,6 +385,142 @@ func NewDexCli() *cli.App {
 		//		},
 		//	},
 		//},
+		{
+			Name:  "engine",
+			Usage: "Manage hydro dex engine",
+			Subcommands: cli.Commands{
+				{
+					Name:  "reconcile",
+					Usage: "Compare the engine order book with the database",
+					Description: `
+    Example: reconcile all markets and only report the discrepancies
+
+    hydro-dex-ctl engine reconcile
+
+    Example: reconcile market 'HOT-WETH', and fix the engine book with the database
+
+    hydro-dex-ctl engine reconcile --repair book HOT-WETH`,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:        "repair",
+							Usage:       "book: fix the engine book with the database, db: fix the database with the engine book",
+							Destination: &repair,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						printIfErr(admin.Reconcile(c.Args().Get(0), repair))
+						return nil
+					},
+				},
+				{
+					Name:  "reconcileReport",
+					Usage: "Show the latest reconciliation reports",
+					Description: `
+    Example:
+
+    hydro-dex-ctl engine reconcileReport HOT-WETH`,
+					Action: func(c *cli.Context) error {
+						printIfErr(admin.ReconcileReports(c.Args().Get(0)))
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "launchlog",
+			Usage: "Inspect launch logs",
+			Subcommands: cli.Commands{
+				{
+					Name:  "show",
+					Usage: "Show a launch log with its attempts, transaction and trades",
+					Description: `
+    Example:
+
+    hydro-dex-ctl launchlog show 42`,
+					Action: func(c *cli.Context) error {
+						ID := c.Args().Get(0)
+						if len(ID) == 0 {
+							return cli.ShowSubcommandHelp(c)
+						}
+
+						printIfErr(admin.ShowLaunchLog(ID))
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "ledger",
+			Usage: "Manage the virtual balances of paper trading",
+			Subcommands: cli.Commands{
+				{
+					Name:  "deposit",
+					Usage: "Credit an address with an amount of a token, in token units",
+					Description: `
+    Example: credit 1000 DAI
+
+    hydro-dex-ctl ledger deposit 0x126aa4ef50a6e546aa5ecd1eb83c060fb780891a 0xbc3524faa62d0763818636d5e400f112279d6cc0 1000`,
+					Action: func(c *cli.Context) error {
+						if c.NArg() != 3 {
+							return cli.ShowSubcommandHelp(c)
+						}
+
+						printIfErr(admin.Deposit(c.Args().Get(0), c.Args().Get(1), c.Args().Get(2)))
+						return nil
+					},
+				},
+				{
+					Name:  "balances",
+					Usage: "Show the ledger balances of an address",
+					Description: `
+    Example:
+
+    hydro-dex-ctl ledger balances 0x126aa4ef50a6e546aa5ecd1eb83c060fb780891a`,
+					Action: func(c *cli.Context) error {
+						address := c.Args().Get(0)
+						if len(address) == 0 {
+							return cli.ShowSubcommandHelp(c)
+						}
+
+						printIfErr(admin.ListLedgerBalances(address))
+						return nil
+					},
+				},
+			},
+		},
+		{
+			Name:  "pnl",
+			Usage: "Show the fees the relayer collected against the gas it paid, per market and day",
+			Description: `
+    Example: the results of all markets in the last 30 days
+
+    hydro-dex-ctl pnl
+
+    Example: export the results of market 'HOT-WETH' in January as CSV
+
+    hydro-dex-ctl pnl --from 2019-01-01 --to 2019-01-31 --format csv HOT-WETH > pnl.csv`,
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:        "from",
+					Usage:       "First day, like 2019-01-01, 29 days before the last day by default",
+					Destination: &from,
+				},
+				cli.StringFlag{
+					Name:        "to",
+					Usage:       "Last day, like 2019-01-31, today by default",
+					Destination: &to,
+				},
+				cli.StringFlag{
+					Name:        "format",
+					Usage:       "csv: the daily results as CSV",
+					Destination: &format,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				printIfErr(admin.RelayerPnL(c.Args().Get(0), from, to, format))
+				return nil
+			},
+		},
 		{
 			Name:  "status",
 			Usage: "Get current status of the ",
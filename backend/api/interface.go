@@ -2,6 +2,7 @@ package api
 
 import (
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/orderbook_feed"
 	"github.com/shopspring/decimal"
 )
 
@@ -35,6 +36,19 @@ type (
 		Data OrderBook
 	}
 
+	OrderBookDeltasReq struct {
+		BaseReq
+		MarketID string `json:"marketID" param:"marketID" validate:"required"`
+		Since    uint64 `json:"since"    query:"since"`
+	}
+
+	OrderBookDeltasResp struct {
+		// Resnapshot is true if the deltas after the given sequence are no longer kept.
+		// The client should fetch a new snapshot then.
+		Resnapshot bool                    `json:"resnapshot"`
+		Deltas     []*orderbook_feed.Delta `json:"deltas"`
+	}
+
 	CandlesReq struct {
 		BaseReq
 		MarketID    string `json:"marketID"    param:"marketID"    validate:"required"`
@@ -78,10 +92,14 @@ type (
 		Price     string `json:"price"     validate:"required"`
 		Amount    string `json:"amount"    validate:"required"`
 		Expires   int64  `json:"expires"`
+
+		// ClientOrderID is an optional id chosen by the trader, it is unique per address.
+		ClientOrderID string `json:"clientOrderID" validate:"max=64"`
 	}
 
 	BuildOrderResp struct {
 		ID              string            `json:"id"`
+		ClientOrderID   string            `json:"clientOrderID,omitempty"`
 		MarketID        string            `json:"marketID"`
 		Side            string            `json:"side"`
 		Type            string            `json:"type"`
@@ -105,6 +123,11 @@ type (
 		ID string `json:"id" param:"orderID" validate:"required,len=66"`
 	}
 
+	ClientOrderReq struct {
+		BaseReq
+		ClientOrderID string `json:"clientOrderID" param:"clientOrderID" validate:"required,max=64"`
+	}
+
 	CacheOrder struct {
 		OrderResponse         BuildOrderResp  `json:"orderResponse"`
 		Address               string          `json:"address"`
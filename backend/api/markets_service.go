@@ -3,6 +3,7 @@ package api
 import (
 	"encoding/json"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/orderbook_feed"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	"github.com/shopspring/decimal"
@@ -43,6 +44,26 @@ func GetOrderBook(p Param) (interface{}, error) {
 	}, nil
 }
 
+// GetOrderBookDeltas replays the order book deltas after a sequence,
+// clients use it to fill the gap when they find a missing sequence in the websocket messages.
+func GetOrderBookDeltas(p Param) (interface{}, error) {
+	params := p.(*OrderBookDeltasReq)
+
+	deltas, ok, err := OrderbookFeed.Since(params.MarketID, params.Since)
+	if err != nil {
+		return nil, err
+	}
+
+	if deltas == nil {
+		deltas = []*orderbook_feed.Delta{}
+	}
+
+	return &OrderBookDeltasResp{
+		Resnapshot: !ok,
+		Deltas:     deltas,
+	}, nil
+}
+
 func GetMarkets(_ Param) (interface{}, error) {
 	var markets []Market
 	dbMarkets := models.MarketDao.FindPublishedMarkets()
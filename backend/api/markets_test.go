@@ -8,6 +8,7 @@ import (
 	"time"
 
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/orderbook_feed"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
@@ -62,6 +63,43 @@ func TestGetOrderBook(t *testing.T) {
 	reflect.DeepEqual(snapshot, snapshot2)
 }
 
+type MOrderbookFeed struct {
+	mock.Mock
+}
+
+func (m *MOrderbookFeed) Reset(marketID string, sequence uint64) error {
+	return m.Called(marketID, sequence).Error(0)
+}
+
+func (m *MOrderbookFeed) Append(marketID string, delta *orderbook_feed.Delta) error {
+	return m.Called(marketID, delta).Error(0)
+}
+
+func (m *MOrderbookFeed) Since(marketID string, sequence uint64) ([]*orderbook_feed.Delta, bool, error) {
+	args := m.Called(marketID, sequence)
+	return args.Get(0).([]*orderbook_feed.Delta), args.Bool(1), args.Error(2)
+}
+
+func TestGetOrderBookDeltasAPI(t *testing.T) {
+	feed := &MOrderbookFeed{}
+	OrderbookFeed = feed
+
+	feed.On("Since", "HOT-DAI", uint64(10)).Return([]*orderbook_feed.Delta{{Sequence: 11, Side: "buy", Price: "1.2", Amount: "1"}}, true, nil)
+	feed.On("Since", "HOT-DAI", uint64(2)).Return([]*orderbook_feed.Delta(nil), false, nil)
+
+	resp := request("/markets/HOT-DAI/orderbook/deltas?since=10", "GET", "", nil)
+	assert.EqualValues(t, 0, resp.Status)
+	data := resp.Data.(map[string]interface{})
+	assert.EqualValues(t, false, data["resnapshot"])
+	assert.EqualValues(t, 1, len(data["deltas"].([]interface{})))
+	assert.EqualValues(t, 11, data["deltas"].([]interface{})[0].(map[string]interface{})["sequence"])
+
+	resp = request("/markets/HOT-DAI/orderbook/deltas?since=2", "GET", "", nil)
+	data = resp.Data.(map[string]interface{})
+	assert.EqualValues(t, true, data["resnapshot"])
+	assert.EqualValues(t, 0, len(data["deltas"].([]interface{})))
+}
+
 type MCache struct {
 	mock.Mock
 }
@@ -8,6 +8,7 @@ import (
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	uuid "github.com/satori/go.uuid"
 	"github.com/shopspring/decimal"
 	"math/rand"
 	"os"
@@ -43,6 +44,16 @@ func GetSingleOrder(p Param) (interface{}, error) {
 	}, nil
 }
 
+func GetSingleOrderByClientOrderID(p Param) (interface{}, error) {
+	req := p.(*ClientOrderReq)
+
+	order := models.OrderDao.FindByClientOrderID(req.Address, req.ClientOrderID)
+
+	return &QuerySingleOrderResp{
+		Order: order,
+	}, nil
+}
+
 func GetOrders(p Param) (interface{}, error) {
 	req := p.(*QueryOrderReq)
 	if req.Status == "" {
@@ -73,21 +84,54 @@ func CancelOrder(p Param) (interface{}, error) {
 		return nil, NewApiError(-1, fmt.Sprintf("order %s not exist", req.ID))
 	}
 
+	return nil, pushCancelOrderEvent(order)
+}
+
+func CancelOrderByClientOrderID(p Param) (interface{}, error) {
+	req := p.(*ClientOrderReq)
+	order := models.OrderDao.FindByClientOrderID(req.Address, req.ClientOrderID)
+	if order == nil {
+		return nil, NewApiError(-1, fmt.Sprintf("client order %s not exist", req.ClientOrderID))
+	}
+
+	return nil, pushCancelOrderEvent(order)
+}
+
+// Events pushed to the engine carry a unique eventID,
+// the engine drops an event it has already processed in case the push is retried.
+type newOrderEvent struct {
+	common.NewOrderEvent
+	EventID string `json:"eventID"`
+}
+
+type cancelOrderEvent struct {
+	common.CancelOrderEvent
+	EventID string `json:"eventID"`
+}
+
+func newEventID() string {
+	return uuid.NewV4().String()
+}
+
+func pushCancelOrderEvent(order *models.Order) error {
 	if order.Status != common.ORDER_PENDING {
-		return nil, nil
+		return nil
 	}
 
-	cancelOrderEvent := common.CancelOrderEvent{
-		Event: common.Event{
-			Type:     common.EventCancelOrder,
-			MarketID: order.MarketID,
+	event := cancelOrderEvent{
+		CancelOrderEvent: common.CancelOrderEvent{
+			Event: common.Event{
+				Type:     common.EventCancelOrder,
+				MarketID: order.MarketID,
+			},
+			Price: order.Price.String(),
+			Side:  order.Side,
+			ID:    order.ID,
 		},
-		Price: order.Price.String(),
-		Side:  order.Side,
-		ID:    order.ID,
+		EventID: newEventID(),
 	}
 
-	return nil, QueueService.Push([]byte(utils.ToJsonString(cancelOrderEvent)))
+	return QueueService.Push([]byte(utils.ToJsonString(event)))
 }
 
 func BuildOrder(p Param) (interface{}, error) {
@@ -99,6 +143,10 @@ func BuildOrder(p Param) (interface{}, error) {
 		return nil, err
 	}
 
+	if req.ClientOrderID != "" && models.OrderDao.FindByClientOrderID(req.Address, req.ClientOrderID) != nil {
+		return nil, NewApiError(-1, "duplicated_client_order_id")
+	}
+
 	buildOrderResponse, err := BuildAndCacheOrder(req.Address, req)
 	if err != nil {
 		return nil, err
@@ -126,6 +174,7 @@ func PlaceOrder(p Param) (interface{}, error) {
 
 	ret := models.Order{
 		ID:              order.ID,
+		ClientOrderID:   cacheOrder.OrderResponse.ClientOrderID,
 		TraderAddress:   order.Address,
 		MarketID:        cacheOrder.OrderResponse.MarketID,
 		Side:            cacheOrder.OrderResponse.Side,
@@ -146,15 +195,18 @@ func PlaceOrder(p Param) (interface{}, error) {
 		CreatedAt:       time.Now().UTC(),
 	}
 
-	newOrderEvent, _ := json.Marshal(common.NewOrderEvent{
-		Event: common.Event{
-			MarketID: cacheOrder.OrderResponse.MarketID,
-			Type:     common.EventNewOrder,
+	event, _ := json.Marshal(newOrderEvent{
+		NewOrderEvent: common.NewOrderEvent{
+			Event: common.Event{
+				MarketID: cacheOrder.OrderResponse.MarketID,
+				Type:     common.EventNewOrder,
+			},
+			Order: utils.ToJsonString(ret),
 		},
-		Order: utils.ToJsonString(ret),
+		EventID: newEventID(),
 	})
 
-	err := QueueService.Push(newOrderEvent)
+	err := QueueService.Push(event)
 
 	if err != nil {
 		return nil, errors.New("place order failed, place try again")
@@ -319,6 +371,7 @@ func BuildAndCacheOrder(address string, order *BuildOrderReq) (*BuildOrderResp,
 	orderHash := hydro.GetOrderHash(sdkOrder)
 	orderResponse := BuildOrderResp{
 		ID:              utils.Bytes2HexP(orderHash),
+		ClientOrderID:   order.ClientOrderID,
 		Json:            &orderJson,
 		Side:            order.Side,
 		Type:            order.OrderType,
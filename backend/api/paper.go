@@ -0,0 +1,32 @@
+package api
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/shopspring/decimal"
+)
+
+// ledgerHydro reads the balances of traders from the ledger in paper trading mode, instead of the chain.
+// The amounts are in the smallest token unit, like those on the chain.
+type ledgerHydro struct {
+	sdk.Hydro
+}
+
+func (ledgerHydro) GetTokenBalance(tokenAddress, address string) decimal.Decimal {
+	token := models.TokenDao.FindTokenByAddress(tokenAddress)
+	if token == nil {
+		return decimal.Zero
+	}
+
+	return models.LedgerDao.GetBalance(address, tokenAddress).Mul(decimal.New(1, int32(token.Decimals)))
+}
+
+// GetTokenAllowance returns the balance, there is nothing to approve on the ledger.
+func (h ledgerHydro) GetTokenAllowance(tokenAddress, proxyAddress, address string) decimal.Decimal {
+	return h.GetTokenBalance(tokenAddress, address)
+}
+
+// GetHotFeeDiscount returns no discount, HOT held on the chain doesn't count.
+func (ledgerHydro) GetHotFeeDiscount(address string) decimal.Decimal {
+	return decimal.New(1, 0)
+}
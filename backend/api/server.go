@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/connection"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/orderbook_feed"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
 	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
@@ -20,6 +21,7 @@ import (
 
 var CacheService common.IKVStore
 var QueueService common.IQueue
+var OrderbookFeed orderbook_feed.IOrderbookFeed
 
 func loadRoutes(e *echo.Echo) {
 	e.Use(initHydroApiContext)
@@ -30,6 +32,7 @@ func loadRoutes(e *echo.Echo) {
 
 	addRoute(e, "GET", "/markets", nil, GetMarkets)
 	addRoute(e, "GET", "/markets/:marketID/orderbook", &OrderBookReq{}, GetOrderBook)
+	addRoute(e, "GET", "/markets/:marketID/orderbook/deltas", &OrderBookDeltasReq{}, GetOrderBookDeltas)
 	addRoute(e, "GET", "/markets/:marketID/trades", &QueryTradeReq{}, GetAllTrades)
 
 	addRoute(e, "GET", "/markets/:marketID/trades/mine", &QueryTradeReq{}, GetAccountTrades, authMiddleware)
@@ -41,6 +44,8 @@ func loadRoutes(e *echo.Echo) {
 	addRoute(e, "POST", "/orders/build", &BuildOrderReq{}, BuildOrder, authMiddleware)
 	addRoute(e, "POST", "/orders", &PlaceOrderReq{}, PlaceOrder, authMiddleware)
 	addRoute(e, "DELETE", "/orders/:orderID", &CancelOrderReq{}, CancelOrder, authMiddleware)
+	addRoute(e, "GET", "/orders/client/:clientOrderID", &ClientOrderReq{}, GetSingleOrderByClientOrderID, authMiddleware)
+	addRoute(e, "DELETE", "/orders/client/:clientOrderID", &ClientOrderReq{}, CancelOrderByClientOrderID, authMiddleware)
 	addRoute(e, "GET", "/account/lockedBalances", &LockedBalanceReq{}, GetLockedBalance, authMiddleware)
 }
 
@@ -134,6 +139,9 @@ func StartServer(ctx context.Context, startMetric func()) {
 
 	// init blockchain
 	hydro = ethereum.NewEthereumHydro(os.Getenv("HSK_BLOCKCHAIN_RPC_URL"), os.Getenv("HSK_HYBRID_EXCHANGE_ADDRESS"))
+	if models.IsPaperTrading() {
+		hydro = ledgerHydro{hydro}
+	}
 
 	//init database
 	models.Connect(os.Getenv("HSK_DATABASE_URL"))
@@ -153,6 +161,8 @@ func StartServer(ctx context.Context, startMetric func()) {
 		},
 	)
 
+	OrderbookFeed = orderbook_feed.NewRedisOrderbookFeed(redisClient, orderbook_feed.DefaultMaxDeltas)
+
 	e := getEchoServer()
 
 	s := &http.Server{
@@ -0,0 +1,292 @@
+package balance_monitor
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_engine"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/HydroProtocol/nights-watch/structs"
+	"github.com/shopspring/decimal"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Balances and allowances are checked when an order is built, but the trader can move the tokens out
+// or revoke the allowance of the proxy afterwards. Every match against such an order fails on chain.
+// The Monitor follows the Transfer and Approval logs of listed tokens. When one of them touches a trader with open orders,
+// the balance and allowance of the trader are checked against the amount locked by the orders again,
+// and the engine is told to shrink or cancel the newest orders until the rest is funded.
+
+const (
+	transferTopic = "0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"
+	approvalTopic = "0x8c5be1e5ebec7d5bd14f71427d1e84f3dd0314c0f7b2291e5b200ac8c7c3b925"
+
+	// listed tokens are loaded again after this long, to pick up new markets
+	marketsRefreshInterval = time.Minute
+)
+
+type token struct {
+	address  string
+	symbol   string
+	decimals int
+}
+
+type Monitor struct {
+	eventQueue      common.IQueue
+	erc20           ethereum.IErc20
+	proxyAddress    string
+	exchangeAddress string
+
+	lock            sync.Mutex
+	markets         map[string]*models.Market
+	tokens          map[string]*token
+	marketsLoadedAt time.Time
+}
+
+func NewMonitor(eventQueue common.IQueue, erc20 ethereum.IErc20, proxyAddress, exchangeAddress string) *Monitor {
+	return &Monitor{
+		eventQueue:      eventQueue,
+		erc20:           erc20,
+		proxyAddress:    proxyAddress,
+		exchangeAddress: exchangeAddress,
+	}
+}
+
+// listedTokens returns the tokens of the published markets by their lower cased addresses, and the markets by ID.
+func (m *Monitor) listedTokens() (map[string]*token, map[string]*models.Market) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	if m.tokens != nil && time.Since(m.marketsLoadedAt) < marketsRefreshInterval {
+		return m.tokens, m.markets
+	}
+
+	m.tokens = make(map[string]*token)
+	m.markets = make(map[string]*models.Market)
+
+	for _, market := range models.MarketDao.FindPublishedMarkets() {
+		m.markets[market.ID] = market
+		m.tokens[strings.ToLower(market.BaseTokenAddress)] = &token{market.BaseTokenAddress, market.BaseTokenSymbol, market.BaseTokenDecimals}
+		m.tokens[strings.ToLower(market.QuoteTokenAddress)] = &token{market.QuoteTokenAddress, market.QuoteTokenSymbol, market.QuoteTokenDecimals}
+	}
+
+	m.marketsLoadedAt = time.Now()
+
+	return m.tokens, m.markets
+}
+
+// TxHandlerFunc takes the receipt of every transaction, tokens can be moved by any contract.
+func (m *Monitor) TxHandlerFunc(txAndReceipt *structs.RemovableTxAndReceipt) {
+	if txAndReceipt.IsRemoved || !txAndReceipt.Receipt.GetResult() {
+		return
+	}
+
+	// settlements move the tokens their orders locked, they are released when the engine confirms the settlement
+	if strings.EqualFold(txAndReceipt.Tx.GetTo(), m.exchangeAddress) {
+		return
+	}
+
+	tokens, markets := m.listedTokens()
+
+	// a trader is checked once per token, however many logs touch them
+	type holding struct {
+		token  *token
+		trader string
+	}
+
+	checked := make(map[holding]bool)
+
+	for _, log := range txAndReceipt.Receipt.GetLogs() {
+		t := tokens[strings.ToLower(log.GetAddress())]
+		if t == nil {
+			continue
+		}
+
+		trader := spender(log, m.proxyAddress)
+		if trader == "" {
+			continue
+		}
+
+		h := holding{t, trader}
+		if checked[h] {
+			continue
+		}
+
+		checked[h] = true
+		m.check(t, trader, markets)
+	}
+}
+
+// spender returns the address whose funds for the proxy may have decreased by a log:
+// the sender of a Transfer, or the owner of an Approval to the proxy.
+func spender(log sdk.IReceiptLog, proxyAddress string) string {
+	topics := log.GetTopics()
+	if len(topics) != 3 {
+		return ""
+	}
+
+	switch strings.ToLower(topics[0]) {
+	case transferTopic:
+		return topicAddress(topics[1])
+	case approvalTopic:
+		if strings.EqualFold(topicAddress(topics[2]), proxyAddress) {
+			return topicAddress(topics[1])
+		}
+	}
+
+	return ""
+}
+
+func topicAddress(topic string) string {
+	bts := utils.Hex2Bytes(topic)
+	if len(bts) < 20 {
+		return ""
+	}
+
+	return utils.Bytes2HexP(bts[len(bts)-20:])
+}
+
+// check compares what the trader can spend of the token with the amount locked by their orders.
+func (m *Monitor) check(t *token, trader string, markets map[string]*models.Market) {
+	var orders []*models.Order
+	for _, order := range models.OrderDao.FindPendingByTrader(trader) {
+		if market := markets[order.MarketID]; market != nil && spends(order, market, t) {
+			orders = append(orders, order)
+		}
+	}
+
+	if len(orders) == 0 {
+		return
+	}
+
+	err, balance := m.erc20.BalanceOf(t.address, trader)
+	if err != nil {
+		utils.Errorf("get %s balance of %s error: %v", t.symbol, trader, err)
+		return
+	}
+
+	err, allowance := m.erc20.AllowanceOf(t.address, m.proxyAddress, trader)
+	if err != nil {
+		utils.Errorf("get %s allowance of %s error: %v", t.symbol, trader, err)
+		return
+	}
+
+	funds := decimal.NewFromBigInt(balance, 0)
+	if allowanceAmount := decimal.NewFromBigInt(allowance, 0); allowanceAmount.LessThan(funds) {
+		funds = allowanceAmount
+	}
+
+	// the tokens of mined trades have left the trader already, but their amounts are pending until the trades are final
+	locked := models.BalanceDao.GetByAccountAndSymbol(trader, t.symbol, t.decimals)
+	locked = locked.Sub(minedLocked(orders, markets, models.TradeDao.FindTradesByOrderIDs(orderIDs(orders))))
+	if locked.LessThanOrEqual(funds) {
+		return
+	}
+
+	utils.Infof("%s of %s locks %s, but only %s can be spent", t.symbol, trader, locked, funds)
+
+	for _, cut := range allocateShortfall(orders, markets, locked.Sub(funds)) {
+		event := &dex_engine.OrderUnfundedEvent{
+			Event: common.Event{
+				Type:     dex_engine.EventOrderUnfunded,
+				MarketID: cut.order.MarketID,
+			},
+			ID:     cut.order.ID,
+			Amount: cut.amount,
+		}
+
+		if err := m.eventQueue.Push([]byte(utils.ToJsonString(event))); err != nil {
+			utils.Errorf("Push event into Queue Error: %v", err)
+		}
+	}
+}
+
+// spends tells if an order locks the token: a sell order locks the base token, a buy order the quote token.
+func spends(order *models.Order, market *models.Market, t *token) bool {
+	if order.Side == "sell" {
+		return strings.EqualFold(market.BaseTokenAddress, t.address)
+	}
+
+	return strings.EqualFold(market.QuoteTokenAddress, t.address)
+}
+
+// lockedPerAmount is the amount of the token in its smallest unit which one unit of the order amount locks,
+// the same way models.BalanceDao counts it.
+func lockedPerAmount(order *models.Order, market *models.Market) decimal.Decimal {
+	if order.Side == "sell" {
+		return decimal.New(1, int32(market.BaseTokenDecimals))
+	}
+
+	return order.Price.Mul(decimal.New(1, int32(market.QuoteTokenDecimals)))
+}
+
+func orderIDs(orders []*models.Order) []string {
+	ids := make([]string, 0, len(orders))
+	for _, order := range orders {
+		ids = append(ids, order.ID)
+	}
+
+	return ids
+}
+
+// minedLocked is the amount of the token which the orders lock for trades mined but not final yet.
+// The settlement moved the tokens already, the balance of the trader doesn't have them.
+func minedLocked(orders []*models.Order, markets map[string]*models.Market, trades []*models.Trade) decimal.Decimal {
+	byID := make(map[string]*models.Order)
+	for _, order := range orders {
+		byID[order.ID] = order
+	}
+
+	locked := decimal.Zero
+	for _, trade := range trades {
+		if trade.Status != models.TradeStatusMined {
+			continue
+		}
+
+		for _, id := range []string{trade.TakerOrderID, trade.MakerOrderID} {
+			if order := byID[id]; order != nil {
+				locked = locked.Add(trade.Amount.Mul(lockedPerAmount(order, markets[order.MarketID])))
+			}
+		}
+	}
+
+	return locked
+}
+
+type cut struct {
+	order  *models.Order
+	amount decimal.Decimal
+}
+
+// allocateShortfall takes the shortfall out of the available amounts of the orders, in the given order.
+// Amounts waiting for settlement can not be taken back, those settlements fail if they are not funded.
+// What is left of an order must still be a valid order, otherwise the order is cut completely.
+func allocateShortfall(orders []*models.Order, markets map[string]*models.Market, shortfall decimal.Decimal) (cuts []cut) {
+	for _, order := range orders {
+		if shortfall.LessThanOrEqual(decimal.Zero) {
+			break
+		}
+
+		if order.AvailableAmount.LessThanOrEqual(decimal.Zero) {
+			continue
+		}
+
+		market := markets[order.MarketID]
+		perAmount := lockedPerAmount(order, market)
+
+		// rounded up to the amount unit of the market
+		amount := shortfall.Div(perAmount).Shift(int32(market.AmountDecimals)).Ceil().Shift(int32(-market.AmountDecimals))
+
+		if rest := order.AvailableAmount.Sub(amount); rest.LessThanOrEqual(decimal.Zero) || rest.Mul(order.Price).LessThan(market.MinOrderSize) {
+			amount = order.AvailableAmount
+		}
+
+		cuts = append(cuts, cut{order, amount})
+		shortfall = shortfall.Sub(amount.Mul(perAmount))
+	}
+
+	return cuts
+}
@@ -0,0 +1,106 @@
+package balance_monitor
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/onrik/ethrpc"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const (
+	proxy  = "0x04f67e8b7c39a25e100847cb167460d715215feb"
+	trader = "0x31ebd457b999bf99759602f5ece5aa5033cb56b3"
+	other  = "0x3eb06f432ae8f518a957852aa44776c234b4a84a"
+)
+
+func addressTopic(address string) string {
+	return "0x000000000000000000000000" + address[2:]
+}
+
+func TestTopics(t *testing.T) {
+	assert.EqualValues(t, transferTopic, utils.Bytes2HexP(crypto.Keccak256([]byte("Transfer(address,address,uint256)"))))
+	assert.EqualValues(t, approvalTopic, utils.Bytes2HexP(crypto.Keccak256([]byte("Approval(address,address,uint256)"))))
+}
+
+func TestSpender(t *testing.T) {
+	log := func(topics ...string) ethereum.ReceiptLog {
+		return ethereum.ReceiptLog{Log: &ethrpc.Log{Topics: topics}}
+	}
+
+	assert.EqualValues(t, trader, spender(log(transferTopic, addressTopic(trader), addressTopic(other)), proxy))
+	assert.EqualValues(t, trader, spender(log(approvalTopic, addressTopic(trader), addressTopic(proxy)), proxy))
+	assert.EqualValues(t, "", spender(log(approvalTopic, addressTopic(trader), addressTopic(other)), proxy))
+	assert.EqualValues(t, "", spender(log(transferTopic), proxy))
+}
+
+func TestAllocateShortfall(t *testing.T) {
+	markets := map[string]*models.Market{
+		"HOT-DAI": {
+			ID:                 "HOT-DAI",
+			BaseTokenDecimals:  18,
+			QuoteTokenDecimals: 18,
+			AmountDecimals:     2,
+			MinOrderSize:       decimal.RequireFromString("1"),
+		},
+	}
+
+	newOrder := func(id, side, price, available string) *models.Order {
+		return &models.Order{
+			ID:              id,
+			MarketID:        "HOT-DAI",
+			Side:            side,
+			Price:           decimal.RequireFromString(price),
+			AvailableAmount: decimal.RequireFromString(available),
+		}
+	}
+
+	// a part of the newest order is cut
+	orders := []*models.Order{newOrder("1", "sell", "1", "10"), newOrder("2", "sell", "1", "10")}
+	cuts := allocateShortfall(orders, markets, decimal.RequireFromString("2.501").Shift(18))
+	assert.Len(t, cuts, 1)
+	assert.EqualValues(t, "1", cuts[0].order.ID)
+	assert.EqualValues(t, "2.51", cuts[0].amount.String())
+
+	// the shortfall is larger than the newest order
+	cuts = allocateShortfall(orders, markets, decimal.RequireFromString("12").Shift(18))
+	assert.Len(t, cuts, 2)
+	assert.EqualValues(t, "10", cuts[0].amount.String())
+	assert.EqualValues(t, "2", cuts[1].amount.String())
+
+	// the rest of the order would be smaller than the min order size
+	cuts = allocateShortfall(orders, markets, decimal.RequireFromString("9.5").Shift(18))
+	assert.Len(t, cuts, 1)
+	assert.EqualValues(t, "10", cuts[0].amount.String())
+
+	// a buy order locks price times amount of the quote token
+	orders = []*models.Order{newOrder("3", "buy", "2", "10")}
+	cuts = allocateShortfall(orders, markets, decimal.RequireFromString("4").Shift(18))
+	assert.Len(t, cuts, 1)
+	assert.EqualValues(t, "2", cuts[0].amount.String())
+}
+
+func TestMinedLocked(t *testing.T) {
+	markets := map[string]*models.Market{
+		"HOT-DAI": {ID: "HOT-DAI", BaseTokenDecimals: 18, QuoteTokenDecimals: 18},
+	}
+
+	orders := []*models.Order{
+		{ID: "sell", MarketID: "HOT-DAI", Side: "sell", Price: decimal.RequireFromString("2")},
+		{ID: "buy", MarketID: "HOT-DAI", Side: "buy", Price: decimal.RequireFromString("2")},
+	}
+
+	trades := []*models.Trade{
+		{TakerOrderID: "sell", MakerOrderID: "other", Amount: decimal.RequireFromString("3"), Status: models.TradeStatusMined},
+		{TakerOrderID: "other", MakerOrderID: "buy", Amount: decimal.RequireFromString("1"), Status: models.TradeStatusMined},
+		{TakerOrderID: "sell", MakerOrderID: "other", Amount: decimal.RequireFromString("5"), Status: "pending"},
+		{TakerOrderID: "sell", MakerOrderID: "other", Amount: decimal.RequireFromString("7"), Status: "successful"},
+	}
+
+	// 3 of the sell order, and 1 of the buy order at the price of 2
+	assert.EqualValues(t, decimal.RequireFromString("5").Shift(18).String(), minedLocked(orders, markets, trades).String())
+	assert.True(t, minedLocked(orders, markets, nil).IsZero())
+}
@@ -12,6 +12,7 @@ func run() int {
 	ctx, stop := context.WithCancel(context.Background())
 
 	go cli.WaitExitSignal(stop)
+	cli.StartBlockchain(ctx)
 	adminapi.StartServer(ctx)
 
 	return 0
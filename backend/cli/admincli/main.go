@@ -1,13 +1,17 @@
 package main
 
 import (
+	"context"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/admin/cli"
+	dexcli "github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	_ "github.com/joho/godotenv/autoload"
 	"os"
 )
 
 func main() {
+	dexcli.StartBlockchain(context.Background())
+
 	cli := admincli.NewDexCli()
 	err := cli.Run(os.Args)
 	if err != nil {
@@ -4,7 +4,7 @@ import (
 	"context"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/api"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
-	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
 	_ "github.com/joho/godotenv/autoload"
 	"os"
 )
@@ -13,7 +13,8 @@ func run() int {
 	ctx, stop := context.WithCancel(context.Background())
 
 	go cli.WaitExitSignal(stop)
-	api.StartServer(ctx, utils.StartMetrics)
+	cli.StartBlockchain(ctx)
+	api.StartServer(ctx, metrics.StartMetrics)
 
 	return 0
 }
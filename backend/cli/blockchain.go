@@ -0,0 +1,19 @@
+package cli
+
+import (
+	"context"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/simchain"
+	"os"
+)
+
+// StartBlockchain serves the simulated chain at HSK_BLOCKCHAIN_RPC_URL if HSK_BLOCKCHAIN is simulated,
+// unless another binary serves it already. The binaries reach the chain through the rpc url either way.
+func StartBlockchain(ctx context.Context) {
+	if !simchain.IsEnabled() {
+		return
+	}
+
+	if _, err := simchain.Serve(ctx, os.Getenv("HSK_BLOCKCHAIN_RPC_URL")); err != nil {
+		panic(err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/connection"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/doctor"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	_ "github.com/joho/godotenv/autoload"
+	"github.com/urfave/cli"
+	"os"
+)
+
+const (
+	emitNone   = ""
+	emitSQL    = "sql"
+	emitEvents = "events"
+)
+
+func main() {
+	app := cli.NewApp()
+	app.Name = "doctor"
+	app.Usage = "Check the invariants of orders, trades, transactions and launch logs"
+	app.Version = "0.0.1"
+
+	var emit string
+	var apply bool
+	var batchSize int
+
+	app.Flags = []cli.Flag{
+		cli.StringFlag{
+			Name:        "emit",
+			Usage:       "Print repairs of the violations, sql or events",
+			Destination: &emit,
+		},
+		cli.BoolFlag{
+			Name:        "apply",
+			Usage:       "Execute the repair sql or push the repair events, instead of printing them only",
+			Destination: &apply,
+		},
+		cli.IntFlag{
+			Name:        "batchSize",
+			Value:       doctor.DefaultBatchSize,
+			Usage:       "Rows loaded from database at a time",
+			Destination: &batchSize,
+		},
+	}
+
+	app.Action = func(c *cli.Context) error {
+		return run(emit, apply, batchSize)
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		utils.Errorf(err.Error())
+		os.Exit(1)
+	}
+}
+
+func run(emit string, apply bool, batchSize int) error {
+	if emit != emitNone && emit != emitSQL && emit != emitEvents {
+		return fmt.Errorf("unknown emit %s, should be sql or events", emit)
+	}
+
+	if apply && emit == emitNone {
+		return fmt.Errorf("--apply needs --emit sql or --emit events")
+	}
+
+	models.Connect(os.Getenv("HSK_DATABASE_URL"))
+
+	var queue common.IQueue
+	if apply && emit == emitEvents {
+		var err error
+		queue, err = common.InitQueue(&common.RedisQueueConfig{
+			Name:   common.HYDRO_ENGINE_EVENTS_QUEUE_KEY,
+			Client: connection.NewRedisClient(os.Getenv("HSK_REDIS_URL")),
+			Ctx:    context.Background(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	var violations, repairs, failures int
+
+	doctor.Scan(batchSize, func(v *doctor.Violation) {
+		violations++
+		fmt.Println(v.String())
+
+		var err error
+
+		switch {
+		case emit == emitSQL && v.RepairSQL != "":
+			repairs++
+			fmt.Printf("  repair: %s\n", v.RepairSQL)
+			if apply {
+				err = models.DB.Exec(v.RepairSQL).Error
+			}
+		case emit == emitEvents && v.RepairEvent != nil:
+			repairs++
+			bts, _ := json.Marshal(v.RepairEvent)
+			fmt.Printf("  repair: %s\n", bts)
+			if apply {
+				err = queue.Push(bts)
+			}
+		}
+
+		if err != nil {
+			failures++
+			fmt.Printf("  repair failed: %v\n", err)
+		}
+	})
+
+	if apply {
+		fmt.Printf("%d violations, %d repairs applied, %d failed\n", violations, repairs-failures, failures)
+	} else {
+		fmt.Printf("%d violations, %d repairs (dry run)\n", violations, repairs)
+	}
+
+	return nil
+}
@@ -8,15 +8,16 @@ import (
 	"context"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_engine"
-	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
 	"os"
 )
 
 func run() int {
 	ctx, stop := context.WithCancel(context.Background())
 	go cli.WaitExitSignal(stop)
+	cli.StartBlockchain(ctx)
 
-	dex_engine.Run(ctx, utils.StartMetrics)
+	dex_engine.Run(ctx, metrics.StartMetrics)
 	return 0
 }
 
@@ -3,19 +3,25 @@ package main
 import (
 	"context"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/connection"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_launcher"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/HydroProtocol/hydro-sdk-backend/launcher"
 	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	_ "github.com/joho/godotenv/autoload"
 	"github.com/shopspring/decimal"
 	"os"
-	"time"
+	"strings"
 )
 
 func run() int {
 	ctx, stop := context.WithCancel(context.Background())
 	go cli.WaitExitSignal(stop)
+	cli.StartBlockchain(ctx)
 
 	models.Connect(os.Getenv("HSK_DATABASE_URL"))
 
@@ -25,93 +31,50 @@ func run() int {
 		hydro.EnableDebug(true)
 	}
 
-	signService := launcher.NewDefaultSignService(os.Getenv("HSK_RELAYER_PK"), hydro.GetTransactionCount)
-
-	fallbackGasPrice := decimal.New(3, 9) // 3Gwei
-	priceDecider := launcher.NewGasStationGasPriceDecider(fallbackGasPrice)
-
-	launcher := launcher.NewLauncher(ctx, signService, hydro, priceDecider)
-
-	Run(launcher, utils.StartMetrics)
-
-	return 0
-}
-
-const pollingIntervalSeconds = 5
-
-func Run(l *launcher.Launcher, startMetrics func()) {
-	utils.Infof("launcher start!")
-	defer utils.Infof("launcher stop!")
-	go startMetrics()
-
-	for {
-		launchLogs := models.LaunchLogDao.FindAllCreated()
-
-		if len(launchLogs) == 0 {
-			select {
-			case <-l.Ctx.Done():
-				utils.Infof("main loop Exit")
-				return
-			default:
-				utils.Infof("no logs need to be sent. sleep %ds", pollingIntervalSeconds)
-
-				time.Sleep(pollingIntervalSeconds * time.Second)
-				continue
-			}
+	// the relayer account, and its delegates which share the settlement.
+	// Plain private keys are for development only, use a keystore or remote signer in production.
+	privateKeys := []string{os.Getenv("HSK_RELAYER_PK")}
+	for _, pk := range strings.Split(os.Getenv("HSK_RELAYER_POOL_PKS"), ",") {
+		if pk = strings.TrimSpace(pk); pk != "" {
+			privateKeys = append(privateKeys, pk)
 		}
+	}
 
-		for _, modelLaunchLog := range launchLogs {
-			modelLaunchLog.GasPrice = decimal.NullDecimal{
-				Decimal: l.GasPriceDecider.GasPriceInWei(),
-				Valid:   true,
-			}
-
-			log := launcher.LaunchLog{
-				ID:          modelLaunchLog.ID,
-				ItemType:    modelLaunchLog.ItemType,
-				ItemID:      modelLaunchLog.ItemID,
-				Status:      modelLaunchLog.Status,
-				Hash:        modelLaunchLog.Hash,
-				BlockNumber: modelLaunchLog.BlockNumber,
-				From:        modelLaunchLog.From,
-				To:          modelLaunchLog.To,
-				Value:       modelLaunchLog.Value,
-				GasLimit:    modelLaunchLog.GasLimit,
-				GasUsed:     modelLaunchLog.GasUsed,
-				GasPrice:    modelLaunchLog.GasPrice,
-				Nonce:       modelLaunchLog.Nonce,
-				Data:        modelLaunchLog.Data,
-				ExecutedAt:  modelLaunchLog.ExecutedAt,
-				CreatedAt:   modelLaunchLog.CreatedAt,
-				UpdatedAt:   modelLaunchLog.UpdatedAt,
-			}
-			//payload, _ := json.Marshal(launchLog)
-			//json.Unmarshal(payload, &log)
+	signers, err := signer.FromEnv("HSK_RELAYER", privateKeys...)
+	if err != nil {
+		panic(err)
+	}
 
-			signedRawTransaction := l.SignService.Sign(&log)
-			transactionHash, err := l.BlockChain.SendRawTransaction(signedRawTransaction)
+	signService := dex_launcher.NewSignService(signers...)
+	accountPool := dex_launcher.NewAccountPool(signService.Addresses(), os.Getenv("HSK_RELAYER_ASSIGNMENT"))
 
-			if err != nil {
-				utils.Debugf("%+v", modelLaunchLog)
-				utils.Infof("Send Tx failed, launchLog ID: %d, err: %+v", modelLaunchLog.ID, err)
-				panic(err)
-			}
+	fallbackGasPrice := decimal.New(3, 9) // 3Gwei
+	priceDecider := launcher.NewGasStationGasPriceDecider(fallbackGasPrice)
 
-			utils.Infof("Send Tx, launchLog ID: %d, hash: %s", modelLaunchLog.ID, transactionHash)
+	queue, err := common.InitQueue(&common.RedisQueueConfig{
+		Name:   common.HYDRO_ENGINE_EVENTS_QUEUE_KEY,
+		Client: connection.NewRedisClient(os.Getenv("HSK_REDIS_URL")),
+		Ctx:    ctx,
+	})
+	if err != nil {
+		panic(err)
+	}
 
-			// todo any other fields?
-			modelLaunchLog.Hash = log.Hash
+	rpc := dex_launcher.NewEthereumRPC(os.Getenv("HSK_BLOCKCHAIN_RPC_URL"))
+	l := dex_launcher.NewLauncher(launcher.NewLauncher(ctx, signService, hydro, priceDecider), rpc, accountPool, queue)
 
-			err = models.UpdateLaunchLogToPending(modelLaunchLog)
+	// without notifications, the launcher still finds new launch logs by polling
+	listener, err := dex_launcher.NewLaunchLogListener(os.Getenv("HSK_DATABASE_URL"))
+	if err != nil {
+		utils.Errorf("listen to launch logs error: %v", err)
+	} else {
+		defer listener.Close()
+		l.ListenTo(listener.Notify)
+	}
 
-			if err != nil {
-				utils.Infof("Update Launch Log Failed, ID: %d, err: %s", modelLaunchLog.ID, err)
-				panic(err)
-			}
+	l.Run(metrics.StartMetrics)
 
-			l.SignService.AfterSign()
-		}
-	}
+	return 0
 }
 
 func main() {
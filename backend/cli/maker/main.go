@@ -8,7 +8,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
-	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	"io/ioutil"
 	"math"
@@ -27,15 +27,16 @@ func randomNumber(min, max, decimals float64) float64 {
 }
 
 // ethereum-test-node
-// maker pk and address
+// maker pk, used when HSK_MAKER_SIGNER and HSK_MAKER_PK are not set
 // https://github.com/HydroProtocol/ethereum-test-node
-const pk = "0xa6553a3cbade744d6c6f63e557345402abd93e25cd1f1dba8bb0d374de2fcf4f"
-const address = "0x126aa4ef50a6e546aa5ecd1eb83c060fb780891a"
+const testNodePk = "0xa6553a3cbade744d6c6f63e557345402abd93e25cd1f1dba8bb0d374de2fcf4f"
+
+var maker signer.Signer
 
 func getHydroAuthenticationHeader() string {
 	message := "HYDRO-AUTHENTICATION"
-	signature, _ := ethereum.PersonalSign([]byte(message), pk)
-	return fmt.Sprintf("%s#%s#%s", address, message, utils.Bytes2HexP(signature))
+	signature, _ := signer.PersonalSign(maker, []byte(message))
+	return fmt.Sprintf("%s#%s#%s", maker.Address(), message, utils.Bytes2HexP(signature))
 }
 
 func setReqHeader(req *http.Request) {
@@ -92,10 +93,11 @@ func placeOrder() {
 
 	_ = json.Unmarshal(resBytes, &buildOrderRes)
 
-	signature, _ := ethereum.PersonalSign(
-		utils.Hex2Bytes(buildOrderRes.Data.Order.ID),
-		pk,
-	)
+	signature, err := signer.PersonalSign(maker, utils.Hex2Bytes(buildOrderRes.Data.Order.ID))
+	if err != nil {
+		utils.Errorf("sign order error: %v", err)
+		return
+	}
 
 	placeOrderRequestBody, _ := json.Marshal(map[string]interface{}{
 		"orderID":   buildOrderRes.Data.Order.ID,
@@ -122,6 +124,18 @@ func toOrderSignature(sign []byte) []byte {
 }
 
 func main() {
+	pk := os.Getenv("HSK_MAKER_PK")
+	if pk == "" && os.Getenv("HSK_MAKER_SIGNER") == "" {
+		pk = testNodePk
+	}
+
+	signers, err := signer.FromEnv("HSK_MAKER", pk)
+	if err != nil {
+		panic(err)
+	}
+
+	maker = signers[0]
+
 	for {
 		placeOrder()
 		time.Sleep(3 * time.Second)
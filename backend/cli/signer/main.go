@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	_ "github.com/joho/godotenv/autoload"
+	urfave "github.com/urfave/cli"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// A local stand-in of the remote signing service, it signs with keystore files.
+// It lets the remote signer be run and tested without an HSM, and is the reference of the protocol.
+func main() {
+	app := urfave.NewApp()
+	app.Name = "signer"
+	app.Usage = "Reference remote signing service, and keystore tools"
+	app.Version = "0.0.1"
+
+	app.Commands = []urfave.Command{
+		{
+			Name:  "serve",
+			Usage: "Sign with the keys configured by HSK_SIGNER_* env vars, e.g. HSK_SIGNER=keystore",
+			Flags: []urfave.Flag{
+				urfave.StringFlag{
+					Name:  "addr",
+					Value: ":3005",
+					Usage: "Address to listen on",
+				},
+			},
+			Action: func(c *urfave.Context) error {
+				return serve(c.String("addr"))
+			},
+		},
+		{
+			Name:      "import",
+			Usage:     "Encrypt a hex private key read from stdin into a keystore file",
+			ArgsUsage: "<keystore file>",
+			Flags: []urfave.Flag{
+				urfave.StringFlag{
+					Name:  "passphraseFile",
+					Usage: "File holding the passphrase",
+				},
+			},
+			Action: func(c *urfave.Context) error {
+				return importKey(c.Args().First(), c.String("passphraseFile"))
+			},
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		utils.Errorf(err.Error())
+		os.Exit(1)
+	}
+}
+
+func serve(addr string) error {
+	signers, err := signer.FromEnv("HSK_SIGNER", os.Getenv("HSK_SIGNER_PK"))
+	if err != nil {
+		return err
+	}
+
+	var token string
+	if tokenFile := os.Getenv("HSK_SIGNER_TOKEN_FILE"); tokenFile != "" {
+		if token, err = signer.ReadSecretFile(tokenFile); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range signers {
+		utils.Infof("signing for %s", s.Address())
+	}
+
+	ctx, stop := context.WithCancel(context.Background())
+	go cli.WaitExitSignal(stop)
+
+	server := &http.Server{Addr: addr, Handler: signer.NewServer(token, signers...)}
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Shutdown(context.Background())
+	}()
+
+	utils.Infof("signer listening on %s", addr)
+
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+
+	return nil
+}
+
+func importKey(keystoreFile, passphraseFile string) error {
+	if keystoreFile == "" || passphraseFile == "" {
+		return fmt.Errorf("keystore file and --passphraseFile are required")
+	}
+
+	passphrase, err := signer.ReadSecretFile(passphraseFile)
+	if err != nil {
+		return err
+	}
+
+	privateKey, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && privateKey == "" {
+		return err
+	}
+
+	keyJSON, err := signer.EncryptKey(utils.Hex2Bytes(strings.TrimSpace(privateKey)), passphrase, signer.StandardScryptN, signer.StandardScryptP)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(keystoreFile, keyJSON, 0600)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"math/big"
+)
+
+// approvals after which a market gives up, it is published by hand again to retry
+const maxApprovalFailures = 3
+
+// approvals publishes the approving markets once the proxy can spend both of their tokens from the relayer.
+// A failed approval is sent again, until it failed maxApprovalFailures times in a row.
+type approvals struct {
+	eventQueue     common.IQueue
+	erc20          ethereum.IErc20
+	proxyAddress   string
+	relayerAddress string
+}
+
+func (a *approvals) BlockHandlerFunc(blockNumber uint64, isRemoved bool) {
+	if isRemoved {
+		return
+	}
+
+	for _, market := range models.MarketDao.FindMarketsByStatus(models.MarketStatusApproving) {
+		a.checkMarket(market)
+	}
+}
+
+func (a *approvals) checkMarket(market *models.Market) {
+	approved := true
+
+	for _, tokenAddress := range []string{market.QuoteTokenAddress, market.BaseTokenAddress} {
+		ok, err := a.checkToken(market, tokenAddress)
+		if err != nil {
+			utils.Errorf("check approval of %s for market %s error: %v", tokenAddress, market.ID, err)
+			return
+		}
+
+		if market.Status == models.MarketStatusApprovalFailed {
+			utils.Errorf("market %s gave up approving: %s", market.ID, market.ApprovalError)
+			break
+		}
+
+		approved = approved && ok
+	}
+
+	// the error of a failed approval is saved while it is sent again
+	if !approved || market.Status == models.MarketStatusApprovalFailed {
+		if _, err := models.MarketDao.UpdateApprovingMarket(market); err != nil {
+			utils.Errorf("update market %s error: %v", market.ID, err)
+		}
+
+		return
+	}
+
+	market.SetStatus(models.MarketStatusPublished)
+	market.ApprovalError = ""
+
+	updated, err := models.MarketDao.UpdateApprovingMarket(market)
+	if err != nil {
+		utils.Errorf("update market %s error: %v", market.ID, err)
+		return
+	}
+
+	// the market was unpublished or published by hand since it was loaded
+	if !updated {
+		utils.Infof("market %s is no longer approving, don't publish it", market.ID)
+		return
+	}
+
+	utils.Infof("market %s is approved, publish it", market.ID)
+
+	event := common.Event{
+		Type:     common.EventOpenMarket,
+		MarketID: market.ID,
+	}
+
+	if err := a.eventQueue.Push([]byte(utils.ToJsonString(event))); err != nil {
+		utils.Errorf("Push event into Queue Error: %v", err)
+	}
+}
+
+// checkToken tells if the proxy can spend the token. A failed approval is sent again,
+// or the market is moved to approval failed if it failed too many times.
+func (a *approvals) checkToken(market *models.Market, tokenAddress string) (bool, error) {
+	err, allowance := a.erc20.AllowanceOf(tokenAddress, a.proxyAddress, a.relayerAddress)
+	if err != nil {
+		return false, err
+	}
+
+	if allowance.Cmp(big.NewInt(0)) > 0 {
+		return true, nil
+	}
+
+	latest := models.LaunchLogDao.FindApprovals(a.relayerAddress, tokenAddress, maxApprovalFailures)
+	if len(latest) > 0 && models.IsApprovalInFlight(latest[0]) {
+		return false, nil
+	}
+
+	// a successful approval whose allowance is not seen yet, or which was spent, is sent again as well
+	if failures := models.CountFailedApprovals(latest); failures > 0 {
+		market.ApprovalError = "approval of " + tokenAddress + " is " + latest[0].Status
+		if latest[0].SimulationResult.Valid {
+			market.ApprovalError += ": " + latest[0].SimulationResult.String
+		}
+
+		if failures >= maxApprovalFailures {
+			market.SetStatus(models.MarketStatusApprovalFailed)
+			return false, nil
+		}
+	}
+
+	approveLog, err := models.NewApproveLaunchLog(a.relayerAddress, a.proxyAddress, tokenAddress)
+	if err != nil {
+		return false, err
+	}
+
+	utils.Infof("approve %s for market %s again", tokenAddress, market.ID)
+
+	return false, models.LaunchLogDao.InsertLaunchLog(approveLog)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	dexcli "github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/HydroProtocol/nights-watch/plugin"
+	"github.com/HydroProtocol/nights-watch/rpc"
+	"github.com/HydroProtocol/nights-watch/structs"
+	"os"
+)
+
+// backfill feeds the transactions of a range of blocks through the plugins again, e.g. after the watcher missed them.
+// It is safe to run over blocks which are processed already: launch logs which are not pending any more are skipped,
+// and the engine ignores cancels and results it has seen. The cursor of the watcher is left alone.
+func backfill(from, to uint64) error {
+	if from == 0 || to < from {
+		return fmt.Errorf("invalid block range %d to %d", from, to)
+	}
+
+	dexcli.StartBlockchain(context.Background())
+
+	queue, _ := connect(context.Background())
+
+	// the transactions of launch logs in the range are mostly settled, they are looked up in the database
+	index := newLaunchLogIndex(os.Getenv("HSK_RELAYER_ADDRESS"))
+	index.load(int64(from))
+
+	plugins := newTxReceiptPlugins(queue, index)
+	r := rpc.NewEthRPCWithRetry(os.Getenv("HSK_BLOCKCHAIN_RPC_URL"), 5)
+
+	for blockNumber := from; blockNumber <= to; blockNumber++ {
+		block, err := r.GetBlockByNum(blockNumber)
+		if err != nil {
+			return err
+		}
+
+		for _, tx := range block.GetTransactions() {
+			if !needReceipt(plugins, tx) {
+				continue
+			}
+
+			receipt, err := r.GetTransactionReceipt(tx.GetHash())
+			if err != nil {
+				return err
+			}
+
+			txAndReceipt := structs.NewRemovableTxAndReceipt(tx, receipt, false, block.Timestamp())
+			for _, p := range plugins {
+				if filterPlugin, ok := p.(*plugin.TxReceiptPluginWithFilter); !ok || filterPlugin.NeedReceipt(tx) {
+					p.Accept(txAndReceipt)
+				}
+			}
+		}
+
+		utils.Infof("Backfilled block %d", blockNumber)
+	}
+
+	// settlements found in the range may be deep enough to be finalized already
+	if depth := getConfirmationDepth(); depth > 0 {
+		head, err := r.GetCurrentBlockNum()
+		if err != nil {
+			return err
+		}
+
+		f := &finalizer{eventQueue: queue, depth: depth}
+		f.BlockHandlerFunc(head, false)
+	}
+
+	return nil
+}
+
+func needReceipt(plugins []plugin.ITxReceiptPlugin, tx sdk.Transaction) bool {
+	for _, p := range plugins {
+		if filterPlugin, ok := p.(*plugin.TxReceiptPluginWithFilter); !ok || filterPlugin.NeedReceipt(tx) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/HydroProtocol/nights-watch/rpc"
+	"github.com/HydroProtocol/nights-watch/structs"
+	"strconv"
+	"sync"
+)
+
+var lagGauge = metrics.NewGauge(
+	"hydro_watcher_lag_blocks",
+	"Blocks between the chain head and the last block the watcher has processed.",
+)
+
+// cursor saves the last block the watcher has processed into the database on every block.
+//
+// Blocks and receipts reach the plugins in two goroutines, and the receipts of a block can still be waiting
+// when the block plugins see it. All the receipts of a block are sent before the block though, in block order,
+// so a block is done once as many receipts are processed as were sent up to it, whether it had any or not.
+// The cursor is the latest block which is done that way.
+type cursor struct {
+	name string
+	rpc  rpc.IBlockChainRPC
+
+	lock sync.Mutex
+
+	// receipts of the added blocks, the ones sent with the blocks seen so far and the ones processed.
+	// Receipts of removed blocks are sent again as removed, they are not counted.
+	sentReceipts      uint64
+	processedReceipts uint64
+
+	// the blocks seen whose receipts are not all processed yet, in block order
+	waiting []waitingBlock
+
+	// the latest block which is done
+	done uint64
+}
+
+type waitingBlock struct {
+	number uint64
+
+	// the receipts sent up to the block
+	sentReceipts uint64
+}
+
+// newCursor starts a cursor at the block the watcher starts from, 0 is the chain head.
+func newCursor(name string, rpc rpc.IBlockChainRPC, startFromBlock uint64) *cursor {
+	c := &cursor{
+		name: name,
+		rpc:  rpc,
+	}
+
+	if startFromBlock > 0 {
+		c.done = startFromBlock - 1
+	}
+
+	return c
+}
+
+// TxHandlerFunc takes every receipt. It is registered last, so that the other plugins are done with the receipt.
+func (c *cursor) TxHandlerFunc(txAndReceipt *structs.RemovableTxAndReceipt) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !txAndReceipt.IsRemoved {
+		c.processedReceipts++
+		c.advance()
+	}
+}
+
+func (c *cursor) BlockHandlerFunc(block *structs.RemovableBlock) {
+	done := c.acceptBlock(block)
+
+	if err := models.WatcherCursorDao.SaveBlockNumber(c.name, done); err != nil {
+		utils.Errorf("save watcher cursor %d error: %v", done, err)
+		return
+	}
+
+	head, err := c.rpc.GetCurrentBlockNum()
+	if err != nil {
+		utils.Errorf("get current block number error: %v", err)
+		return
+	}
+
+	lagGauge.Set(float64(head) - float64(done))
+}
+
+// acceptBlock takes a block seen by the block plugins, and returns the latest block which is done.
+func (c *cursor) acceptBlock(block *structs.RemovableBlock) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	blockNumber := block.Number()
+
+	if block.IsRemoved {
+		// the watcher goes on from the block before the removed one
+		if c.done >= blockNumber {
+			c.done = blockNumber - 1
+		}
+
+		for len(c.waiting) > 0 && c.waiting[len(c.waiting)-1].number >= blockNumber {
+			c.waiting = c.waiting[:len(c.waiting)-1]
+		}
+
+		return c.done
+	}
+
+	// started from the chain head, the first block is where the watcher starts
+	if c.done == 0 {
+		c.done = blockNumber - 1
+	}
+
+	c.sentReceipts += uint64(len(block.GetTransactions()))
+	c.waiting = append(c.waiting, waitingBlock{number: blockNumber, sentReceipts: c.sentReceipts})
+	c.advance()
+
+	return c.done
+}
+
+// advance moves the cursor to the waiting blocks whose receipts are all processed.
+func (c *cursor) advance() {
+	for len(c.waiting) > 0 && c.waiting[0].sentReceipts <= c.processedReceipts {
+		c.done = c.waiting[0].number
+		c.waiting = c.waiting[1:]
+	}
+}
+
+// resumeBlock returns the block the watcher goes on from, 0 is the chain head.
+func resumeBlock(kvStore common.IKVStore) uint64 {
+	if blockNumber, ok := models.WatcherCursorDao.FindBlockNumber(models.WatcherCursorName); ok {
+		return blockNumber + 1
+	}
+
+	// the watcher used to keep its last block in redis only
+	syncedBlockInCache, err := kvStore.Get(common.HYDRO_WATCHER_BLOCK_NUMBER_CACHE_KEY)
+	if err != nil && err != common.KVStoreEmpty {
+		panic(err)
+	}
+
+	if b, err := strconv.Atoi(syncedBlockInCache); err == nil {
+		return uint64(b) + 1
+	}
+
+	return 0
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	"github.com/HydroProtocol/nights-watch/structs"
+	"github.com/onrik/ethrpc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestBlock(number int, transactions int, isRemoved bool) *structs.RemovableBlock {
+	block := &ethrpc.Block{Number: number, Transactions: make([]ethrpc.Transaction, transactions)}
+	return structs.NewRemovableBlock(&ethereum.EthereumBlock{Block: block}, isRemoved)
+}
+
+func newTestReceipt(isRemoved bool) *structs.RemovableTxAndReceipt {
+	return structs.NewRemovableTxAndReceipt(nil, nil, isRemoved, 0)
+}
+
+func TestCursor(t *testing.T) {
+	c := newCursor("test", nil, 0)
+
+	// the receipts of the first block are still waiting
+	assert.EqualValues(t, 99, c.acceptBlock(newTestBlock(100, 2, false)))
+	c.TxHandlerFunc(newTestReceipt(false))
+	assert.EqualValues(t, 99, c.done)
+	c.TxHandlerFunc(newTestReceipt(false))
+	assert.EqualValues(t, 100, c.done)
+
+	// blocks without transactions are done at once
+	assert.EqualValues(t, 101, c.acceptBlock(newTestBlock(101, 0, false)))
+	assert.EqualValues(t, 102, c.acceptBlock(newTestBlock(102, 0, false)))
+
+	// a block without transactions waits for the receipts of the blocks before it
+	assert.EqualValues(t, 102, c.acceptBlock(newTestBlock(103, 1, false)))
+	assert.EqualValues(t, 102, c.acceptBlock(newTestBlock(104, 0, false)))
+	c.TxHandlerFunc(newTestReceipt(false))
+	assert.EqualValues(t, 104, c.done)
+
+	// receipts processed before the block plugins see their block
+	c.TxHandlerFunc(newTestReceipt(false))
+	assert.EqualValues(t, 105, c.acceptBlock(newTestBlock(105, 1, false)))
+}
+
+func TestCursorRemovedBlocks(t *testing.T) {
+	c := newCursor("test", nil, 100)
+	assert.EqualValues(t, 99, c.done)
+
+	assert.EqualValues(t, 100, c.acceptBlock(newTestBlock(100, 0, false)))
+	assert.EqualValues(t, 101, c.acceptBlock(newTestBlock(101, 0, false)))
+	assert.EqualValues(t, 101, c.acceptBlock(newTestBlock(102, 1, false)))
+
+	// the receipt of the removed block is sent again as removed, which doesn't count
+	c.TxHandlerFunc(newTestReceipt(true))
+	assert.EqualValues(t, 101, c.acceptBlock(newTestBlock(102, 1, true)))
+	assert.EqualValues(t, 100, c.acceptBlock(newTestBlock(101, 0, true)))
+
+	// the receipt of the removed block comes late, the blocks which replace them are done in turn
+	c.TxHandlerFunc(newTestReceipt(false))
+	assert.EqualValues(t, 101, c.acceptBlock(newTestBlock(101, 0, false)))
+	assert.EqualValues(t, 101, c.acceptBlock(newTestBlock(102, 1, false)))
+	c.TxHandlerFunc(newTestReceipt(false))
+	assert.EqualValues(t, 102, c.done)
+}
@@ -0,0 +1,220 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/lib/pq"
+	"strings"
+	"sync"
+	"time"
+)
+
+// The watcher sees every transaction of every block, and only a few of them are sent by the launcher.
+// launchLogIndex keeps the hashes of the sent attempts in memory, so that the filter needs no query for most transactions.
+// It is loaded from the database at startup, and the launcher notifies every attempt it sends.
+// A transaction from a relayer address which is not in the index is still looked up in the database,
+// in case a notification is lost.
+
+// blocks a mined transaction stays in the index, it can be removed by a reorganization until then.
+// It is how many blocks nights-watch keeps to find forks.
+const reorgWindow = 64
+
+// Results of the filter lookups
+const (
+	lookupIndexHit = "index_hit"
+	lookupDBHit    = "db_hit"
+	lookupDBMiss   = "db_miss"
+	lookupSkipped  = "skipped"
+)
+
+var filterLookupsCounter = metrics.NewCounter(
+	"hydro_watcher_filter_lookups_total",
+	"Transactions the watcher filter has looked at, by where the launch log was found.",
+	"result",
+)
+
+type nonceKey struct {
+	from  string
+	nonce int64
+}
+
+type launchLogIndex struct {
+	lock sync.Mutex
+
+	// the relayer and its delegates, only their transactions can belong to launch logs
+	relayers map[string]bool
+
+	hashes map[string]nonceKey
+
+	// all hashes sent with the same nonce, and the block one of them is mined in, 0 before
+	nonces   map[nonceKey][]string
+	minedAts map[nonceKey]uint64
+}
+
+func newLaunchLogIndex(relayerAddress string) *launchLogIndex {
+	index := &launchLogIndex{
+		relayers: make(map[string]bool),
+		hashes:   make(map[string]nonceKey),
+		nonces:   make(map[nonceKey][]string),
+		minedAts: make(map[nonceKey]uint64),
+	}
+
+	if relayerAddress != "" {
+		index.relayers[strings.ToLower(relayerAddress)] = true
+	}
+
+	return index
+}
+
+// load adds the attempts which can still be mined or removed, and the addresses the launcher has sent from.
+func (index *launchLogIndex) load(sinceBlock int64) {
+	senders := models.LaunchLogDao.FindSenders()
+	attempts := models.LaunchLogAttemptDao.FindSentSince(sinceBlock)
+
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	for _, sender := range senders {
+		index.relayers[strings.ToLower(sender)] = true
+	}
+
+	for _, attempt := range attempts {
+		index.add(attempt)
+	}
+
+	utils.Infof("launch log index loaded %d attempts of %d relayer addresses", len(attempts), len(index.relayers))
+}
+
+func (index *launchLogIndex) add(attempt *models.SentAttempt) {
+	hash := strings.ToLower(attempt.Hash)
+	if _, ok := index.hashes[hash]; ok {
+		return
+	}
+
+	key := nonceKey{strings.ToLower(attempt.From), attempt.Nonce}
+
+	index.relayers[key.from] = true
+	index.hashes[hash] = key
+	index.nonces[key] = append(index.nonces[key], hash)
+
+	// sent again after the block of the nonce was removed
+	delete(index.minedAts, key)
+}
+
+func (index *launchLogIndex) Add(attempt *models.SentAttempt) {
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	index.add(attempt)
+}
+
+// Filter tells if a transaction is sent for a launch log. Only transactions of relayer addresses missing in the index
+// are looked up in the database.
+func (index *launchLogIndex) Filter(tx sdk.Transaction) bool {
+	hash := strings.ToLower(tx.GetHash())
+
+	index.lock.Lock()
+	key, ok := index.hashes[hash]
+	if ok {
+		if _, mined := index.minedAts[key]; !mined {
+			index.minedAts[key] = tx.GetBlockNumber()
+		}
+	}
+	isRelayer := index.relayers[strings.ToLower(tx.GetFrom())]
+	index.lock.Unlock()
+
+	if ok {
+		filterLookupsCounter.Inc(lookupIndexHit)
+		return true
+	}
+
+	if !isRelayer {
+		filterLookupsCounter.Inc(lookupSkipped)
+		return false
+	}
+
+	if models.FindLaunchLogByAttemptHash(tx.GetHash()) == nil {
+		filterLookupsCounter.Inc(lookupDBMiss)
+		utils.Debugf("Skip useless transaction %s", tx.GetHash())
+		return false
+	}
+
+	filterLookupsCounter.Inc(lookupDBHit)
+	utils.Infof("transaction %s of a launch log is missing in the index", tx.GetHash())
+
+	if nonce, ok := transactionNonce(tx); ok {
+		index.Add(&models.SentAttempt{Hash: tx.GetHash(), From: tx.GetFrom(), Nonce: nonce})
+	}
+
+	return true
+}
+
+func transactionNonce(tx sdk.Transaction) (int64, bool) {
+	ethereumTx, ok := tx.(*ethereum.EthereumTransaction)
+	if !ok {
+		return 0, false
+	}
+
+	return int64(ethereumTx.Nonce), true
+}
+
+// BlockHandlerFunc drops the nonces which are mined deeper than a reorganization can reach.
+func (index *launchLogIndex) BlockHandlerFunc(blockNumber uint64, isRemoved bool) {
+	if isRemoved {
+		return
+	}
+
+	index.lock.Lock()
+	defer index.lock.Unlock()
+
+	for key, minedAt := range index.minedAts {
+		if minedAt+reorgWindow >= blockNumber {
+			continue
+		}
+
+		for _, hash := range index.nonces[key] {
+			delete(index.hashes, hash)
+		}
+
+		delete(index.nonces, key)
+		delete(index.minedAts, key)
+	}
+}
+
+// listen adds the attempts notified by the launcher until the listener is closed.
+// The listener sends a nil notification after it reconnects, notifications may be lost then, so the index is loaded again.
+func (index *launchLogIndex) listen(listener *pq.Listener, sinceBlock func() int64) {
+	for notification := range listener.Notify {
+		if notification == nil {
+			index.load(sinceBlock())
+			continue
+		}
+
+		var attempt models.SentAttempt
+		if err := json.Unmarshal([]byte(notification.Extra), &attempt); err != nil {
+			utils.Errorf("invalid attempt notification %s: %v", notification.Extra, err)
+			continue
+		}
+
+		index.Add(&attempt)
+	}
+}
+
+func newAttemptListener(databaseURL string) (*pq.Listener, error) {
+	listener := pq.NewListener(databaseURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			utils.Errorf("attempt listener error: %v", err)
+		}
+	})
+
+	if err := listener.Listen(models.LaunchLogAttemptsSentChannel); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	"github.com/lib/pq"
+	"github.com/onrik/ethrpc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+const (
+	indexRelayer  = "0x31ebd457b999bf99759602f5ece5aa5033cb56b3"
+	indexDelegate = "0x3eb06f432ae8f518a957852aa44776c234b4a84a"
+	indexTrader   = "0x93388b4efe13b9b18ed480783c05462409851547"
+)
+
+func newIndexTx(hash, from string, nonce int, blockNumber int) sdk.Transaction {
+	return &ethereum.EthereumTransaction{Transaction: &ethrpc.Transaction{Hash: hash, From: from, Nonce: nonce, BlockNumber: &blockNumber}}
+}
+
+func mockLaunchLogDaos() (*mLaunchLogDao, *mLaunchLogAttemptDao, func()) {
+	launchLogDao := &mLaunchLogDao{}
+	launchLogAttemptDao := &mLaunchLogAttemptDao{}
+	models.LaunchLogDao = launchLogDao
+	models.LaunchLogAttemptDao = launchLogAttemptDao
+
+	return launchLogDao, launchLogAttemptDao, func() {
+		models.LaunchLogDao = models.LaunchLogDaoPG
+		models.LaunchLogAttemptDao = models.LaunchLogAttemptDaoPG
+	}
+}
+
+func TestLaunchLogIndexFilter(t *testing.T) {
+	launchLogDao, launchLogAttemptDao, restore := mockLaunchLogDaos()
+	defer restore()
+
+	index := newLaunchLogIndex(indexRelayer)
+	index.Add(&models.SentAttempt{Hash: "0xA1", From: indexRelayer, Nonce: 1})
+
+	// sent, in any case of the hash
+	hits := filterLookupsCounter.Value(lookupIndexHit)
+	assert.True(t, index.Filter(newIndexTx("0xa1", indexRelayer, 1, 100)))
+	assert.EqualValues(t, hits+1, filterLookupsCounter.Value(lookupIndexHit))
+
+	// transactions of other addresses are never looked up
+	skipped := filterLookupsCounter.Value(lookupSkipped)
+	assert.False(t, index.Filter(newIndexTx("0xb1", indexTrader, 1, 100)))
+	assert.EqualValues(t, skipped+1, filterLookupsCounter.Value(lookupSkipped))
+
+	// a transaction of the relayer missing in the index is looked up in the database
+	launchLogDao.On("FindByHash", "0xa2").Return((*models.LaunchLog)(nil))
+	launchLogAttemptDao.On("FindByHash", "0xa2").Return((*models.LaunchLogAttempt)(nil))
+	misses := filterLookupsCounter.Value(lookupDBMiss)
+	assert.False(t, index.Filter(newIndexTx("0xa2", indexRelayer, 2, 100)))
+	assert.EqualValues(t, misses+1, filterLookupsCounter.Value(lookupDBMiss))
+
+	// found there, it is added to the index
+	launchLogDao.On("FindByHash", "0xa3").Return(&models.LaunchLog{ID: 3}).Once()
+	dbHits := filterLookupsCounter.Value(lookupDBHit)
+	assert.True(t, index.Filter(newIndexTx("0xa3", indexRelayer, 3, 100)))
+	assert.EqualValues(t, dbHits+1, filterLookupsCounter.Value(lookupDBHit))
+	assert.EqualValues(t, nonceKey{indexRelayer, 3}, index.hashes["0xa3"])
+
+	assert.True(t, index.Filter(newIndexTx("0xa3", indexRelayer, 3, 100)))
+	assert.EqualValues(t, dbHits+1, filterLookupsCounter.Value(lookupDBHit))
+	launchLogDao.AssertExpectations(t)
+}
+
+func TestLaunchLogIndexEviction(t *testing.T) {
+	index := newLaunchLogIndex(indexRelayer)
+	index.Add(&models.SentAttempt{Hash: "0xa1", From: indexRelayer, Nonce: 1})
+	index.Add(&models.SentAttempt{Hash: "0xa1-replacement", From: indexRelayer, Nonce: 1})
+	index.Add(&models.SentAttempt{Hash: "0xa2", From: indexRelayer, Nonce: 2})
+
+	// the replacement is mined, the block of the first one seen wins
+	assert.True(t, index.Filter(newIndexTx("0xa1-replacement", indexRelayer, 1, 100)))
+	assert.True(t, index.Filter(newIndexTx("0xa1-replacement", indexRelayer, 1, 101)))
+	assert.EqualValues(t, 100, index.minedAts[nonceKey{indexRelayer, 1}])
+
+	// kept while a reorganization can remove its block
+	index.BlockHandlerFunc(100+reorgWindow, false)
+	assert.Len(t, index.nonces, 2)
+
+	// removed blocks don't evict
+	index.BlockHandlerFunc(100+reorgWindow+1, true)
+	assert.Len(t, index.nonces, 2)
+
+	// all the hashes of the nonce go, the nonce which is not mined stays
+	index.BlockHandlerFunc(100+reorgWindow+1, false)
+	assert.Len(t, index.nonces, 1)
+	assert.Len(t, index.minedAts, 0)
+	assert.EqualValues(t, map[string]nonceKey{"0xa2": {indexRelayer, 2}}, index.hashes)
+}
+
+func TestLaunchLogIndexSentAgain(t *testing.T) {
+	index := newLaunchLogIndex(indexRelayer)
+	index.Add(&models.SentAttempt{Hash: "0xa1", From: indexRelayer, Nonce: 1})
+	assert.True(t, index.Filter(newIndexTx("0xa1", indexRelayer, 1, 100)))
+
+	// the block is removed, and the nonce is sent again
+	index.Add(&models.SentAttempt{Hash: "0xa1-again", From: indexRelayer, Nonce: 1})
+	assert.NotContains(t, index.minedAts, nonceKey{indexRelayer, 1})
+
+	index.BlockHandlerFunc(100+reorgWindow+1, false)
+	assert.Len(t, index.hashes, 2)
+
+	// mined again, it is evicted from its new block on
+	assert.True(t, index.Filter(newIndexTx("0xa1-again", indexRelayer, 1, 110)))
+	index.BlockHandlerFunc(110+reorgWindow, false)
+	assert.Len(t, index.hashes, 2)
+	index.BlockHandlerFunc(110+reorgWindow+1, false)
+	assert.Len(t, index.hashes, 0)
+}
+
+func TestLaunchLogIndexReloadAfterReconnect(t *testing.T) {
+	launchLogDao, launchLogAttemptDao, restore := mockLaunchLogDaos()
+	defer restore()
+
+	launchLogDao.On("FindSenders").Return([]string{indexDelegate}).Once()
+	launchLogAttemptDao.On("FindSentSince", int64(36)).Return([]*models.SentAttempt{{Hash: "0xd1", From: indexDelegate, Nonce: 7}}).Once()
+
+	index := newLaunchLogIndex(indexRelayer)
+
+	listener := &pq.Listener{Notify: make(chan *pq.Notification, 3)}
+	listener.Notify <- &pq.Notification{Extra: `{"hash":"0xa1","from":"` + indexRelayer + `","nonce":1}`}
+
+	// reconnected, the attempts sent meanwhile are loaded from the database
+	listener.Notify <- nil
+	listener.Notify <- &pq.Notification{Extra: `not json`}
+	close(listener.Notify)
+
+	index.listen(listener, func() int64 { return 36 })
+
+	assert.EqualValues(t, map[string]nonceKey{
+		"0xa1": {indexRelayer, 1},
+		"0xd1": {indexDelegate, 7},
+	}, index.hashes)
+	assert.True(t, index.relayers[indexDelegate])
+
+	launchLogDao.AssertExpectations(t)
+	launchLogAttemptDao.AssertExpectations(t)
+}
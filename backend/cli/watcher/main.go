@@ -6,43 +6,245 @@ import (
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
 	"github.com/HydroProtocol/nights-watch/plugin"
 	"github.com/HydroProtocol/nights-watch/structs"
 	"strconv"
 
-	"github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/balance_monitor"
+	dexcli "github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/connection"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_engine"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_launcher"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/exchange_logs"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/relayer_pnl"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
-	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	"github.com/HydroProtocol/nights-watch"
+	"github.com/HydroProtocol/nights-watch/rpc"
+	"github.com/shopspring/decimal"
+	"github.com/urfave/cli"
 	"os"
+	"time"
 )
 
 type DBTransactionHandler struct {
 	eventQueue common.IQueue
-	kvStore    common.IKVStore
+	rpc        *dex_launcher.EthereumRPC
+
+	// blocks on top of a settlement before its trades are finalized
+	confirmationDepth int64
+}
+
+// getConfirmationDepth returns the confirmation depth of the chain, trades are finalized as soon as they are mined by default.
+func getConfirmationDepth() int64 {
+	depth, err := strconv.ParseInt(os.Getenv("HSK_CONFIRMATION_DEPTH"), 10, 64)
+	if err != nil || depth < 0 {
+		return 0
+	}
+
+	return depth
+}
+
+// finalizer finalizes the mined settlements which are confirmation depth blocks deep.
+// The engine sets the mined status asynchronously, so every pass looks at all the mined launch logs deep enough,
+// whatever block they are in.
+type finalizer struct {
+	eventQueue common.IQueue
+	depth      int64
+
+	// the launch logs whose confirmation is pushed, with the block they were mined in then.
+	// A launch log mined again after a reorg is finalized again with its new block.
+	pushed map[int64]int64
+}
+
+func newFinalizer(eventQueue common.IQueue, depth int64) *finalizer {
+	return &finalizer{
+		eventQueue: eventQueue,
+		depth:      depth,
+		pushed:     make(map[int64]int64),
+	}
+}
+
+func (f *finalizer) BlockHandlerFunc(blockNumber uint64, isRemoved bool) {
+	if isRemoved {
+		return
+	}
+
+	launchLogs := models.LaunchLogDao.FindMinedUntil(int64(blockNumber) - f.depth)
+
+	// the confirmations the engine has applied are forgotten
+	mined := make(map[int64]bool, len(launchLogs))
+	for _, launchLog := range launchLogs {
+		mined[launchLog.ID] = true
+	}
+
+	for id := range f.pushed {
+		if !mined[id] {
+			delete(f.pushed, id)
+		}
+	}
+
+	for _, launchLog := range launchLogs {
+		if minedAt, ok := f.pushed[launchLog.ID]; ok && minedAt == launchLog.BlockNumber.Int64 {
+			continue
+		}
+
+		transaction := models.TransactionDao.FindTransactionByID(launchLog.ItemID)
+		utils.Infof("LaunchLog %d mined in block %d is finalized at block %d", launchLog.ID, launchLog.BlockNumber.Int64, blockNumber)
+
+		event := &common.ConfirmTransactionEvent{
+			Event: common.Event{
+				Type:     common.EventConfirmTransaction,
+				MarketID: transaction.MarketID,
+			},
+			Hash:   launchLog.Hash.String,
+			Status: common.STATUS_SUCCESSFUL,
+			// trades are executed when they are mined
+			Timestamp: uint64(transaction.ExecutedAt.Unix()),
+		}
+
+		if err := f.eventQueue.Push([]byte(utils.ToJsonString(event))); err != nil {
+			utils.Errorf("Push event into Queue Error: %v", err)
+			return
+		}
+
+		f.pushed[launchLog.ID] = launchLog.BlockNumber.Int64
+	}
+}
+
+// recordReceipt saves the block the transaction of the launch log is mined in, the gas it used, and what it paid per gas.
+// Nodes before the London fork have no effective gas price in receipts, it is the gas price of the attempt then.
+func (handler DBTransactionHandler) recordReceipt(launchLog *models.LaunchLog, hash string, blockNumber uint64) {
+	launchLog.BlockNumber = sql.NullInt64{Int64: int64(blockNumber), Valid: true}
+
+	receipt, err := handler.rpc.Receipt(hash)
+	if err != nil || receipt == nil {
+		utils.Errorf("get receipt of %s error: %v", hash, err)
+	} else {
+		launchLog.GasUsed = sql.NullInt64{Int64: receipt.GasUsed, Valid: true}
+		launchLog.EffectiveGasPrice = receipt.EffectiveGasPrice
+		if !launchLog.EffectiveGasPrice.Valid {
+			if attempt := models.LaunchLogAttemptDao.FindByHash(hash); attempt != nil && !attempt.MaxPriorityFeePerGas.Valid {
+				launchLog.EffectiveGasPrice = decimal.NullDecimal{Decimal: attempt.GasPrice, Valid: true}
+			}
+		}
+	}
+
+	if err := models.LaunchLogDao.UpdateLaunchLogReceipt(launchLog); err != nil {
+		utils.Errorf("update receipt of launch log %d error: %v", launchLog.ID, err)
+	}
+}
+
+// blockTime returns the time the block was mined at, or now if the node doesn't tell.
+func (handler DBTransactionHandler) blockTime(blockNumber uint64) time.Time {
+	at, err := handler.rpc.BlockTime(blockNumber)
+	if err != nil {
+		utils.Errorf("get time of block %d error: %v", blockNumber, err)
+		return time.Now()
+	}
+
+	return at
+}
+
+// updateGasUsedEstimation feeds the gas a successful settlement used per match into the estimation of its market.
+func updateGasUsedEstimation(launchLog *models.LaunchLog, transaction *models.Transaction) {
+	if !launchLog.GasUsed.Valid {
+		return
+	}
+
+	matches := len(models.TradeDao.FindTradeByTransactionID(transaction.ID))
+	if matches == 0 {
+		return
+	}
+
+	gasUsedPerMatch := launchLog.GasUsed.Int64 / int64(matches)
+	if err := models.MarketDao.UpdateGasUsedEstimation(transaction.MarketID, gasUsedPerMatch); err != nil {
+		utils.Errorf("update gas used estimation of market %s error: %v", transaction.MarketID, err)
+	}
+}
+
+// handleRemoved takes back the result of a launch log whose block is removed by a chain reorganization.
+// The launch log is orphaned until the launcher sends it again, and the engine puts its trades back to pending.
+//
+// The confirmation of a trade launch log may still be waiting in the queue of the engine, the launch log is pending then.
+// The removal is pushed anyway, the engine applies it after the confirmation and orphans the launch log then.
+func (handler DBTransactionHandler) handleRemoved(launchLog *models.LaunchLog) {
+	if launchLog.ItemType != models.LaunchLogItemTypeTrade {
+		if launchLog.Status != common.STATUS_SUCCESSFUL && launchLog.Status != common.STATUS_FAILED {
+			utils.Infof("LaunchLog %d is %s, its removal changes nothing", launchLog.ID, launchLog.Status)
+			return
+		}
+
+		if err := models.TransitLaunchLog(launchLog, models.LaunchLogStatusOrphaned); err != nil {
+			panic(err)
+		}
+
+		return
+	}
+
+	utils.Infof("LaunchLog %d was %s, but its block is removed", launchLog.ID, launchLog.Status)
+
+	// guarded by the status in the database, which the engine may move meanwhile
+	if _, err := models.LaunchLogDao.UpdateLaunchLogStatus(launchLog, models.LaunchLogStatusOrphaned); err != nil {
+		panic(err)
+	}
+
+	relayer_pnl.RemoveSettlement(launchLog)
+
+	transaction := models.TransactionDao.FindTransactionByID(launchLog.ItemID)
+
+	event := &dex_engine.TransactionRemovedEvent{
+		Event: common.Event{
+			Type:     dex_engine.EventTransactionRemoved,
+			MarketID: transaction.MarketID,
+		},
+		Hash: launchLog.Hash.String,
+	}
+
+	if err := handler.eventQueue.Push([]byte(utils.ToJsonString(event))); err != nil {
+		utils.Errorf("Push event into Queue Error: %v", err)
+	}
 }
 
 func (handler DBTransactionHandler) TxHandlerFunc(txAndReceipt *structs.RemovableTxAndReceipt) {
 	tx := txAndReceipt.Tx
 	txReceipt := txAndReceipt.Receipt
 
-	launchLog := models.LaunchLogDao.FindByHash(tx.GetHash())
+	launchLog := models.FindLaunchLogByAttemptHash(tx.GetHash())
 	if launchLog == nil {
 		utils.Debugf("Skip useless transaction %s", tx.GetHash())
 		return
 	}
 
-	if launchLog.Status != common.STATUS_PENDING {
+	if txAndReceipt.IsRemoved {
+		handler.handleRemoved(launchLog)
+		return
+	}
+
+	// an orphaned launch log may be mined again before the launcher sends it again
+	if launchLog.Status != common.STATUS_PENDING && launchLog.Status != models.LaunchLogStatusOrphaned {
 		utils.Infof("LaunchLog is not pending %s, skip", launchLog.Hash.String)
 		return
 	}
 
 	txResult := txReceipt.GetResult()
 	hash := tx.GetHash()
+
+	// an earlier attempt is mined instead of the latest replacement
+	if launchLog.Hash.String != hash {
+		utils.Infof("LaunchLog %d is mined with attempt %s instead of %s", launchLog.ID, hash, launchLog.Hash.String)
+		launchLog.Hash = sql.NullString{String: hash, Valid: true}
+
+		if err := models.UpdateLaunchLogToPending(launchLog); err != nil {
+			panic(err)
+		}
+	}
+
 	transaction := models.TransactionDao.FindTransactionByID(launchLog.ItemID)
 	utils.Infof("Transaction %s txResult is %+v", tx.GetHash(), txResult)
 
@@ -53,16 +255,30 @@ func (handler DBTransactionHandler) TxHandlerFunc(txAndReceipt *structs.Removabl
 		status = common.STATUS_FAILED
 	}
 
-	//approve event should not process with engine, so update and return
-	if launchLog.ItemType == "hydroApprove" {
-		launchLog.Status = status
-		err := models.LaunchLogDao.UpdateLaunchLog(launchLog)
+	handler.recordReceipt(launchLog, hash, tx.GetBlockNumber())
+
+	//approve and nonce filling events should not process with engine, so update and return
+	if launchLog.ItemType != models.LaunchLogItemTypeTrade {
+		err := models.TransitLaunchLog(launchLog, status)
 		if err != nil {
 			panic(err)
 		}
 		return
 	}
 
+	minedAt := handler.blockTime(tx.GetBlockNumber())
+	relayer_pnl.RecordSettlementCost(launchLog, transaction, status, minedAt)
+
+	if status == common.STATUS_SUCCESSFUL {
+		updateGasUsedEstimation(launchLog, transaction)
+		relayer_pnl.RecordTradeRevenues(transaction.ID, minedAt)
+
+		// finalized by the finalizer once it is deep enough
+		if handler.confirmationDepth > 0 {
+			status = models.TradeStatusMined
+		}
+	}
+
 	event := &common.ConfirmTransactionEvent{
 		Event: common.Event{
 			Type:     common.EventConfirmTransaction,
@@ -79,14 +295,49 @@ func (handler DBTransactionHandler) TxHandlerFunc(txAndReceipt *structs.Removabl
 	if err != nil {
 		utils.Errorf("Push event into Queue Error: %v", err)
 	}
-
-	handler.kvStore.Set(common.HYDRO_WATCHER_BLOCK_NUMBER_CACHE_KEY, strconv.FormatUint(tx.GetBlockNumber(), 10), 0)
 }
 
 func main() {
-	ctx, stop := context.WithCancel(context.Background())
-	go cli.WaitExitSignal(stop)
+	app := cli.NewApp()
+	app.Name = "watcher"
+	app.Usage = "Follow the chain, and tell the engine how the settlements went"
+
+	app.Action = func(c *cli.Context) error {
+		return watch()
+	}
+
+	var from, to uint64
+
+	app.Commands = []cli.Command{
+		{
+			Name:  "backfill",
+			Usage: "Scan a range of blocks again, transactions which are processed already are skipped",
+			Flags: []cli.Flag{
+				cli.Uint64Flag{
+					Name:        "from",
+					Usage:       "First block of the range",
+					Destination: &from,
+				},
+				cli.Uint64Flag{
+					Name:        "to",
+					Usage:       "Last block of the range",
+					Destination: &to,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				return backfill(from, to)
+			},
+		},
+	}
+
+	if err := app.Run(os.Args); err != nil {
+		utils.Errorf(err.Error())
+		os.Exit(1)
+	}
+}
 
+// connect connects to the database and redis, and returns the engine event queue and the key/value store.
+func connect(ctx context.Context) (common.IQueue, common.IKVStore) {
 	// Init Database Client
 	models.Connect(os.Getenv("HSK_DATABASE_URL"))
 
@@ -111,46 +362,101 @@ func main() {
 		panic(err)
 	}
 
-	// only interested in tx send by launcher
-	filter := func(tx sdk.Transaction) bool {
-		launchLog := models.LaunchLogDao.FindByHash(tx.GetHash())
-
-		if launchLog == nil {
-			utils.Debugf("Skip useless transaction %s", tx.GetHash())
-			return false
-		} else {
-			return true
-		}
-	}
+	return queue, kvStore
+}
 
+// newTxReceiptPlugins returns the plugins which handle the transactions of a block, in the order they run.
+func newTxReceiptPlugins(queue common.IQueue, index *launchLogIndex) []plugin.ITxReceiptPlugin {
 	dbTxHandler := DBTransactionHandler{
 		eventQueue: queue,
-		kvStore:    kvStore,
+		rpc:        dex_launcher.NewEthereumRPC(os.Getenv("HSK_BLOCKCHAIN_RPC_URL")),
+
+		confirmationDepth: getConfirmationDepth(),
 	}
 
-	p := plugin.NewTxReceiptPluginWithFilter(dbTxHandler.TxHandlerFunc, filter)
+	// every transaction sent to the exchange, settlements of our own included
+	reconciler := exchange_logs.NewReconciler(queue, os.Getenv("HSK_HYBRID_EXCHANGE_ADDRESS"), os.Getenv("HSK_RELAYER_ADDRESS"))
+
+	// transfers and approvals of listed tokens may leave open orders unfunded
+	monitor := balance_monitor.NewMonitor(queue, ethereum.NewErc20Service(nil), os.Getenv("HSK_PROXY_ADDRESS"), os.Getenv("HSK_HYBRID_EXCHANGE_ADDRESS"))
+
+	return []plugin.ITxReceiptPlugin{
+		// only interested in tx send by launcher
+		plugin.NewTxReceiptPluginWithFilter(dbTxHandler.TxHandlerFunc, index.Filter),
+		plugin.NewTxReceiptPluginWithFilter(reconciler.TxHandlerFunc, reconciler.Filter),
+		plugin.NewTxReceiptPlugin(monitor.TxHandlerFunc),
+	}
+}
+
+func watch() error {
+	ctx, stop := context.WithCancel(context.Background())
+	go dexcli.WaitExitSignal(stop)
+	dexcli.StartBlockchain(ctx)
+
+	queue, kvStore := connect(ctx)
 
 	api := os.Getenv("HSK_BLOCKCHAIN_RPC_URL")
 	w := nights_watch.NewHttpBasedEthWatcher(ctx, api)
-	w.RegisterTxReceiptPlugin(p)
 
-	syncedBlockInCache, err := kvStore.Get(common.HYDRO_WATCHER_BLOCK_NUMBER_CACHE_KEY)
-	if err != nil && err != common.KVStoreEmpty {
-		panic(err)
+	startFromBlock := resumeBlock(kvStore)
+
+	// listening before loading, so that no attempt is missed in between
+	listener, err := newAttemptListener(os.Getenv("HSK_DATABASE_URL"))
+	if err != nil {
+		return err
 	}
+	defer listener.Close()
 
-	var startFromBlock uint64
-	if b, err := strconv.Atoi(syncedBlockInCache); err == nil {
-		startFromBlock = uint64(b) + 1
-	} else {
-		startFromBlock = 0
+	blockchain := rpc.NewEthRPCWithRetry(api, 5)
+
+	// the index keeps the attempts which can be mined or removed in the blocks the watcher goes through
+	sinceBlock := func() int64 {
+		if blockNumber, ok := models.WatcherCursorDao.FindBlockNumber(models.WatcherCursorName); ok {
+			return int64(blockNumber) - reorgWindow
+		}
+
+		head, err := blockchain.GetCurrentBlockNum()
+		if err != nil {
+			panic(err)
+		}
+
+		return int64(head) - reorgWindow
 	}
 
-	go utils.StartMetrics()
+	index := newLaunchLogIndex(os.Getenv("HSK_RELAYER_ADDRESS"))
+	index.load(sinceBlock())
+	go index.listen(listener, sinceBlock)
+
+	for _, p := range newTxReceiptPlugins(queue, index) {
+		w.RegisterTxReceiptPlugin(p)
+	}
+
+	w.RegisterBlockPlugin(plugin.NewBlockNumPlugin(index.BlockHandlerFunc))
+
+	if depth := getConfirmationDepth(); depth > 0 {
+		f := newFinalizer(queue, depth)
+		w.RegisterBlockPlugin(plugin.NewBlockNumPlugin(f.BlockHandlerFunc))
+	}
+
+	a := &approvals{
+		eventQueue:     queue,
+		erc20:          ethereum.NewErc20Service(nil),
+		proxyAddress:   os.Getenv("HSK_PROXY_ADDRESS"),
+		relayerAddress: os.Getenv("HSK_RELAYER_ADDRESS"),
+	}
+	w.RegisterBlockPlugin(plugin.NewBlockNumPlugin(a.BlockHandlerFunc))
+
+	c := newCursor(models.WatcherCursorName, blockchain, startFromBlock)
+	w.RegisterTxReceiptPlugin(plugin.NewTxReceiptPlugin(c.TxHandlerFunc))
+	w.RegisterBlockPlugin(plugin.NewSimpleBlockPlugin(c.BlockHandlerFunc))
+
+	go metrics.StartMetrics()
 	err = w.RunTillExitFromBlock(startFromBlock)
 	if err != nil {
 		utils.Infof("Watcher Exit with err: %s", err)
 	} else {
 		utils.Infof("Watcher Exit")
 	}
+
+	return err
 }
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_engine"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"testing"
+	"time"
+)
+
+type mLaunchLogDao struct {
+	models.ILaunchLogDao
+	mock.Mock
+}
+
+func (m *mLaunchLogDao) FindMinedUntil(blockNumber int64) []*models.LaunchLog {
+	args := m.Called(blockNumber)
+	return args.Get(0).([]*models.LaunchLog)
+}
+
+func (m *mLaunchLogDao) FindByHash(hash string) *models.LaunchLog {
+	args := m.Called(hash)
+	return args.Get(0).(*models.LaunchLog)
+}
+
+func (m *mLaunchLogDao) FindSenders() []string {
+	args := m.Called()
+	return args.Get(0).([]string)
+}
+
+func (m *mLaunchLogDao) UpdateLaunchLogStatus(launchLog *models.LaunchLog, status string) (bool, error) {
+	args := m.Called(launchLog, status)
+	return args.Bool(0), args.Error(1)
+}
+
+type mLaunchLogAttemptDao struct {
+	models.ILaunchLogAttemptDao
+	mock.Mock
+}
+
+func (m *mLaunchLogAttemptDao) FindByHash(hash string) *models.LaunchLogAttempt {
+	args := m.Called(hash)
+	return args.Get(0).(*models.LaunchLogAttempt)
+}
+
+func (m *mLaunchLogAttemptDao) FindSentSince(blockNumber int64) []*models.SentAttempt {
+	args := m.Called(blockNumber)
+	return args.Get(0).([]*models.SentAttempt)
+}
+
+type mTransactionDao struct {
+	models.ITransactionDao
+	mock.Mock
+}
+
+func (m *mTransactionDao) FindTransactionByID(id int64) *models.Transaction {
+	args := m.Called(id)
+	return args.Get(0).(*models.Transaction)
+}
+
+type mRelayerPnLDao struct {
+	models.IRelayerPnLDao
+	mock.Mock
+}
+
+func (m *mRelayerPnLDao) DeleteSettlement(launchLogID, transactionID int64) error {
+	args := m.Called(launchLogID, transactionID)
+	return args.Error(0)
+}
+
+func newMinedLaunchLog(id int64, hash string, blockNumber int64) *models.LaunchLog {
+	return &models.LaunchLog{
+		ID:          id,
+		ItemID:      id,
+		Status:      models.TradeStatusMined,
+		Hash:        sql.NullString{String: hash, Valid: true},
+		BlockNumber: sql.NullInt64{Int64: blockNumber, Valid: true},
+	}
+}
+
+func pushedEvents(queue *common.MockQueue) []*common.ConfirmTransactionEvent {
+	events := make([]*common.ConfirmTransactionEvent, 0, len(queue.Buffers))
+	for _, buffer := range queue.Buffers {
+		var event common.ConfirmTransactionEvent
+		_ = json.Unmarshal(buffer, &event)
+		events = append(events, &event)
+	}
+
+	queue.ResetBuffer()
+	return events
+}
+
+func pushedHashes(queue *common.MockQueue) []string {
+	var hashes []string
+	for _, event := range pushedEvents(queue) {
+		hashes = append(hashes, event.Hash)
+	}
+
+	return hashes
+}
+
+func TestFinalizer(t *testing.T) {
+	launchLogDao := &mLaunchLogDao{}
+	transactionDao := &mTransactionDao{}
+	models.LaunchLogDao = launchLogDao
+	models.TransactionDao = transactionDao
+	defer func() {
+		models.LaunchLogDao = models.LaunchLogDaoPG
+		models.TransactionDao = models.TransactionDaoPG
+	}()
+
+	queue := &common.MockQueue{}
+	queue.On("Push", mock.Anything).Return(nil)
+	transactionDao.On("FindTransactionByID", mock.Anything).Return(&models.Transaction{MarketID: "HOT-DAI", ExecutedAt: time.Unix(1548892800, 0)})
+
+	f := newFinalizer(queue, 3)
+
+	// the settlements mined 3 blocks deep are finalized
+	launchLogDao.On("FindMinedUntil", int64(7)).Return([]*models.LaunchLog{newMinedLaunchLog(1, "0x01", 5), newMinedLaunchLog(2, "0x02", 7)}).Once()
+	f.BlockHandlerFunc(10, false)
+
+	events := pushedEvents(queue)
+	assert.Len(t, events, 2)
+	assert.EqualValues(t, "0x01", events[0].Hash)
+	assert.EqualValues(t, "0x02", events[1].Hash)
+	assert.EqualValues(t, common.STATUS_SUCCESSFUL, events[0].Status)
+	assert.EqualValues(t, "HOT-DAI", events[0].MarketID)
+	assert.EqualValues(t, 1548892800, events[0].Timestamp)
+
+	// a launch log whose confirmation is not applied yet is not pushed again
+	launchLogDao.On("FindMinedUntil", int64(7)).Return([]*models.LaunchLog{newMinedLaunchLog(1, "0x01", 5), newMinedLaunchLog(2, "0x02", 7)}).Once()
+	f.BlockHandlerFunc(10, false)
+	assert.Empty(t, pushedHashes(queue))
+
+	// the engine sets the mined status late, the launch log is finalized though its block is passed already
+	launchLogDao.On("FindMinedUntil", int64(8)).Return([]*models.LaunchLog{newMinedLaunchLog(1, "0x01", 5), newMinedLaunchLog(3, "0x03", 6), newMinedLaunchLog(4, "0x04", 8)}).Once()
+	f.BlockHandlerFunc(11, false)
+	assert.EqualValues(t, []string{"0x03", "0x04"}, pushedHashes(queue))
+
+	// removed blocks finalize nothing
+	f.BlockHandlerFunc(11, true)
+	assert.Empty(t, pushedHashes(queue))
+
+	// the first one is mined again after a reorg into an earlier block, the others are applied by the engine
+	launchLogDao.On("FindMinedUntil", int64(9)).Return([]*models.LaunchLog{newMinedLaunchLog(1, "0x01", 6)}).Once()
+	f.BlockHandlerFunc(12, false)
+	assert.EqualValues(t, []string{"0x01"}, pushedHashes(queue))
+	assert.EqualValues(t, map[int64]int64{1: 6}, f.pushed)
+
+	launchLogDao.AssertExpectations(t)
+}
+
+func TestFinalizerWithoutDepth(t *testing.T) {
+	launchLogDao := &mLaunchLogDao{}
+	transactionDao := &mTransactionDao{}
+	models.LaunchLogDao = launchLogDao
+	models.TransactionDao = transactionDao
+	defer func() {
+		models.LaunchLogDao = models.LaunchLogDaoPG
+		models.TransactionDao = models.TransactionDaoPG
+	}()
+
+	queue := &common.MockQueue{}
+	queue.On("Push", mock.Anything).Return(nil)
+	transactionDao.On("FindTransactionByID", mock.Anything).Return(&models.Transaction{})
+
+	// settlements are finalized in the block they are mined in
+	f := newFinalizer(queue, 0)
+	launchLogDao.On("FindMinedUntil", int64(10)).Return([]*models.LaunchLog{newMinedLaunchLog(1, "0x01", 10)}).Once()
+	f.BlockHandlerFunc(10, false)
+	assert.EqualValues(t, []string{"0x01"}, pushedHashes(queue))
+}
+
+func TestHandleRemovedBeforeConfirmationIsApplied(t *testing.T) {
+	launchLogDao := &mLaunchLogDao{}
+	transactionDao := &mTransactionDao{}
+	relayerPnLDao := &mRelayerPnLDao{}
+	models.LaunchLogDao = launchLogDao
+	models.TransactionDao = transactionDao
+	models.RelayerPnLDao = relayerPnLDao
+	defer func() {
+		models.LaunchLogDao = models.LaunchLogDaoPG
+		models.TransactionDao = models.TransactionDaoPG
+		models.RelayerPnLDao = models.RelayerPnLDaoPG
+	}()
+
+	queue := &common.MockQueue{}
+	queue.On("Push", mock.Anything).Return(nil)
+	handler := DBTransactionHandler{eventQueue: queue}
+
+	// the engine hasn't applied the confirmation of the removed block yet
+	launchLog := &models.LaunchLog{ID: 1, ItemID: 7, ItemType: models.LaunchLogItemTypeTrade, Status: common.STATUS_PENDING, Hash: sql.NullString{String: "0x01", Valid: true}}
+
+	launchLogDao.On("UpdateLaunchLogStatus", launchLog, models.LaunchLogStatusOrphaned).Return(false, nil).Once()
+	relayerPnLDao.On("DeleteSettlement", int64(1), int64(7)).Return(nil).Once()
+	transactionDao.On("FindTransactionByID", int64(7)).Return(&models.Transaction{ID: 7, MarketID: "HOT-DAI"})
+
+	handler.handleRemoved(launchLog)
+
+	// the removal is queued after the confirmation, the engine orphans the launch log when it applies it
+	assert.Len(t, queue.Buffers, 1)
+	assert.Contains(t, string(queue.Buffers[0]), dex_engine.EventTransactionRemoved)
+	assert.Contains(t, string(queue.Buffers[0]), `"hash":"0x01"`)
+
+	launchLogDao.AssertExpectations(t)
+	relayerPnLDao.AssertExpectations(t)
+}
@@ -4,8 +4,8 @@ import (
 	"context"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/cli"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/connection"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
-	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	"github.com/HydroProtocol/hydro-sdk-backend/websocket"
 	"os"
 
@@ -23,6 +23,7 @@ func run() int {
 	redisClient = redisClient.WithContext(ctx)
 
 	go cli.WaitExitSignal(stop)
+	cli.StartBlockchain(ctx)
 
 	// new a source queue
 	queue, err := common.InitQueue(&common.RedisQueueConfig{
@@ -47,7 +48,7 @@ func run() int {
 
 	// Start the server
 	// It will block the current process to listen on the `addr` your provided.
-	go utils.StartMetrics()
+	go metrics.StartMetrics()
 	wsServer.Start(ctx)
 
 	return 0
@@ -0,0 +1,18 @@
+drop table if exists trade_revenues;
+drop table if exists settlement_costs;
+drop table if exists ledger_entries;
+drop table if exists ledger_balances;
+drop table if exists watcher_cursors;
+drop table if exists launch_log_attempts;
+
+alter table if exists launch_logs drop column if exists claimed_until;
+alter table if exists launch_logs drop column if exists simulation_result;
+alter table if exists launch_logs drop column if exists effective_gas_price;
+alter table if exists launch_logs drop column if exists max_priority_fee_per_gas;
+
+drop index if exists idx_orders_trader_address_client_order_id;
+alter table if exists orders drop column if exists cancel_reason;
+alter table if exists orders drop column if exists client_order_id;
+
+alter table if exists markets drop column if exists approval_error;
+alter table if exists markets drop column if exists status;
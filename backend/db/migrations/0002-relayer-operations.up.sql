@@ -0,0 +1,90 @@
+-- markets are approved by the admin before they are published
+alter table markets add column if not exists status text not null default 'published';
+alter table markets add column if not exists approval_error text not null default '';
+
+-- orders can be posted with an id of the trader, and keep why the relayer canceled them
+alter table orders add column if not exists client_order_id text not null default '';
+alter table orders add column if not exists cancel_reason text not null default '';
+create unique index if not exists idx_orders_trader_address_client_order_id on orders (trader_address, client_order_id) where client_order_id <> '';
+
+-- launch logs are sent with EIP-1559 fees, simulated before sending, and claimed by one launcher at a time
+alter table launch_logs add column if not exists max_priority_fee_per_gas numeric(32,18);
+alter table launch_logs add column if not exists effective_gas_price numeric(32,18);
+alter table launch_logs add column if not exists simulation_result text;
+alter table launch_logs add column if not exists claimed_until timestamp;
+
+-- launch_log_attempts table, every try to sign and send a transaction for a launch log, and how it went
+create table if not exists launch_log_attempts(
+  id SERIAL PRIMARY KEY,
+  launch_log_id integer not null,
+  transaction_hash text not null,
+  nonce integer not null,
+  gas_price numeric(32,18) not null,
+  max_priority_fee_per_gas numeric(32,18),
+  signed_at timestamp,
+  sent_at timestamp,
+  error text,
+  created_at timestamp
+);
+create index if not exists idx_launch_log_attempts_launch_log_id on launch_log_attempts (launch_log_id);
+create unique index if not exists idx_launch_log_attempts_transaction_hash on launch_log_attempts (transaction_hash) where error is null;
+
+-- watcher_cursors table, the last block each watcher has processed
+create table if not exists watcher_cursors(
+  name text PRIMARY KEY,
+  block_number bigint not null,
+  updated_at timestamp
+);
+
+-- ledger_balances table, the virtual balances of paper trading, in token units
+create table if not exists ledger_balances(
+  address text not null,
+  token_address text not null,
+  amount numeric(32,18) not null,
+  updated_at timestamp,
+  PRIMARY KEY (address, token_address)
+);
+
+-- ledger_entries table, every change of a ledger balance, by the deposit or settlement it belongs to
+create table if not exists ledger_entries(
+  id SERIAL PRIMARY KEY,
+  reference text not null,
+  kind text not null,
+  address text not null,
+  token_address text not null,
+  amount numeric(32,18) not null,
+  created_at timestamp
+);
+create index if not exists idx_ledger_entries_reference on ledger_entries (reference);
+create index if not exists idx_ledger_entries_address on ledger_entries (address, token_address);
+
+-- settlement_costs table, the gas the relayer paid for each mined settlement, converted to the quote token of its market
+create table if not exists settlement_costs(
+  launch_log_id integer PRIMARY KEY,
+  transaction_id integer not null,
+  market_id text not null,
+  transaction_hash text not null,
+  status text not null,
+  gas_used integer not null,
+  effective_gas_price numeric(32,18) not null,
+  eth_price numeric(32,18) not null,
+  gas_cost numeric(32,18) not null,
+  mined_at timestamp not null,
+  created_at timestamp
+);
+create index if not exists idx_settlement_costs_mined_at on settlement_costs (mined_at, market_id);
+
+-- trade_revenues table, the fees the relayer collected for each successful trade, in quote token
+create table if not exists trade_revenues(
+  trade_id integer PRIMARY KEY,
+  transaction_id integer not null,
+  market_id text not null,
+  maker_fee numeric(32,18) not null,
+  taker_fee numeric(32,18) not null,
+  maker_rebate numeric(32,18) not null,
+  gas_fee numeric(32,18) not null,
+  executed_at timestamp not null,
+  created_at timestamp
+);
+create index if not exists idx_trade_revenues_transaction_id on trade_revenues (transaction_id);
+create index if not exists idx_trade_revenues_executed_at on trade_revenues (executed_at, market_id);
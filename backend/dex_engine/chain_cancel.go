@@ -0,0 +1,48 @@
+package dex_engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+)
+
+// A trader can cancel an order on chain by calling cancelOrder of the exchange, without asking the relayer.
+// The watcher sees the Cancel log, and the engine takes the available amount of the order out of the book.
+// Settlements of the order which are not mined yet revert, they are resolved by their transaction results.
+
+const EventOrderCanceledOnChain = "EVENT/EVENT_ORDER_CANCELED_ON_CHAIN"
+
+type OrderCanceledOnChainEvent struct {
+	common.Event
+	ID   string `json:"id"`
+	Hash string `json:"hash"`
+}
+
+func parseOrderCanceledOnChainEvent(eventJSON string) (*OrderCanceledOnChainEvent, error) {
+	var event OrderCanceledOnChainEvent
+	if err := json.Unmarshal([]byte(eventJSON), &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (m *MarketHandler) handleOrderCanceledOnChain(event *OrderCanceledOnChainEvent) (interface{}, error) {
+	order := models.OrderDao.FindByID(event.ID)
+	if order == nil {
+		return nil, fmt.Errorf("cannot find order with id %s", event.ID)
+	}
+
+	if order.Status != common.ORDER_PENDING || order.AvailableAmount.LessThanOrEqual(decimal.Zero) {
+		utils.Infof("order %s canceled on chain in %s has nothing to cancel, skip it", order.ID, event.Hash)
+		return order, nil
+	}
+
+	utils.Infof("order %s is canceled on chain in %s", order.ID, event.Hash)
+	err := m.cancelOrder(order, models.OrderCancelReasonCanceledOnChain)
+
+	return order, err
+}
@@ -0,0 +1,46 @@
+package dex_engine
+
+import "encoding/json"
+
+// The api attaches an eventID to every order event it pushes, so that a retried push is only processed once.
+type eventWithID struct {
+	EventID string `json:"eventID"`
+}
+
+func getEventID(eventJSON string) string {
+	var e eventWithID
+	_ = json.Unmarshal([]byte(eventJSON), &e)
+	return e.EventID
+}
+
+// eventIDSet remembers the latest processed event ids of a market.
+// When it is full, the oldest id is forgotten.
+type eventIDSet struct {
+	ids  map[string]struct{}
+	ring []string
+	next int
+}
+
+func newEventIDSet(size int) *eventIDSet {
+	return &eventIDSet{
+		ids:  make(map[string]struct{}, size),
+		ring: make([]string, size),
+	}
+}
+
+// Add returns false if the id has been added before.
+func (s *eventIDSet) Add(id string) bool {
+	if _, ok := s.ids[id]; ok {
+		return false
+	}
+
+	if oldest := s.ring[s.next]; oldest != "" {
+		delete(s.ids, oldest)
+	}
+
+	s.ring[s.next] = id
+	s.ids[id] = struct{}{}
+	s.next = (s.next + 1) % len(s.ring)
+
+	return true
+}
@@ -0,0 +1,24 @@
+package dex_engine
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEventIDSet(t *testing.T) {
+	s := newEventIDSet(2)
+
+	assert.True(t, s.Add("a"))
+	assert.False(t, s.Add("a"))
+	assert.True(t, s.Add("b"))
+
+	// "a" is the oldest one, it is forgotten when "c" comes in
+	assert.True(t, s.Add("c"))
+	assert.True(t, s.Add("a"))
+	assert.False(t, s.Add("c"))
+}
+
+func TestGetEventID(t *testing.T) {
+	assert.Equal(t, "some-id", getEventID(`{"eventType":"EVENT/NEW_ORDER","eventID":"some-id"}`))
+	assert.Equal(t, "", getEventID(`{"eventType":"EVENT/NEW_ORDER"}`))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/connection"
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/orderbook_feed"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/HydroProtocol/hydro-sdk-backend/engine"
 	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
@@ -20,6 +21,9 @@ type RedisOrderBookSnapshotHandler struct {
 }
 
 func (handler RedisOrderBookSnapshotHandler) Update(key string, bookSnapshot *common.SnapshotV2) sync.WaitGroup {
+	sequencer.updateSnapshot(key, bookSnapshot)
+	latestBookSnapshots.set(strings.TrimPrefix(key, common.GetMarketOrderbookSnapshotV2Key("")), bookSnapshot)
+
 	bts, err := json.Marshal(bookSnapshot)
 	if err != nil {
 		panic(err)
@@ -34,9 +38,9 @@ type RedisOrderBookActivitiesHandler struct {
 }
 
 func (handler RedisOrderBookActivitiesHandler) Update(webSocketMessages []common.WebSocketMessage) sync.WaitGroup {
-	for _, msg := range webSocketMessages {
+	for i, msg := range webSocketMessages {
 		if strings.HasPrefix(msg.ChannelID, "Market#") {
-			pushMessage(msg)
+			sendOrderbookChangeMessage(&webSocketMessages[i])
 		}
 	}
 
@@ -50,6 +54,7 @@ type DexEngine struct {
 	// all redis queues handlers
 	marketHandlerMap map[string]*MarketHandler
 	eventQueue       common.IQueue
+	kvStore          common.IKVStore
 
 	// Wait for all queue handler exit gracefully
 	Wg sync.WaitGroup
@@ -83,6 +88,8 @@ func NewDexEngine(ctx context.Context) *DexEngine {
 
 	// setup handler for hydro engine
 	kvStore, _ := common.InitKVStore(&common.RedisKVStoreConfig{Ctx: ctx, Client: redis})
+	InitOrderbookSequencer(kvStore, orderbook_feed.NewRedisOrderbookFeed(redis, orderbook_feed.DefaultMaxDeltas))
+
 	snapshotHandler := RedisOrderBookSnapshotHandler{kvStore: kvStore}
 	e.RegisterOrderBookSnapshotHandler(snapshotHandler)
 
@@ -92,6 +99,7 @@ func NewDexEngine(ctx context.Context) *DexEngine {
 	engine := &DexEngine{
 		ctx:              ctx,
 		eventQueue:       eventQueue,
+		kvStore:          kvStore,
 		marketHandlerMap: make(map[string]*MarketHandler),
 		Wg:               sync.WaitGroup{},
 
@@ -110,7 +118,12 @@ func NewDexEngine(ctx context.Context) *DexEngine {
 }
 
 func (e *DexEngine) newMarket(marketId string) (marketHandler *MarketHandler, err error) {
-	_, ok := e.marketHandlerMap[marketId]
+	existing, ok := e.marketHandlerMap[marketId]
+
+	if ok && existing.Stopped() {
+		delete(e.marketHandlerMap, marketId)
+		ok = false
+	}
 
 	if ok {
 		err = fmt.Errorf("open market fail, market [%s] already exist", marketId)
@@ -133,24 +146,40 @@ func (e *DexEngine) newMarket(marketId string) (marketHandler *MarketHandler, er
 		return
 	}
 
+	marketHandler.kvStore = e.kvStore
 	e.marketHandlerMap[market.ID] = marketHandler
 	utils.Infof("market %s init done", marketHandler.market.ID)
 	return
 }
 
-func (e *DexEngine) closeMarket(marketId string) {
+// closeMarket passes the close event to the market handler, so that it is processed after all queued events of the market.
+// The handler cancels the resting orders and exits by itself.
+func (e *DexEngine) closeMarket(marketId string, data []byte) {
 	_, ok := e.marketHandlerMap[marketId]
 	if !ok {
 		utils.Errorf("close market fail, market [%s] not found", marketId)
 		return
 	}
 
-	marketHandler := e.marketHandlerMap[marketId]
-	delete(e.marketHandlerMap, marketId)
-	marketHandler.Stop()
+	e.dispatch(marketId, data)
 	return
 }
 
+func (e *DexEngine) dispatch(marketId string, data []byte) {
+	marketHandler, ok := e.marketHandlerMap[marketId]
+	if !ok {
+		utils.Errorf("engine not support market [%s]", marketId)
+		return
+	}
+
+	select {
+	case marketHandler.eventChan <- data:
+	case <-marketHandler.done:
+		delete(e.marketHandlerMap, marketId)
+		utils.Errorf("market [%s] is closed", marketId)
+	}
+}
+
 func runMarket(e *DexEngine, marketHandler *MarketHandler) {
 	e.Wg.Add(1)
 
@@ -170,6 +199,8 @@ func (e *DexEngine) start() {
 		runMarket(e, marketHandler)
 	}
 
+	go e.runReconcileTimer()
+
 	go func() {
 		for {
 			select {
@@ -200,15 +231,20 @@ func (e *DexEngine) start() {
 					}
 					break
 				case common.EventCloseMarket:
-					e.closeMarket(event.MarketID)
+					e.closeMarket(event.MarketID, data)
 					break
-				default:
-					marketHandler, ok := e.marketHandlerMap[event.MarketID]
-					if !ok {
-						utils.Errorf("engine not support market [%s]", event.MarketID)
-					} else {
-						marketHandler.eventChan <- data
+				case EventReconcileMarket:
+					if event.MarketID != "" {
+						e.dispatch(event.MarketID, data)
+						break
 					}
+
+					for marketID := range e.marketHandlerMap {
+						e.dispatch(marketID, data)
+					}
+					break
+				default:
+					e.dispatch(event.MarketID, data)
 				}
 			}
 		}
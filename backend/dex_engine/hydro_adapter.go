@@ -4,6 +4,8 @@ import (
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
 	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"math/big"
 )
 
 func getHydroOrderFromModelOrder(orderJSON *models.OrderJSON) *sdk.Order {
@@ -20,6 +22,24 @@ func getHydroOrderFromModelOrder(orderJSON *models.OrderJSON) *sdk.Order {
 	)
 }
 
+// GetMatchOrderCallData returns the call data which settles the matches of the taker order with the maker orders,
+// filledAmounts are the matched amounts of the maker orders in base token.
+func GetMatchOrderCallData(takerOrder *models.Order, makerOrders []*models.Order, filledAmounts []decimal.Decimal, baseTokenDecimals int) []byte {
+	hydroTakerOrder := getHydroOrderFromModelOrder(takerOrder.GetOrderJson())
+
+	var hydroMakerOrders []*sdk.Order
+	var baseTokenFilledAmounts []*big.Int
+
+	for i, makerOrder := range makerOrders {
+		hydroMakerOrders = append(hydroMakerOrders, getHydroOrderFromModelOrder(makerOrder.GetOrderJson()))
+
+		baseTokenHugeAmt := filledAmounts[i].Mul(decimal.New(1, int32(baseTokenDecimals))).Truncate(0)
+		baseTokenFilledAmounts = append(baseTokenFilledAmounts, utils.DecimalToBigInt(baseTokenHugeAmt))
+	}
+
+	return hydroProtocol.GetMatchOrderCallData(hydroTakerOrder, hydroMakerOrders, baseTokenFilledAmounts)
+}
+
 func getHydroOrderHashHexFromOrderJson(orderJSON *models.OrderJSON) string {
 	order := sdk.NewOrderWithData(
 		orderJSON.Trader,
@@ -7,37 +7,86 @@ import (
 	"errors"
 	"fmt"
 	"github.com/HydroProtocol/hydro-sdk-backend/engine"
-	"math/big"
 	"os"
 	"runtime"
+	"strconv"
 	"time"
 
 	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
-	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	"github.com/shopspring/decimal"
 )
 
+// how many processed event ids a market handler remembers for deduplication
+const processedEventsToKeep = 10000
+
+// the gas limit of a match, used only if the launcher can't estimate the gas of the launch log
+const fallbackGasLimitPerMatch = 250000
+
 type MarketHandler struct {
 	ctx         context.Context
 	market      *models.Market
 	eventChan   chan []byte
 	hydroEngine *engine.Engine
+
+	// closing is set once the market is unpublished. A closing handler rejects new orders
+	// and only stays alive to apply the results of settlements which are already in flight.
+	closing       bool
+	closeDeadline <-chan time.Time
+
+	// copy of the orders resting in the hydro engine book, which is not accessible from outside
+	bookOrders map[string]*common.MemoryOrder
+
+	// used to save reconciliation reports, can be nil
+	kvStore common.IKVStore
+
+	// ids of the latest processed events, used to drop events which are pushed more than once
+	processedEvents *eventIDSet
+
+	// done is closed when the handler has finished closing the market and will not read eventChan any more.
+	done chan struct{}
 }
 
 // Run is synchronous, it will be improved in the later releases.
 func (m *MarketHandler) Run() {
-	for data := range m.eventChan {
-		_ = handleEvent(m, string(data))
+	defer utils.Infof("market %s stopped", m.market.ID)
+
+	for {
+		select {
+		case data, ok := <-m.eventChan:
+			if !ok {
+				return
+			}
+
+			_ = handleEvent(m, string(data))
+
+			if m.closing && (m.closeDeadline == nil || models.TransactionDao.CountPendingByMarket(m.market.ID) == 0) {
+				close(m.done)
+				return
+			}
+		case <-m.closeDeadline:
+			utils.Errorf("market %s closed with %d settlements still pending", m.market.ID, models.TransactionDao.CountPendingByMarket(m.market.ID))
+			close(m.done)
+			return
+		}
 	}
-	utils.Infof("market %s stopped", m.market.ID)
 }
 
 func (m *MarketHandler) Stop() {
 	close(m.eventChan)
 }
 
+// Stopped reports whether the handler has finished closing its market.
+func (m *MarketHandler) Stopped() bool {
+	select {
+	case <-m.done:
+		return true
+	default:
+		return false
+	}
+}
+
 // handleEvent recover any panic which is caused by event.
 // It will log event and response as well.
 func handleEvent(marketHandler *MarketHandler, eventJSON string) (err error) {
@@ -72,6 +121,14 @@ func handleEvent(marketHandler *MarketHandler, eventJSON string) (err error) {
 }
 
 func (m *MarketHandler) handleEvent(event common.Event, eventJSON string) (interface{}, error) {
+	if eventID := getEventID(eventJSON); eventID != "" && !m.processedEvents.Add(eventID) {
+		return nil, fmt.Errorf("duplicated event %s for market %s", eventID, m.market.ID)
+	}
+
+	if m.closing && event.Type == common.EventNewOrder {
+		return nil, fmt.Errorf("market %s is closed, new order rejected", m.market.ID)
+	}
+
 	switch event.Type {
 	case common.EventNewOrder:
 		var e common.NewOrderEvent
@@ -88,6 +145,39 @@ func (m *MarketHandler) handleEvent(event common.Event, eventJSON string) (inter
 		_ = json.Unmarshal([]byte(eventJSON), &e)
 		res, err := m.handleTransactionResult(&e)
 		return res, err
+	case common.EventCloseMarket:
+		res, err := m.handleCloseMarket()
+		return res, err
+	case EventSimulationFailed:
+		e, err := parseSimulationFailedEvent(eventJSON)
+		if err != nil {
+			return nil, err
+		}
+		return m.handleSimulationFailed(e)
+	case EventTransactionRemoved:
+		e, err := parseTransactionRemovedEvent(eventJSON)
+		if err != nil {
+			return nil, err
+		}
+		return m.handleTransactionRemoved(e)
+	case EventOrderCanceledOnChain:
+		e, err := parseOrderCanceledOnChainEvent(eventJSON)
+		if err != nil {
+			return nil, err
+		}
+		return m.handleOrderCanceledOnChain(e)
+	case EventOrderUnfunded:
+		e, err := parseOrderUnfundedEvent(eventJSON)
+		if err != nil {
+			return nil, err
+		}
+		return m.handleOrderUnfunded(e)
+	case EventReconcileMarket:
+		e, err := parseReconcileMarketEvent(eventJSON)
+		if err != nil {
+			return nil, err
+		}
+		return m.handleReconcile(e)
 	default:
 		return nil, fmt.Errorf("unsupport event for market %s %s", m.market.ID, eventJSON)
 	}
@@ -98,22 +188,55 @@ func (m MarketHandler) handleNewOrder(event *common.NewOrderEvent) (transaction
 	var eventOrder models.Order
 	_ = json.Unmarshal([]byte(eventOrderString), &eventOrder)
 
+	// the same signed order may be posted more than once, it must not be matched twice
+	if models.OrderDao.FindByID(eventOrder.ID) != nil {
+		utils.Errorf("order %s already exists, skip it", eventOrder.ID)
+		return
+	}
+
+	if eventOrder.ClientOrderID != "" && models.OrderDao.FindByClientOrderID(eventOrder.TraderAddress, eventOrder.ClientOrderID) != nil {
+		utils.Errorf("client order id %s of %s already exists, skip order %s", eventOrder.ClientOrderID, eventOrder.TraderAddress, eventOrder.ID)
+		return
+	}
+
+	utils.Debugf("%s NEW_ORDER  price: %s amount: %s %4s", event.MarketID, eventOrder.Price.StringFixed(5), eventOrder.Amount.StringFixed(5), eventOrder.Side)
+
+	transaction, launchLog = m.matchOrder(&eventOrder, eventOrder.Amount)
+
+	_ = InsertOrder(&eventOrder)
+
+	return transaction, launchLog
+}
+
+// matchOrder matches the amount of an order against the book, the rest of the amount is put into the book.
+// It saves the maker orders and the trades, the order itself is saved by the caller.
+func (m MarketHandler) matchOrder(eventOrder *models.Order, amount decimal.Decimal) (transaction *models.Transaction, launchLog *models.LaunchLog) {
 	eventMemoryOrder := &common.MemoryOrder{
 		ID:           eventOrder.ID,
 		MarketID:     eventOrder.MarketID,
 		Price:        eventOrder.Price,
-		Amount:       eventOrder.Amount,
+		Amount:       amount,
 		Side:         eventOrder.Side,
 		GasFeeAmount: eventOrder.GasFeeAmount,
 		MakerFeeRate: eventOrder.MakerFeeRate,
 		TakerFeeRate: eventOrder.TakerFeeRate,
 	}
 
-	utils.Debugf("%s NEW_ORDER  price: %s amount: %s %4s", event.MarketID, eventOrder.Price.StringFixed(5), eventOrder.Amount.StringFixed(5), eventOrder.Side)
-
 	matchResult, hasMatch := m.hydroEngine.HandleNewOrder(eventMemoryOrder)
+
+	for _, item := range matchResult.MatchItems {
+		if item.MakerOrderIsDone {
+			delete(m.bookOrders, item.MakerOrder.ID)
+		} else {
+			m.setBookOrder(item.MakerOrder)
+		}
+	}
+
+	if !matchResult.TakerOrderIsDone {
+		m.setBookOrder(eventMemoryOrder)
+	}
 	if hasMatch {
-		resultWithOrders := NewMatchResultWithOrders(&eventOrder, &matchResult)
+		resultWithOrders := NewMatchResultWithOrders(eventOrder, &matchResult)
 
 		for i := range resultWithOrders.MatchItems {
 			item := resultWithOrders.MatchItems[i]
@@ -155,8 +278,6 @@ func (m MarketHandler) handleNewOrder(event *common.NewOrderEvent) (transaction
 		}
 	}
 
-	_ = InsertOrder(&eventOrder)
-
 	return transaction, launchLog
 }
 
@@ -164,15 +285,12 @@ func (m MarketHandler) handleNewOrder(event *common.NewOrderEvent) (transaction
 // Will separate the matches into different transactions in another  release.
 func processTransactionAndLaunchLog(matchResult *MatchResultWithOrders) (*models.Transaction, *models.LaunchLog) {
 	takerOrder := matchResult.modelTakerOrder
-	hydroTakerOrder := getHydroOrderFromModelOrder(takerOrder.GetOrderJson())
 
-	var hydroMakerOrders []*sdk.Order
-	var baseTokenFilledAmounts []*big.Int
+	var makerOrders []*models.Order
+	var filledAmounts []decimal.Decimal
 
 	market := models.MarketDao.FindMarketByID(takerOrder.MarketID)
 
-	baseTokenDecimal := market.BaseTokenDecimals
-
 	for _, item := range matchResult.MatchItems {
 		if item.MatchShouldBeCanceled {
 			//skip if match should be canceled
@@ -180,13 +298,8 @@ func processTransactionAndLaunchLog(matchResult *MatchResultWithOrders) (*models
 		}
 
 		modelMakerOrder := matchResult.modelMakerOrders[item.MakerOrder.ID]
-
-		hydroMakerOrder := getHydroOrderFromModelOrder(modelMakerOrder.GetOrderJson())
-		hydroMakerOrders = append(hydroMakerOrders, hydroMakerOrder)
-
-		baseTokenHugeAmt := item.MatchedAmount.Mul(decimal.New(1, int32(baseTokenDecimal))).Truncate(0)
-		baseTokenFilledAmt := utils.DecimalToBigInt(baseTokenHugeAmt)
-		baseTokenFilledAmounts = append(baseTokenFilledAmounts, baseTokenFilledAmt)
+		makerOrders = append(makerOrders, modelMakerOrder)
+		filledAmounts = append(filledAmounts, item.MatchedAmount)
 
 		_ = UpdateOrder(modelMakerOrder)
 	}
@@ -208,14 +321,14 @@ func processTransactionAndLaunchLog(matchResult *MatchResultWithOrders) (*models
 	}
 
 	launchLog := &models.LaunchLog{
-		ItemType:  "hydroTrade",
+		ItemType:  models.LaunchLogItemTypeTrade,
 		ItemID:    transaction.ID,
 		Status:    "created",
 		From:      os.Getenv("HSK_RELAYER_ADDRESS"),
 		To:        os.Getenv("HSK_HYBRID_EXCHANGE_ADDRESS"),
 		Value:     decimal.Zero,
-		GasLimit:  int64(len(matchResult.MatchItems) * 250000),
-		Data:      utils.Bytes2HexP(hydroProtocol.GetMatchOrderCallData(hydroTakerOrder, hydroMakerOrders, baseTokenFilledAmounts)),
+		GasLimit:  int64(len(makerOrders) * fallbackGasLimitPerMatch),
+		Data:      utils.Bytes2HexP(GetMatchOrderCallData(takerOrder, makerOrders, filledAmounts, market.BaseTokenDecimals)),
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
 	}
@@ -262,6 +375,19 @@ func (m *MarketHandler) handleCancelOrder(event *common.CancelOrderEvent) (inter
 		return nil, errors.New(fmt.Sprintf("cannot find order with id %s", event.ID))
 	}
 
+	if order.Status != common.ORDER_PENDING || order.AvailableAmount.LessThanOrEqual(decimal.Zero) {
+		utils.Infof("order %s has nothing to cancel, skip it", order.ID)
+		return order, nil
+	}
+
+	err := m.cancelOrder(order, "")
+
+	return order, err
+}
+
+// cancelOrder removes the available amount of an order from the order book and marks it as canceled.
+// Amounts waiting for settlement are left untouched, they are resolved by the transaction result.
+func (m *MarketHandler) cancelOrder(order *models.Order, reason string) error {
 	bookOrder := &common.MemoryOrder{
 		MarketID: m.market.ID,
 		ID:       order.ID,
@@ -271,21 +397,65 @@ func (m *MarketHandler) handleCancelOrder(event *common.CancelOrderEvent) (inter
 	}
 	msg, success := m.hydroEngine.HandleCancelOrder(bookOrder)
 	if success {
-		pushMessage(msg)
+		sendOrderbookChangeMessage(msg)
 	}
+	delete(m.bookOrders, order.ID)
 
 	order.CanceledAmount = order.CanceledAmount.Add(order.AvailableAmount)
 	order.AvailableAmount = decimal.Zero
+	order.CancelReason = reason
 	order.AutoSetStatusByAmounts()
 
-	err := UpdateOrder(order)
+	return UpdateOrder(order)
+}
 
-	return order, err
+// handleCloseMarket cancels all resting orders of a unpublished market, which releases their locked balances.
+// If HSK_MARKET_CLOSE_WAIT_SECONDS is set, the handler keeps running until the pending settlements of the market
+// are confirmed or the timeout is reached, otherwise it stops right away.
+func (m *MarketHandler) handleCloseMarket() (interface{}, error) {
+	m.closing = true
+
+	orders := models.OrderDao.FindMarketPendingOrders(m.market.ID)
+	var canceledOrders []*models.Order
+
+	for _, order := range orders {
+		if order.AvailableAmount.LessThanOrEqual(decimal.Zero) {
+			continue
+		}
+
+		if err := m.cancelOrder(order, models.OrderCancelReasonMarketClosed); err != nil {
+			return canceledOrders, err
+		}
+
+		canceledOrders = append(canceledOrders, order)
+	}
+
+	utils.Infof("market %s closed, %d orders canceled", m.market.ID, len(canceledOrders))
+
+	if wait := getMarketCloseWait(); wait > 0 {
+		m.closeDeadline = time.After(wait)
+	}
+
+	return canceledOrders, nil
 }
 
+func getMarketCloseWait() time.Duration {
+	seconds, _ := strconv.Atoi(os.Getenv("HSK_MARKET_CLOSE_WAIT_SECONDS"))
+	return time.Duration(seconds) * time.Second
+}
+
+// handleTransactionResult applies a mined or finalized transaction to its trades and orders.
+// A mined transaction only marks its trades, the amounts of the orders are confirmed when it is finalized.
 func (m *MarketHandler) handleTransactionResult(event *common.ConfirmTransactionEvent) (interface{}, error) {
 	executedAt := time.Unix(int64(event.Timestamp), 0)
 	transaction := models.TransactionDao.FindTransactionByHash(event.Hash)
+
+	// the watcher may finalize a transaction again before this one is applied
+	if transaction.Status == common.STATUS_SUCCESSFUL || transaction.Status == common.STATUS_FAILED {
+		utils.Infof("transaction %d is %s already, skip %s", transaction.ID, transaction.Status, event.Status)
+		return nil, nil
+	}
+
 	transaction.Status = event.Status
 	transaction.ExecutedAt = executedAt
 	_ = models.TransactionDao.UpdateTransaction(transaction)
@@ -293,6 +463,18 @@ func (m *MarketHandler) handleTransactionResult(event *common.ConfirmTransaction
 	_ = models.LaunchLogDao.UpdateLaunchLogsStatusByItemID(event.Status, transaction.ID)
 
 	trades := models.TradeDao.FindTradesByHash(event.Hash)
+
+	if event.Status == models.TradeStatusMined {
+		for _, trade := range trades {
+			previousStatus := trade.Status
+			trade.Status = event.Status
+			trade.ExecutedAt = executedAt
+			_ = UpdateTrade(trade, previousStatus)
+		}
+
+		return nil, nil
+	}
+
 	takerOrder := models.OrderDao.FindByID(trades[0].TakerOrderID)
 
 	for _, trade := range trades {
@@ -312,9 +494,10 @@ func (m *MarketHandler) handleTransactionResult(event *common.ConfirmTransaction
 		makerOrder.AutoSetStatusByAmounts()
 		_ = UpdateOrder(makerOrder)
 
+		previousStatus := trade.Status
 		trade.Status = event.Status
 		trade.ExecutedAt = time.Unix(int64(event.Timestamp), 0)
-		_ = UpdateTrade(trade)
+		_ = UpdateTrade(trade, previousStatus)
 	}
 
 	takerOrder.AutoSetStatusByAmounts()
@@ -326,6 +509,9 @@ func (m *MarketHandler) handleTransactionResult(event *common.ConfirmTransaction
 func NewMarketHandler(ctx context.Context, market *models.Market, engine *engine.Engine) (*MarketHandler, error) {
 	orders := models.OrderDao.FindMarketPendingOrders(market.ID)
 
+	sequencer.openMarket(market.ID)
+	bookOrders := make(map[string]*common.MemoryOrder)
+
 	// re-insert available orders into HydroEngine
 	for _, order := range orders {
 		if order.AvailableAmount.LessThanOrEqual(decimal.Zero) {
@@ -339,31 +525,23 @@ func NewMarketHandler(ctx context.Context, market *models.Market, engine *engine
 			Amount:   order.AvailableAmount,
 			Side:     order.Side,
 		}
-		msg := engine.ReInsertOrder(&bookOrder)
-		pushMessage(msg)
+		engine.ReInsertOrder(&bookOrder)
+		bookOrders[order.ID] = &bookOrder
 	}
 
+	sequencer.marketOpened(market.ID)
+
 	marketHandler := MarketHandler{
 		market:    market,
 		eventChan: make(chan []byte),
+		done:      make(chan struct{}),
 		ctx:       ctx,
 
+		bookOrders:      bookOrders,
+		processedEvents: newEventIDSet(processedEventsToKeep),
+
 		hydroEngine: engine,
 	}
 
-	//// todo if Load Snapshot is necessary
-	//res, err := kvStore.Get(common.GetMarketOrderbookSnapshotV2Key(market.ID))
-	//if err == common.KVStoreEmpty {
-	//	// do nothing
-	//} else if err != nil {
-	//	panic(fmt.Errorf("get snapshot error %v", err))
-	//}
-	//
-	//var snapshot struct {
-	//	Sequence uint64 `json:"sequence"`
-	//}
-	//
-	//_ = json.Unmarshal([]byte(res), &snapshot)
-
 	return &marketHandler, nil
 }
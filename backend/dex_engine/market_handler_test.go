@@ -80,6 +80,9 @@ func (s *marketHandlerSuite) SetupTest() {
 	s.marketHandler = marketHandler
 
 	s.marketHandler.hydroEngine.RegisterOrderBookActivitiesHandler(RedisOrderBookActivitiesHandler{})
+
+	latestBookSnapshots = &bookSnapshots{snapshots: make(map[string]*common.SnapshotV2)}
+	s.marketHandler.hydroEngine.RegisterOrderBookSnapshotHandler(RedisOrderBookSnapshotHandler{kvStore: kvStore})
 }
 
 func (s *marketHandlerSuite) TearDownTest() {
@@ -111,6 +114,133 @@ func (s *marketHandlerSuite) TestHandleNewOrder() {
 	//s.Equal("140", s.marketHandler.orderbook.MaxBid().String())
 }
 
+func (s *marketHandlerSuite) TestHandleDuplicatedEvents() {
+	order := newModelOrder("buy", decimal.New(100, 0), decimal.New(10, 0))
+	eventJSON := `{"eventType":"` + common.EventNewOrder + `","eventID":"event-1","order":` + utils.ToJsonString(utils.ToJsonString(order)) + `}`
+
+	s.AssertChange(func() {
+		_ = handleEvent(s.marketHandler, eventJSON)
+		_ = handleEvent(s.marketHandler, eventJSON)
+	}, func() int {
+		return models.OrderDao.Count()
+	}, 1)
+
+	// the same order in a different event is skipped as well
+	s.AssertChange(func() {
+		_, _ = s.marketHandler.handleNewOrder(&common.NewOrderEvent{Order: utils.ToJsonString(order)})
+	}, func() int {
+		return models.OrderDao.Count()
+	}, 0)
+
+	// a second order with the same client order id is skipped
+	_, _ = s.marketHandler.handleNewOrder(&common.NewOrderEvent{Order: utils.ToJsonString(newModelOrderWithClientID("client-1"))})
+	s.AssertChange(func() {
+		_, _ = s.marketHandler.handleNewOrder(&common.NewOrderEvent{Order: utils.ToJsonString(newModelOrderWithClientID("client-1"))})
+	}, func() int {
+		return models.OrderDao.Count()
+	}, 0)
+}
+
+func newModelOrderWithClientID(clientOrderID string) *models.Order {
+	order := newModelOrder("buy", decimal.New(100, 0), decimal.New(1, 0))
+	order.ClientOrderID = clientOrderID
+	return order
+}
+
+func (s *marketHandlerSuite) TestReconcile() {
+	buyOrder := newModelOrder("buy", decimal.New(100, 0), decimal.New(10, 0))
+	_, _ = s.marketHandler.handleNewOrder(&common.NewOrderEvent{Order: utils.ToJsonString(buyOrder)})
+
+	sellOrder := newModelOrder("sell", decimal.New(120, 0), decimal.New(5, 0))
+	_, _ = s.marketHandler.handleNewOrder(&common.NewOrderEvent{Order: utils.ToJsonString(sellOrder)})
+
+	report, err := s.marketHandler.handleReconcile(&ReconcileMarketEvent{})
+	s.Nil(err)
+	s.Equal(0, len(report.Discrepancies))
+
+	// database drifts away from the book
+	dbOrder := models.OrderDao.FindByID(buyOrder.ID)
+	dbOrder.AvailableAmount = decimal.New(4, 0)
+	dbOrder.CanceledAmount = decimal.New(6, 0)
+	_ = models.OrderDao.UpdateOrder(dbOrder)
+
+	report, _ = s.marketHandler.handleReconcile(&ReconcileMarketEvent{})
+	s.Equal(1, len(report.Discrepancies))
+	s.Equal(buyOrder.ID, report.Discrepancies[0].OrderID)
+	s.Equal("10", report.Discrepancies[0].BookAmount.String())
+	s.Equal("4", report.Discrepancies[0].DBAmount.String())
+	s.False(report.Discrepancies[0].Repaired)
+
+	// fix the book with database
+	report, _ = s.marketHandler.handleReconcile(&ReconcileMarketEvent{Repair: ReconcileRepairBook})
+	s.True(report.Discrepancies[0].Repaired)
+	s.Equal("4", s.marketHandler.bookOrders[buyOrder.ID].Amount.String())
+
+	report, _ = s.marketHandler.handleReconcile(&ReconcileMarketEvent{})
+	s.Equal(0, len(report.Discrepancies))
+
+	// fix database with the book
+	dbOrder = models.OrderDao.FindByID(sellOrder.ID)
+	dbOrder.AvailableAmount = decimal.Zero
+	dbOrder.CanceledAmount = dbOrder.Amount
+	dbOrder.Status = common.ORDER_CANCELED
+	_ = models.OrderDao.UpdateOrder(dbOrder)
+
+	report, _ = s.marketHandler.handleReconcile(&ReconcileMarketEvent{Repair: ReconcileRepairDB})
+	s.Equal(1, len(report.Discrepancies))
+	s.True(report.Discrepancies[0].Repaired)
+
+	dbOrder = models.OrderDao.FindByID(sellOrder.ID)
+	s.Equal(common.ORDER_PENDING, dbOrder.Status)
+	s.Equal("5", dbOrder.AvailableAmount.String())
+	s.True(dbOrder.CanceledAmount.IsZero())
+
+	_, err = s.marketHandler.handleReconcile(&ReconcileMarketEvent{Repair: "unknown"})
+	s.NotNil(err)
+
+	// the sdk engine drifts away from the copy of the handler, only the price level can tell
+	s.marketHandler.hydroEngine.HandleCancelOrder(&common.MemoryOrder{ID: buyOrder.ID, MarketID: buyOrder.MarketID, Side: "buy", Price: buyOrder.Price, Amount: decimal.New(4, 0)})
+
+	report, _ = s.marketHandler.handleReconcile(&ReconcileMarketEvent{})
+	s.Equal(0, len(report.Discrepancies))
+	s.Equal(1, len(report.LevelDiscrepancies))
+	s.Equal("buy", report.LevelDiscrepancies[0].Side)
+	s.Equal("100", report.LevelDiscrepancies[0].Price.String())
+	s.Equal("0", report.LevelDiscrepancies[0].BookAmount.String())
+	s.Equal("4", report.LevelDiscrepancies[0].DBAmount.String())
+}
+
+func (s *marketHandlerSuite) TestHandleCloseMarket() {
+	buyOrder := newModelOrder("buy", decimal.New(100, 0), decimal.New(10, 0))
+	_, _ = s.marketHandler.handleNewOrder(&common.NewOrderEvent{Order: utils.ToJsonString(buyOrder)})
+
+	sellOrder := newModelOrder("sell", decimal.New(120, 0), decimal.New(5, 0))
+	_, _ = s.marketHandler.handleNewOrder(&common.NewOrderEvent{Order: utils.ToJsonString(sellOrder)})
+
+	_, err := s.marketHandler.handleEvent(common.Event{Type: common.EventCloseMarket, MarketID: s.marketHandler.market.ID}, "")
+	s.Nil(err)
+	s.True(s.marketHandler.closing)
+
+	for _, id := range []string{buyOrder.ID, sellOrder.ID} {
+		order := models.OrderDao.FindByID(id)
+		s.Equal(common.ORDER_CANCELED, order.Status)
+		s.Equal(models.OrderCancelReasonMarketClosed, order.CancelReason)
+		s.True(order.AvailableAmount.IsZero())
+		s.Equal(order.Amount.String(), order.CanceledAmount.String())
+	}
+
+	s.Equal(0, len(models.OrderDao.FindMarketPendingOrders(s.marketHandler.market.ID)))
+
+	// new orders are rejected once the market is closing
+	newOrder := newModelOrder("buy", decimal.New(100, 0), decimal.New(1, 0))
+	s.AssertChange(func() {
+		_, err = s.marketHandler.handleEvent(common.Event{Type: common.EventNewOrder}, utils.ToJsonString(&common.NewOrderEvent{Order: utils.ToJsonString(newOrder)}))
+		s.NotNil(err)
+	}, func() int {
+		return models.OrderDao.Count()
+	}, 0)
+}
+
 type batchMatchOrdersTest struct {
 	takerOrderParams          *buildOrderParams
 	makerOrdersParams         []*buildOrderParams
@@ -835,6 +965,242 @@ func (s *marketHandlerSuite) assertOrderAmounts(available, pending, confirmed, c
 	s.Equal(canceled, order.CanceledAmount.String(), "Canceled Amount not match")
 }
 
+// a settlement mined first and finalized later confirms the amounts of the orders once
+func (s *marketHandlerSuite) TestHandleMinedThenSuccessful() {
+	b := &batchMatchOrdersTest{
+		takerOrderParams:          &buildOrderParams{"sell", "140", "100"},
+		makerOrdersParams:         []*buildOrderParams{{"buy", "140", "140"}},
+		expectedTradesCount:       1,
+		expectedTransactionsCount: 1,
+	}
+	b.Reset()
+
+	_, launchLog := s.batchNewOrderTestPendingPart(b)
+	launchLog.Hash = sql.NullString{String: "fake-mined", Valid: true}
+	models.UpdateLaunchLogToPending(launchLog)
+
+	queue := wsQueue.(*common.MockQueue)
+	queue.ResetBuffer()
+
+	confirm := func(status string) {
+		_, _ = s.marketHandler.handleTransactionResult(&common.ConfirmTransactionEvent{Hash: "fake-mined", Status: status})
+	}
+
+	// mined, the amounts stay pending and the trade is published
+	confirm(models.TradeStatusMined)
+	s.assertOrderAmounts("0", "100", "0", "0", models.OrderDao.FindByID(b.takerOrder.ID))
+	s.assertOrderAmounts("40", "100", "0", "0", models.OrderDao.FindByID(b.makerOrders[0].ID))
+	s.Equal(models.TradeStatusMined, models.TradeDao.FindTradesByHash("fake-mined")[0].Status)
+	s.Equal(1, countMarketTradeMessages(queue))
+
+	// finalized, the amounts are confirmed and the trade is not published again
+	confirm(common.STATUS_SUCCESSFUL)
+	s.assertExpectedResult(b, &expectedResult{
+		expectedAmounts: [][]string{{"0", "0", "100", "0"}, {"40", "0", "100", "0"}},
+		expectedStatus:  []string{common.ORDER_FULL_FILLED, common.ORDER_PENDING},
+	})
+	s.Equal(common.STATUS_SUCCESSFUL, models.TradeDao.FindTradesByHash("fake-mined")[0].Status)
+	s.Equal(0, countMarketTradeMessages(queue))
+
+	// finalized again before the first one was applied, it is skipped
+	confirm(common.STATUS_SUCCESSFUL)
+	s.assertOrderAmounts("0", "0", "100", "0", models.OrderDao.FindByID(b.takerOrder.ID))
+	s.assertOrderAmounts("40", "0", "100", "0", models.OrderDao.FindByID(b.makerOrders[0].ID))
+}
+
+// a settlement whose block is removed is pending again, and is confirmed again when it is mined in another block
+func (s *marketHandlerSuite) TestHandleTransactionRemoved() {
+	b := &batchMatchOrdersTest{
+		takerOrderParams:          &buildOrderParams{"sell", "140", "100"},
+		makerOrdersParams:         []*buildOrderParams{{"buy", "140", "140"}},
+		expectedTradesCount:       1,
+		expectedTransactionsCount: 1,
+	}
+
+	removeAndConfirm := func(hash string, status string) {
+		b.Reset()
+		_, launchLog := s.batchNewOrderTestPendingPart(b)
+		launchLog.Hash = sql.NullString{String: hash, Valid: true}
+		models.UpdateLaunchLogToPending(launchLog)
+
+		_, _ = s.marketHandler.handleTransactionResult(&common.ConfirmTransactionEvent{Hash: hash, Status: status})
+		takerOrder := models.OrderDao.FindByID(b.takerOrder.ID)
+		makerOrder := models.OrderDao.FindByID(b.makerOrders[0].ID)
+
+		_, err := s.marketHandler.handleTransactionRemoved(&TransactionRemovedEvent{Hash: hash})
+		s.Nil(err)
+
+		// the amounts are pending as before the confirmation
+		s.assertOrderAmounts("0", "100", "0", "0", models.OrderDao.FindByID(b.takerOrder.ID))
+		s.assertOrderAmounts("40", "100", "0", "0", models.OrderDao.FindByID(b.makerOrders[0].ID))
+		s.Equal(common.ORDER_PENDING, models.OrderDao.FindByID(b.takerOrder.ID).Status)
+		s.Equal(common.STATUS_PENDING, models.TransactionDao.FindTransactionByHash(hash).Status)
+		s.Equal(common.STATUS_PENDING, models.TradeDao.FindTradesByHash(hash)[0].Status)
+		s.Equal(models.LaunchLogStatusOrphaned, models.LaunchLogDao.FindLaunchLogByID(int(launchLog.ID)).Status)
+
+		// a second removal is skipped
+		_, _ = s.marketHandler.handleTransactionRemoved(&TransactionRemovedEvent{Hash: hash})
+		s.assertOrderAmounts("0", "100", "0", "0", models.OrderDao.FindByID(b.takerOrder.ID))
+
+		// confirmed again in another block, the amounts are the same as after the first confirmation
+		_, _ = s.marketHandler.handleTransactionResult(&common.ConfirmTransactionEvent{Hash: hash, Status: status})
+		s.Equal(takerOrder.Status, models.OrderDao.FindByID(b.takerOrder.ID).Status)
+		s.assertOrderAmounts(takerOrder.AvailableAmount.String(), takerOrder.PendingAmount.String(), takerOrder.ConfirmedAmount.String(), takerOrder.CanceledAmount.String(), models.OrderDao.FindByID(b.takerOrder.ID))
+		s.assertOrderAmounts(makerOrder.AvailableAmount.String(), makerOrder.PendingAmount.String(), makerOrder.ConfirmedAmount.String(), makerOrder.CanceledAmount.String(), models.OrderDao.FindByID(b.makerOrders[0].ID))
+		s.Equal(status, models.TradeDao.FindTradesByHash(hash)[0].Status)
+	}
+
+	removeAndConfirm("fake-removed-success", common.STATUS_SUCCESSFUL)
+	s.assertOrderAmounts("0", "0", "100", "0", models.OrderDao.FindByID(b.takerOrder.ID))
+	s.assertOrderAmounts("40", "0", "100", "0", models.OrderDao.FindByID(b.makerOrders[0].ID))
+
+	s.SetupTest()
+	removeAndConfirm("fake-removed-failed", common.STATUS_FAILED)
+	s.assertOrderAmounts("0", "0", "0", "100", models.OrderDao.FindByID(b.takerOrder.ID))
+	s.assertOrderAmounts("40", "0", "0", "100", models.OrderDao.FindByID(b.makerOrders[0].ID))
+}
+
+// the taker order sells 100 to a maker order of 60 at 141, and 40 of a maker order of 60 at 140
+func (s *marketHandlerSuite) newSimulationFailedTest() (*batchMatchOrdersTest, string) {
+	b := &batchMatchOrdersTest{
+		takerOrderParams:          &buildOrderParams{"sell", "140", "100"},
+		makerOrdersParams:         []*buildOrderParams{{"buy", "141", "60"}, {"buy", "140", "60"}},
+		expectedTradesCount:       2,
+		expectedTransactionsCount: 1,
+	}
+	b.Reset()
+
+	_, launchLog := s.batchNewOrderTestPendingPart(b)
+	_, _ = models.UpdateLaunchLogToFailed(launchLog, models.LaunchLogStatusSimulationFailed)
+
+	return b, launchLog.Hash.String
+}
+
+func (s *marketHandlerSuite) TestHandleSimulationFailedBlamingMaker() {
+	b, hash := s.newSimulationFailedTest()
+
+	_, err := s.marketHandler.handleSimulationFailed(&SimulationFailedEvent{Hash: hash, BadOrderIDs: []string{b.makerOrders[0].ID}})
+	s.Nil(err)
+
+	// the blamed maker order is canceled, the taker order is matched again with the other one
+	badOrder := models.OrderDao.FindByID(b.makerOrders[0].ID)
+	s.assertOrderAmounts("0", "0", "0", "60", badOrder)
+	s.Equal(models.OrderCancelReasonSimulationFailed, badOrder.CancelReason)
+	s.assertOrderAmounts("40", "60", "0", "0", models.OrderDao.FindByID(b.takerOrder.ID))
+	s.assertOrderAmounts("0", "60", "0", "0", models.OrderDao.FindByID(b.makerOrders[1].ID))
+
+	s.Equal(common.STATUS_FAILED, models.TransactionDao.FindTransactionByHash(hash).Status)
+	for _, trade := range models.TradeDao.FindTradesByHash(hash) {
+		s.Equal(common.STATUS_FAILED, trade.Status)
+	}
+
+	launchLogs := models.LaunchLogDao.FindAllCreated()
+	s.Equal(1, len(launchLogs))
+
+	trades := models.TradeDao.FindTradeByTransactionID(launchLogs[0].ItemID)
+	s.Equal(1, len(trades))
+	s.Equal(b.takerOrder.ID, trades[0].TakerOrderID)
+	s.Equal(b.makerOrders[1].ID, trades[0].MakerOrderID)
+	s.Equal("60", trades[0].Amount.String())
+}
+
+func (s *marketHandlerSuite) TestHandleSimulationFailedBlamingTaker() {
+	b, hash := s.newSimulationFailedTest()
+
+	_, err := s.marketHandler.handleSimulationFailed(&SimulationFailedEvent{Hash: hash, BadOrderIDs: []string{b.takerOrder.ID}})
+	s.Nil(err)
+
+	// the blamed taker order is canceled, the maker orders rest in the book again
+	badOrder := models.OrderDao.FindByID(b.takerOrder.ID)
+	s.assertOrderAmounts("0", "0", "0", "100", badOrder)
+	s.Equal(models.OrderCancelReasonSimulationFailed, badOrder.CancelReason)
+	s.assertOrderAmounts("60", "0", "0", "0", models.OrderDao.FindByID(b.makerOrders[0].ID))
+	s.assertOrderAmounts("60", "0", "0", "0", models.OrderDao.FindByID(b.makerOrders[1].ID))
+	s.Equal("60", s.marketHandler.bookOrders[b.makerOrders[0].ID].Amount.String())
+	s.Equal("60", s.marketHandler.bookOrders[b.makerOrders[1].ID].Amount.String())
+
+	s.Equal(0, len(models.LaunchLogDao.FindAllCreated()))
+}
+
+func (s *marketHandlerSuite) TestHandleSimulationFailedAfterCancel() {
+	b, hash := s.newSimulationFailedTest()
+
+	// the trader cancels the rest of the second maker order before the simulation fails
+	_, _ = s.marketHandler.handleCancelOrder(&common.CancelOrderEvent{ID: b.makerOrders[1].ID})
+	s.assertOrderAmounts("0", "40", "0", "20", models.OrderDao.FindByID(b.makerOrders[1].ID))
+
+	_, err := s.marketHandler.handleSimulationFailed(&SimulationFailedEvent{Hash: hash, BadOrderIDs: []string{b.makerOrders[0].ID}})
+	s.Nil(err)
+
+	// the canceled order isn't put back, its released amount is canceled too
+	canceledOrder := models.OrderDao.FindByID(b.makerOrders[1].ID)
+	s.assertOrderAmounts("0", "0", "0", "60", canceledOrder)
+	s.Equal(common.ORDER_CANCELED, canceledOrder.Status)
+	s.Nil(s.marketHandler.bookOrders[b.makerOrders[1].ID])
+
+	// nothing is left to match, the taker order rests in the book
+	s.assertOrderAmounts("100", "0", "0", "0", models.OrderDao.FindByID(b.takerOrder.ID))
+	s.Equal("100", s.marketHandler.bookOrders[b.takerOrder.ID].Amount.String())
+	s.Equal(0, len(models.LaunchLogDao.FindAllCreated()))
+}
+
+func (s *marketHandlerSuite) TestHandleOrderUnfunded() {
+	order := newModelOrder("buy", decimal.New(100, 0), decimal.New(10, 0))
+	_, _ = s.marketHandler.handleNewOrder(&common.NewOrderEvent{Order: utils.ToJsonString(order)})
+
+	// a part of the order is unfunded, it is taken out of the book
+	_, err := s.marketHandler.handleOrderUnfunded(&OrderUnfundedEvent{ID: order.ID, Amount: decimal.New(4, 0)})
+	s.Nil(err)
+
+	dbOrder := models.OrderDao.FindByID(order.ID)
+	s.assertOrderAmounts("6", "0", "0", "4", dbOrder)
+	s.Equal(common.ORDER_PENDING, dbOrder.Status)
+	s.Equal(models.OrderCancelReasonUnfunded, dbOrder.CancelReason)
+	s.Equal("6", s.marketHandler.bookOrders[order.ID].Amount.String())
+
+	// more than the rest is unfunded, the order is canceled
+	_, err = s.marketHandler.handleOrderUnfunded(&OrderUnfundedEvent{ID: order.ID, Amount: decimal.New(8, 0)})
+	s.Nil(err)
+
+	dbOrder = models.OrderDao.FindByID(order.ID)
+	s.assertOrderAmounts("0", "0", "0", "10", dbOrder)
+	s.Equal(common.ORDER_CANCELED, dbOrder.Status)
+	s.Nil(s.marketHandler.bookOrders[order.ID])
+
+	// nothing is left to cancel
+	_, err = s.marketHandler.handleOrderUnfunded(&OrderUnfundedEvent{ID: order.ID, Amount: decimal.New(1, 0)})
+	s.Nil(err)
+	s.assertOrderAmounts("0", "0", "0", "10", models.OrderDao.FindByID(order.ID))
+}
+
+func (s *marketHandlerSuite) TestHandleOrderCanceledOnChain() {
+	b := &batchMatchOrdersTest{
+		takerOrderParams:          &buildOrderParams{"sell", "100", "4"},
+		makerOrdersParams:         []*buildOrderParams{{"buy", "100", "10"}},
+		expectedTradesCount:       1,
+		expectedTransactionsCount: 1,
+	}
+	b.Reset()
+	s.batchNewOrderTestPendingPart(b)
+
+	makerOrderID := b.makerOrders[0].ID
+	s.Equal("6", s.marketHandler.bookOrders[makerOrderID].Amount.String())
+
+	// the rest of the order is taken out of the book, the pending amount waits for its settlement
+	_, err := s.marketHandler.handleOrderCanceledOnChain(&OrderCanceledOnChainEvent{ID: makerOrderID, Hash: "fake-cancel"})
+	s.Nil(err)
+
+	dbOrder := models.OrderDao.FindByID(makerOrderID)
+	s.assertOrderAmounts("0", "4", "0", "6", dbOrder)
+	s.Equal(models.OrderCancelReasonCanceledOnChain, dbOrder.CancelReason)
+	s.Nil(s.marketHandler.bookOrders[makerOrderID])
+
+	// the same Cancel log seen again changes nothing
+	_, err = s.marketHandler.handleOrderCanceledOnChain(&OrderCanceledOnChainEvent{ID: makerOrderID, Hash: "fake-cancel"})
+	s.Nil(err)
+	s.assertOrderAmounts("0", "4", "0", "6", models.OrderDao.FindByID(makerOrderID))
+}
+
 func (s *marketHandlerSuite) TestCancelOrder() {
 	order1 := newModelOrder("buy", utils.StringToDecimal("0.02"), utils.StringToDecimal("10"))
 	_ = models.OrderDao.InsertOrder(order1)
@@ -0,0 +1,115 @@
+package dex_engine
+
+import (
+	"encoding/json"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/orderbook_feed"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"strings"
+	"sync"
+)
+
+// The sequence of a sdk order book starts from 0 every time the engine starts.
+// orderbookSequencer adds the last persisted sequence of the market to it,
+// so that the sequence of deltas and snapshots keeps increasing across restarts.
+type orderbookSequencer struct {
+	kvStore common.IKVStore
+	feed    orderbook_feed.IOrderbookFeed
+
+	mu      sync.Mutex
+	offsets map[string]uint64
+	latest  map[string]uint64
+}
+
+// nil in tests, sequences are passed through as they are
+var sequencer *orderbookSequencer
+
+func InitOrderbookSequencer(kvStore common.IKVStore, feed orderbook_feed.IOrderbookFeed) {
+	sequencer = &orderbookSequencer{
+		kvStore: kvStore,
+		feed:    feed,
+		offsets: make(map[string]uint64),
+		latest:  make(map[string]uint64),
+	}
+}
+
+// openMarket is called before the pending orders of the market are re-inserted into the book.
+func (s *orderbookSequencer) openMarket(marketID string) {
+	if s == nil {
+		return
+	}
+
+	var offset uint64
+
+	res, err := s.kvStore.Get(common.GetMarketOrderbookSnapshotV2Key(marketID))
+	if err == nil {
+		var snapshot common.SnapshotV2
+		_ = json.Unmarshal([]byte(res), &snapshot)
+		offset = snapshot.Sequence
+	} else if err != common.KVStoreEmpty {
+		panic(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offsets[marketID] = offset
+	s.latest[marketID] = offset
+}
+
+// marketOpened is called after the pending orders are re-inserted.
+// The re-inserted orders are not sent as deltas, clients behind the rebuilt book have to fetch a new snapshot.
+func (s *orderbookSequencer) marketOpened(marketID string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	latest := s.latest[marketID]
+	s.mu.Unlock()
+
+	if err := s.feed.Reset(marketID, latest); err != nil {
+		utils.Errorf("reset orderbook feed of market %s error: %v", marketID, err)
+	}
+}
+
+func (s *orderbookSequencer) sequence(marketID string, bookSequence uint64) uint64 {
+	if s == nil {
+		return bookSequence
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sequence := s.offsets[marketID] + bookSequence
+	if sequence > s.latest[marketID] {
+		s.latest[marketID] = sequence
+	}
+
+	return sequence
+}
+
+func (s *orderbookSequencer) updateSnapshot(key string, snapshot *common.SnapshotV2) {
+	marketID := strings.TrimPrefix(key, common.GetMarketOrderbookSnapshotV2Key(""))
+	snapshot.Sequence = s.sequence(marketID, snapshot.Sequence)
+}
+
+// sendOrderbookChangeMessage sequences a order book change of the sdk engine, keeps it in the feed and sends it to ws servers.
+func sendOrderbookChangeMessage(msg *common.WebSocketMessage) {
+	payload, ok := msg.Payload.(*common.WebsocketMarketOrderChangePayload)
+	if !ok {
+		_ = pushMessage(msg)
+		return
+	}
+
+	marketID := strings.TrimPrefix(msg.ChannelID, common.GetMarketChannelID(""))
+	payload.Sequence = sequencer.sequence(marketID, payload.Sequence)
+
+	if sequencer != nil {
+		if err := sequencer.feed.Append(marketID, payload); err != nil {
+			utils.Errorf("append orderbook delta of market %s error: %v", marketID, err)
+		}
+	}
+
+	_ = pushMessage(msg)
+}
@@ -0,0 +1,376 @@
+package dex_engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The book held by the hydro engine and the pending orders in database can drift apart,
+// e.g. when a panic is recovered by handleEvent after the book is changed but before the orders are saved.
+// The reconciliation compares the available amount of each order in database with the copy the market handler keeps
+// of the orders it put into the book, and repairs them. The sdk engine doesn't expose its orders, only snapshots
+// of its price levels, so the amounts of each price level in database are compared with its latest snapshot as well.
+// A price level which differs is only reported, it can't tell which order to repair.
+
+const EventReconcileMarket = "EVENT/EVENT_RECONCILE_MARKET"
+
+// How a discrepancy is repaired
+const (
+	// only report discrepancies
+	ReconcileRepairNone = ""
+
+	// database is the source of truth, the engine book is changed to match it
+	ReconcileRepairBook = "book"
+
+	// engine book is the source of truth, the order rows are changed to match it
+	ReconcileRepairDB = "db"
+)
+
+// ReconcileMarketEvent asks the engine to reconcile a market, or all markets if MarketID is empty.
+type ReconcileMarketEvent struct {
+	common.Event
+	Repair string `json:"repair"`
+}
+
+type BookDiscrepancy struct {
+	OrderID    string          `json:"orderID"`
+	Side       string          `json:"side"`
+	Price      decimal.Decimal `json:"price"`
+	BookAmount decimal.Decimal `json:"bookAmount"`
+	DBAmount   decimal.Decimal `json:"dbAmount"`
+	DBStatus   string          `json:"dbStatus"`
+	Repaired   bool            `json:"repaired"`
+}
+
+// LevelDiscrepancy is a price level whose amount in the snapshot of the sdk engine differs from the orders in database.
+type LevelDiscrepancy struct {
+	Side       string          `json:"side"`
+	Price      decimal.Decimal `json:"price"`
+	BookAmount decimal.Decimal `json:"bookAmount"`
+	DBAmount   decimal.Decimal `json:"dbAmount"`
+}
+
+type ReconcileReport struct {
+	MarketID           string              `json:"marketID"`
+	Repair             string              `json:"repair"`
+	Discrepancies      []*BookDiscrepancy  `json:"discrepancies"`
+	LevelDiscrepancies []*LevelDiscrepancy `json:"levelDiscrepancies"`
+	CheckedAt          time.Time           `json:"checkedAt"`
+}
+
+func GetReconcileReportKey(marketID string) string {
+	return fmt.Sprintf("HYDRO_ENGINE_RECONCILE_REPORT:%s", marketID)
+}
+
+var bookDiscrepanciesGauge = metrics.NewGauge(
+	"hydro_engine_book_discrepancies",
+	"Orders whose available amount in the engine book differs from the database, found by the latest reconciliation.",
+	"market",
+)
+
+var levelDiscrepanciesGauge = metrics.NewGauge(
+	"hydro_engine_level_discrepancies",
+	"Price levels whose amount in the snapshot of the sdk engine differs from the database, found by the latest reconciliation.",
+	"market",
+)
+
+// bookSnapshots keeps the latest snapshot the sdk engine sent for each market.
+type bookSnapshots struct {
+	mu        sync.Mutex
+	snapshots map[string]*common.SnapshotV2
+}
+
+var latestBookSnapshots = &bookSnapshots{snapshots: make(map[string]*common.SnapshotV2)}
+
+func (b *bookSnapshots) set(marketID string, snapshot *common.SnapshotV2) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.snapshots[marketID] = snapshot
+}
+
+func (b *bookSnapshots) get(marketID string) *common.SnapshotV2 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.snapshots[marketID]
+}
+
+var bookRepairsCounter = metrics.NewCounter(
+	"hydro_engine_book_repairs_total",
+	"Book discrepancies repaired by the reconciliation.",
+	"market", "direction",
+)
+
+func isValidReconcileRepair(repair string) bool {
+	return repair == ReconcileRepairNone || repair == ReconcileRepairBook || repair == ReconcileRepairDB
+}
+
+func getReconcileInterval() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("HSK_RECONCILE_INTERVAL_SECONDS"))
+	if err != nil {
+		seconds = 300
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// setBookOrder keeps the copy of an order which is resting in the engine book.
+func (m *MarketHandler) setBookOrder(order *common.MemoryOrder) {
+	if order.Amount.LessThanOrEqual(decimal.Zero) {
+		delete(m.bookOrders, order.ID)
+		return
+	}
+
+	m.bookOrders[order.ID] = &common.MemoryOrder{
+		ID:       order.ID,
+		MarketID: order.MarketID,
+		Side:     order.Side,
+		Price:    order.Price,
+		Amount:   order.Amount,
+	}
+}
+
+func (m *MarketHandler) handleReconcile(event *ReconcileMarketEvent) (*ReconcileReport, error) {
+	if !isValidReconcileRepair(event.Repair) {
+		return nil, fmt.Errorf("unknown reconcile repair direction %s", event.Repair)
+	}
+
+	report := m.reconcile(event.Repair)
+
+	bookDiscrepanciesGauge.Set(float64(len(report.Discrepancies)), m.market.ID)
+	levelDiscrepanciesGauge.Set(float64(len(report.LevelDiscrepancies)), m.market.ID)
+
+	if len(report.Discrepancies) > 0 {
+		utils.Errorf("market %s has %d orders out of sync between book and database", m.market.ID, len(report.Discrepancies))
+	}
+
+	if len(report.LevelDiscrepancies) > 0 {
+		utils.Errorf("market %s has %d price levels out of sync between the sdk engine and database", m.market.ID, len(report.LevelDiscrepancies))
+	}
+
+	if m.kvStore != nil {
+		_ = m.kvStore.Set(GetReconcileReportKey(m.market.ID), utils.ToJsonString(report), 0)
+	}
+
+	return report, nil
+}
+
+func (m *MarketHandler) reconcile(repair string) *ReconcileReport {
+	report := &ReconcileReport{
+		MarketID:           m.market.ID,
+		Repair:             repair,
+		Discrepancies:      []*BookDiscrepancy{},
+		LevelDiscrepancies: []*LevelDiscrepancy{},
+		CheckedAt:          time.Now().UTC(),
+	}
+
+	dbOrders := m.findAvailableOrders()
+
+	for id, order := range dbOrders {
+		bookOrder := m.bookOrders[id]
+		if bookOrder != nil && bookOrder.Amount.Equal(order.AvailableAmount) {
+			continue
+		}
+
+		report.Discrepancies = append(report.Discrepancies, m.repairDiscrepancy(repair, bookOrder, order))
+	}
+
+	for id, bookOrder := range m.bookOrders {
+		if _, ok := dbOrders[id]; ok {
+			continue
+		}
+
+		report.Discrepancies = append(report.Discrepancies, m.repairDiscrepancy(repair, bookOrder, models.OrderDao.FindByID(id)))
+	}
+
+	// the levels are compared after the repairs, a repaired book sends a new snapshot
+	if snapshot := latestBookSnapshots.get(m.market.ID); snapshot != nil {
+		if repair == ReconcileRepairDB && len(report.Discrepancies) > 0 {
+			dbOrders = m.findAvailableOrders()
+		}
+
+		report.LevelDiscrepancies = compareLevels(snapshot, dbOrders)
+	}
+
+	return report
+}
+
+// findAvailableOrders returns the pending orders of the market in database which should rest in the book, by ID.
+func (m *MarketHandler) findAvailableOrders() map[string]*models.Order {
+	dbOrders := make(map[string]*models.Order)
+	for _, order := range models.OrderDao.FindMarketPendingOrders(m.market.ID) {
+		if order.AvailableAmount.GreaterThan(decimal.Zero) {
+			dbOrders[order.ID] = order
+		}
+	}
+
+	return dbOrders
+}
+
+// compareLevels compares the amount of each price level in the snapshot with the available amounts of the orders.
+func compareLevels(snapshot *common.SnapshotV2, dbOrders map[string]*models.Order) []*LevelDiscrepancy {
+	type level struct {
+		side  string
+		price string
+	}
+
+	levels := make(map[level]*LevelDiscrepancy)
+	find := func(side string, price decimal.Decimal) *LevelDiscrepancy {
+		key := level{side, price.String()}
+		if levels[key] == nil {
+			levels[key] = &LevelDiscrepancy{Side: side, Price: price, BookAmount: decimal.Zero, DBAmount: decimal.Zero}
+		}
+
+		return levels[key]
+	}
+
+	for side, bookLevels := range map[string][][2]string{"buy": snapshot.Bids, "sell": snapshot.Asks} {
+		for _, bookLevel := range bookLevels {
+			d := find(side, utils.StringToDecimal(bookLevel[0]))
+			d.BookAmount = d.BookAmount.Add(utils.StringToDecimal(bookLevel[1]))
+		}
+	}
+
+	for _, order := range dbOrders {
+		d := find(order.Side, order.Price)
+		d.DBAmount = d.DBAmount.Add(order.AvailableAmount)
+	}
+
+	discrepancies := []*LevelDiscrepancy{}
+	for _, d := range levels {
+		if !d.BookAmount.Equal(d.DBAmount) {
+			utils.Infof("level discrepancy of %s %s: book %s, database %s", d.Side, d.Price, d.BookAmount, d.DBAmount)
+			discrepancies = append(discrepancies, d)
+		}
+	}
+
+	return discrepancies
+}
+
+// repairDiscrepancy builds the discrepancy of an order, and repairs it in the given direction.
+// Either bookOrder or dbOrder can be nil.
+func (m *MarketHandler) repairDiscrepancy(repair string, bookOrder *common.MemoryOrder, dbOrder *models.Order) *BookDiscrepancy {
+	d := &BookDiscrepancy{
+		BookAmount: decimal.Zero,
+		DBAmount:   decimal.Zero,
+	}
+
+	if bookOrder != nil {
+		d.OrderID, d.Side, d.Price, d.BookAmount = bookOrder.ID, bookOrder.Side, bookOrder.Price, bookOrder.Amount
+	}
+
+	if dbOrder != nil {
+		d.OrderID, d.Side, d.Price, d.DBStatus = dbOrder.ID, dbOrder.Side, dbOrder.Price, dbOrder.Status
+		if dbOrder.Status == common.ORDER_PENDING {
+			d.DBAmount = dbOrder.AvailableAmount
+		}
+	}
+
+	switch repair {
+	case ReconcileRepairBook:
+		d.Repaired = m.repairBook(bookOrder, dbOrder, d.DBAmount)
+	case ReconcileRepairDB:
+		d.Repaired = m.repairDB(dbOrder, d.BookAmount)
+	}
+
+	if d.Repaired {
+		bookRepairsCounter.Inc(m.market.ID, repair)
+	}
+
+	utils.Infof("book discrepancy of order %s in market %s: book %s, database %s, repaired %v", d.OrderID, m.market.ID, d.BookAmount, d.DBAmount, d.Repaired)
+
+	return d
+}
+
+// repairBook replaces the order in engine book with the available amount in database.
+func (m *MarketHandler) repairBook(bookOrder *common.MemoryOrder, dbOrder *models.Order, dbAmount decimal.Decimal) bool {
+	if bookOrder != nil {
+		msg, success := m.hydroEngine.HandleCancelOrder(bookOrder)
+		if success {
+			sendOrderbookChangeMessage(msg)
+		}
+
+		delete(m.bookOrders, bookOrder.ID)
+	}
+
+	if dbAmount.LessThanOrEqual(decimal.Zero) {
+		return true
+	}
+
+	newBookOrder := &common.MemoryOrder{
+		ID:       dbOrder.ID,
+		MarketID: dbOrder.MarketID,
+		Side:     dbOrder.Side,
+		Price:    dbOrder.Price,
+		Amount:   dbAmount,
+	}
+
+	if msg := m.hydroEngine.ReInsertOrder(newBookOrder); msg != nil {
+		sendOrderbookChangeMessage(msg)
+	}
+
+	m.setBookOrder(newBookOrder)
+	return true
+}
+
+// repairDB changes the available amount of the order to the amount in engine book.
+// The difference goes to the canceled amount, so that the amounts of the order still add up.
+func (m *MarketHandler) repairDB(dbOrder *models.Order, bookAmount decimal.Decimal) bool {
+	if dbOrder == nil {
+		return false
+	}
+
+	canceledAmount := dbOrder.Amount.Sub(bookAmount).Sub(dbOrder.PendingAmount).Sub(dbOrder.ConfirmedAmount)
+	if canceledAmount.LessThan(decimal.Zero) {
+		return false
+	}
+
+	dbOrder.AvailableAmount = bookAmount
+	dbOrder.CanceledAmount = canceledAmount
+	dbOrder.AutoSetStatusByAmounts()
+
+	return UpdateOrder(dbOrder) == nil
+}
+
+// runReconcileTimer asks the engine to reconcile all markets periodically.
+func (e *DexEngine) runReconcileTimer() {
+	interval := getReconcileInterval()
+	if interval <= 0 {
+		return
+	}
+
+	event := utils.ToJsonString(ReconcileMarketEvent{
+		Event:  common.Event{Type: EventReconcileMarket},
+		Repair: os.Getenv("HSK_RECONCILE_REPAIR"),
+	})
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-e.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := e.eventQueue.Push([]byte(event)); err != nil {
+				utils.Errorf("push reconcile event error: %v", err)
+			}
+		}
+	}
+}
+
+func parseReconcileMarketEvent(eventJSON string) (*ReconcileMarketEvent, error) {
+	var event ReconcileMarketEvent
+	err := json.Unmarshal([]byte(eventJSON), &event)
+	return &event, err
+}
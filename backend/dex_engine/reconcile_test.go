@@ -0,0 +1,43 @@
+package dex_engine
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/stretchr/testify/assert"
+	"sort"
+	"testing"
+)
+
+func TestCompareLevels(t *testing.T) {
+	snapshot := &common.SnapshotV2{
+		Bids: [][2]string{{"100", "10"}, {"99", "3"}},
+		Asks: [][2]string{{"120", "5"}},
+	}
+
+	newOrder := func(side, price, available string) *models.Order {
+		return &models.Order{Side: side, Price: utils.StringToDecimal(price), AvailableAmount: utils.StringToDecimal(available)}
+	}
+
+	dbOrders := map[string]*models.Order{
+		"1": newOrder("buy", "100", "6"),
+		"2": newOrder("buy", "100.000", "4"),
+		"3": newOrder("buy", "99", "2"),
+		"4": newOrder("sell", "121", "1"),
+	}
+
+	discrepancies := compareLevels(snapshot, dbOrders)
+	sort.Slice(discrepancies, func(i, j int) bool { return discrepancies[i].Price.LessThan(discrepancies[j].Price) })
+
+	// the orders at 100 add up to the level, the others don't
+	assert.Len(t, discrepancies, 3)
+	assert.EqualValues(t, "99", discrepancies[0].Price.String())
+	assert.EqualValues(t, "3", discrepancies[0].BookAmount.String())
+	assert.EqualValues(t, "2", discrepancies[0].DBAmount.String())
+	assert.EqualValues(t, "sell", discrepancies[1].Side)
+	assert.EqualValues(t, "0", discrepancies[1].DBAmount.String())
+	assert.EqualValues(t, "121", discrepancies[2].Price.String())
+	assert.EqualValues(t, "0", discrepancies[2].BookAmount.String())
+
+	assert.Len(t, compareLevels(&common.SnapshotV2{}, map[string]*models.Order{}), 0)
+}
@@ -0,0 +1,87 @@
+package dex_engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+)
+
+// A chain reorganization can drop the block a settlement was mined in. The watcher is told the transaction is removed,
+// and the engine takes back its result: the transaction and its trades are pending again,
+// and the amounts of the orders are moved back from confirmed or canceled to pending.
+// Its launch logs are orphaned, in case the watcher saw them still pending before the confirmation was applied.
+// The launcher sends the settlement again, and the watcher confirms it like any other.
+
+const EventTransactionRemoved = "EVENT/EVENT_TRANSACTION_REMOVED"
+
+type TransactionRemovedEvent struct {
+	common.Event
+	Hash string `json:"hash"`
+}
+
+func parseTransactionRemovedEvent(eventJSON string) (*TransactionRemovedEvent, error) {
+	var event TransactionRemovedEvent
+	if err := json.Unmarshal([]byte(eventJSON), &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (m *MarketHandler) handleTransactionRemoved(event *TransactionRemovedEvent) (interface{}, error) {
+	transaction := models.TransactionDao.FindTransactionByHash(event.Hash)
+	if transaction == nil {
+		return nil, fmt.Errorf("cannot find transaction with hash %s", event.Hash)
+	}
+
+	if transaction.Status == common.STATUS_PENDING {
+		utils.Infof("transaction %d is pending already, skip removal", transaction.ID)
+		return transaction, nil
+	}
+
+	utils.Infof("transaction %d was %s, but its block is removed", transaction.ID, transaction.Status)
+
+	transaction.Status = common.STATUS_PENDING
+	_ = models.TransactionDao.UpdateTransaction(transaction)
+
+	_ = models.LaunchLogDao.UpdateLaunchLogsStatusByItemID(models.LaunchLogStatusOrphaned, transaction.ID)
+
+	orders := make(map[string]*models.Order)
+	findOrder := func(orderID string) *models.Order {
+		if order, ok := orders[orderID]; ok {
+			return order
+		}
+
+		order := models.OrderDao.FindByID(orderID)
+		orders[orderID] = order
+		return order
+	}
+
+	for _, trade := range models.TradeDao.FindTradesByHash(event.Hash) {
+		for _, order := range []*models.Order{findOrder(trade.TakerOrderID), findOrder(trade.MakerOrderID)} {
+			switch trade.Status {
+			case common.STATUS_SUCCESSFUL:
+				order.ConfirmedAmount = order.ConfirmedAmount.Sub(trade.Amount)
+			case common.STATUS_FAILED:
+				order.CanceledAmount = order.CanceledAmount.Sub(trade.Amount)
+			default:
+				continue
+			}
+
+			order.PendingAmount = order.PendingAmount.Add(trade.Amount)
+		}
+
+		previousStatus := trade.Status
+		trade.Status = common.STATUS_PENDING
+		_ = UpdateTrade(trade, previousStatus)
+	}
+
+	for _, order := range orders {
+		order.AutoSetStatusByAmounts()
+		_ = UpdateOrder(order)
+	}
+
+	return transaction, nil
+}
@@ -32,11 +32,13 @@ func InsertOrder(order *models.Order) error {
 	return err
 }
 
-func UpdateTrade(trade *models.Trade) error {
+// UpdateTrade saves a trade which had the previous status. The trade is published to the market once,
+// when it moves into the statuses counted in the market stats.
+func UpdateTrade(trade *models.Trade, previousStatus string) error {
 	err := models.TradeDao.UpdateTrade(trade)
 	sendTradeUpdateMessage(trade)
 
-	if trade.Status == common.STATUS_SUCCESSFUL {
+	if models.IsTradeStatusInStats(trade.Status) && !models.IsTradeStatusInStats(previousStatus) {
 		sendNewMarketTradeMessage(trade)
 	}
 	return err
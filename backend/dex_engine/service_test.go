@@ -0,0 +1,63 @@
+package dex_engine
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"os"
+	"strings"
+	"testing"
+)
+
+func countMarketTradeMessages(queue *common.MockQueue) int {
+	count := 0
+	for _, buffer := range queue.Buffers {
+		if strings.Contains(string(buffer), `"type":"`+common.WsTypeNewMarketTrade+`"`) {
+			count++
+		}
+	}
+
+	queue.ResetBuffer()
+	return count
+}
+
+func TestUpdateTradePublishesOnce(t *testing.T) {
+	tradeDao := &models.MTradeDao{}
+	tradeDao.On("UpdateTrade", mock.Anything).Return(nil)
+	models.TradeDao = tradeDao
+	defer func() { models.TradeDao = models.TradeDaoPG }()
+
+	queue := &common.MockQueue{}
+	queue.On("Push", mock.Anything).Return(nil)
+	wsQueue = queue
+
+	trade := &models.Trade{MarketID: "HOT-DAI", Status: models.TradeStatusMined}
+	assert.Nil(t, UpdateTrade(trade, common.STATUS_PENDING))
+	assert.EqualValues(t, 1, countMarketTradeMessages(queue))
+
+	trade.Status = common.STATUS_SUCCESSFUL
+	assert.Nil(t, UpdateTrade(trade, models.TradeStatusMined))
+	assert.EqualValues(t, 0, countMarketTradeMessages(queue))
+
+	// finalized as soon as it is mined
+	assert.Nil(t, UpdateTrade(trade, common.STATUS_PENDING))
+	assert.EqualValues(t, 1, countMarketTradeMessages(queue))
+
+	// with finalized trades only in the stats, it is published when it is finalized
+	_ = os.Setenv("HSK_STATS_FINALIZED_ONLY", "true")
+	defer os.Unsetenv("HSK_STATS_FINALIZED_ONLY")
+
+	trade.Status = models.TradeStatusMined
+	assert.Nil(t, UpdateTrade(trade, common.STATUS_PENDING))
+	assert.EqualValues(t, 0, countMarketTradeMessages(queue))
+
+	trade.Status = common.STATUS_SUCCESSFUL
+	assert.Nil(t, UpdateTrade(trade, models.TradeStatusMined))
+	assert.EqualValues(t, 1, countMarketTradeMessages(queue))
+
+	// a failed trade is never published
+	trade.Status = common.STATUS_FAILED
+	assert.Nil(t, UpdateTrade(trade, common.STATUS_PENDING))
+	assert.EqualValues(t, 0, countMarketTradeMessages(queue))
+}
@@ -0,0 +1,167 @@
+package dex_engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// The launcher simulates a settlement before sending it. When it reverts, the launch log is never sent,
+// and the engine is told which orders made it revert. Those orders are canceled,
+// the other orders get their matched amounts back and are matched again.
+
+const EventSimulationFailed = "EVENT/EVENT_SIMULATION_FAILED"
+
+type SimulationFailedEvent struct {
+	common.Event
+	Hash        string   `json:"hash"`
+	Reason      string   `json:"reason"`
+	BadOrderIDs []string `json:"badOrderIDs"`
+}
+
+func parseSimulationFailedEvent(eventJSON string) (*SimulationFailedEvent, error) {
+	var event SimulationFailedEvent
+	if err := json.Unmarshal([]byte(eventJSON), &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (m *MarketHandler) handleSimulationFailed(event *SimulationFailedEvent) (interface{}, error) {
+	transaction := models.TransactionDao.FindTransactionByHash(event.Hash)
+	if transaction == nil {
+		return nil, fmt.Errorf("cannot find transaction with hash %s", event.Hash)
+	}
+
+	if transaction.Status != common.STATUS_PENDING {
+		utils.Infof("transaction %d is %s already, skip simulation failure", transaction.ID, transaction.Status)
+		return transaction, nil
+	}
+
+	utils.Infof("transaction %d reverted in simulation: %s, bad orders: %v", transaction.ID, event.Reason, event.BadOrderIDs)
+
+	now := time.Now().UTC()
+	transaction.Status = common.STATUS_FAILED
+	transaction.ExecutedAt = now
+	_ = models.TransactionDao.UpdateTransaction(transaction)
+
+	_ = models.LaunchLogDao.UpdateLaunchLogsStatusByItemID(common.STATUS_FAILED, transaction.ID)
+
+	// release the pending amounts of the trades
+	orders := make(map[string]*models.Order)
+	released := make(map[string]decimal.Decimal)
+	var takerID string
+	var makerIDs []string
+
+	release := func(orderID string, amount decimal.Decimal) {
+		order, ok := orders[orderID]
+		if !ok {
+			order = models.OrderDao.FindByID(orderID)
+			orders[orderID] = order
+		}
+
+		order.PendingAmount = order.PendingAmount.Sub(amount)
+		released[orderID] = released[orderID].Add(amount)
+	}
+
+	for _, trade := range models.TradeDao.FindTradesByHash(event.Hash) {
+		if _, ok := orders[trade.MakerOrderID]; !ok {
+			makerIDs = append(makerIDs, trade.MakerOrderID)
+		}
+
+		takerID = trade.TakerOrderID
+		release(trade.TakerOrderID, trade.Amount)
+		release(trade.MakerOrderID, trade.Amount)
+
+		previousStatus := trade.Status
+		trade.Status = common.STATUS_FAILED
+		trade.ExecutedAt = now
+		_ = UpdateTrade(trade, previousStatus)
+	}
+
+	badOrders := make(map[string]bool)
+	for _, id := range event.BadOrderIDs {
+		badOrders[id] = true
+	}
+
+	// bad orders are canceled first, so that the others are not matched with them again
+	for id, order := range orders {
+		if !badOrders[id] {
+			continue
+		}
+
+		order.CanceledAmount = order.CanceledAmount.Add(released[id])
+		if err := m.cancelOrder(order, models.OrderCancelReasonSimulationFailed); err != nil {
+			return transaction, err
+		}
+	}
+
+	// maker orders were resting in the book, they are put back before the taker order is matched again
+	for _, id := range makerIDs {
+		if badOrders[id] || id == takerID {
+			continue
+		}
+
+		order := orders[id]
+		if !m.isRequeueable(order) {
+			m.cancelReleased(order, released[id])
+			continue
+		}
+
+		order.AvailableAmount = order.AvailableAmount.Add(released[id])
+		m.repairBook(m.bookOrders[id], order, order.AvailableAmount)
+
+		order.AutoSetStatusByAmounts()
+		_ = UpdateOrder(order)
+	}
+
+	if takerOrder := orders[takerID]; takerOrder != nil && !badOrders[takerID] {
+		if m.isRequeueable(takerOrder) {
+			m.requeueTakerOrder(takerOrder, released[takerID])
+		} else {
+			m.cancelReleased(takerOrder, released[takerID])
+		}
+	}
+
+	return transaction, nil
+}
+
+// isRequeueable tells if an order is still open for matching.
+// An order which is neither in the book nor completely matched has been canceled, e.g. by its trader.
+func (m *MarketHandler) isRequeueable(order *models.Order) bool {
+	_, inBook := m.bookOrders[order.ID]
+	return inBook || order.CanceledAmount.IsZero()
+}
+
+// cancelReleased cancels the released amount of an order which is not open for matching any more.
+func (m *MarketHandler) cancelReleased(order *models.Order, released decimal.Decimal) {
+	order.CanceledAmount = order.CanceledAmount.Add(released)
+	order.AutoSetStatusByAmounts()
+	_ = UpdateOrder(order)
+}
+
+// requeueTakerOrder gives the taker order its released amount back, and matches all its available amount again.
+func (m *MarketHandler) requeueTakerOrder(order *models.Order, released decimal.Decimal) {
+	// the rest of the taker order may be resting in the book
+	if bookOrder := m.bookOrders[order.ID]; bookOrder != nil {
+		msg, success := m.hydroEngine.HandleCancelOrder(bookOrder)
+		if success {
+			sendOrderbookChangeMessage(msg)
+		}
+
+		delete(m.bookOrders, order.ID)
+	}
+
+	order.AvailableAmount = order.AvailableAmount.Add(released)
+	if order.AvailableAmount.GreaterThan(decimal.Zero) {
+		m.matchOrder(order, order.AvailableAmount)
+	}
+
+	order.AutoSetStatusByAmounts()
+	_ = UpdateOrder(order)
+}
@@ -0,0 +1,60 @@
+package dex_engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+)
+
+// The watcher follows the token balances and allowances of traders with open orders.
+// When they no longer cover the orders, the engine is told how much of an order is unfunded.
+// That amount is taken out of the book and canceled, the order is canceled completely if nothing is left.
+
+const EventOrderUnfunded = "EVENT/EVENT_ORDER_UNFUNDED"
+
+type OrderUnfundedEvent struct {
+	common.Event
+	ID     string          `json:"id"`
+	Amount decimal.Decimal `json:"amount"`
+}
+
+func parseOrderUnfundedEvent(eventJSON string) (*OrderUnfundedEvent, error) {
+	var event OrderUnfundedEvent
+	if err := json.Unmarshal([]byte(eventJSON), &event); err != nil {
+		return nil, err
+	}
+
+	return &event, nil
+}
+
+func (m *MarketHandler) handleOrderUnfunded(event *OrderUnfundedEvent) (interface{}, error) {
+	order := models.OrderDao.FindByID(event.ID)
+	if order == nil {
+		return nil, fmt.Errorf("cannot find order with id %s", event.ID)
+	}
+
+	if order.Status != common.ORDER_PENDING || order.AvailableAmount.LessThanOrEqual(decimal.Zero) {
+		utils.Infof("unfunded order %s has nothing to cancel, skip it", order.ID)
+		return order, nil
+	}
+
+	// the order may have been matched since the watcher looked at it
+	if event.Amount.GreaterThanOrEqual(order.AvailableAmount) {
+		utils.Infof("order %s is unfunded, cancel it", order.ID)
+		return order, m.cancelOrder(order, models.OrderCancelReasonUnfunded)
+	}
+
+	utils.Infof("order %s is unfunded by %s, shrink it", order.ID, event.Amount)
+
+	order.AvailableAmount = order.AvailableAmount.Sub(event.Amount)
+	order.CanceledAmount = order.CanceledAmount.Add(event.Amount)
+	order.CancelReason = models.OrderCancelReasonUnfunded
+	m.repairBook(m.bookOrders[order.ID], order, order.AvailableAmount)
+
+	order.AutoSetStatusByAmounts()
+
+	return order, UpdateOrder(order)
+}
@@ -0,0 +1,132 @@
+package dex_launcher
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trades are settled by a pool of relayer accounts, each of them has its own nonces,
+// so that a slow transaction only blocks the launch logs of one account.
+// Accounts other than the relayer of the orders must be approved as its delegates on the exchange contract.
+// Approve launch logs are always sent by the account they were created for.
+
+// How a launch log is assigned to an account
+const (
+	AssignLeastPending = "least_pending"
+	AssignRoundRobin   = "round_robin"
+)
+
+var accountBalanceGauge = metrics.NewGauge(
+	"hydro_launcher_account_balance_eth",
+	"Ether balance of the relayer account.",
+	"account",
+)
+
+var accountPendingGauge = metrics.NewGauge(
+	"hydro_launcher_account_pending",
+	"Launch logs of the relayer account which have a nonce and are not mined yet.",
+	"account",
+)
+
+type AccountPool struct {
+	addresses []string
+	strategy  string
+
+	mutex sync.Mutex
+	next  int
+}
+
+func NewAccountPool(addresses []string, strategy string) *AccountPool {
+	if len(addresses) == 0 {
+		panic("relayer account pool is empty")
+	}
+
+	if strategy != AssignRoundRobin {
+		strategy = AssignLeastPending
+	}
+
+	lowerAddresses := make([]string, 0, len(addresses))
+	for _, address := range addresses {
+		lowerAddresses = append(lowerAddresses, strings.ToLower(address))
+	}
+
+	return &AccountPool{
+		addresses: lowerAddresses,
+		strategy:  strategy,
+	}
+}
+
+func (p *AccountPool) Addresses() []string {
+	return p.addresses
+}
+
+// Assign chooses the account to send a trade launch log.
+func (p *AccountPool) Assign() string {
+	if p.strategy == AssignRoundRobin {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+
+		address := p.addresses[p.next%len(p.addresses)]
+		p.next++
+		return address
+	}
+
+	return leastPendingAccount(p.addresses, models.LaunchLogDao.CountInFlightByFrom())
+}
+
+// leastPendingAccount returns the first of the accounts which has the fewest launch logs in flight.
+func leastPendingAccount(addresses []string, inFlight map[string]int) string {
+	best := addresses[0]
+
+	for _, address := range addresses[1:] {
+		if inFlight[address] < inFlight[best] {
+			best = address
+		}
+	}
+
+	return best
+}
+
+func getAccountCheckInterval() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("HSK_LAUNCHER_ACCOUNT_CHECK_SECONDS"))
+	if err != nil {
+		seconds = 60
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// assignAccount sets the sender of a launch log which has no nonce yet.
+func (l *Launcher) assignAccount(launchLog *models.LaunchLog) {
+	if launchLog.ItemType != models.LaunchLogItemTypeTrade {
+		return
+	}
+
+	launchLog.From = l.accountPool.Assign()
+}
+
+// checkAccounts updates the gauges of the accounts, and checks their nonce gaps.
+func (l *Launcher) checkAccounts() {
+	inFlight := models.LaunchLogDao.CountInFlightByFrom()
+
+	for _, address := range l.accountPool.Addresses() {
+		accountPendingGauge.Set(float64(inFlight[address]), address)
+
+		balance, err := l.rpc.Balance(address)
+		if err != nil {
+			utils.Errorf("get balance of %s error: %v", address, err)
+		} else {
+			ether, _ := balance.Div(decimal.New(1, 18)).Float64()
+			accountBalanceGauge.Set(ether, address)
+		}
+
+		l.checkNonceGaps(address)
+	}
+}
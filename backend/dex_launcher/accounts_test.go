@@ -0,0 +1,22 @@
+package dex_launcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLeastPendingAccount(t *testing.T) {
+	addresses := []string{"0xa", "0xb", "0xc"}
+
+	assert.EqualValues(t, "0xa", leastPendingAccount(addresses, map[string]int{}))
+	assert.EqualValues(t, "0xb", leastPendingAccount(addresses, map[string]int{"0xa": 2, "0xb": 1, "0xc": 1}))
+	assert.EqualValues(t, "0xc", leastPendingAccount(addresses, map[string]int{"0xa": 2, "0xb": 1}))
+}
+
+func TestRoundRobinAccount(t *testing.T) {
+	pool := NewAccountPool([]string{"0xA", "0xB"}, AssignRoundRobin)
+
+	assert.EqualValues(t, "0xa", pool.Assign())
+	assert.EqualValues(t, "0xb", pool.Assign())
+	assert.EqualValues(t, "0xa", pool.Assign())
+}
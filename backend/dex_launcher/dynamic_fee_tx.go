@@ -0,0 +1,62 @@
+package dex_launcher
+
+import (
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/rlp"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"math/big"
+)
+
+// dynamicFeeTx is an EIP-1559 (type 2) transaction, which the sdk can't encode.
+// https://eips.ethereum.org/EIPS/eip-1559
+type dynamicFeeTx struct {
+	ChainID              int64
+	Nonce                uint64
+	MaxPriorityFeePerGas *big.Int
+	MaxFeePerGas         *big.Int
+	GasLimit             uint64
+	To                   string
+	Value                *big.Int
+	Data                 []byte
+
+	// [R || S || V], V is the y parity 0 or 1
+	Signature []byte
+}
+
+const dynamicFeeTxType = 0x02
+
+func (t *dynamicFeeTx) fields() []interface{} {
+	return []interface{}{
+		rlp.EncodeUint64ToBytes(uint64(t.ChainID)),
+		rlp.EncodeUint64ToBytes(t.Nonce),
+		t.MaxPriorityFeePerGas.Bytes(),
+		t.MaxFeePerGas.Bytes(),
+		rlp.EncodeUint64ToBytes(t.GasLimit),
+		utils.Hex2Bytes(t.To[2:]),
+		t.Value.Bytes(),
+		t.Data,
+		// empty access list
+		[]interface{}{},
+	}
+}
+
+// SigningHash returns the hash to be signed.
+func (t *dynamicFeeTx) SigningHash() []byte {
+	return crypto.Keccak256([]byte{dynamicFeeTxType}, rlp.Encode(t.fields()))
+}
+
+// EncodeRlp returns the raw signed transaction, the type byte followed by the rlp encoded fields.
+func (t *dynamicFeeTx) EncodeRlp() []byte {
+	fields := append(t.fields(),
+		rlp.EncodeUint64ToBytes(uint64(t.Signature[64])),
+		new(big.Int).SetBytes(t.Signature[0:32]).Bytes(),
+		new(big.Int).SetBytes(t.Signature[32:64]).Bytes(),
+	)
+
+	return append([]byte{dynamicFeeTxType}, rlp.Encode(fields)...)
+}
+
+// Hash returns the hash of the signed transaction.
+func (t *dynamicFeeTx) Hash() []byte {
+	return crypto.Keccak256(t.EncodeRlp())
+}
@@ -0,0 +1,180 @@
+package dex_launcher
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
+	"github.com/onrik/ethrpc"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// How the launcher reacts to an error of sending a launch log
+type sendErrorClass string
+
+const (
+	// the node can't be reached, or is not ready. Retry later.
+	sendErrorTransient sendErrorClass = "transient"
+
+	// the gas price is too low for the node, or for replacing the transaction in mempool. Retry later.
+	sendErrorUnderpriced sendErrorClass = "underpriced"
+
+	// the node already has the transaction, it is sent
+	sendErrorKnown sendErrorClass = "known"
+
+	// the transaction can never be mined, e.g. invalid data or gas limit
+	sendErrorFailed sendErrorClass = "failed"
+
+	// the relayer account has to be fixed by hand, e.g. no ether or the nonce is used by another transaction
+	sendErrorNeedsAttention sendErrorClass = "needs_attention"
+
+	// neither the node nor the signer, e.g. the database. Not retried right away, the launch log is tried in the next round.
+	sendErrorUnknown sendErrorClass = "unknown"
+)
+
+// signError is returned when a launch log can't be signed, e.g. its data isn't hex.
+// Errors of the signer itself are signer.UnavailableError or signer.ConfigError.
+type signError struct {
+	reason interface{}
+}
+
+func (e signError) Error() string {
+	return fmt.Sprintf("sign launch log error: %v", e.reason)
+}
+
+var sendErrorsCounter = metrics.NewCounter(
+	"hydro_launcher_send_errors_total",
+	"Errors of sending launch logs, by how the launcher reacts to them.",
+	"class",
+)
+
+var sendErrorMessages = []struct {
+	substring string
+	class     sendErrorClass
+}{
+	{"already known", sendErrorKnown},
+	{"known transaction", sendErrorKnown},
+	{"replacement transaction underpriced", sendErrorUnderpriced},
+	{"transaction underpriced", sendErrorUnderpriced},
+	{"fee too low", sendErrorUnderpriced},
+	{"less than block base fee", sendErrorUnderpriced},
+	{"nonce too low", sendErrorNeedsAttention},
+	{"insufficient funds", sendErrorNeedsAttention},
+	{"intrinsic gas too low", sendErrorFailed},
+	{"exceeds block gas limit", sendErrorFailed},
+	{"gas limit reached", sendErrorFailed},
+	{"oversized data", sendErrorFailed},
+	{"invalid sender", sendErrorFailed},
+	{"rlp", sendErrorFailed},
+}
+
+func classifySendError(err error) sendErrorClass {
+	switch e := err.(type) {
+	case signError:
+		return sendErrorFailed
+	case signer.ConfigError:
+		return sendErrorNeedsAttention
+	case signer.UnavailableError:
+		return sendErrorTransient
+	case ethrpc.EthError:
+		message := strings.ToLower(e.Message)
+		for _, m := range sendErrorMessages {
+			if strings.Contains(message, m.substring) {
+				return m.class
+			}
+		}
+
+		// an unknown rejection, it would be rejected again
+		return sendErrorNeedsAttention
+	default:
+		if isNetworkError(err) {
+			return sendErrorTransient
+		}
+
+		return sendErrorUnknown
+	}
+}
+
+// isNetworkError tells if the node can't be reached, or answers with something else than JSON-RPC,
+// e.g. the error page of a proxy in front of it.
+func isNetworkError(err error) bool {
+	var netErr net.Error
+	var syntaxErr *json.SyntaxError
+
+	return errors.As(err, &netErr) || errors.As(err, &syntaxErr) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF)
+}
+
+// isNonceTooLow tells if the node rejected the transaction because its nonce is used by a mined transaction.
+func isNonceTooLow(err error) bool {
+	e, ok := err.(ethrpc.EthError)
+	return ok && strings.Contains(strings.ToLower(e.Message), "nonce too low")
+}
+
+func isRetryableSendError(err error) bool {
+	class := classifySendError(err)
+	return class == sendErrorTransient || class == sendErrorUnderpriced
+}
+
+const maxRetryBackoff = 30 * time.Second
+
+func getSendRetries() int {
+	retries, err := strconv.Atoi(os.Getenv("HSK_LAUNCHER_SEND_RETRIES"))
+	if err != nil || retries < 0 {
+		retries = 5
+	}
+
+	return retries
+}
+
+// retry calls fn until it succeeds, returns an error which is not retryable, or the retries run out.
+// The wait between the calls doubles every time.
+func (l *Launcher) retry(fn func() error) error {
+	backoff := l.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || !isRetryableSendError(err) || attempt >= l.sendRetries {
+			return err
+		}
+
+		select {
+		case <-l.Ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > maxRetryBackoff {
+			backoff = maxRetryBackoff
+		}
+	}
+}
+
+// a database write is tried again this many times, it must not hold the launcher up as long as a node which is down
+const databaseRetries = 2
+
+// retryDatabase calls fn until it succeeds or the retries run out, e.g. the connection to the database dropped.
+// A launch log which was moved on by someone else is not retried, it would never succeed.
+func (l *Launcher) retryDatabase(fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+
+		_, invalid := err.(models.InvalidLaunchLogTransitionError)
+		if err == nil || invalid || attempt >= databaseRetries {
+			return err
+		}
+
+		select {
+		case <-l.Ctx.Done():
+			return err
+		case <-time.After(l.retryBackoff):
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package dex_launcher
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/launcher"
+	"github.com/onrik/ethrpc"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/url"
+	"testing"
+	"time"
+)
+
+var connectionRefused = &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+
+func TestClassifySendError(t *testing.T) {
+	assert.EqualValues(t, sendErrorTransient, classifySendError(connectionRefused))
+	assert.EqualValues(t, sendErrorTransient, classifySendError(&url.Error{Op: "Post", URL: "http://node", Err: connectionRefused}))
+	assert.EqualValues(t, sendErrorTransient, classifySendError(json.Unmarshal([]byte("<html>502 Bad Gateway</html>"), &struct{}{})))
+	assert.EqualValues(t, sendErrorUnknown, classifySendError(errors.New("pq: could not serialize access")))
+	assert.EqualValues(t, sendErrorUnknown, classifySendError(models.InvalidLaunchLogTransitionError{ID: 1, From: "pending", To: "created"}))
+	assert.EqualValues(t, sendErrorKnown, classifySendError(ethrpc.EthError{Code: -32000, Message: "already known"}))
+	assert.EqualValues(t, sendErrorUnderpriced, classifySendError(ethrpc.EthError{Code: -32000, Message: "replacement transaction underpriced"}))
+	assert.EqualValues(t, sendErrorNeedsAttention, classifySendError(ethrpc.EthError{Code: -32000, Message: "insufficient funds for gas * price + value"}))
+	assert.EqualValues(t, sendErrorNeedsAttention, classifySendError(ethrpc.EthError{Code: -32000, Message: "nonce too low"}))
+	assert.EqualValues(t, sendErrorFailed, classifySendError(ethrpc.EthError{Code: -32000, Message: "intrinsic gas too low"}))
+	assert.EqualValues(t, sendErrorFailed, classifySendError(signError{reason: "invalid hex"}))
+	assert.EqualValues(t, sendErrorNeedsAttention, classifySendError(signer.ConfigError{Err: errors.New("401 Unauthorized")}))
+	assert.EqualValues(t, sendErrorTransient, classifySendError(signer.UnavailableError{Err: errors.New("503 Service Unavailable")}))
+	assert.EqualValues(t, sendErrorNeedsAttention, classifySendError(ethrpc.EthError{Code: -32000, Message: "something new"}))
+}
+
+func TestRetry(t *testing.T) {
+	l := &Launcher{
+		Launcher:     &launcher.Launcher{Ctx: context.Background()},
+		sendRetries:  2,
+		retryBackoff: time.Millisecond,
+	}
+
+	// transient errors are retried
+	calls := 0
+	err := l.retry(func() error {
+		calls++
+		return connectionRefused
+	})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 3, calls)
+
+	calls = 0
+	err = l.retry(func() error {
+		calls++
+		if calls < 2 {
+			return connectionRefused
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.EqualValues(t, 2, calls)
+
+	// permanent errors are not
+	calls = 0
+	err = l.retry(func() error {
+		calls++
+		return ethrpc.EthError{Code: -32000, Message: "intrinsic gas too low"}
+	})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 1, calls)
+}
+
+func TestRetryDatabase(t *testing.T) {
+	l := &Launcher{
+		Launcher:     &launcher.Launcher{Ctx: context.Background()},
+		sendRetries:  5,
+		retryBackoff: time.Millisecond,
+	}
+
+	// database errors are retried fewer times than those of the node
+	calls := 0
+	err := l.retryDatabase(func() error {
+		calls++
+		return errors.New("pq: the database system is starting up")
+	})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, databaseRetries+1, calls)
+
+	// a launch log moved on by someone else is not
+	calls = 0
+	err = l.retryDatabase(func() error {
+		calls++
+		return models.InvalidLaunchLogTransitionError{ID: 1, From: "successful", To: "pending"}
+	})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 1, calls)
+
+	// nor by retry, it is not an error of sending
+	calls = 0
+	err = l.retry(func() error {
+		calls++
+		return errors.New("pq: the database system is starting up")
+	})
+	assert.NotNil(t, err)
+	assert.EqualValues(t, 1, calls)
+}
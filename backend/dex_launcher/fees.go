@@ -0,0 +1,182 @@
+package dex_launcher
+
+import (
+	"github.com/shopspring/decimal"
+	"os"
+	"sort"
+	"strconv"
+)
+
+// On chains with the EIP-1559 fee market, e.g. Arbitrum and Polygon, the launcher sends type 2 transactions.
+// Their max fee and priority fee are derived from the fee history of recent blocks, by configurable strategies.
+// The gas price of such a launch log is its max fee per gas, which is what the replacement logic bumps.
+
+// Transaction types the launcher sends, set by HSK_LAUNCHER_TX_TYPE
+const (
+	TxTypeLegacy     = "legacy"
+	TxTypeDynamicFee = "eip1559"
+)
+
+// How the priority fee is decided, set by HSK_LAUNCHER_PRIORITY_FEE_STRATEGY
+const (
+	// the median of the priority fees paid at HSK_LAUNCHER_PRIORITY_FEE_PERCENTILE in recent blocks,
+	// but not less than HSK_LAUNCHER_PRIORITY_FEE_GWEI
+	PriorityFeePercentile = "percentile"
+
+	// always HSK_LAUNCHER_PRIORITY_FEE_GWEI
+	PriorityFeeFixed = "fixed"
+)
+
+// Which base fee the max fee is derived from, set by HSK_LAUNCHER_MAX_FEE_STRATEGY.
+// The max fee is the base fee times HSK_LAUNCHER_BASE_FEE_MULTIPLIER plus the priority fee.
+const (
+	// the base fee of the next block
+	MaxFeeNextBaseFee = "next_base_fee"
+
+	// the highest base fee of recent blocks, which rides out short spikes
+	MaxFeeRecentMax = "recent_max"
+)
+
+// transactionFees are the fees of a launch log transaction.
+// A legacy transaction only has a gas price, an EIP-1559 transaction has a priority fee, and its gas price is the max fee.
+type transactionFees struct {
+	GasPrice             decimal.Decimal
+	MaxPriorityFeePerGas decimal.NullDecimal
+}
+
+func (f transactionFees) isDynamic() bool {
+	return f.MaxPriorityFeePerGas.Valid
+}
+
+type FeeStrategy struct {
+	historyBlocks int
+
+	priorityFeeStrategy   string
+	priorityFeePercentile float64
+	priorityFee           decimal.Decimal
+
+	maxFeeStrategy    string
+	baseFeeMultiplier decimal.Decimal
+	maxGasPrice       decimal.Decimal
+}
+
+func NewFeeStrategy(maxGasPrice decimal.Decimal) *FeeStrategy {
+	s := &FeeStrategy{
+		historyBlocks:         20,
+		priorityFeeStrategy:   os.Getenv("HSK_LAUNCHER_PRIORITY_FEE_STRATEGY"),
+		priorityFeePercentile: 50,
+		priorityFee:           decimal.New(1, 9),
+		maxFeeStrategy:        os.Getenv("HSK_LAUNCHER_MAX_FEE_STRATEGY"),
+		baseFeeMultiplier:     decimal.New(2, 0),
+		maxGasPrice:           maxGasPrice,
+	}
+
+	if s.priorityFeeStrategy != PriorityFeeFixed {
+		s.priorityFeeStrategy = PriorityFeePercentile
+	}
+
+	if s.maxFeeStrategy != MaxFeeRecentMax {
+		s.maxFeeStrategy = MaxFeeNextBaseFee
+	}
+
+	if blocks, err := strconv.Atoi(os.Getenv("HSK_LAUNCHER_FEE_HISTORY_BLOCKS")); err == nil && blocks > 0 {
+		s.historyBlocks = blocks
+	}
+
+	if percentile, err := strconv.ParseFloat(os.Getenv("HSK_LAUNCHER_PRIORITY_FEE_PERCENTILE"), 64); err == nil && percentile >= 0 && percentile <= 100 {
+		s.priorityFeePercentile = percentile
+	}
+
+	if gwei, err := decimal.NewFromString(os.Getenv("HSK_LAUNCHER_PRIORITY_FEE_GWEI")); err == nil && !gwei.IsNegative() {
+		s.priorityFee = gwei.Mul(decimal.New(1, 9))
+	}
+
+	if multiplier, err := decimal.NewFromString(os.Getenv("HSK_LAUNCHER_BASE_FEE_MULTIPLIER")); err == nil && multiplier.GreaterThanOrEqual(decimal.New(1, 0)) {
+		s.baseFeeMultiplier = multiplier
+	}
+
+	return s
+}
+
+func getTxType() string {
+	if os.Getenv("HSK_LAUNCHER_TX_TYPE") == TxTypeDynamicFee {
+		return TxTypeDynamicFee
+	}
+
+	return TxTypeLegacy
+}
+
+// Fees returns the fees of a transaction sent now.
+func (s *FeeStrategy) Fees(history *FeeHistory) transactionFees {
+	priorityFee := s.priorityFee
+	if s.priorityFeeStrategy == PriorityFeePercentile {
+		if median := medianDecimal(history.Rewards); median.GreaterThan(priorityFee) {
+			priorityFee = median
+		}
+	}
+
+	baseFee := history.BaseFees[len(history.BaseFees)-1]
+	if s.maxFeeStrategy == MaxFeeRecentMax {
+		for _, fee := range history.BaseFees {
+			if fee.GreaterThan(baseFee) {
+				baseFee = fee
+			}
+		}
+	}
+
+	maxFee := baseFee.Mul(s.baseFeeMultiplier).Ceil().Add(priorityFee)
+
+	if s.maxGasPrice.GreaterThan(decimal.Zero) && maxFee.GreaterThan(s.maxGasPrice) {
+		maxFee = s.maxGasPrice
+	}
+
+	// the priority fee is paid out of the max fee
+	if priorityFee.GreaterThan(maxFee) {
+		priorityFee = maxFee
+	}
+
+	return transactionFees{
+		GasPrice:             maxFee,
+		MaxPriorityFeePerGas: decimal.NullDecimal{Decimal: priorityFee, Valid: true},
+	}
+}
+
+func medianDecimal(values []decimal.Decimal) decimal.Decimal {
+	if len(values) == 0 {
+		return decimal.Zero
+	}
+
+	sorted := make([]decimal.Decimal, len(values))
+	copy(sorted, values)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].LessThan(sorted[j]) })
+
+	return sorted[len(sorted)/2]
+}
+
+// currentFees returns the fees to send a launch log now.
+func (l *Launcher) currentFees() (transactionFees, error) {
+	if l.txType != TxTypeDynamicFee {
+		return transactionFees{GasPrice: l.GasPriceDecider.GasPriceInWei()}, nil
+	}
+
+	history, err := l.rpc.FeeHistory(l.feeStrategy.historyBlocks, l.feeStrategy.priorityFeePercentile)
+	if err != nil {
+		return transactionFees{}, err
+	}
+
+	return l.feeStrategy.Fees(history), nil
+}
+
+// getChainID returns the chain id, it is loaded once.
+func (l *Launcher) getChainID() (int64, error) {
+	if l.chainID == 0 {
+		chainID, err := l.rpc.ChainID()
+		if err != nil {
+			return 0, err
+		}
+
+		l.chainID = chainID
+	}
+
+	return l.chainID, nil
+}
@@ -0,0 +1,76 @@
+package dex_launcher
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newTestFeeStrategy() *FeeStrategy {
+	return &FeeStrategy{
+		historyBlocks:         3,
+		priorityFeeStrategy:   PriorityFeePercentile,
+		priorityFeePercentile: 50,
+		priorityFee:           gwei(1),
+		maxFeeStrategy:        MaxFeeNextBaseFee,
+		baseFeeMultiplier:     decimal.New(2, 0),
+		maxGasPrice:           decimal.Zero,
+	}
+}
+
+func dynamicFees(maxFee, priorityFee decimal.Decimal) transactionFees {
+	return transactionFees{GasPrice: maxFee, MaxPriorityFeePerGas: decimal.NullDecimal{Decimal: priorityFee, Valid: true}}
+}
+
+func TestFeeStrategyFees(t *testing.T) {
+	history := &FeeHistory{
+		BaseFees: []decimal.Decimal{gwei(40), gwei(10), gwei(20), gwei(30)},
+		Rewards:  []decimal.Decimal{gwei(2), gwei(5), gwei(3)},
+	}
+
+	s := newTestFeeStrategy()
+
+	// next base fee 30 * 2 + median priority fee 3
+	fees := s.Fees(history)
+	assert.True(t, gwei(63).Equal(fees.GasPrice))
+	assert.True(t, gwei(3).Equal(fees.MaxPriorityFeePerGas.Decimal))
+
+	// the floor of the percentile
+	s.priorityFee = gwei(4)
+	assert.True(t, gwei(4).Equal(s.Fees(history).MaxPriorityFeePerGas.Decimal))
+
+	s.priorityFeeStrategy = PriorityFeeFixed
+	s.priorityFee = gwei(1)
+	assert.True(t, gwei(1).Equal(s.Fees(history).MaxPriorityFeePerGas.Decimal))
+
+	// highest recent base fee 40 * 2 + 1
+	s.maxFeeStrategy = MaxFeeRecentMax
+	assert.True(t, gwei(81).Equal(s.Fees(history).GasPrice))
+
+	// capped, the priority fee is not more than the max fee
+	s.maxGasPrice = gwei(50)
+	s.priorityFee = gwei(60)
+	fees = s.Fees(history)
+	assert.True(t, gwei(50).Equal(fees.GasPrice))
+	assert.True(t, gwei(50).Equal(fees.MaxPriorityFeePerGas.Decimal))
+}
+
+func TestBumpFees(t *testing.T) {
+	// legacy stays legacy
+	fees := bumpFees(transactionFees{GasPrice: gwei(10)}, dynamicFees(gwei(5), gwei(1)), 20, decimal.Zero)
+	assert.False(t, fees.isDynamic())
+	assert.True(t, gwei(12).Equal(fees.GasPrice))
+
+	fees = bumpFees(dynamicFees(gwei(10), gwei(2)), dynamicFees(gwei(5), gwei(1)), 20, decimal.Zero)
+	assert.True(t, gwei(12).Equal(fees.GasPrice))
+	assert.True(t, decimal.New(24, 8).Equal(fees.MaxPriorityFeePerGas.Decimal))
+	assert.True(t, isEnoughBump(dynamicFees(gwei(10), gwei(2)), fees))
+
+	// the priority fee can't be bumped when it is capped by the max fee
+	fees = bumpFees(dynamicFees(gwei(10), gwei(10)), dynamicFees(gwei(5), gwei(1)), 20, gwei(11))
+	assert.True(t, gwei(11).Equal(fees.MaxPriorityFeePerGas.Decimal))
+	assert.True(t, isEnoughBump(dynamicFees(gwei(10), gwei(10)), fees))
+
+	assert.False(t, isEnoughBump(dynamicFees(gwei(10), gwei(2)), dynamicFees(gwei(20), gwei(2))))
+	assert.False(t, isEnoughBump(transactionFees{GasPrice: gwei(10)}, transactionFees{GasPrice: decimal.New(105, 8)}))
+}
@@ -0,0 +1,41 @@
+package dex_launcher
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"os"
+	"strconv"
+)
+
+// The gas limit of a launch log is estimated by eth_estimateGas right before it is sent, plus a margin,
+// because the gas a match uses depends on the tokens, e.g. tokens with transfer hooks use much more.
+// The gas limit the launch log was created with is kept if it reverts in the estimation.
+
+func getGasLimitMarginPercent() int64 {
+	percent, err := strconv.ParseInt(os.Getenv("HSK_LAUNCHER_GAS_LIMIT_MARGIN_PERCENT"), 10, 64)
+	if err != nil || percent < 0 {
+		percent = 20
+	}
+
+	return percent
+}
+
+func gasLimitWithMargin(estimatedGas, marginPercent int64) int64 {
+	return estimatedGas * (100 + marginPercent) / 100
+}
+
+// estimateGasLimit sets the gas limit of the launch log by its estimation.
+func (l *Launcher) estimateGasLimit(launchLog *models.LaunchLog) error {
+	gas, err := l.rpc.EstimateGas(launchLog.From, launchLog.To, launchLog.Data)
+	if err != nil {
+		if reverted, reason := revertOf("", err); reverted {
+			utils.Infof("launch log %d reverts in gas estimation: %s, keep gas limit %d", launchLog.ID, reason, launchLog.GasLimit)
+			return nil
+		}
+
+		return err
+	}
+
+	launchLog.GasLimit = gasLimitWithMargin(gas, l.gasLimitMarginPercent)
+	return nil
+}
@@ -0,0 +1,12 @@
+package dex_launcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestGasLimitWithMargin(t *testing.T) {
+	assert.EqualValues(t, 120000, gasLimitWithMargin(100000, 20))
+	assert.EqualValues(t, 100000, gasLimitWithMargin(100000, 0))
+	assert.EqualValues(t, 25200, gasLimitWithMargin(21000, 20))
+}
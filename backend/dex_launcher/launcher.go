@@ -0,0 +1,374 @@
+package dex_launcher
+
+import (
+	"database/sql"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/launcher"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/lib/pq"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// Launch logs are claimed in batches, so that several launchers can run at the same time.
+// A launcher wakes up when a launch log is inserted, and polls as well in case a notification is lost.
+const pollingIntervalSeconds = 5
+
+const claimBatchSize = 50
+
+type Launcher struct {
+	*launcher.Launcher
+
+	eventQueue common.IQueue
+	rpc        *EthereumRPC
+
+	// notified when launch logs are inserted, nil if the launcher only polls
+	notifications <-chan *pq.Notification
+	claimLease    time.Duration
+
+	accountPool          *AccountPool
+	accountCheckInterval time.Duration
+	lastAccountCheck     time.Time
+
+	nonceManager  *NonceManager
+	fillNonceGaps bool
+
+	txType      string
+	feeStrategy *FeeStrategy
+	chainID     int64
+
+	replaceTimeout      time.Duration
+	gasPriceBumpPercent int64
+	maxGasPrice         decimal.Decimal
+	resubmitSchedule    *resubmitSchedule
+
+	sendRetries  int
+	retryBackoff time.Duration
+
+	simulationEnabled     bool
+	gasLimitMarginPercent int64
+
+	paperTrading bool
+}
+
+var needsAttentionGauge = metrics.NewGauge(
+	"hydro_launcher_launch_logs_needs_attention",
+	"Launch logs which can't be sent until the relayer account is fixed by hand.",
+)
+
+var launchLogsGivenUpCounter = metrics.NewCounter(
+	"hydro_launcher_launch_logs_given_up_total",
+	"Launch logs which are never going to be sent, by the status they are marked with.",
+	"status",
+)
+
+var updateErrorsCounter = metrics.NewCounter(
+	"hydro_launcher_update_errors_total",
+	"Sent launch logs which could not be saved as pending.",
+)
+
+func NewLauncher(l *launcher.Launcher, rpc *EthereumRPC, accountPool *AccountPool, eventQueue common.IQueue) *Launcher {
+	maxGasPrice := getMaxGasPrice()
+
+	return &Launcher{
+		Launcher:              l,
+		eventQueue:            eventQueue,
+		rpc:                   rpc,
+		accountPool:           accountPool,
+		accountCheckInterval:  getAccountCheckInterval(),
+		nonceManager:          NewNonceManager(rpc.PendingNonce),
+		fillNonceGaps:         isNonceGapFillEnabled(),
+		txType:                getTxType(),
+		feeStrategy:           NewFeeStrategy(maxGasPrice),
+		replaceTimeout:        getReplaceTimeout(),
+		gasPriceBumpPercent:   getGasPriceBumpPercent(),
+		maxGasPrice:           maxGasPrice,
+		sendRetries:           getSendRetries(),
+		simulationEnabled:     isSimulationEnabled(),
+		gasLimitMarginPercent: getGasLimitMarginPercent(),
+		retryBackoff:          time.Second,
+		resubmitSchedule:      newResubmitSchedule(5 * time.Second),
+		claimLease:            getClaimLease(),
+		paperTrading:          models.IsPaperTrading(),
+	}
+}
+
+// ListenTo makes the launcher wake up on the notifications of inserted launch logs, instead of only polling.
+func (l *Launcher) ListenTo(notifications <-chan *pq.Notification) {
+	l.notifications = notifications
+}
+
+func (l *Launcher) Run(startMetrics func()) {
+	utils.Infof("launcher start!")
+	defer utils.Infof("launcher stop!")
+	go startMetrics()
+
+	for {
+		// nothing is sent to the chain in paper trading mode
+		if !l.paperTrading {
+			if l.accountCheckInterval > 0 && time.Since(l.lastAccountCheck) >= l.accountCheckInterval {
+				l.checkAccounts()
+				l.lastAccountCheck = time.Now()
+			}
+
+			l.resubmitOrphanedLaunchLogs()
+			l.replaceStuckLaunchLogs()
+		} else {
+			l.confirmPaperSettlements()
+		}
+
+		needsAttentionGauge.Set(float64(models.LaunchLogDao.CountByStatus(models.LaunchLogStatusNeedsAttention)))
+
+		launchLogs := models.LaunchLogDao.ClaimCreated(claimBatchSize, l.claimLease)
+
+		if len(launchLogs) == 0 {
+			utils.Debugf("no logs need to be sent. wait %ds", pollingIntervalSeconds)
+
+			select {
+			case <-l.Ctx.Done():
+				utils.Infof("main loop Exit")
+				return
+			case <-l.notifications:
+				// one claim picks up all the launch logs inserted meanwhile
+				l.drainNotifications()
+			case <-time.After(pollingIntervalSeconds * time.Second):
+			}
+
+			continue
+		}
+
+		for i, modelLaunchLog := range launchLogs {
+			if !l.launch(modelLaunchLog) {
+				// the node is unavailable, the rest will fail the same way. Another launcher may have better luck
+				l.releaseClaims(launchLogs[i:])
+				time.Sleep(pollingIntervalSeconds * time.Second)
+				break
+			}
+		}
+	}
+}
+
+// launch sends a created launch log, returns false if it should be retried later.
+func (l *Launcher) launch(modelLaunchLog *models.LaunchLog) bool {
+	if l.paperTrading {
+		return l.settleOnLedger(modelLaunchLog)
+	}
+
+	if !modelLaunchLog.Nonce.Valid {
+		l.assignAccount(modelLaunchLog)
+	}
+
+	if modelLaunchLog.ItemType != models.LaunchLogItemTypeFillNonce {
+		if err := l.estimateGasLimit(modelLaunchLog); err != nil {
+			utils.Errorf("estimate gas of launch log %d error: %v", modelLaunchLog.ID, err)
+			return false
+		}
+	}
+
+	// simulated before a nonce is allocated, so that a launch log which reverts doesn't burn a nonce
+	if l.simulationEnabled && modelLaunchLog.ItemType != models.LaunchLogItemTypeFillNonce {
+		passed, err := l.simulate(modelLaunchLog)
+		if err != nil {
+			utils.Errorf("simulate launch log %d error: %v", modelLaunchLog.ID, err)
+			return false
+		}
+
+		if !passed {
+			return true
+		}
+	}
+
+	if !modelLaunchLog.Nonce.Valid {
+		err := l.retry(func() error { return l.nonceManager.Allocate(modelLaunchLog) })
+		if err != nil {
+			utils.Errorf("allocate nonce for launch log %d error: %v", modelLaunchLog.ID, err)
+			return false
+		}
+	}
+
+	fees, err := l.currentFees()
+	if err != nil {
+		utils.Errorf("decide fees for launch log %d error: %v", modelLaunchLog.ID, err)
+		return false
+	}
+
+	err = l.retry(func() error { return l.send(modelLaunchLog, fees) })
+	if err == nil {
+		return true
+	}
+
+	class := classifySendError(err)
+	sendErrorsCounter.Inc(string(class))
+	utils.Errorf("Send Tx failed, launchLog ID: %d, class: %s, err: %v", modelLaunchLog.ID, class, err)
+
+	switch class {
+	case sendErrorFailed:
+		l.giveUp(modelLaunchLog, common.STATUS_FAILED)
+	case sendErrorNeedsAttention:
+		l.giveUp(modelLaunchLog, models.LaunchLogStatusNeedsAttention)
+	default:
+		return false
+	}
+
+	return true
+}
+
+// send signs the launch log with its nonce and the fees, sends it and records the attempt, whether it succeeds or not.
+// Sending a launch log again replaces the transaction sent before.
+func (l *Launcher) send(modelLaunchLog *models.LaunchLog, fees transactionFees) error {
+	modelLaunchLog.GasPrice = decimal.NullDecimal{
+		Decimal: fees.GasPrice,
+		Valid:   true,
+	}
+	modelLaunchLog.MaxPriorityFeePerGas = fees.MaxPriorityFeePerGas
+
+	log := toSdkLaunchLog(modelLaunchLog)
+
+	attempt := &models.LaunchLogAttempt{
+		LaunchLogID: modelLaunchLog.ID,
+		Nonce:       log.Nonce.Int64,
+		GasPrice:    fees.GasPrice,
+		CreatedAt:   time.Now().UTC(),
+
+		MaxPriorityFeePerGas: fees.MaxPriorityFeePerGas,
+	}
+
+	signedRawTransaction, err := l.sign(log, fees)
+	if err != nil {
+		l.recordAttempt(attempt, err)
+		return err
+	}
+
+	signedAt := time.Now().UTC()
+	attempt.SignedAt = &signedAt
+	attempt.Hash = log.Hash.String
+
+	// the hash is known once it is signed, keep it even if sending fails
+	modelLaunchLog.Hash = log.Hash
+
+	transactionHash, err := l.BlockChain.SendRawTransaction(signedRawTransaction)
+
+	sentAt := time.Now().UTC()
+	attempt.SentAt = &sentAt
+
+	if err != nil && classifySendError(err) != sendErrorKnown {
+		utils.Debugf("%+v", modelLaunchLog)
+		l.recordAttempt(attempt, err)
+		return err
+	}
+
+	utils.Infof("Send Tx, launchLog ID: %d, nonce: %d, gas price: %s, priority fee: %s, hash: %s", modelLaunchLog.ID, log.Nonce.Int64, fees.GasPrice, fees.MaxPriorityFeePerGas.Decimal, transactionHash)
+
+	l.recordAttempt(attempt, nil)
+
+	// the transaction is sent, an error from here is not an error of sending
+	err = l.retryDatabase(func() error { return models.UpdateLaunchLogToPending(modelLaunchLog) })
+
+	if err != nil {
+		updateErrorsCounter.Inc()
+		utils.Errorf("Update Launch Log Failed, ID: %d, err: %s", modelLaunchLog.ID, err)
+	}
+
+	return nil
+}
+
+// recordAttempt saves the attempt with the error it failed with, which is nil if the transaction was sent.
+func (l *Launcher) recordAttempt(attempt *models.LaunchLogAttempt, sendErr error) {
+	if sendErr != nil {
+		attempt.Error = sql.NullString{String: sendErr.Error(), Valid: true}
+	}
+
+	err := l.retryDatabase(func() error { return models.LaunchLogAttemptDao.InsertLaunchLogAttempt(attempt) })
+	if err != nil {
+		utils.Errorf("Insert Launch Log Attempt Failed, ID: %d, err: %s", attempt.LaunchLogID, err)
+	}
+}
+
+// sign signs the launch log as a legacy transaction, or as an EIP-1559 transaction if the fees have a priority fee.
+func (l *Launcher) sign(log *launcher.LaunchLog, fees transactionFees) (signedRawTransaction string, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			switch e := r.(type) {
+			case signer.UnavailableError:
+				// the remote signer can't be reached, retry later
+				err = e
+			case signer.ConfigError:
+				// the signer refuses the account, the launch log itself is fine
+				err = e
+			default:
+				err = signError{reason: r}
+			}
+		}
+	}()
+
+	if !fees.isDynamic() {
+		return l.SignService.Sign(log), nil
+	}
+
+	signService, ok := l.SignService.(SignService)
+	if !ok {
+		return "", signError{reason: "the sign service can't sign EIP-1559 transactions"}
+	}
+
+	chainID, err := l.getChainID()
+	if err != nil {
+		return "", err
+	}
+
+	return signService.SignDynamicFee(log, chainID, fees.MaxPriorityFeePerGas.Decimal), nil
+}
+
+// giveUp marks a launch log which can't be sent, and tells the engine its trades failed,
+// so that the pending amounts of the orders are released.
+func (l *Launcher) giveUp(modelLaunchLog *models.LaunchLog, status string) {
+	launchLogsGivenUpCounter.Inc(status)
+
+	transaction, err := models.UpdateLaunchLogToFailed(modelLaunchLog, status)
+	if err != nil {
+		utils.Errorf("Update Launch Log Failed, ID: %d, err: %s", modelLaunchLog.ID, err)
+		return
+	}
+
+	if transaction == nil {
+		return
+	}
+
+	event := &common.ConfirmTransactionEvent{
+		Event: common.Event{
+			Type:     common.EventConfirmTransaction,
+			MarketID: transaction.MarketID,
+		},
+		Hash:      modelLaunchLog.Hash.String,
+		Status:    common.STATUS_FAILED,
+		Timestamp: uint64(time.Now().Unix()),
+	}
+
+	if err := l.eventQueue.Push([]byte(utils.ToJsonString(event))); err != nil {
+		utils.Errorf("Push event into Queue Error: %v", err)
+	}
+}
+
+func toSdkLaunchLog(modelLaunchLog *models.LaunchLog) *launcher.LaunchLog {
+	return &launcher.LaunchLog{
+		ID:          modelLaunchLog.ID,
+		ItemType:    modelLaunchLog.ItemType,
+		ItemID:      modelLaunchLog.ItemID,
+		Status:      modelLaunchLog.Status,
+		Hash:        modelLaunchLog.Hash,
+		BlockNumber: modelLaunchLog.BlockNumber,
+		From:        modelLaunchLog.From,
+		To:          modelLaunchLog.To,
+		Value:       modelLaunchLog.Value,
+		GasLimit:    modelLaunchLog.GasLimit,
+		GasUsed:     modelLaunchLog.GasUsed,
+		GasPrice:    modelLaunchLog.GasPrice,
+		Nonce:       modelLaunchLog.Nonce,
+		Data:        modelLaunchLog.Data,
+		ExecutedAt:  modelLaunchLog.ExecutedAt,
+		CreatedAt:   modelLaunchLog.CreatedAt,
+		UpdatedAt:   modelLaunchLog.UpdatedAt,
+	}
+}
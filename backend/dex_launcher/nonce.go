@@ -0,0 +1,118 @@
+package dex_launcher
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"os"
+	"time"
+)
+
+// Nonces are allocated from the launch_logs table instead of a counter in memory, each sender has its own nonces,
+// so that restarts and several launchers sending from the same account never reuse a nonce.
+// The pending nonce of the chain is the lower bound, which skips nonces used by transactions sent outside the launcher.
+
+const fillNonceGasLimit = 21000
+
+type NonceManager struct {
+	pendingNonce func(address string) (int, error)
+}
+
+func NewNonceManager(pendingNonce func(string) (int, error)) *NonceManager {
+	return &NonceManager{
+		pendingNonce: pendingNonce,
+	}
+}
+
+// Allocate gives the launch log the next nonce of its sender.
+func (m *NonceManager) Allocate(launchLog *models.LaunchLog) error {
+	chainNonce, err := m.pendingNonce(launchLog.From)
+	if err != nil {
+		return err
+	}
+
+	return models.LaunchLogDao.AllocateNonce(launchLog, int64(chainNonce))
+}
+
+// FindGaps returns the nonces which are allocated, but are neither on chain nor held by a launch log to be sent.
+// e.g. the launch log of the nonce failed before it was sent. All the later transactions wait for a gap forever.
+func (m *NonceManager) FindGaps(address string) ([]int64, error) {
+	chainNonce, err := m.pendingNonce(address)
+	if err != nil {
+		return nil, err
+	}
+
+	maxNonce := models.LaunchLogDao.FindMaxNonce(address)
+	inFlight := models.LaunchLogDao.FindNoncesInFlight(address, int64(chainNonce))
+
+	return findNonceGaps(int64(chainNonce), maxNonce, inFlight), nil
+}
+
+// findNonceGaps returns the nonces in [chainNonce, maxNonce] which are not in the sorted inFlight nonces.
+func findNonceGaps(chainNonce, maxNonce int64, inFlight []int64) []int64 {
+	var gaps []int64
+
+	i := 0
+	for nonce := chainNonce; nonce <= maxNonce; nonce++ {
+		for i < len(inFlight) && inFlight[i] < nonce {
+			i++
+		}
+
+		if i < len(inFlight) && inFlight[i] == nonce {
+			continue
+		}
+
+		gaps = append(gaps, nonce)
+	}
+
+	return gaps
+}
+
+// FillGap creates a launch log of a zero value transfer to the account itself with the burned nonce.
+// It is sent by the launcher like the other launch logs.
+func (m *NonceManager) FillGap(address string, nonce int64) error {
+	now := time.Now().UTC()
+
+	launchLog := &models.LaunchLog{
+		ItemType: models.LaunchLogItemTypeFillNonce,
+		Status:   models.LaunchLogStatusCreated,
+		From:     address,
+		To:       address,
+		Value:    decimal.Zero,
+		GasLimit: fillNonceGasLimit,
+		Data:     "0x",
+
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+
+	launchLog.Nonce.Int64, launchLog.Nonce.Valid = nonce, true
+
+	return models.LaunchLogDao.InsertLaunchLog(launchLog)
+}
+
+func isNonceGapFillEnabled() bool {
+	return os.Getenv("HSK_LAUNCHER_FILL_NONCE_GAPS") == "true"
+}
+
+// checkNonceGaps reports the nonce gaps of the account, and fills them if it is enabled.
+func (l *Launcher) checkNonceGaps(address string) {
+	gaps, err := l.nonceManager.FindGaps(address)
+	if err != nil {
+		utils.Errorf("find nonce gaps of %s error: %v", address, err)
+		return
+	}
+
+	for _, nonce := range gaps {
+		if !l.fillNonceGaps {
+			utils.Errorf("nonce %d of %s is burned, later transactions are blocked, set HSK_LAUNCHER_FILL_NONCE_GAPS=true to fill it", nonce, address)
+			continue
+		}
+
+		utils.Infof("nonce %d of %s is burned, fill it with a self transfer", nonce, address)
+
+		if err := l.nonceManager.FillGap(address, nonce); err != nil {
+			utils.Errorf("fill nonce %d of %s error: %v", nonce, address, err)
+		}
+	}
+}
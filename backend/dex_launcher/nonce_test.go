@@ -0,0 +1,15 @@
+package dex_launcher
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestFindNonceGaps(t *testing.T) {
+	assert.EqualValues(t, []int64(nil), findNonceGaps(5, 7, []int64{5, 6, 7}))
+	assert.EqualValues(t, []int64{6}, findNonceGaps(5, 7, []int64{5, 7}))
+	assert.EqualValues(t, []int64{5, 6, 7}, findNonceGaps(5, 7, nil))
+
+	// nothing allocated above the chain nonce
+	assert.EqualValues(t, []int64(nil), findNonceGaps(8, 7, nil))
+}
@@ -0,0 +1,56 @@
+package dex_launcher
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/lib/pq"
+	"os"
+	"strconv"
+	"time"
+)
+
+// NewLaunchLogListener listens to the launch logs inserted by the engine, the admin and other launchers.
+// After it reconnects, it sends a nil notification, which wakes the launcher up to catch up as well.
+func NewLaunchLogListener(databaseURL string) (*pq.Listener, error) {
+	listener := pq.NewListener(databaseURL, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			utils.Errorf("launch log listener error: %v", err)
+		}
+	})
+
+	if err := listener.Listen(models.LaunchLogsCreatedChannel); err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	return listener, nil
+}
+
+// getClaimLease returns how long a launch log is held by the launcher claiming it.
+// It must be longer than sending a launch log takes, including the retries.
+func getClaimLease() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("HSK_LAUNCHER_CLAIM_LEASE_SECONDS"))
+	if err != nil || seconds <= 0 {
+		seconds = 120
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+func (l *Launcher) drainNotifications() {
+	for {
+		select {
+		case <-l.notifications:
+		default:
+			return
+		}
+	}
+}
+
+func (l *Launcher) releaseClaims(launchLogs []*models.LaunchLog) {
+	for _, launchLog := range launchLogs {
+		if err := models.LaunchLogDao.ReleaseClaim(launchLog); err != nil {
+			utils.Errorf("release claim of launch log %d error: %v", launchLog.ID, err)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+package dex_launcher
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/relayer_pnl"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"os"
+	"time"
+)
+
+// In paper trading mode, a trade launch log is settled on the ledger instead of being sent,
+// and the engine is told at once that it is successful, or failed if a balance is not enough.
+// A settled launch log stays pending until the engine applies its confirmation, which is pushed again if it is lost.
+// The amounts and fees are those the exchange contract would move, see models.CalculateTradeFees.
+// Launch logs of other types mean nothing without a chain, they are just marked successful.
+
+var paperSettlementsCounter = metrics.NewCounter(
+	"hydro_launcher_paper_settlements_total",
+	"Trade launch logs settled on the ledger in paper trading mode, by status.",
+	"status",
+)
+
+// paperHash is the placeholder hash of a launch log settled on the ledger, the engine finds its transaction by it.
+func paperHash(launchLog *models.LaunchLog) sql.NullString {
+	return sql.NullString{String: fmt.Sprintf("paper:%d", launchLog.ID), Valid: true}
+}
+
+func paperLedgerReference(launchLog *models.LaunchLog) string {
+	return fmt.Sprintf("launch_log:%d", launchLog.ID)
+}
+
+// settleOnLedger settles a created launch log on the ledger, returns false if it should be retried later.
+// Applying the entries of a launch log again does nothing, so a launch log claimed again after a crash is only confirmed.
+func (l *Launcher) settleOnLedger(launchLog *models.LaunchLog) bool {
+	if launchLog.ItemType != models.LaunchLogItemTypeTrade {
+		launchLog.Hash = paperHash(launchLog)
+
+		for _, status := range []string{common.STATUS_PENDING, common.STATUS_SUCCESSFUL} {
+			if err := models.TransitLaunchLog(launchLog, status); err != nil {
+				utils.Errorf("update launch log %d error: %v", launchLog.ID, err)
+				return false
+			}
+		}
+
+		return true
+	}
+
+	relayer := os.Getenv("HSK_RELAYER_ADDRESS")
+
+	entries, err := tradeLedgerEntries(launchLog.ItemID, relayer)
+	if err != nil {
+		utils.Errorf("ledger entries of launch log %d error: %v", launchLog.ID, err)
+		return false
+	}
+
+	// the relayer pays the maker rebates out of the fees, and out of its own pocket when they are more than the fees
+	_, err = models.LedgerDao.Apply(paperLedgerReference(launchLog), entries, relayer)
+	if insufficient, ok := err.(models.InsufficientLedgerBalanceError); ok {
+		utils.Infof("launch log %d is not settled on the ledger: %v", launchLog.ID, insufficient)
+		paperSettlementsCounter.Inc(common.STATUS_FAILED)
+		l.giveUp(launchLog, common.STATUS_FAILED)
+		return true
+	} else if err != nil {
+		utils.Errorf("apply ledger entries of launch log %d error: %v", launchLog.ID, err)
+		return false
+	}
+
+	launchLog.Hash = paperHash(launchLog)
+
+	if err := models.UpdateLaunchLogToPending(launchLog); err != nil {
+		utils.Errorf("Update Launch Log Failed, ID: %d, err: %s", launchLog.ID, err)
+		return false
+	}
+
+	paperSettlementsCounter.Inc(common.STATUS_SUCCESSFUL)
+
+	// nothing is sent, so the relayer pays no gas
+	relayer_pnl.RecordTradeRevenues(launchLog.ItemID, time.Now())
+
+	// if the confirmation is lost, it is pushed again by confirmPaperSettlements
+	l.pushPaperConfirmation(launchLog)
+
+	return true
+}
+
+// a settled launch log which is still pending this long after has its confirmation pushed again
+const paperConfirmationTimeout = time.Minute
+
+// confirmPaperSettlements pushes the confirmations of the settled launch logs which the engine hasn't applied again,
+// e.g. the push failed, or the launcher stopped right after the launch log moved to pending.
+// The engine skips a transaction which is successful already, so a confirmation pushed twice does no harm.
+func (l *Launcher) confirmPaperSettlements() {
+	for _, launchLog := range models.LaunchLogDao.FindAllPending() {
+		if !isUnconfirmedPaperSettlement(launchLog, time.Now()) {
+			continue
+		}
+
+		utils.Infof("launch log %d is settled on the ledger but still pending since %s, confirm it again", launchLog.ID, launchLog.UpdatedAt)
+		l.pushPaperConfirmation(launchLog)
+	}
+}
+
+func isUnconfirmedPaperSettlement(launchLog *models.LaunchLog, now time.Time) bool {
+	return launchLog.ItemType == models.LaunchLogItemTypeTrade &&
+		launchLog.Status == common.STATUS_PENDING &&
+		launchLog.Hash.String == paperHash(launchLog).String &&
+		now.Sub(launchLog.UpdatedAt) >= paperConfirmationTimeout
+}
+
+func (l *Launcher) pushPaperConfirmation(launchLog *models.LaunchLog) {
+	transaction := models.TransactionDao.FindTransactionByID(launchLog.ItemID)
+
+	event := &common.ConfirmTransactionEvent{
+		Event: common.Event{
+			Type:     common.EventConfirmTransaction,
+			MarketID: transaction.MarketID,
+		},
+		Hash:      launchLog.Hash.String,
+		Status:    common.STATUS_SUCCESSFUL,
+		Timestamp: uint64(time.Now().Unix()),
+	}
+
+	if err := l.eventQueue.Push([]byte(utils.ToJsonString(event))); err != nil {
+		utils.Errorf("Push event into Queue Error: %v", err)
+	}
+}
+
+// tradeLedgerEntries loads the trades of the transaction with their orders and market, and returns their ledger entries.
+func tradeLedgerEntries(transactionID int64, relayer string) ([]*models.LedgerEntry, error) {
+	settlement, err := models.FindSettlement(transactionID)
+	if err != nil {
+		return nil, err
+	}
+
+	return ledgerEntries(settlement.Trades, settlement.Fees(), settlement.Market, relayer), nil
+}
+
+// ledgerEntries returns the entries of the trades and their fees: the base token goes from the seller to the buyer
+// and the quote token the other way, the fees in quote token go to the relayer, and the relayer pays the maker rebates.
+func ledgerEntries(trades []*models.Trade, fees []*models.TradeFees, market *models.Market, relayer string) []*models.LedgerEntry {
+	var entries []*models.LedgerEntry
+
+	add := func(kind, address, tokenAddress string, amount decimal.Decimal) {
+		if amount.IsZero() {
+			return
+		}
+
+		entries = append(entries, &models.LedgerEntry{Kind: kind, Address: address, TokenAddress: tokenAddress, Amount: amount})
+	}
+
+	// a fee is paid by the trader to the relayer
+	fee := func(kind, trader string, amount decimal.Decimal) {
+		add(kind, trader, market.QuoteTokenAddress, amount.Neg())
+		add(kind, relayer, market.QuoteTokenAddress, amount)
+	}
+
+	for i, trade := range trades {
+		seller, buyer := trade.Taker, trade.Maker
+		if trade.TakerSide == "buy" {
+			seller, buyer = trade.Maker, trade.Taker
+		}
+
+		add(models.LedgerEntryKindTrade, seller, market.BaseTokenAddress, trade.Amount.Neg())
+		add(models.LedgerEntryKindTrade, buyer, market.BaseTokenAddress, trade.Amount)
+		add(models.LedgerEntryKindTrade, buyer, market.QuoteTokenAddress, fees[i].QuoteAmount.Neg())
+		add(models.LedgerEntryKindTrade, seller, market.QuoteTokenAddress, fees[i].QuoteAmount)
+
+		fee(models.LedgerEntryKindFee, trade.Maker, fees[i].MakerFee)
+		fee(models.LedgerEntryKindFee, trade.Taker, fees[i].TakerFee)
+
+		add(models.LedgerEntryKindRebate, relayer, market.QuoteTokenAddress, fees[i].MakerRebate.Neg())
+		add(models.LedgerEntryKindRebate, trade.Maker, market.QuoteTokenAddress, fees[i].MakerRebate)
+
+		fee(models.LedgerEntryKindGasFee, trade.Maker, fees[i].MakerGasFee)
+		fee(models.LedgerEntryKindGasFee, trade.Taker, fees[i].TakerGasFee)
+	}
+
+	return entries
+}
@@ -0,0 +1,136 @@
+package dex_launcher
+
+import (
+	"database/sql"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+const (
+	paperRelayer = "0xrelayer"
+	paperMaker   = "0xmaker"
+	paperTaker   = "0xtaker"
+	paperBase    = "0xhot"
+	paperQuote   = "0xdai"
+)
+
+func paperBalances(entries []*models.LedgerEntry) map[[2]string]string {
+	balances := make(map[[2]string]string)
+	for _, balance := range models.NetLedgerEntries(entries) {
+		balances[[2]string{balance.Address, balance.TokenAddress}] = balance.Amount.String()
+	}
+
+	return balances
+}
+
+func newPaperOrders(makerRebateRate string) map[string]*models.Order {
+	return map[string]*models.Order{
+		"maker": {
+			ID:              "maker",
+			TraderAddress:   paperMaker,
+			MakerFeeRate:    decimal.RequireFromString("0.001"),
+			MakerRebateRate: decimal.RequireFromString(makerRebateRate),
+			GasFeeAmount:    decimal.RequireFromString("0.5"),
+		},
+		"taker": {
+			ID:            "taker",
+			TraderAddress: paperTaker,
+			TakerFeeRate:  decimal.RequireFromString("0.003"),
+			GasFeeAmount:  decimal.RequireFromString("0.5"),
+		},
+	}
+}
+
+func newPaperTrade(id int64, takerSide, amount, price string) *models.Trade {
+	return &models.Trade{
+		ID:           id,
+		Maker:        paperMaker,
+		Taker:        paperTaker,
+		TakerSide:    takerSide,
+		MakerOrderID: "maker",
+		TakerOrderID: "taker",
+		Amount:       decimal.RequireFromString(amount),
+		Price:        decimal.RequireFromString(price),
+	}
+}
+
+var paperMarket = &models.Market{
+	BaseTokenAddress:   paperBase,
+	QuoteTokenAddress:  paperQuote,
+	QuoteTokenDecimals: 18,
+}
+
+func TestLedgerEntries(t *testing.T) {
+	// the taker sells 100 HOT at 2 DAI in two trades, each order pays its gas fee once
+	trades := []*models.Trade{newPaperTrade(1, "sell", "40", "2"), newPaperTrade(2, "sell", "60", "2")}
+	fees := models.CalculateTradeFees(trades, newPaperOrders("0"), paperMarket, map[string]bool{})
+	entries := ledgerEntries(trades, fees, paperMarket, paperRelayer)
+
+	assert.EqualValues(t, map[[2]string]string{
+		{paperTaker, paperBase}:    "-100",
+		{paperMaker, paperBase}:    "100",
+		{paperTaker, paperQuote}:   "198.9",  // 200 - 0.6 fee - 0.5 gas
+		{paperMaker, paperQuote}:   "-200.7", // 200 + 0.2 fee + 0.5 gas
+		{paperRelayer, paperQuote}: "1.8",
+	}, paperBalances(entries))
+}
+
+func TestLedgerEntriesWithRebate(t *testing.T) {
+	// the taker buys, the maker gets half of the taker fee instead of paying its fee, the gas fees are paid already
+	trades := []*models.Trade{newPaperTrade(1, "buy", "100", "2")}
+	fees := models.CalculateTradeFees(trades, newPaperOrders("0.5"), paperMarket, map[string]bool{"maker": true, "taker": true})
+	entries := ledgerEntries(trades, fees, paperMarket, paperRelayer)
+
+	assert.EqualValues(t, map[[2]string]string{
+		{paperTaker, paperBase}:    "100",
+		{paperMaker, paperBase}:    "-100",
+		{paperTaker, paperQuote}:   "-200.6",
+		{paperMaker, paperQuote}:   "200.3",
+		{paperRelayer, paperQuote}: "0.3",
+	}, paperBalances(entries))
+
+	for _, entry := range entries {
+		assert.False(t, entry.Kind == models.LedgerEntryKindGasFee)
+	}
+}
+
+func TestLedgerEntriesWithRebateOverFees(t *testing.T) {
+	// the maker gets 1.5 times the taker fee, the relayer pays the difference
+	trades := []*models.Trade{newPaperTrade(1, "buy", "100", "2")}
+	fees := models.CalculateTradeFees(trades, newPaperOrders("1.5"), paperMarket, map[string]bool{"maker": true, "taker": true})
+	entries := ledgerEntries(trades, fees, paperMarket, paperRelayer)
+
+	assert.EqualValues(t, "-0.3", fees[0].Revenue().String())
+	assert.EqualValues(t, map[[2]string]string{
+		{paperTaker, paperBase}:    "100",
+		{paperMaker, paperBase}:    "-100",
+		{paperTaker, paperQuote}:   "-200.6",
+		{paperMaker, paperQuote}:   "200.9",
+		{paperRelayer, paperQuote}: "-0.3",
+	}, paperBalances(entries))
+}
+
+func TestIsUnconfirmedPaperSettlement(t *testing.T) {
+	now := time.Unix(1548892800, 0)
+
+	launchLog := &models.LaunchLog{ID: 3, ItemType: models.LaunchLogItemTypeTrade, Status: common.STATUS_PENDING, UpdatedAt: now.Add(-paperConfirmationTimeout)}
+	launchLog.Hash = paperHash(launchLog)
+	assert.True(t, isUnconfirmedPaperSettlement(launchLog, now))
+
+	// its confirmation may still be on the way
+	assert.False(t, isUnconfirmedPaperSettlement(launchLog, now.Add(-time.Second)))
+
+	// a launch log sent to a chain before paper trading was turned on
+	sent := *launchLog
+	sent.Hash = sql.NullString{String: "0xabc", Valid: true}
+	assert.False(t, isUnconfirmedPaperSettlement(&sent, now))
+
+	// the engine has applied it
+	confirmed := *launchLog
+	confirmed.Status = common.STATUS_SUCCESSFUL
+	assert.False(t, isUnconfirmedPaperSettlement(&confirmed, now))
+}
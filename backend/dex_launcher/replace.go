@@ -0,0 +1,234 @@
+package dex_launcher
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"os"
+	"strconv"
+	"time"
+)
+
+// A transaction sent with a too low gas price stays in the mempool, and blocks all the later nonces behind it.
+// When a launch log has been pending for too long, it is signed again with the same nonce and a higher gas price.
+// Every attempt is recorded, the watcher accepts whichever of them is mined.
+
+// nodes only accept a replacement which pays at least 10% more
+const minGasPriceBumpPercent = 10
+
+func getReplaceTimeout() time.Duration {
+	seconds, err := strconv.Atoi(os.Getenv("HSK_LAUNCHER_REPLACE_TIMEOUT_SECONDS"))
+	if err != nil {
+		seconds = 180
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+func getGasPriceBumpPercent() int64 {
+	percent, err := strconv.ParseInt(os.Getenv("HSK_LAUNCHER_GAS_PRICE_BUMP_PERCENT"), 10, 64)
+	if err != nil || percent < minGasPriceBumpPercent {
+		percent = 20
+	}
+
+	return percent
+}
+
+// getMaxGasPrice returns the max gas price in wei, zero means no limit.
+func getMaxGasPrice() decimal.Decimal {
+	gwei, err := decimal.NewFromString(os.Getenv("HSK_LAUNCHER_MAX_GAS_PRICE_GWEI"))
+	if err != nil {
+		return decimal.Zero
+	}
+
+	return gwei.Mul(decimal.New(1, 9))
+}
+
+// bumpGasPrice returns the gas price to replace a transaction sent with oldGasPrice.
+// It is the bumped old price, or the current market price if that is higher, capped by maxGasPrice.
+func bumpGasPrice(oldGasPrice, currentGasPrice decimal.Decimal, bumpPercent int64, maxGasPrice decimal.Decimal) decimal.Decimal {
+	gasPrice := oldGasPrice.Mul(decimal.New(100+bumpPercent, -2)).Ceil()
+
+	if currentGasPrice.GreaterThan(gasPrice) {
+		gasPrice = currentGasPrice
+	}
+
+	if maxGasPrice.GreaterThan(decimal.Zero) && gasPrice.GreaterThan(maxGasPrice) {
+		gasPrice = maxGasPrice
+	}
+
+	return gasPrice
+}
+
+// bumpFees returns the fees to replace a transaction sent with oldFees.
+// The gas price, which is the max fee of an EIP-1559 transaction, is bumped by bumpGasPrice.
+// The priority fee of an EIP-1559 transaction is bumped the same way, and is capped by the max fee.
+// A legacy transaction is replaced by a legacy one, even if the launcher is switched to EIP-1559, so the type of a nonce doesn't change.
+func bumpFees(oldFees, currentFees transactionFees, bumpPercent int64, maxGasPrice decimal.Decimal) transactionFees {
+	fees := transactionFees{
+		GasPrice: bumpGasPrice(oldFees.GasPrice, currentFees.GasPrice, bumpPercent, maxGasPrice),
+	}
+
+	if !oldFees.isDynamic() {
+		return fees
+	}
+
+	priorityFee := bumpGasPrice(oldFees.MaxPriorityFeePerGas.Decimal, currentFees.MaxPriorityFeePerGas.Decimal, bumpPercent, maxGasPrice)
+	if priorityFee.GreaterThan(fees.GasPrice) {
+		priorityFee = fees.GasPrice
+	}
+
+	fees.MaxPriorityFeePerGas = decimal.NullDecimal{Decimal: priorityFee, Valid: true}
+	return fees
+}
+
+// isEnoughBump tells if nodes accept the fees to replace a transaction sent with oldFees,
+// both the max fee and the priority fee must be at least 10% higher.
+func isEnoughBump(oldFees, fees transactionFees) bool {
+	minBump := decimal.New(100+minGasPriceBumpPercent, -2)
+
+	if fees.GasPrice.LessThan(oldFees.GasPrice.Mul(minBump)) {
+		return false
+	}
+
+	if oldFees.isDynamic() && fees.MaxPriorityFeePerGas.Decimal.LessThan(oldFees.MaxPriorityFeePerGas.Decimal.Mul(minBump)) {
+		return false
+	}
+
+	return true
+}
+
+func (l *Launcher) replaceStuckLaunchLogs() {
+	if l.replaceTimeout <= 0 {
+		return
+	}
+
+	for _, launchLog := range models.LaunchLogDao.FindAllPending() {
+		if !launchLog.Nonce.Valid || time.Since(launchLog.UpdatedAt) < l.replaceTimeout {
+			continue
+		}
+
+		currentFees, err := l.currentFees()
+		if err != nil {
+			utils.Errorf("decide fees to replace launch log %d error: %v", launchLog.ID, err)
+			return
+		}
+
+		oldFees := transactionFees{GasPrice: launchLog.GasPrice.Decimal, MaxPriorityFeePerGas: launchLog.MaxPriorityFeePerGas}
+		fees := bumpFees(oldFees, currentFees, l.gasPriceBumpPercent, l.maxGasPrice)
+
+		// a replacement which doesn't pay enough more is rejected by nodes
+		if !isEnoughBump(oldFees, fees) {
+			utils.Debugf("launch log %d is stuck at the max gas price %s", launchLog.ID, launchLog.GasPrice.Decimal)
+			continue
+		}
+
+		utils.Infof("launch log %d has been pending since %s, replace it with gas price %s, priority fee %s", launchLog.ID, launchLog.UpdatedAt, fees.GasPrice, fees.MaxPriorityFeePerGas.Decimal)
+
+		// the sent transaction is still valid, so the launch log is never given up here.
+		// e.g. nonce too low means one of the attempts has been mined.
+		if err := l.send(launchLog, fees); err != nil {
+			class := classifySendError(err)
+			sendErrorsCounter.Inc(string(class))
+			utils.Infof("replace launch log %d failed, class: %s, err: %v", launchLog.ID, class, err)
+		}
+	}
+}
+
+// an orphaned launch log which keeps failing is sent again at most this often
+const maxResubmitBackoff = 5 * time.Minute
+
+// resubmitSchedule spaces out the resubmissions of each orphaned launch log which failed, the wait doubles every time.
+type resubmitSchedule struct {
+	backoff  time.Duration
+	failures map[int64]int
+	nextAt   map[int64]time.Time
+}
+
+func newResubmitSchedule(backoff time.Duration) *resubmitSchedule {
+	return &resubmitSchedule{
+		backoff:  backoff,
+		failures: make(map[int64]int),
+		nextAt:   make(map[int64]time.Time),
+	}
+}
+
+// isDue tells if the launch log can be sent again now.
+func (s *resubmitSchedule) isDue(id int64, now time.Time) bool {
+	return !now.Before(s.nextAt[id])
+}
+
+// failed records a failure of the launch log, and returns how many times in a row it failed.
+func (s *resubmitSchedule) failed(id int64, now time.Time) int {
+	s.failures[id]++
+
+	backoff := s.backoff << uint(s.failures[id]-1)
+	if backoff > maxResubmitBackoff || backoff <= 0 {
+		backoff = maxResubmitBackoff
+	}
+
+	s.nextAt[id] = now.Add(backoff)
+	return s.failures[id]
+}
+
+func (s *resubmitSchedule) forget(id int64) {
+	delete(s.failures, id)
+	delete(s.nextAt, id)
+}
+
+// keepOnly forgets the launch logs which are not orphaned anymore, e.g. the watcher saw them mined again.
+func (s *resubmitSchedule) keepOnly(launchLogs []*models.LaunchLog) {
+	orphaned := make(map[int64]bool, len(launchLogs))
+	for _, launchLog := range launchLogs {
+		orphaned[launchLog.ID] = true
+	}
+
+	for id := range s.failures {
+		if !orphaned[id] {
+			s.forget(id)
+		}
+	}
+}
+
+// resubmitOrphanedLaunchLogs sends the launch logs whose blocks are removed by a chain reorganization again.
+// The transaction is signed again with the same nonce and fees, so a node which still has it reports it as known.
+// If it is mined again meanwhile, the node rejects it as nonce too low, and the watcher confirms it anyway,
+// so nonce too low is only given up after the send retries, in case another transaction took the nonce.
+// Other errors are handled like those of a first send, and a launch log which keeps failing is sent again less and less often.
+func (l *Launcher) resubmitOrphanedLaunchLogs() {
+	launchLogs := models.LaunchLogDao.FindByStatus(models.LaunchLogStatusOrphaned)
+	l.resubmitSchedule.keepOnly(launchLogs)
+
+	for _, launchLog := range launchLogs {
+		if !launchLog.Nonce.Valid || !l.resubmitSchedule.isDue(launchLog.ID, time.Now()) {
+			continue
+		}
+
+		fees := transactionFees{GasPrice: launchLog.GasPrice.Decimal, MaxPriorityFeePerGas: launchLog.MaxPriorityFeePerGas}
+
+		utils.Infof("launch log %d is orphaned by a chain reorganization, send it again", launchLog.ID)
+
+		err := l.send(launchLog, fees)
+		if err == nil {
+			l.resubmitSchedule.forget(launchLog.ID)
+			continue
+		}
+
+		class := classifySendError(err)
+		sendErrorsCounter.Inc(string(class))
+		failures := l.resubmitSchedule.failed(launchLog.ID, time.Now())
+		utils.Errorf("resubmit launch log %d failed %d times, class: %s, err: %v", launchLog.ID, failures, class, err)
+
+		switch {
+		case class == sendErrorFailed:
+			l.giveUp(launchLog, common.STATUS_FAILED)
+		case class == sendErrorNeedsAttention && (!isNonceTooLow(err) || failures > l.sendRetries):
+			l.giveUp(launchLog, models.LaunchLogStatusNeedsAttention)
+		default:
+			continue
+		}
+
+		l.resubmitSchedule.forget(launchLog.ID)
+	}
+}
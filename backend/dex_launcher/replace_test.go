@@ -0,0 +1,65 @@
+package dex_launcher
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/onrik/ethrpc"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func gwei(n int64) decimal.Decimal {
+	return decimal.New(n, 9)
+}
+
+func TestBumpGasPrice(t *testing.T) {
+	// bumped old price
+	assert.True(t, gwei(12).Equal(bumpGasPrice(gwei(10), gwei(5), 20, decimal.Zero)))
+
+	// market price is higher than the bumped price
+	assert.True(t, gwei(30).Equal(bumpGasPrice(gwei(10), gwei(30), 20, decimal.Zero)))
+
+	// capped
+	assert.True(t, gwei(11).Equal(bumpGasPrice(gwei(10), gwei(30), 20, gwei(11))))
+
+	// wei is an integer
+	assert.EqualValues(t, "4", bumpGasPrice(decimal.New(3, 0), decimal.Zero, 20, decimal.Zero).String())
+}
+
+func TestResubmitSchedule(t *testing.T) {
+	schedule := newResubmitSchedule(5 * time.Second)
+	now := time.Unix(1548892800, 0)
+
+	assert.True(t, schedule.isDue(1, now))
+
+	// the wait doubles after every failure
+	assert.EqualValues(t, 1, schedule.failed(1, now))
+	assert.False(t, schedule.isDue(1, now.Add(4*time.Second)))
+	assert.True(t, schedule.isDue(1, now.Add(5*time.Second)))
+
+	assert.EqualValues(t, 2, schedule.failed(1, now))
+	assert.False(t, schedule.isDue(1, now.Add(9*time.Second)))
+	assert.True(t, schedule.isDue(1, now.Add(10*time.Second)))
+
+	// and is capped
+	for i := 0; i < 20; i++ {
+		schedule.failed(1, now)
+	}
+	assert.True(t, schedule.isDue(1, now.Add(maxResubmitBackoff)))
+
+	// other launch logs are not delayed
+	assert.True(t, schedule.isDue(2, now))
+
+	// a launch log which is not orphaned anymore is forgotten
+	schedule.failed(2, now)
+	schedule.keepOnly([]*models.LaunchLog{{ID: 2}})
+	assert.True(t, schedule.isDue(1, now))
+	assert.False(t, schedule.isDue(2, now))
+}
+
+func TestIsNonceTooLow(t *testing.T) {
+	assert.True(t, isNonceTooLow(ethrpc.EthError{Code: -32000, Message: "nonce too low"}))
+	assert.False(t, isNonceTooLow(ethrpc.EthError{Code: -32000, Message: "insufficient funds for gas * price + value"}))
+	assert.False(t, isNonceTooLow(signError{reason: "nonce too low"}))
+}
@@ -0,0 +1,174 @@
+package dex_launcher
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/onrik/ethrpc"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// EthereumRPC has the rpc calls the launcher needs but the sdk doesn't provide.
+type EthereumRPC struct {
+	client *ethrpc.EthRPC
+}
+
+func NewEthereumRPC(url string) *EthereumRPC {
+	return &EthereumRPC{
+		client: ethrpc.New(url),
+	}
+}
+
+// PendingNonce returns the next nonce of the address, counting the transactions in the mempool.
+func (r *EthereumRPC) PendingNonce(address string) (int, error) {
+	return r.client.EthGetTransactionCount(address, "pending")
+}
+
+// Balance returns the ether balance of the address in wei.
+func (r *EthereumRPC) Balance(address string) (decimal.Decimal, error) {
+	balance, err := r.client.EthGetBalance(address, "latest")
+	if err != nil {
+		return decimal.Zero, err
+	}
+
+	return decimal.NewFromBigInt(&balance, 0), nil
+}
+
+// Call executes the call data at the latest block without sending a transaction.
+func (r *EthereumRPC) Call(from, to, data string, gasLimit int64) (string, error) {
+	return r.client.EthCall(ethrpc.T{
+		From: from,
+		To:   to,
+		Gas:  int(gasLimit),
+		Data: data,
+	}, "latest")
+}
+
+// EstimateGas returns the gas the call data uses at the latest block.
+func (r *EthereumRPC) EstimateGas(from, to, data string) (int64, error) {
+	gas, err := r.client.EthEstimateGas(ethrpc.T{
+		From: from,
+		To:   to,
+		Data: data,
+	})
+
+	return int64(gas), err
+}
+
+// FeeHistory is the fee market of recent blocks.
+type FeeHistory struct {
+	// base fees of the blocks, the last one is the base fee of the next block
+	BaseFees []decimal.Decimal
+
+	// priority fees paid at the percentile in each block
+	Rewards []decimal.Decimal
+}
+
+// FeeHistory returns the base fees and the priority fees at the percentile of the latest blocks.
+func (r *EthereumRPC) FeeHistory(blocks int, percentile float64) (*FeeHistory, error) {
+	var res struct {
+		BaseFeePerGas []string   `json:"baseFeePerGas"`
+		Reward        [][]string `json:"reward"`
+	}
+
+	result, err := r.client.Call("eth_feeHistory", fmt.Sprintf("0x%x", blocks), "latest", []float64{percentile})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(result, &res); err != nil {
+		return nil, err
+	}
+
+	history := &FeeHistory{}
+
+	for _, baseFee := range res.BaseFeePerGas {
+		history.BaseFees = append(history.BaseFees, hexToDecimal(baseFee))
+	}
+
+	for _, reward := range res.Reward {
+		if len(reward) > 0 {
+			history.Rewards = append(history.Rewards, hexToDecimal(reward[0]))
+		}
+	}
+
+	if len(history.BaseFees) == 0 {
+		return nil, fmt.Errorf("no base fee in fee history, the chain may not support EIP-1559")
+	}
+
+	return history, nil
+}
+
+// ChainID returns the chain id, which EIP-1559 transactions are signed with.
+func (r *EthereumRPC) ChainID() (int64, error) {
+	var chainID string
+
+	result, err := r.client.Call("eth_chainId")
+	if err != nil {
+		return 0, err
+	}
+
+	if err := json.Unmarshal(result, &chainID); err != nil {
+		return 0, err
+	}
+
+	return hexToDecimal(chainID).IntPart(), nil
+}
+
+// Receipt has the fields of a transaction receipt the launcher records.
+type Receipt struct {
+	GasUsed int64
+
+	// the price paid per gas, it's missing on nodes before the London fork
+	EffectiveGasPrice decimal.NullDecimal
+}
+
+// Receipt returns the receipt of a mined transaction, nil if it isn't mined.
+func (r *EthereumRPC) Receipt(hash string) (*Receipt, error) {
+	var res *struct {
+		GasUsed           string `json:"gasUsed"`
+		EffectiveGasPrice string `json:"effectiveGasPrice"`
+	}
+
+	result, err := r.client.Call("eth_getTransactionReceipt", hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(result, &res); err != nil {
+		return nil, err
+	}
+
+	if res == nil {
+		return nil, nil
+	}
+
+	receipt := &Receipt{
+		GasUsed: hexToDecimal(res.GasUsed).IntPart(),
+	}
+
+	if res.EffectiveGasPrice != "" {
+		receipt.EffectiveGasPrice = decimal.NullDecimal{Decimal: hexToDecimal(res.EffectiveGasPrice), Valid: true}
+	}
+
+	return receipt, nil
+}
+
+// BlockTime returns the time the block was mined at.
+func (r *EthereumRPC) BlockTime(blockNumber uint64) (time.Time, error) {
+	block, err := r.client.EthGetBlockByNumber(int(blockNumber), false)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if block == nil {
+		return time.Time{}, fmt.Errorf("block %d is not found", blockNumber)
+	}
+
+	return time.Unix(int64(block.Timestamp), 0), nil
+}
+
+func hexToDecimal(hex string) decimal.Decimal {
+	return decimal.NewFromBigInt(utils.Hex2BigInt(hex), 0)
+}
@@ -0,0 +1,149 @@
+package dex_launcher
+
+import (
+	"database/sql"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/launcher"
+	sdksigner "github.com/HydroProtocol/hydro-sdk-backend/sdk/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/types"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"strings"
+)
+
+// SignService signs launch logs with the key of their senders.
+type SignService interface {
+	launcher.ISignService
+
+	// Addresses returns the accounts the service can sign for
+	Addresses() []string
+
+	// SignDynamicFee signs the launch log as an EIP-1559 transaction, its gas price is the max fee per gas
+	SignDynamicFee(launchLog *launcher.LaunchLog, chainID int64, maxPriorityFeePerGas decimal.Decimal) string
+}
+
+// signerSignService works like the sdk default sign service, except that it doesn't keep a nonce,
+// and signs for several accounts through signers, so that it never holds a key itself.
+// A launch log is signed with the nonce allocated to it by the NonceManager,
+// so a stuck transaction can be replaced by signing its launch log again.
+type signerSignService struct {
+	addresses []string
+	signers   map[string]signer.Signer
+}
+
+func NewSignService(signers ...signer.Signer) SignService {
+	s := &signerSignService{
+		signers: make(map[string]signer.Signer),
+	}
+
+	for _, sg := range signers {
+		address := strings.ToLower(sg.Address())
+		if _, ok := s.signers[address]; ok {
+			continue
+		}
+
+		s.addresses = append(s.addresses, address)
+		s.signers[address] = sg
+	}
+
+	return s
+}
+
+// NewLocalSignService signs with plain hex private keys, it is for development only.
+func NewLocalSignService(privateKeyStrs ...string) SignService {
+	signers := make([]signer.Signer, 0, len(privateKeyStrs))
+
+	for _, privateKeyStr := range privateKeyStrs {
+		sg, err := signer.NewPrivateKeySignerByHex(privateKeyStr)
+		if err != nil {
+			panic(err)
+		}
+
+		signers = append(signers, sg)
+	}
+
+	return NewSignService(signers...)
+}
+
+func (s *signerSignService) Addresses() []string {
+	return s.addresses
+}
+
+// AfterSign does nothing, nonces are allocated by the NonceManager.
+func (s *signerSignService) AfterSign() {
+}
+
+// Sign panics if the launch log can't be signed, as the sdk sign service does.
+func (s *signerSignService) Sign(launchLog *launcher.LaunchLog) string {
+	sg := s.signerOf(launchLog)
+
+	transaction := types.NewTransaction(
+		uint64(launchLog.Nonce.Int64),
+		launchLog.To,
+		utils.DecimalToBigInt(launchLog.Value),
+		uint64(launchLog.GasLimit),
+		utils.DecimalToBigInt(launchLog.GasPrice.Decimal),
+		utils.Hex2Bytes(launchLog.Data[2:]),
+	)
+
+	signature, err := sg.SignHash(sdksigner.HomesteadHash(transaction))
+	if err != nil {
+		utils.Errorf("sign transaction error: %v", err)
+		panic(err)
+	}
+
+	// Since we are using HomesteadHash, the v is either 27 or 28
+	signature[64] += 27
+	transaction.Signature = signature
+
+	launchLog.Hash = sql.NullString{
+		String: utils.Bytes2HexP(sdksigner.Hash(transaction)),
+		Valid:  true,
+	}
+
+	return utils.Bytes2HexP(sdksigner.EncodeRlp(transaction))
+}
+
+func (s *signerSignService) SignDynamicFee(launchLog *launcher.LaunchLog, chainID int64, maxPriorityFeePerGas decimal.Decimal) string {
+	sg := s.signerOf(launchLog)
+
+	transaction := &dynamicFeeTx{
+		ChainID:              chainID,
+		Nonce:                uint64(launchLog.Nonce.Int64),
+		MaxPriorityFeePerGas: utils.DecimalToBigInt(maxPriorityFeePerGas),
+		MaxFeePerGas:         utils.DecimalToBigInt(launchLog.GasPrice.Decimal),
+		GasLimit:             uint64(launchLog.GasLimit),
+		To:                   launchLog.To,
+		Value:                utils.DecimalToBigInt(launchLog.Value),
+		Data:                 utils.Hex2Bytes(launchLog.Data[2:]),
+	}
+
+	signature, err := sg.SignHash(transaction.SigningHash())
+	if err != nil {
+		utils.Errorf("sign transaction error: %v", err)
+		panic(err)
+	}
+
+	transaction.Signature = signature
+
+	launchLog.Hash = sql.NullString{
+		String: utils.Bytes2HexP(transaction.Hash()),
+		Valid:  true,
+	}
+
+	return utils.Bytes2HexP(transaction.EncodeRlp())
+}
+
+func (s *signerSignService) signerOf(launchLog *launcher.LaunchLog) signer.Signer {
+	if !launchLog.Nonce.Valid {
+		panic(fmt.Errorf("launch log %d has no nonce", launchLog.ID))
+	}
+
+	sg := s.signers[strings.ToLower(launchLog.From)]
+	if sg == nil {
+		panic(signer.ConfigError{Err: fmt.Errorf("no signer of launch log %d sender %s", launchLog.ID, launchLog.From)})
+	}
+
+	return sg
+}
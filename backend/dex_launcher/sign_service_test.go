@@ -0,0 +1,151 @@
+package dex_launcher
+
+import (
+	"database/sql"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/launcher"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testPrivateKey = "b7a0c9d2786fc4dd080ea5d619d36771aeb0c8c26c290afd3451b92ba2b7bc2c"
+const testAddress = "0x31ebd457b999bf99759602f5ece5aa5033cb56b3"
+
+func newTestLaunchLog(nonce int64, gasPrice decimal.Decimal) *launcher.LaunchLog {
+	return &launcher.LaunchLog{
+		From:     testAddress,
+		To:       "0x93388b4efe13b9b18ed480783c05462409851547",
+		Value:    decimal.Zero,
+		GasLimit: 250000,
+		GasPrice: decimal.NullDecimal{Decimal: gasPrice, Valid: true},
+		Nonce:    sql.NullInt64{Int64: nonce, Valid: true},
+		Data:     "0x",
+	}
+}
+
+func TestLocalSignServiceReplace(t *testing.T) {
+	signService := NewLocalSignService(testPrivateKey, testPrivateKey)
+	assert.EqualValues(t, []string{testAddress}, signService.Addresses())
+
+	log := newTestLaunchLog(5, gwei(3))
+	signService.Sign(log)
+	firstHash := log.Hash.String
+
+	// replacement keeps the nonce, but has a new hash
+	log.GasPrice.Decimal = gwei(4)
+	signService.Sign(log)
+	assert.EqualValues(t, 5, log.Nonce.Int64)
+	assert.NotEqual(t, firstHash, log.Hash.String)
+
+	log.Nonce.Valid = false
+	assert.Panics(t, func() { signService.Sign(log) })
+
+	// unknown sender
+	other := newTestLaunchLog(1, gwei(3))
+	other.From = "0x93388b4efe13b9b18ed480783c05462409851547"
+	assert.Panics(t, func() { signService.Sign(other) })
+}
+
+func TestSignMalformedLaunchLog(t *testing.T) {
+	l := &Launcher{Launcher: &launcher.Launcher{SignService: NewLocalSignService(testPrivateKey)}}
+
+	log := newTestLaunchLog(1, gwei(3))
+	log.Data = ""
+
+	_, err := l.sign(log, transactionFees{GasPrice: gwei(3)})
+	assert.EqualValues(t, sendErrorFailed, classifySendError(err))
+}
+
+func TestSignRemoteSignerUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	signService := NewSignService(signer.NewRemoteSigner(server.URL, testAddress, ""))
+	l := &Launcher{Launcher: &launcher.Launcher{SignService: signService}}
+
+	_, err := l.sign(newTestLaunchLog(1, gwei(3)), transactionFees{GasPrice: gwei(3)})
+	assert.EqualValues(t, sendErrorTransient, classifySendError(err))
+}
+
+func TestSignRemoteSignerMisconfigured(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	signService := NewSignService(signer.NewRemoteSigner(server.URL, testAddress, "wrong"))
+	l := &Launcher{Launcher: &launcher.Launcher{SignService: signService}}
+
+	_, err := l.sign(newTestLaunchLog(1, gwei(3)), transactionFees{GasPrice: gwei(3)})
+	assert.EqualValues(t, sendErrorNeedsAttention, classifySendError(err))
+
+	// a sender without a signer
+	log := newTestLaunchLog(1, gwei(3))
+	log.From = "0x93388b4efe13b9b18ed480783c05462409851547"
+	_, err = l.sign(log, transactionFees{GasPrice: gwei(3)})
+	assert.EqualValues(t, sendErrorNeedsAttention, classifySendError(err))
+}
+
+func TestSignDynamicFee(t *testing.T) {
+	signService := NewLocalSignService(testPrivateKey)
+
+	log := newTestLaunchLog(5, gwei(30))
+	raw := utils.Hex2Bytes(signService.SignDynamicFee(log, 42161, gwei(2)))
+
+	// type 2, and the hash is of the typed envelope
+	assert.EqualValues(t, dynamicFeeTxType, raw[0])
+	assert.EqualValues(t, utils.Bytes2HexP(crypto.Keccak256(raw)), log.Hash.String)
+
+	transaction := &dynamicFeeTx{
+		ChainID:              42161,
+		Nonce:                5,
+		MaxPriorityFeePerGas: utils.DecimalToBigInt(gwei(2)),
+		MaxFeePerGas:         utils.DecimalToBigInt(gwei(30)),
+		GasLimit:             250000,
+		To:                   log.To,
+		Value:                utils.DecimalToBigInt(decimal.Zero),
+		Data:                 []byte{},
+	}
+
+	publicKey, err := crypto.SigToPub(transaction.SigningHash(), signatureOfTestTx(t, raw))
+	assert.Nil(t, err)
+	assert.EqualValues(t, testAddress, strings.ToLower(crypto.PubKey2Address(*publicKey)))
+}
+
+// A type 2 transaction signed by go-ethereum with the same key and fields
+func TestSignDynamicFeeVector(t *testing.T) {
+	signService := NewLocalSignService(testPrivateKey)
+
+	log := newTestLaunchLog(5, gwei(30))
+	log.Value = decimal.New(1, 15)
+	log.Data = "0xd0e30db0"
+
+	raw := signService.SignDynamicFee(log, 42161, gwei(2))
+	assert.EqualValues(t, "0x02f87982a4b10584773594008506fc23ac008303d0909493388b4efe13b9b18ed480783c0546240985154787038d7ea4c6800084d0e30db0c001a088e89052ffad1796d8303ea2a8ef8866ab684e27a00bb296085a8203888e33eda0089c9f4790889fad7019b6e20bd390a533a91ed58c727e520b6f3a8ddc9afec1", raw)
+	assert.EqualValues(t, "0x6e76958df768f75f68d1d0d2e2d8e7b451fbf5520378e7d19af0a04bf9580f09", log.Hash.String)
+}
+
+// signatureOfTestTx returns [R || S || V] from the end of a raw dynamic fee transaction of newTestLaunchLog,
+// whose R and S have no leading zeros.
+func signatureOfTestTx(t *testing.T, raw []byte) []byte {
+	// ... v, 0xa0 r(32), 0xa0 s(32)
+	s := raw[len(raw)-32:]
+	assert.EqualValues(t, 0xa0, raw[len(raw)-33])
+	r := raw[len(raw)-65 : len(raw)-33]
+	assert.EqualValues(t, 0xa0, raw[len(raw)-66])
+
+	v := raw[len(raw)-67]
+	if v == 0x80 {
+		v = 0
+	}
+
+	return append(append(append([]byte{}, r...), s...), v)
+}
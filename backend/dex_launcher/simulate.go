@@ -0,0 +1,197 @@
+package dex_launcher
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_engine"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/onrik/ethrpc"
+	"github.com/shopspring/decimal"
+	"os"
+	"strings"
+)
+
+// A settlement which reverts costs the relayer gas, and fails the trades of all its orders.
+// Before a launch log is sent, it is simulated by eth_call at the latest block. If it reverts, it is never sent.
+// For a trade, the taker order is simulated with each maker order alone to find the orders to blame,
+// and the engine is told to cancel them and to match the others again.
+
+const simulationSuccess = "success"
+
+// the selector of Error(string), which require and revert encode their reason with
+var revertReasonSelector = []byte{0x08, 0xc3, 0x79, 0xa0}
+
+var simulationFailuresCounter = metrics.NewCounter(
+	"hydro_launcher_simulation_failures_total",
+	"Launch logs which reverted in the simulation before sending, by item type.",
+	"item_type",
+)
+
+func isSimulationEnabled() bool {
+	return os.Getenv("HSK_LAUNCHER_SIMULATE") != "false"
+}
+
+// revertOf tells if the result of an eth_call is a revert, and returns its reason.
+// Nodes report a revert as an error, except some old nodes which return the encoded reason as the result.
+func revertOf(result string, err error) (bool, string) {
+	if err != nil {
+		ethErr, ok := err.(ethrpc.EthError)
+		if !ok {
+			return false, ""
+		}
+
+		message := ethErr.Message
+		if !strings.Contains(strings.ToLower(message), "revert") {
+			return false, ""
+		}
+
+		for _, prefix := range []string{"execution reverted: ", "VM Exception while processing transaction: revert "} {
+			if strings.HasPrefix(message, prefix) {
+				return true, strings.TrimPrefix(message, prefix)
+			}
+		}
+
+		return true, message
+	}
+
+	data := utils.Hex2Bytes(result)
+	if bytes.HasPrefix(data, revertReasonSelector) {
+		return true, decodeRevertReason(data)
+	}
+
+	return false, ""
+}
+
+// decodeRevertReason decodes the abi encoded Error(string).
+func decodeRevertReason(data []byte) string {
+	// selector, offset, length, string
+	if len(data) < 4+32+32 {
+		return ""
+	}
+
+	length := binary.BigEndian.Uint64(data[4+32+24 : 4+32+32])
+	if uint64(len(data)) < 4+32+32+length {
+		return ""
+	}
+
+	return string(data[4+32+32 : 4+32+32+length])
+}
+
+// blameOrders returns the orders which make a settlement revert.
+// makerReverted tells if the match of the taker order with each maker order alone reverts.
+// If only some of them revert, those maker orders are bad. If none of them reverts, it is the taker order,
+// e.g. its balance is enough for each match but not all of them. If all of them revert, the reason tells
+// whether the maker orders or the common taker order are bad.
+func blameOrders(takerOrderID string, makerOrderIDs []string, makerReverted []bool, reason string) []string {
+	var reverted []string
+	for i, id := range makerOrderIDs {
+		if makerReverted[i] {
+			reverted = append(reverted, id)
+		}
+	}
+
+	upperReason := strings.ToUpper(reason)
+
+	if strings.Contains(upperReason, "TAKER") {
+		return []string{takerOrderID}
+	}
+
+	if len(reverted) > 0 && (len(reverted) < len(makerOrderIDs) || strings.Contains(upperReason, "MAKER")) {
+		return reverted
+	}
+
+	return []string{takerOrderID}
+}
+
+// simulate calls the launch log at the latest block, returns false if it reverts or can't be simulated now.
+// A launch log which reverts is given up, and the engine is told.
+func (l *Launcher) simulate(launchLog *models.LaunchLog) (bool, error) {
+	result, err := l.rpc.Call(launchLog.From, launchLog.To, launchLog.Data, launchLog.GasLimit)
+
+	reverted, reason := revertOf(result, err)
+	if !reverted {
+		if err != nil {
+			return false, err
+		}
+
+		launchLog.SimulationResult.String, launchLog.SimulationResult.Valid = simulationSuccess, true
+		return true, nil
+	}
+
+	utils.Infof("launch log %d reverts in simulation: %s", launchLog.ID, reason)
+
+	var badOrderIDs []string
+	if launchLog.ItemType == models.LaunchLogItemTypeTrade {
+		if badOrderIDs, err = l.findBadOrders(launchLog, reason); err != nil {
+			return false, err
+		}
+	}
+
+	l.rejectSimulation(launchLog, reason, badOrderIDs)
+	return false, nil
+}
+
+// findBadOrders simulates the taker order of a trade launch log with each of its maker orders alone.
+func (l *Launcher) findBadOrders(launchLog *models.LaunchLog, reason string) ([]string, error) {
+	trades := models.TradeDao.FindTradeByTransactionID(launchLog.ItemID)
+	if len(trades) == 0 {
+		return nil, nil
+	}
+
+	market := models.MarketDao.FindMarketByID(trades[0].MarketID)
+	takerOrder := models.OrderDao.FindByID(trades[0].TakerOrderID)
+
+	var makerOrderIDs []string
+	var makerReverted []bool
+
+	for _, trade := range trades {
+		makerOrder := models.OrderDao.FindByID(trade.MakerOrderID)
+		data := dex_engine.GetMatchOrderCallData(takerOrder, []*models.Order{makerOrder}, []decimal.Decimal{trade.Amount}, market.BaseTokenDecimals)
+
+		result, err := l.rpc.Call(launchLog.From, launchLog.To, utils.Bytes2HexP(data), launchLog.GasLimit)
+		reverted, _ := revertOf(result, err)
+		if !reverted && err != nil {
+			return nil, err
+		}
+
+		makerOrderIDs = append(makerOrderIDs, makerOrder.ID)
+		makerReverted = append(makerReverted, reverted)
+	}
+
+	return blameOrders(takerOrder.ID, makerOrderIDs, makerReverted, reason), nil
+}
+
+// rejectSimulation marks a launch log which reverted in simulation, and tells the engine which orders to blame.
+func (l *Launcher) rejectSimulation(launchLog *models.LaunchLog, reason string, badOrderIDs []string) {
+	simulationFailuresCounter.Inc(launchLog.ItemType)
+
+	launchLog.SimulationResult.String, launchLog.SimulationResult.Valid = fmt.Sprintf("reverted: %s", reason), true
+
+	transaction, err := models.UpdateLaunchLogToFailed(launchLog, models.LaunchLogStatusSimulationFailed)
+	if err != nil {
+		utils.Errorf("Update Launch Log Failed, ID: %d, err: %s", launchLog.ID, err)
+		return
+	}
+
+	if transaction == nil {
+		return
+	}
+
+	event := &dex_engine.SimulationFailedEvent{
+		Event: common.Event{
+			Type:     dex_engine.EventSimulationFailed,
+			MarketID: transaction.MarketID,
+		},
+		Hash:        launchLog.Hash.String,
+		Reason:      reason,
+		BadOrderIDs: badOrderIDs,
+	}
+
+	if err := l.eventQueue.Push([]byte(utils.ToJsonString(event))); err != nil {
+		utils.Errorf("Push event into Queue Error: %v", err)
+	}
+}
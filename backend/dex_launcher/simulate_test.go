@@ -0,0 +1,60 @@
+package dex_launcher
+
+import (
+	"errors"
+	"github.com/onrik/ethrpc"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// Error("MAKER_BALANCE_NOT_ENOUGH")
+const encodedRevertReason = "0x08c379a0" +
+	"0000000000000000000000000000000000000000000000000000000000000020" +
+	"0000000000000000000000000000000000000000000000000000000000000018" +
+	"4d414b45525f42414c414e43455f4e4f545f454e4f5547480000000000000000"
+
+func TestRevertOf(t *testing.T) {
+	reverted, reason := revertOf("", ethrpc.EthError{Code: 3, Message: "execution reverted: TAKER_BALANCE_NOT_ENOUGH"})
+	assert.True(t, reverted)
+	assert.EqualValues(t, "TAKER_BALANCE_NOT_ENOUGH", reason)
+
+	reverted, reason = revertOf("", ethrpc.EthError{Code: -32000, Message: "VM Exception while processing transaction: revert ORDER_IS_NOT_FILLABLE"})
+	assert.True(t, reverted)
+	assert.EqualValues(t, "ORDER_IS_NOT_FILLABLE", reason)
+
+	reverted, reason = revertOf("", ethrpc.EthError{Code: -32000, Message: "execution reverted"})
+	assert.True(t, reverted)
+	assert.EqualValues(t, "execution reverted", reason)
+
+	reverted, reason = revertOf(encodedRevertReason, nil)
+	assert.True(t, reverted)
+	assert.EqualValues(t, "MAKER_BALANCE_NOT_ENOUGH", reason)
+
+	reverted, _ = revertOf("0x", nil)
+	assert.False(t, reverted)
+
+	reverted, _ = revertOf("", ethrpc.EthError{Code: -32000, Message: "header not found"})
+	assert.False(t, reverted)
+
+	reverted, _ = revertOf("", errors.New("connection refused"))
+	assert.False(t, reverted)
+}
+
+func TestDecodeRevertReasonTruncated(t *testing.T) {
+	assert.EqualValues(t, "", decodeRevertReason([]byte{0x08, 0xc3, 0x79, 0xa0}))
+}
+
+func TestBlameOrders(t *testing.T) {
+	makers := []string{"m1", "m2", "m3"}
+
+	// only one maker order reverts alone
+	assert.EqualValues(t, []string{"m2"}, blameOrders("t", makers, []bool{false, true, false}, "MAKER_BALANCE_NOT_ENOUGH"))
+
+	// each match is fine alone, the taker can't afford all of them
+	assert.EqualValues(t, []string{"t"}, blameOrders("t", makers, []bool{false, false, false}, "BALANCE_NOT_ENOUGH"))
+
+	// all of them revert, the reason tells
+	assert.EqualValues(t, []string{"t"}, blameOrders("t", makers, []bool{true, true, true}, "TAKER_BALANCE_NOT_ENOUGH"))
+	assert.EqualValues(t, makers, blameOrders("t", makers, []bool{true, true, true}, "MAKER_ORDER_EXPIRED"))
+	assert.EqualValues(t, []string{"t"}, blameOrders("t", makers, []bool{true, true, true}, ""))
+}
@@ -0,0 +1,237 @@
+package doctor
+
+import (
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/shopspring/decimal"
+	"strconv"
+	"strings"
+)
+
+// Invariants checked by the doctor
+const (
+	// amount = available + pending + confirmed + canceled
+	CheckOrderAmounts = "order_amounts"
+
+	// status agrees with AutoSetStatusByAmounts
+	CheckOrderStatus = "order_status"
+
+	// pending trades add up to the pending amount, successful trades to the confirmed amount
+	CheckOrderTrades = "order_trades"
+
+	// trades have the status of their transaction
+	CheckTradeStatus = "trade_status"
+
+	// a transaction has the status of its launch logs
+	CheckTransactionStatus = "transaction_status"
+
+	// a transaction is sent by at least one launch log
+	CheckTransactionLaunchLog = "transaction_launch_log"
+
+	// a pending launch log has a hash
+	CheckLaunchLogHash = "launch_log_hash"
+
+	// the transaction of a trade launch log exists
+	CheckLaunchLogItem = "launch_log_item"
+)
+
+// Violation is a row which breaks an invariant.
+// A row broken in several ways has a violation for each of them, the repair is attached to the first one.
+type Violation struct {
+	Check   string `json:"check"`
+	Table   string `json:"table"`
+	ID      string `json:"id"`
+	Message string `json:"message"`
+
+	RepairSQL   string      `json:"repairSQL,omitempty"`
+	RepairEvent interface{} `json:"repairEvent,omitempty"`
+}
+
+func (v *Violation) String() string {
+	return fmt.Sprintf("[%s] %s %s: %s", v.Check, v.Table, v.ID, v.Message)
+}
+
+func CheckOrder(order *models.Order, trades []*models.Trade) []*Violation {
+	var violations []*Violation
+
+	sum := order.AvailableAmount.Add(order.PendingAmount).Add(order.ConfirmedAmount).Add(order.CanceledAmount)
+	if !sum.Equal(order.Amount) {
+		violations = append(violations, &Violation{
+			Check: CheckOrderAmounts,
+			Table: "orders",
+			ID:    order.ID,
+			Message: fmt.Sprintf("amount %s, available %s + pending %s + confirmed %s + canceled %s = %s",
+				order.Amount, order.AvailableAmount, order.PendingAmount, order.ConfirmedAmount, order.CanceledAmount, sum),
+		})
+	}
+
+	expected := *order
+	expected.AutoSetStatusByAmounts()
+	if expected.Status != order.Status {
+		violations = append(violations, &Violation{
+			Check:   CheckOrderStatus,
+			Table:   "orders",
+			ID:      order.ID,
+			Message: fmt.Sprintf("status %s, amounts say %s", order.Status, expected.Status),
+		})
+	}
+
+	pendingAmount, confirmedAmount := tradeAmountsOfOrder(order.ID, trades)
+	if !pendingAmount.Equal(order.PendingAmount) || !confirmedAmount.Equal(order.ConfirmedAmount) {
+		violations = append(violations, &Violation{
+			Check: CheckOrderTrades,
+			Table: "orders",
+			ID:    order.ID,
+			Message: fmt.Sprintf("pending %s, confirmed %s, but pending trades sum to %s and successful trades sum to %s",
+				order.PendingAmount, order.ConfirmedAmount, pendingAmount, confirmedAmount),
+		})
+	}
+
+	if len(violations) > 0 {
+		violations[0].RepairSQL = orderRepairSQL(order, pendingAmount, confirmedAmount)
+	}
+
+	return violations
+}
+
+func tradeAmountsOfOrder(orderID string, trades []*models.Trade) (pendingAmount, confirmedAmount decimal.Decimal) {
+	for _, trade := range trades {
+		if trade.MakerOrderID != orderID && trade.TakerOrderID != orderID {
+			continue
+		}
+
+		switch trade.Status {
+		case common.STATUS_PENDING, models.TradeStatusMined:
+			pendingAmount = pendingAmount.Add(trade.Amount)
+		case common.STATUS_SUCCESSFUL:
+			confirmedAmount = confirmedAmount.Add(trade.Amount)
+		}
+	}
+
+	return
+}
+
+// orderRepairSQL takes the trades and the available amount as the truth,
+// the rest of the amount is canceled. Empty if the trades already exceed the order.
+func orderRepairSQL(order *models.Order, pendingAmount, confirmedAmount decimal.Decimal) string {
+	repaired := *order
+	repaired.PendingAmount = pendingAmount
+	repaired.ConfirmedAmount = confirmedAmount
+	repaired.CanceledAmount = order.Amount.Sub(order.AvailableAmount).Sub(pendingAmount).Sub(confirmedAmount)
+
+	if repaired.CanceledAmount.LessThan(decimal.Zero) {
+		return ""
+	}
+
+	repaired.AutoSetStatusByAmounts()
+
+	return fmt.Sprintf("update orders set pending_amount = %s, confirmed_amount = %s, canceled_amount = %s, status = %s where id = %s;",
+		repaired.PendingAmount, repaired.ConfirmedAmount, repaired.CanceledAmount, quote(repaired.Status), quote(order.ID))
+}
+
+func CheckTransaction(transaction *models.Transaction, trades []*models.Trade, launchLogs []*models.LaunchLog) []*Violation {
+	var violations []*Violation
+	id := strconv.FormatInt(transaction.ID, 10)
+
+	if len(launchLogs) == 0 {
+		violations = append(violations, &Violation{
+			Check:   CheckTransactionLaunchLog,
+			Table:   "transactions",
+			ID:      id,
+			Message: fmt.Sprintf("%s transaction has no launch log", transaction.Status),
+		})
+	}
+
+	for _, launchLog := range launchLogs {
+		if launchLog.Status == transaction.Status {
+			continue
+		}
+
+		// the trades of a launch log which needs attention or failed the simulation are failed
+		if isUnsentStatus(launchLog.Status) && transaction.Status == common.STATUS_FAILED {
+			continue
+		}
+
+		v := &Violation{
+			Check:   CheckTransactionStatus,
+			Table:   "transactions",
+			ID:      id,
+			Message: fmt.Sprintf("status %s, but launch log %d is %s", transaction.Status, launchLog.ID, launchLog.Status),
+		}
+
+		if transaction.Status == common.STATUS_PENDING && isFinalStatus(launchLog.Status) {
+			// the result was never applied, let the engine settle the trades and orders
+			if transaction.TransactionHash != nil && transaction.TransactionHash.Valid {
+				v.RepairEvent = &common.ConfirmTransactionEvent{
+					Event: common.Event{
+						Type:     common.EventConfirmTransaction,
+						MarketID: transaction.MarketID,
+					},
+					Hash:      transaction.TransactionHash.String,
+					Status:    launchLog.Status,
+					Timestamp: uint64(launchLog.ExecutedAt.Unix()),
+				}
+			}
+		} else if isFinalStatus(transaction.Status) && !isFinalStatus(launchLog.Status) {
+			// the engine updates the launch logs after the transaction
+			v.RepairSQL = fmt.Sprintf("update launch_logs set status = %s where id = %d;", quote(transaction.Status), launchLog.ID)
+		}
+
+		violations = append(violations, v)
+	}
+
+	for _, trade := range trades {
+		if trade.Status == transaction.Status {
+			continue
+		}
+
+		violations = append(violations, &Violation{
+			Check:   CheckTradeStatus,
+			Table:   "trades",
+			ID:      strconv.FormatInt(trade.ID, 10),
+			Message: fmt.Sprintf("status %s, but transaction %d is %s", trade.Status, transaction.ID, transaction.Status),
+		})
+	}
+
+	return violations
+}
+
+// CheckLaunchLog checks a launch log, transaction is the item of a trade launch log, nil if it is not found.
+func CheckLaunchLog(launchLog *models.LaunchLog, transaction *models.Transaction) []*Violation {
+	var violations []*Violation
+	id := strconv.FormatInt(launchLog.ID, 10)
+
+	if launchLog.Status == common.STATUS_PENDING && !launchLog.Hash.Valid {
+		violations = append(violations, &Violation{
+			Check:   CheckLaunchLogHash,
+			Table:   "launch_logs",
+			ID:      id,
+			Message: "pending launch log has no transaction hash",
+		})
+	}
+
+	if launchLog.ItemType == models.LaunchLogItemTypeTrade && transaction == nil {
+		violations = append(violations, &Violation{
+			Check:   CheckLaunchLogItem,
+			Table:   "launch_logs",
+			ID:      id,
+			Message: fmt.Sprintf("transaction %d is not found", launchLog.ItemID),
+		})
+	}
+
+	return violations
+}
+
+// isUnsentStatus tells if the launch log is given up before it is sent.
+func isUnsentStatus(status string) bool {
+	return status == models.LaunchLogStatusNeedsAttention || status == models.LaunchLogStatusSimulationFailed
+}
+
+func isFinalStatus(status string) bool {
+	return status == common.STATUS_SUCCESSFUL || status == common.STATUS_FAILED
+}
+
+func quote(s string) string {
+	return "'" + strings.Replace(s, "'", "''", -1) + "'"
+}
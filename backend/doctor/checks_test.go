@@ -0,0 +1,116 @@
+package doctor
+
+import (
+	"database/sql"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func newOrder(amount, available, pending, confirmed, canceled string) *models.Order {
+	order := &models.Order{
+		ID:              "0xorder",
+		Amount:          decimal.RequireFromString(amount),
+		AvailableAmount: decimal.RequireFromString(available),
+		PendingAmount:   decimal.RequireFromString(pending),
+		ConfirmedAmount: decimal.RequireFromString(confirmed),
+		CanceledAmount:  decimal.RequireFromString(canceled),
+	}
+	order.AutoSetStatusByAmounts()
+	return order
+}
+
+func newTrade(status, amount string) *models.Trade {
+	return &models.Trade{
+		ID:           1,
+		Status:       status,
+		MakerOrderID: "0xorder",
+		TakerOrderID: "0xother",
+		Amount:       decimal.RequireFromString(amount),
+	}
+}
+
+func TestCheckOrder(t *testing.T) {
+	order := newOrder("10", "5", "2", "3", "0")
+	trades := []*models.Trade{newTrade(common.STATUS_PENDING, "2"), newTrade(common.STATUS_SUCCESSFUL, "3"), newTrade(common.STATUS_FAILED, "1")}
+	assert.Len(t, CheckOrder(order, trades), 0)
+
+	// a failed settlement moved the pending amount nowhere
+	order = newOrder("10", "5", "2", "3", "0")
+	order.PendingAmount = decimal.Zero
+	trades = []*models.Trade{newTrade(common.STATUS_FAILED, "2"), newTrade(common.STATUS_SUCCESSFUL, "3")}
+
+	violations := CheckOrder(order, trades)
+	assert.Len(t, violations, 1)
+	assert.EqualValues(t, CheckOrderAmounts, violations[0].Check)
+	assert.EqualValues(t, "update orders set pending_amount = 0, confirmed_amount = 3, canceled_amount = 2, status = 'pending' where id = '0xorder';", violations[0].RepairSQL)
+
+	// status disagrees with the amounts
+	order = newOrder("10", "0", "0", "10", "0")
+	order.Status = common.ORDER_PENDING
+	violations = CheckOrder(order, []*models.Trade{newTrade(common.STATUS_SUCCESSFUL, "10")})
+	assert.Len(t, violations, 1)
+	assert.EqualValues(t, CheckOrderStatus, violations[0].Check)
+	assert.Contains(t, violations[0].RepairSQL, "status = 'full_filled'")
+
+	// trades exceed the order, no repair
+	order = newOrder("10", "5", "0", "5", "0")
+	violations = CheckOrder(order, []*models.Trade{newTrade(common.STATUS_SUCCESSFUL, "8")})
+	assert.Len(t, violations, 1)
+	assert.EqualValues(t, CheckOrderTrades, violations[0].Check)
+	assert.EqualValues(t, "", violations[0].RepairSQL)
+}
+
+func TestCheckTransaction(t *testing.T) {
+	transaction := &models.Transaction{
+		ID:              1,
+		MarketID:        "WETH-DAI",
+		TransactionHash: &sql.NullString{String: "0xhash", Valid: true},
+		Status:          common.STATUS_PENDING,
+	}
+
+	launchLog := &models.LaunchLog{ID: 2, ItemType: "hydroTrade", ItemID: 1, Status: common.STATUS_PENDING, ExecutedAt: time.Unix(100, 0)}
+	trade := newTrade(common.STATUS_PENDING, "1")
+
+	assert.Len(t, CheckTransaction(transaction, []*models.Trade{trade}, []*models.LaunchLog{launchLog}), 0)
+
+	violations := CheckTransaction(transaction, nil, nil)
+	assert.Len(t, violations, 1)
+	assert.EqualValues(t, CheckTransactionLaunchLog, violations[0].Check)
+
+	// the watcher saw the result, but the engine never applied it
+	launchLog.Status = common.STATUS_SUCCESSFUL
+	violations = CheckTransaction(transaction, []*models.Trade{trade}, []*models.LaunchLog{launchLog})
+	assert.Len(t, violations, 1)
+	assert.EqualValues(t, CheckTransactionStatus, violations[0].Check)
+	event := violations[0].RepairEvent.(*common.ConfirmTransactionEvent)
+	assert.EqualValues(t, "0xhash", event.Hash)
+	assert.EqualValues(t, common.STATUS_SUCCESSFUL, event.Status)
+	assert.EqualValues(t, 100, event.Timestamp)
+
+	// the engine applied the result, but failed to update the launch log
+	transaction.Status = common.STATUS_SUCCESSFUL
+	launchLog.Status = common.STATUS_PENDING
+	violations = CheckTransaction(transaction, []*models.Trade{trade}, []*models.LaunchLog{launchLog})
+	assert.Len(t, violations, 2)
+	assert.EqualValues(t, "update launch_logs set status = 'successful' where id = 2;", violations[0].RepairSQL)
+	assert.EqualValues(t, CheckTradeStatus, violations[1].Check)
+}
+
+func TestCheckLaunchLog(t *testing.T) {
+	launchLog := &models.LaunchLog{ID: 1, ItemType: "hydroTrade", ItemID: 1, Status: common.STATUS_PENDING}
+
+	violations := CheckLaunchLog(launchLog, nil)
+	assert.Len(t, violations, 2)
+	assert.EqualValues(t, CheckLaunchLogHash, violations[0].Check)
+	assert.EqualValues(t, CheckLaunchLogItem, violations[1].Check)
+
+	launchLog.Hash = sql.NullString{String: "0xhash", Valid: true}
+	assert.Len(t, CheckLaunchLog(launchLog, &models.Transaction{ID: 1}), 0)
+
+	launchLog.ItemType = "hydroApprove"
+	assert.Len(t, CheckLaunchLog(launchLog, nil), 0)
+}
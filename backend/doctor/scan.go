@@ -0,0 +1,111 @@
+package doctor
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+)
+
+const DefaultBatchSize = 500
+
+// Scan walks through orders, transactions and launch logs in batches, and reports every violation it finds.
+func Scan(batchSize int, report func(*Violation)) {
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	scanOrders(batchSize, report)
+	scanTransactions(batchSize, report)
+	scanLaunchLogs(batchSize, report)
+}
+
+func scanOrders(batchSize int, report func(*Violation)) {
+	lastID := ""
+
+	for {
+		orders := models.OrderDao.FindOrdersAfterID(lastID, batchSize)
+		if len(orders) == 0 {
+			return
+		}
+
+		orderIDs := make([]string, 0, len(orders))
+		for _, order := range orders {
+			orderIDs = append(orderIDs, order.ID)
+		}
+
+		trades := models.TradeDao.FindTradesByOrderIDs(orderIDs)
+
+		for _, order := range orders {
+			reportAll(report, CheckOrder(order, trades))
+		}
+
+		lastID = orders[len(orders)-1].ID
+	}
+}
+
+func scanTransactions(batchSize int, report func(*Violation)) {
+	var lastID int64
+
+	for {
+		transactions := models.TransactionDao.FindTransactionsAfterID(lastID, batchSize)
+		if len(transactions) == 0 {
+			return
+		}
+
+		ids := make([]int64, 0, len(transactions))
+		for _, transaction := range transactions {
+			ids = append(ids, transaction.ID)
+		}
+
+		trades := make(map[int64][]*models.Trade)
+		for _, trade := range models.TradeDao.FindTradesByTransactionIDs(ids) {
+			trades[trade.TransactionID] = append(trades[trade.TransactionID], trade)
+		}
+
+		launchLogs := make(map[int64][]*models.LaunchLog)
+		for _, launchLog := range models.LaunchLogDao.FindLaunchLogsByItemIDs(models.LaunchLogItemTypeTrade, ids) {
+			launchLogs[launchLog.ItemID] = append(launchLogs[launchLog.ItemID], launchLog)
+		}
+
+		for _, transaction := range transactions {
+			reportAll(report, CheckTransaction(transaction, trades[transaction.ID], launchLogs[transaction.ID]))
+		}
+
+		lastID = transactions[len(transactions)-1].ID
+	}
+}
+
+func scanLaunchLogs(batchSize int, report func(*Violation)) {
+	var lastID int64
+
+	for {
+		launchLogs := models.LaunchLogDao.FindLaunchLogsAfterID(lastID, batchSize)
+		if len(launchLogs) == 0 {
+			return
+		}
+
+		var itemIDs []int64
+		for _, launchLog := range launchLogs {
+			if launchLog.ItemType == models.LaunchLogItemTypeTrade {
+				itemIDs = append(itemIDs, launchLog.ItemID)
+			}
+		}
+
+		transactions := make(map[int64]*models.Transaction)
+		if len(itemIDs) > 0 {
+			for _, transaction := range models.TransactionDao.FindTransactionsByIDs(itemIDs) {
+				transactions[transaction.ID] = transaction
+			}
+		}
+
+		for _, launchLog := range launchLogs {
+			reportAll(report, CheckLaunchLog(launchLog, transactions[launchLog.ItemID]))
+		}
+
+		lastID = launchLogs[len(launchLogs)-1].ID
+	}
+}
+
+func reportAll(report func(*Violation), violations []*Violation) {
+	for _, v := range violations {
+		report(v)
+	}
+}
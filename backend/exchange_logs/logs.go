@@ -0,0 +1,135 @@
+package exchange_logs
+
+import (
+	"errors"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"strings"
+)
+
+// Events emitted by the HybridExchange contract.
+//
+//	event Match(OrderAddressSet addressSet, MatchResult result);
+//	event Cancel(bytes32 indexed orderHash);
+//
+// A settlement emits one Match per maker order, in the order the maker orders are passed to matchOrders.
+// Neither struct has dynamic fields, so a Match log has no topics but its signature,
+// and its data is the 13 words of both structs in declaration order.
+const (
+	matchEventSignature  = "Match((address,address,address),(address,address,address,uint256,uint256,uint256,uint256,uint256,uint256,uint256))"
+	cancelEventSignature = "Cancel(bytes32)"
+
+	matchLogWords = 13
+	wordLength    = 32
+)
+
+var MatchTopic = utils.Bytes2HexP(crypto.Keccak256([]byte(matchEventSignature)))
+var CancelTopic = utils.Bytes2HexP(crypto.Keccak256([]byte(cancelEventSignature)))
+
+type MatchLog struct {
+	BaseToken  string
+	QuoteToken string
+	Relayer    string
+
+	Maker string
+	Taker string
+	Buyer string
+
+	MakerFee               decimal.Decimal
+	MakerRebate            decimal.Decimal
+	TakerFee               decimal.Decimal
+	MakerGasFee            decimal.Decimal
+	TakerGasFee            decimal.Decimal
+	BaseTokenFilledAmount  decimal.Decimal
+	QuoteTokenFilledAmount decimal.Decimal
+}
+
+// DecodeMatchLog decodes the data of a Match log. Addresses are lower cased, amounts are in the smallest token unit.
+func DecodeMatchLog(data string) (*MatchLog, error) {
+	bts := utils.Hex2Bytes(data)
+	if len(bts) != matchLogWords*wordLength {
+		return nil, fmt.Errorf("match log data has %d bytes, %d expected", len(bts), matchLogWords*wordLength)
+	}
+
+	words := make([][]byte, matchLogWords)
+	for i := range words {
+		words[i] = bts[i*wordLength : (i+1)*wordLength]
+	}
+
+	address := func(word []byte) string {
+		return utils.Bytes2HexP(word[wordLength-20:])
+	}
+
+	amount := func(word []byte) decimal.Decimal {
+		return decimal.NewFromBigInt(utils.Bytes2BigInt(word), 0)
+	}
+
+	return &MatchLog{
+		BaseToken:              address(words[0]),
+		QuoteToken:             address(words[1]),
+		Relayer:                address(words[2]),
+		Maker:                  address(words[3]),
+		Taker:                  address(words[4]),
+		Buyer:                  address(words[5]),
+		MakerFee:               amount(words[6]),
+		MakerRebate:            amount(words[7]),
+		TakerFee:               amount(words[8]),
+		MakerGasFee:            amount(words[9]),
+		TakerGasFee:            amount(words[10]),
+		BaseTokenFilledAmount:  amount(words[11]),
+		QuoteTokenFilledAmount: amount(words[12]),
+	}, nil
+}
+
+// DecodeCancelLog returns the hash of the order canceled by a Cancel log, which is the order ID in the database.
+func DecodeCancelLog(topics []string) (string, error) {
+	if len(topics) != 2 {
+		return "", errors.New("cancel log should have 2 topics")
+	}
+
+	orderHash := utils.Hex2Bytes(topics[1])
+	if len(orderHash) != wordLength {
+		return "", fmt.Errorf("invalid order hash %s", topics[1])
+	}
+
+	return utils.Bytes2HexP(orderHash), nil
+}
+
+// ExchangeLogs are the logs of a receipt emitted by the exchange.
+type ExchangeLogs struct {
+	Matches          []*MatchLog
+	CanceledOrderIDs []string
+}
+
+// ParseReceiptLogs picks the Match and Cancel logs emitted by the exchange out of the logs of a receipt.
+func ParseReceiptLogs(logs []sdk.IReceiptLog, exchangeAddress string) (*ExchangeLogs, error) {
+	exchangeLogs := &ExchangeLogs{}
+
+	for _, log := range logs {
+		if !strings.EqualFold(log.GetAddress(), exchangeAddress) || len(log.GetTopics()) == 0 {
+			continue
+		}
+
+		switch strings.ToLower(log.GetTopics()[0]) {
+		case MatchTopic:
+			match, err := DecodeMatchLog(log.GetData())
+			if err != nil {
+				return nil, fmt.Errorf("log %d: %v", log.GetLogIndex(), err)
+			}
+
+			exchangeLogs.Matches = append(exchangeLogs.Matches, match)
+		case CancelTopic:
+			orderID, err := DecodeCancelLog(log.GetTopics())
+			if err != nil {
+				return nil, fmt.Errorf("log %d: %v", log.GetLogIndex(), err)
+			}
+
+			exchangeLogs.CanceledOrderIDs = append(exchangeLogs.CanceledOrderIDs, orderID)
+		}
+	}
+
+	return exchangeLogs, nil
+}
@@ -0,0 +1,116 @@
+package exchange_logs
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	"github.com/onrik/ethrpc"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+const (
+	exchange = "0x179fd00c328d4ecdb5043c8686d377a24ede9d11"
+	relayer  = "0x93388b4efe13b9b18ed480783c05462409851547"
+	maker    = "0x31ebd457b999bf99759602f5ece5aa5033cb56b3"
+	taker    = "0x3eb06f432ae8f518a957852aa44776c234b4a84a"
+	orderID  = "0x5d6e8bbc3de5d8e77d1a6b6d9d5fa4ffd22c1ad5e0b0bdad2cc4d0e6ea0dc0a7"
+)
+
+func word(hex string) string {
+	return strings.Repeat("0", 64-len(hex)) + hex
+}
+
+func amountWord(amount int64) string {
+	return word(big.NewInt(amount).Text(16))
+}
+
+func matchData(baseTokenFilledAmount int64) string {
+	return "0x" +
+		word("1") + word("2") + word(relayer[2:]) +
+		word(maker[2:]) + word(taker[2:]) + word(taker[2:]) +
+		amountWord(1) + amountWord(2) + amountWord(3) + amountWord(4) + amountWord(5) +
+		amountWord(baseTokenFilledAmount) + amountWord(baseTokenFilledAmount*2)
+}
+
+func TestTopics(t *testing.T) {
+	assert.EqualValues(t, "0xe8d9861dbc9c663ed3accd261bbe2fe01e0d3d9e5f51fa38523b265c7757a93a", CancelTopic)
+	assert.Len(t, MatchTopic, 66)
+}
+
+func TestDecodeMatchLog(t *testing.T) {
+	match, err := DecodeMatchLog(matchData(100))
+	assert.Nil(t, err)
+
+	assert.EqualValues(t, "0x0000000000000000000000000000000000000001", match.BaseToken)
+	assert.EqualValues(t, relayer, match.Relayer)
+	assert.EqualValues(t, maker, match.Maker)
+	assert.EqualValues(t, taker, match.Taker)
+	assert.EqualValues(t, taker, match.Buyer)
+	assert.EqualValues(t, "3", match.TakerFee.String())
+	assert.EqualValues(t, "100", match.BaseTokenFilledAmount.String())
+	assert.EqualValues(t, "200", match.QuoteTokenFilledAmount.String())
+
+	_, err = DecodeMatchLog("0x" + word("1"))
+	assert.NotNil(t, err)
+}
+
+func TestDecodeCancelLog(t *testing.T) {
+	id, err := DecodeCancelLog([]string{CancelTopic, orderID})
+	assert.Nil(t, err)
+	assert.EqualValues(t, orderID, id)
+
+	_, err = DecodeCancelLog([]string{CancelTopic})
+	assert.NotNil(t, err)
+}
+
+func TestParseReceiptLogs(t *testing.T) {
+	logs := []sdk.IReceiptLog{
+		ethereum.ReceiptLog{Log: &ethrpc.Log{Address: exchange, Topics: []string{MatchTopic}, Data: matchData(100)}},
+		ethereum.ReceiptLog{Log: &ethrpc.Log{Address: exchange, Topics: []string{CancelTopic, orderID}}},
+		// a Transfer log of a token
+		ethereum.ReceiptLog{Log: &ethrpc.Log{Address: maker, Topics: []string{"0xddf252ad1be2c89b69c2b068fc378daa952ba7f163c4a11628f55a4df523b3ef"}}},
+	}
+
+	exchangeLogs, err := ParseReceiptLogs(logs, strings.ToUpper(exchange))
+	assert.Nil(t, err)
+	assert.Len(t, exchangeLogs.Matches, 1)
+	assert.EqualValues(t, []string{orderID}, exchangeLogs.CanceledOrderIDs)
+}
+
+func TestCompareMatches(t *testing.T) {
+	orders := map[string]*models.Order{
+		"maker-order": {ID: "maker-order", TraderAddress: maker},
+		"taker-order": {ID: "taker-order", TraderAddress: taker},
+	}
+
+	trade := &models.Trade{
+		ID:           1,
+		Maker:        maker,
+		Taker:        taker,
+		MakerOrderID: "maker-order",
+		TakerOrderID: "taker-order",
+		Amount:       decimal.RequireFromString("0.01"),
+	}
+
+	match, _ := DecodeMatchLog(matchData(100))
+	assert.Len(t, compareMatches([]*models.Trade{trade}, []*MatchLog{match}, orders, 4), 0)
+
+	discrepancies := compareMatches([]*models.Trade{trade}, []*MatchLog{match}, orders, 18)
+	assert.Len(t, discrepancies, 1)
+	assert.EqualValues(t, DiscrepancyMatch, discrepancies[0].Kind)
+
+	discrepancies = compareMatches([]*models.Trade{trade, trade}, []*MatchLog{match}, orders, 4)
+	assert.Len(t, discrepancies, 1)
+	assert.EqualValues(t, DiscrepancyMatchCount, discrepancies[0].Kind)
+
+	orders["taker-order"] = &models.Order{ID: "taker-order", TraderAddress: relayer}
+	delete(orders, "maker-order")
+	discrepancies = compareMatches([]*models.Trade{trade}, []*MatchLog{match}, orders, 4)
+	assert.Len(t, discrepancies, 2)
+	assert.EqualValues(t, DiscrepancyOrder, discrepancies[0].Kind)
+	assert.EqualValues(t, DiscrepancyOrder, discrepancies[1].Kind)
+}
@@ -0,0 +1,208 @@
+package exchange_logs
+
+import (
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_engine"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/HydroProtocol/nights-watch/structs"
+	"sort"
+	"strings"
+)
+
+// The Reconciler reads the logs of every transaction sent to the exchange, and holds them against the database.
+// The Match logs of a settlement are compared with its trades and their orders,
+// orders canceled on chain by their traders are canceled in the engine as well.
+// A difference between the chain and the database is never repaired here, it is flagged for an operator.
+
+// Kinds of discrepancies between the chain and the database.
+const (
+	// a settlement of our relayer without a launch log
+	DiscrepancyUnknownSettlement = "unknown_settlement"
+
+	// the number of Match logs differs from the number of trades
+	DiscrepancyMatchCount = "match_count"
+
+	// a Match log differs from its trade
+	DiscrepancyMatch = "match"
+
+	// the order of a trade is missing, or belongs to another trader than the Match log says
+	DiscrepancyOrder = "order"
+
+	// the logs of the exchange can not be decoded
+	DiscrepancyUndecodable = "undecodable"
+)
+
+var discrepanciesCounter = metrics.NewCounter(
+	"hydro_watcher_chain_discrepancies_total",
+	"Differences between the logs of the exchange and the database.",
+	"kind",
+)
+
+type Discrepancy struct {
+	Kind    string
+	Message string
+}
+
+type Reconciler struct {
+	eventQueue      common.IQueue
+	exchangeAddress string
+	relayerAddress  string
+}
+
+func NewReconciler(eventQueue common.IQueue, exchangeAddress, relayerAddress string) *Reconciler {
+	return &Reconciler{
+		eventQueue:      eventQueue,
+		exchangeAddress: exchangeAddress,
+		relayerAddress:  relayerAddress,
+	}
+}
+
+// Filter only lets transactions sent to the exchange through.
+func (r *Reconciler) Filter(tx sdk.Transaction) bool {
+	return strings.EqualFold(tx.GetTo(), r.exchangeAddress)
+}
+
+func (r *Reconciler) TxHandlerFunc(txAndReceipt *structs.RemovableTxAndReceipt) {
+	// a reverted transaction has no logs, and a removed one is handled with the launch log
+	if txAndReceipt.IsRemoved || !txAndReceipt.Receipt.GetResult() {
+		return
+	}
+
+	hash := txAndReceipt.Tx.GetHash()
+
+	logs, err := ParseReceiptLogs(txAndReceipt.Receipt.GetLogs(), r.exchangeAddress)
+	if err != nil {
+		flag(hash, Discrepancy{Kind: DiscrepancyUndecodable, Message: err.Error()})
+		return
+	}
+
+	for _, orderID := range logs.CanceledOrderIDs {
+		r.cancelOrder(orderID, hash)
+	}
+
+	if len(logs.Matches) > 0 {
+		for _, discrepancy := range r.reconcileMatches(hash, logs.Matches) {
+			flag(hash, discrepancy)
+		}
+	}
+}
+
+func flag(hash string, discrepancy Discrepancy) {
+	utils.Errorf("chain discrepancy %s in transaction %s: %s", discrepancy.Kind, hash, discrepancy.Message)
+	discrepanciesCounter.Inc(discrepancy.Kind)
+}
+
+// cancelOrder tells the engine an order of ours is canceled on chain. Orders of other relayers are skipped.
+func (r *Reconciler) cancelOrder(orderID, hash string) {
+	order := models.OrderDao.FindByID(orderID)
+	if order == nil {
+		utils.Debugf("Skip cancel of unknown order %s", orderID)
+		return
+	}
+
+	if order.Status != common.ORDER_PENDING {
+		utils.Infof("order %s canceled on chain is %s already", order.ID, order.Status)
+		return
+	}
+
+	event := &dex_engine.OrderCanceledOnChainEvent{
+		Event: common.Event{
+			Type:     dex_engine.EventOrderCanceledOnChain,
+			MarketID: order.MarketID,
+		},
+		ID:   order.ID,
+		Hash: hash,
+	}
+
+	if err := r.eventQueue.Push([]byte(utils.ToJsonString(event))); err != nil {
+		utils.Errorf("Push event into Queue Error: %v", err)
+	}
+}
+
+func (r *Reconciler) reconcileMatches(hash string, matches []*MatchLog) []Discrepancy {
+	launchLog := models.FindLaunchLogByAttemptHash(hash)
+	if launchLog == nil || launchLog.ItemType != models.LaunchLogItemTypeTrade {
+		// settlements of other relayers are none of our business
+		for _, match := range matches {
+			if strings.EqualFold(match.Relayer, r.relayerAddress) {
+				return []Discrepancy{{
+					Kind:    DiscrepancyUnknownSettlement,
+					Message: fmt.Sprintf("%d matches of relayer %s", len(matches), match.Relayer),
+				}}
+			}
+		}
+
+		return nil
+	}
+
+	transaction := models.TransactionDao.FindTransactionByID(launchLog.ItemID)
+	market := models.MarketDao.FindMarketByID(transaction.MarketID)
+
+	trades := models.TradeDao.FindTradeByTransactionID(transaction.ID)
+	sort.Slice(trades, func(i, j int) bool {
+		return trades[i].Sequence < trades[j].Sequence
+	})
+
+	orders := make(map[string]*models.Order)
+	for _, trade := range trades {
+		for _, id := range []string{trade.MakerOrderID, trade.TakerOrderID} {
+			if _, ok := orders[id]; !ok {
+				orders[id] = models.OrderDao.FindByID(id)
+			}
+		}
+	}
+
+	return compareMatches(trades, matches, orders, market.BaseTokenDecimals)
+}
+
+// compareMatches holds the Match logs of a settlement against its trades, which are sorted by sequence.
+// Each trade is a match with one maker order, and the exchange emits the Match logs in the same order.
+func compareMatches(trades []*models.Trade, matches []*MatchLog, orders map[string]*models.Order, baseTokenDecimals int) (discrepancies []Discrepancy) {
+	if len(trades) != len(matches) {
+		return []Discrepancy{{
+			Kind:    DiscrepancyMatchCount,
+			Message: fmt.Sprintf("%d Match logs for %d trades", len(matches), len(trades)),
+		}}
+	}
+
+	for i, trade := range trades {
+		match := matches[i]
+
+		amount := trade.Amount.Shift(int32(baseTokenDecimals))
+		if !amount.Equal(match.BaseTokenFilledAmount) {
+			discrepancies = append(discrepancies, Discrepancy{
+				Kind:    DiscrepancyMatch,
+				Message: fmt.Sprintf("trade %d filled %s on chain, %s in database", trade.ID, match.BaseTokenFilledAmount, amount),
+			})
+		}
+
+		if !strings.EqualFold(trade.Maker, match.Maker) || !strings.EqualFold(trade.Taker, match.Taker) {
+			discrepancies = append(discrepancies, Discrepancy{
+				Kind:    DiscrepancyMatch,
+				Message: fmt.Sprintf("trade %d is between %s and %s on chain, %s and %s in database", trade.ID, match.Maker, match.Taker, trade.Maker, trade.Taker),
+			})
+		}
+
+		for _, side := range []struct{ id, trader string }{{trade.MakerOrderID, match.Maker}, {trade.TakerOrderID, match.Taker}} {
+			id, trader := side.id, side.trader
+			order := orders[id]
+			if order == nil {
+				discrepancies = append(discrepancies, Discrepancy{
+					Kind:    DiscrepancyOrder,
+					Message: fmt.Sprintf("order %s of trade %d is missing", id, trade.ID),
+				})
+			} else if !strings.EqualFold(order.TraderAddress, trader) {
+				discrepancies = append(discrepancies, Discrepancy{
+					Kind:    DiscrepancyOrder,
+					Message: fmt.Sprintf("order %s of trade %d belongs to %s, %s on chain", id, trade.ID, order.TraderAddress, trader),
+				})
+			}
+		}
+	}
+
+	return discrepancies
+}
@@ -0,0 +1,188 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// A minimal metrics registry, which is exposed in the prometheus text format.
+// It only supports counters and gauges, which is all the services need for now.
+
+type metric struct {
+	name       string
+	help       string
+	metricType string
+	labelNames []string
+
+	lock   sync.Mutex
+	values map[string]float64
+}
+
+type Counter struct {
+	*metric
+}
+
+type Gauge struct {
+	*metric
+}
+
+var registry = struct {
+	lock    sync.Mutex
+	metrics []*metric
+}{}
+
+func newMetric(name, help, metricType string, labelNames []string) *metric {
+	m := &metric{
+		name:       name,
+		help:       help,
+		metricType: metricType,
+		labelNames: labelNames,
+		values:     make(map[string]float64),
+	}
+
+	registry.lock.Lock()
+	defer registry.lock.Unlock()
+
+	for _, existing := range registry.metrics {
+		if existing.name == name {
+			panic(fmt.Errorf("metric %s is already registered", name))
+		}
+	}
+
+	registry.metrics = append(registry.metrics, m)
+	return m
+}
+
+func NewCounter(name, help string, labelNames ...string) *Counter {
+	return &Counter{newMetric(name, help, "counter", labelNames)}
+}
+
+func NewGauge(name, help string, labelNames ...string) *Gauge {
+	return &Gauge{newMetric(name, help, "gauge", labelNames)}
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(v float64, labelValues ...string) {
+	c.update(labelValues, func(old float64) float64 { return old + v })
+}
+
+func (g *Gauge) Set(v float64, labelValues ...string) {
+	g.update(labelValues, func(float64) float64 { return v })
+}
+
+func (g *Gauge) Add(v float64, labelValues ...string) {
+	g.update(labelValues, func(old float64) float64 { return old + v })
+}
+
+// Value returns the current value of the metric with the given label values.
+func (m *metric) Value(labelValues ...string) float64 {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	return m.values[m.labelsKey(labelValues)]
+}
+
+func (m *metric) update(labelValues []string, fn func(float64) float64) {
+	key := m.labelsKey(labelValues)
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.values[key] = fn(m.values[key])
+}
+
+func (m *metric) labelsKey(labelValues []string) string {
+	if len(labelValues) != len(m.labelNames) {
+		panic(fmt.Errorf("metric %s needs %d label values, got %d", m.name, len(m.labelNames), len(labelValues)))
+	}
+
+	pairs := make([]string, 0, len(labelValues))
+	for i, value := range labelValues {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", m.labelNames[i], strconv.Quote(value)))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+func (m *metric) write(buf *bytes.Buffer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	_, _ = fmt.Fprintf(buf, "# HELP %s %s\n", m.name, m.help)
+	_, _ = fmt.Fprintf(buf, "# TYPE %s %s\n", m.name, m.metricType)
+
+	keys := make([]string, 0, len(m.values))
+	for key := range m.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := strconv.FormatFloat(m.values[key], 'f', -1, 64)
+		if key == "" {
+			_, _ = fmt.Fprintf(buf, "%s %s\n", m.name, value)
+		} else {
+			_, _ = fmt.Fprintf(buf, "%s{%s} %s\n", m.name, key, value)
+		}
+	}
+}
+
+// Text renders all registered metrics in the prometheus text format.
+func Text() string {
+	registry.lock.Lock()
+	metrics := make([]*metric, len(registry.metrics))
+	copy(metrics, registry.metrics)
+	registry.lock.Unlock()
+
+	var buf bytes.Buffer
+	for _, m := range metrics {
+		m.write(&buf)
+	}
+
+	return buf.String()
+}
+
+type Handler struct {
+}
+
+func (Handler) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
+	if req.URL.Path != utils.DefaultMetricPath {
+		resp.WriteHeader(http.StatusNotFound)
+		_, _ = resp.Write([]byte("Not Found"))
+		return
+	}
+
+	resp.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	_, _ = resp.Write([]byte(Text()))
+}
+
+// StartMetrics works like utils.StartMetrics, but serves the registered metrics instead of a static body.
+func StartMetrics() {
+	port := os.Getenv("METRICS_PORT")
+	if len(port) == 0 {
+		port = utils.DefaultMetricPort
+	} else {
+		p, err := strconv.ParseInt(port, 10, 32)
+		if err != nil {
+			panic(err)
+		}
+		if p > 65535 || p < 0 {
+			panic("METRICS_PORT must between 0 and 65535 ")
+		}
+	}
+
+	err := http.ListenAndServe(fmt.Sprintf(":%s", port), Handler{})
+	if err != nil {
+		utils.Errorf("metrics service error: %v", err)
+	}
+}
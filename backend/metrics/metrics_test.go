@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCounterAndGauge(t *testing.T) {
+	counter := NewCounter("test_events_total", "Events handled.", "market")
+	gauge := NewGauge("test_queue_size", "Queue size.")
+
+	counter.Inc("HOT-DAI")
+	counter.Add(2, "HOT-DAI")
+	counter.Inc("WETH-DAI")
+	gauge.Set(5)
+	gauge.Add(-2)
+
+	assert.EqualValues(t, 3, counter.Value("HOT-DAI"))
+	assert.EqualValues(t, 1, counter.Value("WETH-DAI"))
+	assert.EqualValues(t, 3, gauge.Value())
+
+	text := Text()
+	assert.True(t, strings.Contains(text, "# TYPE test_events_total counter\n"))
+	assert.True(t, strings.Contains(text, `test_events_total{market="HOT-DAI"} 3`+"\n"))
+	assert.True(t, strings.Contains(text, "test_queue_size 3\n"))
+
+	assert.Panics(t, func() { counter.Inc() })
+	assert.Panics(t, func() { NewGauge("test_queue_size", "registered twice") })
+}
+
+func TestHandler(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler{}.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	assert.EqualValues(t, 200, rec.Code)
+
+	rec = httptest.NewRecorder()
+	Handler{}.ServeHTTP(rec, httptest.NewRequest("GET", "/other", nil))
+	assert.EqualValues(t, 404, rec.Code)
+}
@@ -1,25 +1,43 @@
 package models
 
 import (
+	"database/sql"
+	"fmt"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 )
 
 func UpdateLaunchLogToPending(launchLog *LaunchLog) (err error) {
-	launchLog.Status = common.STATUS_PENDING
-	err = LaunchLogDao.UpdateLaunchLog(launchLog)
+	_, err = updateLaunchLogStatus(launchLog, common.STATUS_PENDING)
+	return
+}
+
+// UpdateLaunchLogToFailed marks a launch log which will never be mined,
+// and returns its transaction, which is nil if the launch log is not a trade.
+// A launch log which failed before it was signed has no hash, it is given a placeholder,
+// so that the engine can find its transaction by hash like the others.
+func UpdateLaunchLogToFailed(launchLog *LaunchLog, status string) (*Transaction, error) {
+	if !launchLog.Hash.Valid {
+		launchLog.Hash = sql.NullString{String: fmt.Sprintf("unsent:%d", launchLog.ID), Valid: true}
+	}
+
+	return updateLaunchLogStatus(launchLog, status)
+}
+
+func updateLaunchLogStatus(launchLog *LaunchLog, status string) (transaction *Transaction, err error) {
+	err = TransitLaunchLog(launchLog, status)
 
 	if err != nil {
 		utils.Errorf("update launch error: %v", err)
 		return
 	}
 
-	//if approve event, it should not update trades or transactions
-	if launchLog.ItemType == "hydroApprove" {
-		return nil
+	//if not a trade, it should not update trades or transactions
+	if launchLog.ItemType != LaunchLogItemTypeTrade {
+		return nil, nil
 	}
 
-	transaction := TransactionDao.FindTransactionByID(launchLog.ItemID)
+	transaction = TransactionDao.FindTransactionByID(launchLog.ItemID)
 	transaction.TransactionHash = &launchLog.Hash
 
 	err = TransactionDao.UpdateTransaction(transaction)
@@ -40,3 +58,23 @@ func UpdateLaunchLogToPending(launchLog *LaunchLog) (err error) {
 	}
 	return
 }
+
+// FindLaunchLogByAttemptHash finds the launch log of a hash, which is either the latest attempt of the launch log
+// or one it has replaced.
+func FindLaunchLogByAttemptHash(hash string) *LaunchLog {
+	if launchLog := LaunchLogDao.FindByHash(hash); launchLog != nil {
+		return launchLog
+	}
+
+	attempt := LaunchLogAttemptDao.FindByHash(hash)
+	if attempt == nil {
+		return nil
+	}
+
+	launchLog := LaunchLogDao.FindLaunchLogByID(int(attempt.LaunchLogID))
+	if launchLog.ID == 0 {
+		return nil
+	}
+
+	return launchLog
+}
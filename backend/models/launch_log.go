@@ -2,39 +2,102 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/jinzhu/gorm"
 	"github.com/shopspring/decimal"
+	"sort"
+	"strconv"
 	"time"
 )
 
 type ILaunchLogDao interface {
 	FindLaunchLogByID(int) *LaunchLog
 	FindByHash(hash string) *LaunchLog
-	FindPendingLogWithMaxNonce() int64
+	FindMaxNonce(from string) int64
+	FindNoncesInFlight(from string, minNonce int64) []int64
+	AllocateNonce(launchLog *LaunchLog, minNonce int64) error
 	FindAllCreated() []*LaunchLog
+	ClaimCreated(limit int, lease time.Duration) []*LaunchLog
+	ReleaseClaim(*LaunchLog) error
+	FindAllPending() []*LaunchLog
+	CountByStatus(status string) int
+	FindByStatus(status string) []*LaunchLog
+	FindMinedUntil(blockNumber int64) []*LaunchLog
+	CountInFlightByFrom() map[string]int
 	UpdateLaunchLog(*LaunchLog) error
+	UpdateLaunchLogStatus(launchLog *LaunchLog, status string) (bool, error)
+	UpdateLaunchLogReceipt(*LaunchLog) error
 	InsertLaunchLog(*LaunchLog) error
 	UpdateLaunchLogsStatusByItemID(string, int64) error
+	FindLaunchLogsAfterID(id int64, limit int) []*LaunchLog
+	FindLaunchLogsByItemIDs(itemType string, itemIDs []int64) []*LaunchLog
+	FindSenders() []string
+	FindApprovals(from, tokenAddress string, limit int) []*LaunchLog
 }
+
+// Statuses of launch logs, besides the pending, successful and failed statuses of transactions
+const (
+	LaunchLogStatusCreated = "created"
+
+	// the launch log can't be sent until the relayer account is fixed by hand, its trades are failed
+	LaunchLogStatusNeedsAttention = "needs_attention"
+
+	// the launch log reverted when it was simulated before sending, so it is never sent, its trades are failed
+	LaunchLogStatusSimulationFailed = "simulation_failed"
+
+	// the block the launch log was mined in is removed by a chain reorganization, it is to be sent again
+	LaunchLogStatusOrphaned = "orphaned"
+)
+
+// launchLogStatusesHoldingNonce are the statuses of launch logs which own their nonce until they are mined or given up.
+// An orphaned launch log is sent again with its nonce, even if its transaction is dropped by the node.
+var launchLogStatusesHoldingNonce = []string{LaunchLogStatusCreated, common.STATUS_PENDING, LaunchLogStatusOrphaned}
+
+// the Postgres channel notified with the id of every inserted launch log
+const LaunchLogsCreatedChannel = "launch_logs_created"
+
+// Item types of launch logs
+const (
+	LaunchLogItemTypeTrade   = "hydroTrade"
+	LaunchLogItemTypeApprove = "hydroApprove"
+
+	// a zero value transfer to the sender itself, which fills a nonce that is burned
+	LaunchLogItemTypeFillNonce = "fillNonce"
+)
+
+// the gas limit of an approval, used only if the launcher can't estimate its gas
+const fallbackApproveGasLimit = 200000
+
 type LaunchLog struct {
-	ID          int64          `db:"id" auto:"true" primaryKey:"true" autoIncrement:"true" gorm:"primary_key"`
-	ItemType    string         `db:"item_type"`
-	ItemID      int64          `db:"item_id"`
-	Status      string         `db:"status"`
-	Hash        sql.NullString `db:"transaction_hash" gorm:"column:transaction_hash"`
-	BlockNumber sql.NullInt64  `db:"block_number"`
-
-	From     string              `db:"t_from" gorm:"column:t_from"`
-	To       string              `db:"t_to"   gorm:"column:t_to"`
-	Value    decimal.Decimal     `db:"value"`
-	GasLimit int64               `db:"gas_limit"`
-	GasUsed  sql.NullInt64       `db:"gas_used"`
-	GasPrice decimal.NullDecimal `db:"gas_price"`
-	Nonce    sql.NullInt64       `db:"nonce"`
-	Data     string              `db:"data"`
-
-	ExecutedAt time.Time `db:"executed_at"`
-	CreatedAt  time.Time `db:"created_at"`
-	UpdatedAt  time.Time `db:"updated_at"`
+	ID          int64          `json:"id"          db:"id" auto:"true" primaryKey:"true" autoIncrement:"true" gorm:"primary_key"`
+	ItemType    string         `json:"itemType"    db:"item_type"`
+	ItemID      int64          `json:"itemID"      db:"item_id"`
+	Status      string         `json:"status"      db:"status"`
+	Hash        sql.NullString `json:"hash"        db:"transaction_hash" gorm:"column:transaction_hash"`
+	BlockNumber sql.NullInt64  `json:"blockNumber" db:"block_number"`
+
+	From     string              `json:"from"     db:"t_from" gorm:"column:t_from"`
+	To       string              `json:"to"       db:"t_to"   gorm:"column:t_to"`
+	Value    decimal.Decimal     `json:"value"    db:"value"`
+	GasLimit int64               `json:"gasLimit" db:"gas_limit"`
+	GasUsed  sql.NullInt64       `json:"gasUsed"  db:"gas_used"`
+	GasPrice decimal.NullDecimal `json:"gasPrice" db:"gas_price"`
+	Nonce    sql.NullInt64       `json:"nonce"    db:"nonce"`
+	Data     string              `json:"data"     db:"data"`
+
+	// An EIP-1559 transaction has a priority fee, and its gas price is the max fee per gas.
+	// The effective gas price is what the mined transaction paid per gas, from its receipt.
+	MaxPriorityFeePerGas decimal.NullDecimal `json:"maxPriorityFeePerGas" db:"max_priority_fee_per_gas"`
+	EffectiveGasPrice    decimal.NullDecimal `json:"effectiveGasPrice"    db:"effective_gas_price"`
+
+	// "success", or "reverted: <reason>" of the simulation before sending
+	SimulationResult sql.NullString `json:"simulationResult" db:"simulation_result"`
+
+	ExecutedAt time.Time `json:"executedAt" db:"executed_at"`
+	CreatedAt  time.Time `json:"createdAt"  db:"created_at"`
+	UpdatedAt  time.Time `json:"updatedAt"  db:"updated_at"`
 }
 
 func (LaunchLog) TableName() string {
@@ -70,10 +133,15 @@ func (launchLogDaoPG) FindByHash(hash string) *LaunchLog {
 	return &launchLog
 }
 
-func (launchLogDaoPG) FindPendingLogWithMaxNonce() int64 {
+// FindMaxNonce returns the max nonce allocated to launch logs sent from the address, -1 if there is none.
+func (launchLogDaoPG) FindMaxNonce(from string) int64 {
+	return findMaxNonce(DB, from)
+}
+
+func findMaxNonce(db *gorm.DB, from string) int64 {
 	var nonce sql.NullInt64
 
-	err := DB.Raw(`select max(nonce) from launch_logs`).Row().Scan(&nonce)
+	err := db.Raw(`select max(nonce) from launch_logs where lower(t_from) = lower(?)`, from).Row().Scan(&nonce)
 	if err != nil {
 		panic(err)
 	}
@@ -84,20 +152,252 @@ func (launchLogDaoPG) FindPendingLogWithMaxNonce() int64 {
 	}
 }
 
+// FindNoncesInFlight returns the nonces not less than minNonce, which are held by launch logs to be sent, sent again or being mined.
+func (launchLogDaoPG) FindNoncesInFlight(from string, minNonce int64) []int64 {
+	var nonces []int64
+
+	DB.Model(&LaunchLog{}).
+		Where("lower(t_from) = lower(?) and nonce >= ? and status in (?)", from, minNonce, launchLogStatusesHoldingNonce).
+		Order("nonce asc").
+		Pluck("distinct nonce", &nonces)
+
+	return nonces
+}
+
+// AllocateNonce gives the launch log the next nonce of its sender, which is at least minNonce, and saves them together.
+// Allocations of the same sender are serialized by an advisory lock, so that several launchers never share a nonce.
+func (launchLogDaoPG) AllocateNonce(launchLog *LaunchLog, minNonce int64) error {
+	tx := DB.Begin()
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	nonce, err := allocateNonce(tx, launchLog, minNonce)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+
+	launchLog.Nonce = sql.NullInt64{Int64: nonce, Valid: true}
+	return nil
+}
+
+func allocateNonce(tx *gorm.DB, launchLog *LaunchLog, minNonce int64) (int64, error) {
+	if err := tx.Exec(`select pg_advisory_xact_lock(hashtext(lower(?)))`, launchLog.From).Error; err != nil {
+		return 0, err
+	}
+
+	nonce := findMaxNonce(tx, launchLog.From) + 1
+	if nonce < minNonce {
+		nonce = minNonce
+	}
+
+	res := tx.Exec(`update launch_logs set nonce = ?, t_from = ? where id = ? and nonce is null`, nonce, launchLog.From, launchLog.ID)
+	if res.Error != nil {
+		return 0, res.Error
+	}
+
+	if res.RowsAffected != 1 {
+		return 0, fmt.Errorf("launch log %d already has a nonce", launchLog.ID)
+	}
+
+	return nonce, nil
+}
+
 func (launchLogDaoPG) FindAllCreated() []*LaunchLog {
 	var launchLogs []*LaunchLog
-	DB.Where("status = 'created'").Order("created_at asc").Find(&launchLogs)
+	DB.Where("status = ?", LaunchLogStatusCreated).Order("created_at asc").Find(&launchLogs)
 	return launchLogs
 }
 
+// ClaimCreated returns the oldest created launch logs which no other launcher is sending, and claims them for the lease.
+// Rows locked by another launcher are skipped instead of waited for, and a claim expires if its launcher dies.
+func (launchLogDaoPG) ClaimCreated(limit int, lease time.Duration) []*LaunchLog {
+	var launchLogs []*LaunchLog
+	now := time.Now().UTC()
+
+	DB.Raw(`update launch_logs set claimed_until = ? where id in (
+		select id from launch_logs where status = ? and (claimed_until is null or claimed_until < ?)
+		order by created_at asc limit ? for update skip locked
+	) returning *`, now.Add(lease), LaunchLogStatusCreated, now, limit).Scan(&launchLogs)
+
+	sort.Slice(launchLogs, func(i, j int) bool { return launchLogs[i].CreatedAt.Before(launchLogs[j].CreatedAt) })
+	return launchLogs
+}
+
+// ReleaseClaim lets any launcher claim the launch log again at once.
+func (launchLogDaoPG) ReleaseClaim(launchLog *LaunchLog) error {
+	return DB.Exec(`update launch_logs set claimed_until = null where id = ?`, launchLog.ID).Error
+}
+
+func (launchLogDaoPG) FindAllPending() []*LaunchLog {
+	var launchLogs []*LaunchLog
+	DB.Where("status = 'pending'").Order("nonce asc").Find(&launchLogs)
+	return launchLogs
+}
+
+func (launchLogDaoPG) FindByStatus(status string) []*LaunchLog {
+	var launchLogs []*LaunchLog
+	DB.Where("status = ?", status).Order("id asc").Find(&launchLogs)
+	return launchLogs
+}
+
+// FindMinedUntil returns the mined launch logs which are not finalized, in the blocks up to the block number.
+func (launchLogDaoPG) FindMinedUntil(blockNumber int64) []*LaunchLog {
+	var launchLogs []*LaunchLog
+	DB.Where("status = ? and block_number <= ?", TradeStatusMined, blockNumber).Order("block_number asc").Find(&launchLogs)
+	return launchLogs
+}
+
+func (launchLogDaoPG) CountByStatus(status string) int {
+	var count int
+	DB.Model(&LaunchLog{}).Where("status = ?", status).Count(&count)
+	return count
+}
+
+// CountInFlightByFrom returns the number of launch logs which have a nonce and are not mined yet, orphaned ones included, by sender.
+func (launchLogDaoPG) CountInFlightByFrom() map[string]int {
+	counts := make(map[string]int)
+
+	rows, err := DB.Raw(`select lower(t_from), count(*) from launch_logs where nonce is not null and status in (?) group by lower(t_from)`,
+		launchLogStatusesHoldingNonce).Rows()
+	if err != nil {
+		utils.Errorf("count in flight launch logs error: %v", err)
+		return counts
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var from string
+		var count int
+		if err := rows.Scan(&from, &count); err == nil {
+			counts[from] = count
+		}
+	}
+
+	return counts
+}
+
 func (launchLogDaoPG) UpdateLaunchLog(launchLog *LaunchLog) error {
 	return DB.Save(launchLog).Error
 }
 
+// UpdateLaunchLogStatus moves the launch log to the status, with the fields the launcher sets when it sends or gives it up,
+// only if its status in the database can move to the status. It tells if the launch log was updated.
+// The fields of the receipt and the nonce are left alone, they are saved by UpdateLaunchLogReceipt and AllocateNonce.
+func (launchLogDaoPG) UpdateLaunchLogStatus(launchLog *LaunchLog, status string) (bool, error) {
+	now := time.Now().UTC()
+
+	res := DB.Exec(`update launch_logs set status = ?, transaction_hash = ?, gas_limit = ?, gas_price = ?, max_priority_fee_per_gas = ?,
+		simulation_result = ?, updated_at = ? where id = ? and status in (?)`,
+		status, launchLog.Hash, launchLog.GasLimit, launchLog.GasPrice, launchLog.MaxPriorityFeePerGas,
+		launchLog.SimulationResult, now, launchLog.ID, launchLogStatusesBefore(status))
+	if res.Error != nil || res.RowsAffected == 0 {
+		return false, res.Error
+	}
+
+	launchLog.UpdatedAt = now
+	return true, nil
+}
+
+// UpdateLaunchLogReceipt saves the fields of a launch log which come from the receipt of its transaction.
+func (launchLogDaoPG) UpdateLaunchLogReceipt(launchLog *LaunchLog) error {
+	return DB.Exec(`update launch_logs set block_number = ?, gas_used = ?, effective_gas_price = ? where id = ?`,
+		launchLog.BlockNumber, launchLog.GasUsed, launchLog.EffectiveGasPrice, launchLog.ID).Error
+}
+
+// InsertLaunchLog saves a new launch log, and notifies the launchers listening on LaunchLogsCreatedChannel.
 func (launchLogDaoPG) InsertLaunchLog(launchLog *LaunchLog) error {
-	return DB.Create(launchLog).Error
+	if err := DB.Create(launchLog).Error; err != nil {
+		return err
+	}
+
+	// launchers poll as well, a lost notification only delays the launch log
+	if err := DB.Exec(`select pg_notify(?, ?)`, LaunchLogsCreatedChannel, strconv.FormatInt(launchLog.ID, 10)).Error; err != nil {
+		utils.Errorf("notify launch log %d error: %v", launchLog.ID, err)
+	}
+
+	return nil
 }
 
+// UpdateLaunchLogsStatusByItemID updates the launch logs of a transaction which can move to the status,
+// e.g. those which were given up before they were sent are kept.
 func (launchLogDaoPG) UpdateLaunchLogsStatusByItemID(status string, itemID int64) error {
-	return DB.Exec(`update launch_logs set "status" = ? where item_id = ?`, status, itemID).Error
+	return DB.Exec(`update launch_logs set "status" = ? where item_id = ? and item_type = ? and status in (?)`,
+		status, itemID, LaunchLogItemTypeTrade, launchLogStatusesBefore(status)).Error
+}
+
+// FindLaunchLogsAfterID returns launch logs ordered by id, it is used to walk through the whole table in batches.
+func (launchLogDaoPG) FindLaunchLogsAfterID(id int64, limit int) []*LaunchLog {
+	var launchLogs []*LaunchLog
+	DB.Where("id > ?", id).Order("id asc").Limit(limit).Find(&launchLogs)
+	return launchLogs
+}
+
+func (launchLogDaoPG) FindLaunchLogsByItemIDs(itemType string, itemIDs []int64) []*LaunchLog {
+	var launchLogs []*LaunchLog
+	DB.Where("item_type = ? and item_id in (?)", itemType, itemIDs).Order("id asc").Find(&launchLogs)
+	return launchLogs
+}
+
+// FindSenders returns the addresses launch logs have been sent from, the relayer and its delegates.
+func (launchLogDaoPG) FindSenders() (senders []string) {
+	DB.Model(&LaunchLog{}).Pluck("distinct t_from", &senders)
+	return
+}
+
+// FindApprovals returns the latest approvals of the token sent from the address, newest first.
+func (launchLogDaoPG) FindApprovals(from, tokenAddress string, limit int) []*LaunchLog {
+	var launchLogs []*LaunchLog
+	DB.Where("item_type = ? and lower(t_from) = lower(?) and lower(t_to) = lower(?)", LaunchLogItemTypeApprove, from, tokenAddress).
+		Order("id desc").Limit(limit).Find(&launchLogs)
+	return launchLogs
+}
+
+// NewApproveLaunchLog builds a launch log which approves the proxy to spend the token of the address without limit.
+func NewApproveLaunchLog(from, proxyAddress, tokenAddress string) (*LaunchLog, error) {
+	if len(proxyAddress) != 42 {
+		return nil, fmt.Errorf("invalid proxy address %s", proxyAddress)
+	}
+
+	return &LaunchLog{
+		ItemType:  LaunchLogItemTypeApprove,
+		Status:    LaunchLogStatusCreated,
+		From:      from,
+		To:        tokenAddress,
+		Value:     decimal.Zero,
+		GasLimit:  fallbackApproveGasLimit,
+		Data:      fmt.Sprintf("0x095ea7b3000000000000000000000000%sf000000000000000000000000000000000000000000000000000000000000000", proxyAddress[2:]),
+		CreatedAt: time.Now().UTC(),
+		UpdatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// IsApprovalInFlight tells if an approval can still be mined.
+func IsApprovalInFlight(launchLog *LaunchLog) bool {
+	switch launchLog.Status {
+	case LaunchLogStatusCreated, common.STATUS_PENDING, LaunchLogStatusOrphaned, TradeStatusMined:
+		return true
+	}
+
+	return false
+}
+
+// CountFailedApprovals returns how many of the approvals, newest first, failed in a row before the latest one.
+func CountFailedApprovals(approvals []*LaunchLog) (count int) {
+	for _, approval := range approvals {
+		switch approval.Status {
+		case common.STATUS_FAILED, LaunchLogStatusSimulationFailed, LaunchLogStatusNeedsAttention:
+			count++
+		default:
+			return
+		}
+	}
+
+	return
 }
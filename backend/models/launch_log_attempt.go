@@ -0,0 +1,118 @@
+package models
+
+import (
+	"database/sql"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+type ILaunchLogAttemptDao interface {
+	InsertLaunchLogAttempt(*LaunchLogAttempt) error
+	FindByHash(hash string) *LaunchLogAttempt
+	FindByLaunchLogID(launchLogID int64) []*LaunchLogAttempt
+	FindSentSince(blockNumber int64) []*SentAttempt
+}
+
+// the Postgres channel notified with a SentAttempt for every attempt the node accepted
+const LaunchLogAttemptsSentChannel = "launch_log_attempts_sent"
+
+// SentAttempt is a transaction sent for a launch log, as the watcher needs to recognize it.
+type SentAttempt struct {
+	Hash  string `json:"hash"`
+	From  string `json:"from"`
+	Nonce int64  `json:"nonce"`
+}
+
+// LaunchLogAttempt is a try to sign and send a transaction for a launch log, whether it succeeded or not.
+// A launch log has more than one attempt when sending is retried,
+// or when a stuck transaction is replaced with a higher gas price.
+type LaunchLogAttempt struct {
+	ID          int64           `json:"id"          db:"id" primaryKey:"true" autoIncrement:"true" gorm:"primary_key"`
+	LaunchLogID int64           `json:"launchLogID" db:"launch_log_id"`
+	Hash        string          `json:"hash"        db:"transaction_hash" gorm:"column:transaction_hash"`
+	Nonce       int64           `json:"nonce"       db:"nonce"`
+	GasPrice    decimal.Decimal `json:"gasPrice"    db:"gas_price"`
+	CreatedAt   time.Time       `json:"createdAt"   db:"created_at"`
+
+	MaxPriorityFeePerGas decimal.NullDecimal `json:"maxPriorityFeePerGas" db:"max_priority_fee_per_gas"`
+
+	// nil if the attempt didn't get that far. The hash is empty if it was never signed
+	SignedAt *time.Time `json:"signedAt" db:"signed_at"`
+	SentAt   *time.Time `json:"sentAt"   db:"sent_at"`
+
+	// why signing or sending failed, an attempt without an error is a transaction the node accepted
+	Error sql.NullString `json:"error" db:"error"`
+}
+
+func (LaunchLogAttempt) TableName() string {
+	return "launch_log_attempts"
+}
+
+var LaunchLogAttemptDao ILaunchLogAttemptDao
+var LaunchLogAttemptDaoPG ILaunchLogAttemptDao
+
+func init() {
+	LaunchLogAttemptDao = &launchLogAttemptDaoPG{}
+	LaunchLogAttemptDaoPG = LaunchLogAttemptDao
+}
+
+type launchLogAttemptDaoPG struct {
+}
+
+// InsertLaunchLogAttempt saves an attempt. An attempt which was sent is notified on LaunchLogAttemptsSentChannel.
+func (dao launchLogAttemptDaoPG) InsertLaunchLogAttempt(attempt *LaunchLogAttempt) error {
+	// a launch log sent again with the same nonce and fees has the same hash, the attempt is saved once
+	if !attempt.Error.Valid {
+		if sent := dao.FindByHash(attempt.Hash); sent != nil {
+			attempt.ID = sent.ID
+			return nil
+		}
+	}
+
+	if err := DB.Create(attempt).Error; err != nil {
+		return err
+	}
+
+	if attempt.Error.Valid {
+		return nil
+	}
+
+	// the watcher falls back to the database, a lost notification only costs a query
+	err := DB.Exec(`select pg_notify(?, json_build_object('hash', ?::text, 'from', t_from, 'nonce', ?::bigint)::text) from launch_logs where id = ?`,
+		LaunchLogAttemptsSentChannel, attempt.Hash, attempt.Nonce, attempt.LaunchLogID).Error
+	if err != nil {
+		utils.Errorf("notify attempt %s error: %v", attempt.Hash, err)
+	}
+
+	return nil
+}
+
+// FindByHash finds the attempt which sent the transaction of the hash.
+func (launchLogAttemptDaoPG) FindByHash(hash string) *LaunchLogAttempt {
+	var attempt LaunchLogAttempt
+	DB.Where("transaction_hash = ? and error is null", hash).First(&attempt)
+	if attempt.ID == 0 {
+		return nil
+	}
+
+	return &attempt
+}
+
+func (launchLogAttemptDaoPG) FindByLaunchLogID(launchLogID int64) []*LaunchLogAttempt {
+	var attempts []*LaunchLogAttempt
+	DB.Where("launch_log_id = ?", launchLogID).Order("id asc").Find(&attempts)
+	return attempts
+}
+
+// FindSentSince returns the sent attempts whose launch logs can still be mined or removed by a reorganization:
+// those not mined yet, and those mined since the block.
+func (launchLogAttemptDaoPG) FindSentSince(blockNumber int64) []*SentAttempt {
+	var attempts []*SentAttempt
+	DB.Raw(`select a.transaction_hash as hash, l.t_from as "from", a.nonce from launch_log_attempts a
+		join launch_logs l on l.id = a.launch_log_id
+		where a.error is null and (l.status in (?) or l.block_number >= ?)`,
+		[]string{common.STATUS_PENDING, LaunchLogStatusOrphaned, TradeStatusMined}, blockNumber).Scan(&attempts)
+	return attempts
+}
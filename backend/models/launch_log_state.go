@@ -0,0 +1,81 @@
+package models
+
+import (
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+)
+
+// A launch log moves through its statuses only by these transitions.
+//
+//	created -> pending -> mined -> successful or failed -> orphaned
+//	   |          |  ^                                       |
+//	   |          |  +-- replaced, pending again             +-> pending when it is sent again,
+//	   |          |                                              mined, successful or failed when it is mined again
+//	   |          +----> successful or failed, without a confirmation depth    +-> failed or needs_attention, when it can't be sent again
+//	   +-> failed, needs_attention or simulation_failed, when it is given up before it is sent
+var launchLogTransitions = map[string][]string{
+	LaunchLogStatusCreated:   {common.STATUS_PENDING, common.STATUS_FAILED, LaunchLogStatusNeedsAttention, LaunchLogStatusSimulationFailed},
+	common.STATUS_PENDING:    {common.STATUS_PENDING, TradeStatusMined, common.STATUS_SUCCESSFUL, common.STATUS_FAILED},
+	TradeStatusMined:         {common.STATUS_SUCCESSFUL, LaunchLogStatusOrphaned},
+	common.STATUS_SUCCESSFUL: {LaunchLogStatusOrphaned},
+	common.STATUS_FAILED:     {LaunchLogStatusOrphaned},
+	LaunchLogStatusOrphaned:  {common.STATUS_PENDING, TradeStatusMined, common.STATUS_SUCCESSFUL, common.STATUS_FAILED, LaunchLogStatusNeedsAttention},
+}
+
+type InvalidLaunchLogTransitionError struct {
+	ID       int64
+	From, To string
+}
+
+func (e InvalidLaunchLogTransitionError) Error() string {
+	return fmt.Sprintf("launch log %d can't move from %s to %s", e.ID, e.From, e.To)
+}
+
+// CanTransitLaunchLog tells if a launch log can move from one status to another.
+func CanTransitLaunchLog(from, to string) bool {
+	for _, status := range launchLogTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+
+	return false
+}
+
+// launchLogStatusesBefore returns the statuses which can move to the status.
+func launchLogStatusesBefore(to string) []string {
+	var statuses []string
+	for from := range launchLogTransitions {
+		if CanTransitLaunchLog(from, to) {
+			statuses = append(statuses, from)
+		}
+	}
+
+	return statuses
+}
+
+// TransitLaunchLog moves the launch log to the status and saves it, if the transition is allowed.
+// The transition is checked against the status in the database as well, which another process may have moved
+// since the launch log was loaded, e.g. the watcher has seen it mined.
+func TransitLaunchLog(launchLog *LaunchLog, status string) error {
+	if !CanTransitLaunchLog(launchLog.Status, status) {
+		return InvalidLaunchLogTransitionError{ID: launchLog.ID, From: launchLog.Status, To: status}
+	}
+
+	updated, err := LaunchLogDao.UpdateLaunchLogStatus(launchLog, status)
+	if err != nil {
+		return err
+	}
+
+	if !updated {
+		from := launchLog.Status
+		if current := LaunchLogDao.FindLaunchLogByID(int(launchLog.ID)); current != nil && current.ID != 0 {
+			from = current.Status
+		}
+
+		return InvalidLaunchLogTransitionError{ID: launchLog.ID, From: from, To: status}
+	}
+
+	launchLog.Status = status
+	return nil
+}
@@ -2,12 +2,14 @@ package models
 
 import (
 	"database/sql"
+	"fmt"
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/HydroProtocol/hydro-sdk-backend/utils"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 )
@@ -66,3 +68,168 @@ func newLaunchLog() *LaunchLog {
 
 	return &launchLog
 }
+
+func TestLaunchLogDao_PG_FindLaunchLogByAttemptHash(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	launchLog := newLaunchLog()
+	launchLog.Hash = sql.NullString{String: "0xreplacement", Valid: true}
+	assert.Nil(t, LaunchLogDaoPG.InsertLaunchLog(launchLog))
+
+	assert.Nil(t, LaunchLogAttemptDaoPG.InsertLaunchLogAttempt(&LaunchLogAttempt{LaunchLogID: launchLog.ID, Hash: "0xfirst", Nonce: 1, GasPrice: decimal.New(3, 9)}))
+	assert.Nil(t, LaunchLogAttemptDaoPG.InsertLaunchLogAttempt(&LaunchLogAttempt{LaunchLogID: launchLog.ID, Hash: "0xreplacement", Nonce: 1, GasPrice: decimal.New(4, 9)}))
+
+	assert.EqualValues(t, 2, len(LaunchLogAttemptDaoPG.FindByLaunchLogID(launchLog.ID)))
+	assert.EqualValues(t, launchLog.ID, FindLaunchLogByAttemptHash("0xfirst").ID)
+	assert.EqualValues(t, launchLog.ID, FindLaunchLogByAttemptHash("0xreplacement").ID)
+	assert.Nil(t, FindLaunchLogByAttemptHash("0xunknown"))
+}
+
+func TestLaunchLogAttemptDao_PG_InsertSameHashAgain(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	launchLog := newLaunchLog()
+	assert.Nil(t, LaunchLogDaoPG.InsertLaunchLog(launchLog))
+
+	// an orphaned launch log sent again with the same nonce and fees
+	first := &LaunchLogAttempt{LaunchLogID: launchLog.ID, Hash: "0xsame", Nonce: 1, GasPrice: decimal.New(3, 9)}
+	again := &LaunchLogAttempt{LaunchLogID: launchLog.ID, Hash: "0xsame", Nonce: 1, GasPrice: decimal.New(3, 9)}
+	assert.Nil(t, LaunchLogAttemptDaoPG.InsertLaunchLogAttempt(first))
+	assert.Nil(t, LaunchLogAttemptDaoPG.InsertLaunchLogAttempt(again))
+	assert.EqualValues(t, first.ID, again.ID)
+
+	// a failed send of the same hash is recorded
+	failed := &LaunchLogAttempt{LaunchLogID: launchLog.ID, Hash: "0xsame", Nonce: 1, GasPrice: decimal.New(3, 9), Error: sql.NullString{String: "nonce too low", Valid: true}}
+	assert.Nil(t, LaunchLogAttemptDaoPG.InsertLaunchLogAttempt(failed))
+	assert.EqualValues(t, 2, len(LaunchLogAttemptDaoPG.FindByLaunchLogID(launchLog.ID)))
+}
+
+func TestLaunchLogDao_PG_AllocateNonce(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	assert.EqualValues(t, -1, LaunchLogDaoPG.FindMaxNonce(TestUser1))
+
+	launchLog1 := newLaunchLog()
+	launchLog2 := newLaunchLog()
+	_ = LaunchLogDaoPG.InsertLaunchLog(launchLog1)
+	_ = LaunchLogDaoPG.InsertLaunchLog(launchLog2)
+
+	// starts from the chain nonce
+	assert.Nil(t, LaunchLogDaoPG.AllocateNonce(launchLog1, 5))
+	assert.EqualValues(t, 5, launchLog1.Nonce.Int64)
+
+	// continues from the allocated nonces
+	assert.Nil(t, LaunchLogDaoPG.AllocateNonce(launchLog2, 3))
+	assert.EqualValues(t, 6, launchLog2.Nonce.Int64)
+	assert.EqualValues(t, 6, LaunchLogDaoPG.FindMaxNonce(TestUser1))
+
+	// a nonce is allocated once
+	assert.NotNil(t, LaunchLogDaoPG.AllocateNonce(launchLog2, 3))
+
+	launchLog1.Status = common.STATUS_FAILED
+	_ = LaunchLogDaoPG.UpdateLaunchLog(launchLog1)
+	assert.EqualValues(t, []int64{6}, LaunchLogDaoPG.FindNoncesInFlight(TestUser1, 5))
+}
+
+func TestLaunchLogDao_PG_OrphanedLaunchLogHoldsNonce(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	launchLog1 := newLaunchLog()
+	launchLog2 := newLaunchLog()
+	_ = LaunchLogDaoPG.InsertLaunchLog(launchLog1)
+	_ = LaunchLogDaoPG.InsertLaunchLog(launchLog2)
+
+	assert.Nil(t, LaunchLogDaoPG.AllocateNonce(launchLog1, 5))
+	assert.Nil(t, LaunchLogDaoPG.AllocateNonce(launchLog2, 5))
+
+	// the block of the first is removed, it is to be sent again with its nonce
+	launchLog1.Status = LaunchLogStatusOrphaned
+	launchLog2.Status = common.STATUS_SUCCESSFUL
+	_ = LaunchLogDaoPG.UpdateLaunchLog(launchLog1)
+	_ = LaunchLogDaoPG.UpdateLaunchLog(launchLog2)
+
+	assert.EqualValues(t, []int64{5}, LaunchLogDaoPG.FindNoncesInFlight(TestUser1, 5))
+	assert.EqualValues(t, map[string]int{strings.ToLower(TestUser1): 1}, LaunchLogDaoPG.CountInFlightByFrom())
+}
+
+func TestLaunchLogDao_PG_TransitLaunchLogFromStaleRow(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	launchLog := newLaunchLog()
+	_ = LaunchLogDaoPG.InsertLaunchLog(launchLog)
+	assert.Nil(t, LaunchLogDaoPG.AllocateNonce(launchLog, 5))
+
+	launchLog.Hash = sql.NullString{String: "0xfirst", Valid: true}
+	assert.Nil(t, TransitLaunchLog(launchLog, common.STATUS_PENDING))
+
+	// the watcher sees it mined meanwhile
+	mined := LaunchLogDaoPG.FindLaunchLogByID(int(launchLog.ID))
+	mined.BlockNumber = sql.NullInt64{Int64: 100, Valid: true}
+	mined.GasUsed = sql.NullInt64{Int64: 21000, Valid: true}
+	assert.Nil(t, LaunchLogDaoPG.UpdateLaunchLogReceipt(mined))
+	assert.Nil(t, TransitLaunchLog(mined, common.STATUS_SUCCESSFUL))
+
+	// the launcher replaces it from its stale row, which changes nothing
+	launchLog.Hash = sql.NullString{String: "0xreplacement", Valid: true}
+	err := TransitLaunchLog(launchLog, common.STATUS_PENDING)
+	assert.EqualError(t, err, fmt.Sprintf("launch log %d can't move from successful to pending", launchLog.ID))
+
+	saved := LaunchLogDaoPG.FindLaunchLogByID(int(launchLog.ID))
+	assert.EqualValues(t, common.STATUS_SUCCESSFUL, saved.Status)
+	assert.EqualValues(t, "0xfirst", saved.Hash.String)
+	assert.EqualValues(t, 100, saved.BlockNumber.Int64)
+	assert.EqualValues(t, 21000, saved.GasUsed.Int64)
+	assert.EqualValues(t, 5, saved.Nonce.Int64)
+}
+
+func TestLaunchLogTransitions(t *testing.T) {
+	assert.True(t, CanTransitLaunchLog(LaunchLogStatusCreated, common.STATUS_PENDING))
+	assert.True(t, CanTransitLaunchLog(LaunchLogStatusCreated, LaunchLogStatusSimulationFailed))
+	assert.True(t, CanTransitLaunchLog(common.STATUS_PENDING, common.STATUS_PENDING))
+	assert.True(t, CanTransitLaunchLog(common.STATUS_PENDING, common.STATUS_SUCCESSFUL))
+
+	assert.False(t, CanTransitLaunchLog(LaunchLogStatusCreated, common.STATUS_SUCCESSFUL))
+	assert.False(t, CanTransitLaunchLog(common.STATUS_PENDING, LaunchLogStatusNeedsAttention))
+	assert.False(t, CanTransitLaunchLog(common.STATUS_SUCCESSFUL, common.STATUS_FAILED))
+	assert.False(t, CanTransitLaunchLog(LaunchLogStatusNeedsAttention, common.STATUS_FAILED))
+
+	// a chain reorganization
+	assert.True(t, CanTransitLaunchLog(common.STATUS_SUCCESSFUL, LaunchLogStatusOrphaned))
+	assert.True(t, CanTransitLaunchLog(LaunchLogStatusOrphaned, common.STATUS_PENDING))
+	assert.False(t, CanTransitLaunchLog(LaunchLogStatusCreated, LaunchLogStatusOrphaned))
+
+	// an orphaned launch log which can't be sent again is given up
+	assert.True(t, CanTransitLaunchLog(LaunchLogStatusOrphaned, LaunchLogStatusNeedsAttention))
+
+	assert.ElementsMatch(t, []string{LaunchLogStatusCreated, common.STATUS_PENDING, LaunchLogStatusOrphaned}, launchLogStatusesBefore(common.STATUS_FAILED))
+	assert.ElementsMatch(t, []string{common.STATUS_PENDING, TradeStatusMined, LaunchLogStatusOrphaned}, launchLogStatusesBefore(common.STATUS_SUCCESSFUL))
+
+	// finalized after the confirmation depth
+	assert.True(t, CanTransitLaunchLog(common.STATUS_PENDING, TradeStatusMined))
+	assert.True(t, CanTransitLaunchLog(TradeStatusMined, LaunchLogStatusOrphaned))
+	assert.False(t, CanTransitLaunchLog(TradeStatusMined, common.STATUS_FAILED))
+
+	err := TransitLaunchLog(&LaunchLog{ID: 1, Status: common.STATUS_FAILED}, common.STATUS_PENDING)
+	assert.EqualError(t, err, "launch log 1 can't move from failed to pending")
+}
+
+func TestCountFailedApprovals(t *testing.T) {
+	approvals := []*LaunchLog{
+		{Status: LaunchLogStatusSimulationFailed},
+		{Status: common.STATUS_FAILED},
+		{Status: common.STATUS_SUCCESSFUL},
+		{Status: common.STATUS_FAILED},
+	}
+
+	assert.EqualValues(t, 2, CountFailedApprovals(approvals))
+	assert.EqualValues(t, 0, CountFailedApprovals(approvals[2:]))
+	assert.EqualValues(t, 0, CountFailedApprovals(nil))
+
+	assert.True(t, IsApprovalInFlight(&LaunchLog{Status: LaunchLogStatusOrphaned}))
+	assert.False(t, IsApprovalInFlight(approvals[0]))
+}
@@ -0,0 +1,198 @@
+package models
+
+import (
+	"fmt"
+	"github.com/jinzhu/gorm"
+	"github.com/shopspring/decimal"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// In paper trading mode, HSK_PAPER_TRADING=true, trades are settled on a ledger in the database instead of the chain.
+// Traders have virtual balances per token, credited by deposits, which the launcher moves when it settles trades.
+func IsPaperTrading() bool {
+	return os.Getenv("HSK_PAPER_TRADING") == "true"
+}
+
+type ILedgerDao interface {
+	GetBalance(address, tokenAddress string) decimal.Decimal
+	FindBalances(address string) []*LedgerBalance
+	FindEntriesByReference(reference string) []*LedgerEntry
+
+	// Apply applies the entries of the reference at once, it returns false if they are applied already.
+	// The balances of the unbounded addresses may go below zero, e.g. the relayer's, which pays the maker rebates.
+	Apply(reference string, entries []*LedgerEntry, unbounded ...string) (bool, error)
+}
+
+const (
+	LedgerEntryKindDeposit = "deposit"
+	LedgerEntryKindTrade   = "trade"
+	LedgerEntryKindFee     = "fee"
+	LedgerEntryKindRebate  = "rebate"
+	LedgerEntryKindGasFee  = "gas_fee"
+)
+
+// LedgerBalance is the virtual balance of an address, in token units.
+type LedgerBalance struct {
+	Address      string          `json:"address"      db:"address" gorm:"primary_key"`
+	TokenAddress string          `json:"tokenAddress" db:"token_address" gorm:"primary_key"`
+	Amount       decimal.Decimal `json:"amount"       db:"amount"`
+	UpdatedAt    time.Time       `json:"updatedAt"    db:"updated_at"`
+}
+
+func (LedgerBalance) TableName() string {
+	return "ledger_balances"
+}
+
+// LedgerEntry is a change of a balance, the entries of a deposit or a settlement share a reference.
+type LedgerEntry struct {
+	ID           int64           `json:"id"           db:"id" gorm:"primary_key"`
+	Reference    string          `json:"reference"    db:"reference"`
+	Kind         string          `json:"kind"         db:"kind"`
+	Address      string          `json:"address"      db:"address"`
+	TokenAddress string          `json:"tokenAddress" db:"token_address"`
+	Amount       decimal.Decimal `json:"amount"       db:"amount"`
+	CreatedAt    time.Time       `json:"createdAt"    db:"created_at"`
+}
+
+func (LedgerEntry) TableName() string {
+	return "ledger_entries"
+}
+
+// InsufficientLedgerBalanceError is returned when entries would take a balance below zero, none of them is applied then.
+type InsufficientLedgerBalanceError struct {
+	Address      string
+	TokenAddress string
+	Balance      decimal.Decimal
+}
+
+func (e InsufficientLedgerBalanceError) Error() string {
+	return fmt.Sprintf("ledger balance of %s in token %s would be %s", e.Address, e.TokenAddress, e.Balance)
+}
+
+// DepositLedgerReference is the reference of a deposit, which is unique per request of the admin.
+func DepositLedgerReference(address, tokenAddress string, at time.Time) string {
+	return fmt.Sprintf("deposit:%s:%s:%d", strings.ToLower(address), strings.ToLower(tokenAddress), at.UnixNano())
+}
+
+var LedgerDao ILedgerDao
+var LedgerDaoPG ILedgerDao
+
+func init() {
+	LedgerDao = &ledgerDaoPG{}
+	LedgerDaoPG = LedgerDao
+}
+
+type ledgerDaoPG struct {
+}
+
+func (ledgerDaoPG) GetBalance(address, tokenAddress string) decimal.Decimal {
+	var balance LedgerBalance
+	DB.Where("address = ? and token_address = ?", strings.ToLower(address), strings.ToLower(tokenAddress)).First(&balance)
+	return balance.Amount
+}
+
+func (ledgerDaoPG) FindBalances(address string) []*LedgerBalance {
+	var balances []*LedgerBalance
+	DB.Where("address = ?", strings.ToLower(address)).Order("token_address asc").Find(&balances)
+	return balances
+}
+
+func (ledgerDaoPG) FindEntriesByReference(reference string) []*LedgerEntry {
+	var entries []*LedgerEntry
+	DB.Where("reference = ?", reference).Order("id asc").Find(&entries)
+	return entries
+}
+
+func (ledgerDaoPG) Apply(reference string, entries []*LedgerEntry, unbounded ...string) (bool, error) {
+	tx := DB.Begin()
+	if tx.Error != nil {
+		return false, tx.Error
+	}
+
+	applied, err := applyLedgerEntries(tx, reference, entries, unbounded)
+	if err != nil || !applied {
+		tx.Rollback()
+		return false, err
+	}
+
+	return true, tx.Commit().Error
+}
+
+// applyLedgerEntries is serialized per reference by an advisory lock, so that a reference is never applied twice.
+func applyLedgerEntries(tx *gorm.DB, reference string, entries []*LedgerEntry, unbounded []string) (bool, error) {
+	if err := tx.Exec(`select pg_advisory_xact_lock(hashtext(?))`, reference).Error; err != nil {
+		return false, err
+	}
+
+	var count int
+	if err := tx.Model(&LedgerEntry{}).Where("reference = ?", reference).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	if count > 0 {
+		return false, nil
+	}
+
+	isUnbounded := make(map[string]bool)
+	for _, address := range unbounded {
+		isUnbounded[strings.ToLower(address)] = true
+	}
+
+	now := time.Now().UTC()
+
+	for _, balance := range NetLedgerEntries(entries) {
+		var amount decimal.Decimal
+		err := tx.Raw(`insert into ledger_balances (address, token_address, amount, updated_at) values (?, ?, ?, ?)
+			on conflict (address, token_address) do update set amount = ledger_balances.amount + excluded.amount, updated_at = excluded.updated_at
+			returning amount`, balance.Address, balance.TokenAddress, balance.Amount, now).Row().Scan(&amount)
+		if err != nil {
+			return false, err
+		}
+
+		if amount.IsNegative() && !isUnbounded[balance.Address] {
+			return false, InsufficientLedgerBalanceError{Address: balance.Address, TokenAddress: balance.TokenAddress, Balance: amount}
+		}
+	}
+
+	for _, entry := range entries {
+		entry.Reference = reference
+		entry.Address = strings.ToLower(entry.Address)
+		entry.TokenAddress = strings.ToLower(entry.TokenAddress)
+		entry.CreatedAt = now
+
+		if err := tx.Create(entry).Error; err != nil {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// NetLedgerEntries sums the entries per address and token, so that a balance is only checked after all of them.
+// The sums are in the order of address and token, which keeps the row locks of concurrent settlements in order.
+func NetLedgerEntries(entries []*LedgerEntry) []*LedgerBalance {
+	sums := make(map[[2]string]decimal.Decimal)
+
+	for _, entry := range entries {
+		key := [2]string{strings.ToLower(entry.Address), strings.ToLower(entry.TokenAddress)}
+		sums[key] = sums[key].Add(entry.Amount)
+	}
+
+	balances := make([]*LedgerBalance, 0, len(sums))
+	for key, amount := range sums {
+		balances = append(balances, &LedgerBalance{Address: key[0], TokenAddress: key[1], Amount: amount})
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Address != balances[j].Address {
+			return balances[i].Address < balances[j].Address
+		}
+
+		return balances[i].TokenAddress < balances[j].TokenAddress
+	})
+
+	return balances
+}
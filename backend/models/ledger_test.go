@@ -0,0 +1,52 @@
+package models
+
+import (
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestNetLedgerEntries(t *testing.T) {
+	entries := []*LedgerEntry{
+		{Address: "0xB", TokenAddress: "0xdai", Amount: decimal.New(-5, 0)},
+		{Address: "0xa", TokenAddress: "0xhot", Amount: decimal.New(3, 0)},
+		{Address: "0xb", TokenAddress: "0xDAI", Amount: decimal.New(2, 0)},
+		{Address: "0xa", TokenAddress: "0xdai", Amount: decimal.New(1, 0)},
+	}
+
+	balances := NetLedgerEntries(entries)
+
+	assert.Len(t, balances, 3)
+	assert.EqualValues(t, []string{"0xa", "0xa", "0xb"}, []string{balances[0].Address, balances[1].Address, balances[2].Address})
+	assert.EqualValues(t, []string{"0xdai", "0xhot", "0xdai"}, []string{balances[0].TokenAddress, balances[1].TokenAddress, balances[2].TokenAddress})
+	assert.True(t, decimal.New(-3, 0).Equal(balances[2].Amount))
+}
+
+func TestLedgerDao_PG_ApplyUnbounded(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	// the relayer pays a rebate which is more than the fee
+	entries := []*LedgerEntry{
+		{Kind: LedgerEntryKindFee, Address: "0xtaker", TokenAddress: "0xdai", Amount: decimal.New(-6, -1)},
+		{Kind: LedgerEntryKindFee, Address: "0xrelayer", TokenAddress: "0xdai", Amount: decimal.New(6, -1)},
+		{Kind: LedgerEntryKindRebate, Address: "0xrelayer", TokenAddress: "0xdai", Amount: decimal.New(-9, -1)},
+		{Kind: LedgerEntryKindRebate, Address: "0xmaker", TokenAddress: "0xdai", Amount: decimal.New(9, -1)},
+	}
+
+	_, err := LedgerDaoPG.Apply(DepositLedgerReference("0xtaker", "0xdai", time.Unix(1548892800, 0)), []*LedgerEntry{
+		{Kind: LedgerEntryKindDeposit, Address: "0xtaker", TokenAddress: "0xdai", Amount: decimal.New(1, 0)},
+	})
+	assert.Nil(t, err)
+
+	_, err = LedgerDaoPG.Apply("settlement:1", entries)
+	_, insufficient := err.(InsufficientLedgerBalanceError)
+	assert.True(t, insufficient)
+
+	applied, err := LedgerDaoPG.Apply("settlement:1", entries, "0xRelayer")
+	assert.Nil(t, err)
+	assert.True(t, applied)
+	assert.EqualValues(t, "-0.3", LedgerDaoPG.GetBalance("0xrelayer", "0xdai").String())
+	assert.EqualValues(t, "0.4", LedgerDaoPG.GetBalance("0xtaker", "0xdai").String())
+}
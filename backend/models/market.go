@@ -7,9 +7,12 @@ import (
 type IMarketDao interface {
 	FindAllMarkets() []*Market
 	FindPublishedMarkets() []*Market
+	FindMarketsByStatus(status string) []*Market
 	FindMarketByID(marketID string) *Market
 	InsertMarket(market *Market) error
 	UpdateMarket(market *Market) error
+	UpdateApprovingMarket(market *Market) (bool, error)
+	UpdateGasUsedEstimation(marketID string, gasUsed int64) error
 }
 
 type Market struct {
@@ -32,6 +35,31 @@ type Market struct {
 	TakerFeeRate      decimal.Decimal `json:"takerFeeRate"      db:"taker_fee_rate"`
 	GasUsedEstimation int             `json:"gasUsedEstimation" db:"gas_used_estimation"`
 	IsPublished       bool            `json:"isPublished"       db:"is_published"`
+
+	// IsPublished is true only when the status is published
+	Status string `json:"status" db:"status"`
+
+	// why the latest approval of a token of the market failed, empty once the market is published
+	ApprovalError string `json:"approvalError" db:"approval_error"`
+}
+
+// Statuses of markets. Before a market is published, the relayer approves the proxy for both of its tokens.
+const (
+	MarketStatusUnpublished = "unpublished"
+
+	// waiting for the approvals of its tokens to be mined
+	MarketStatusApproving = "approving"
+
+	MarketStatusPublished = "published"
+
+	// an approval failed too many times, the market is published again by hand to retry
+	MarketStatusApprovalFailed = "approval_failed"
+)
+
+// SetStatus changes the status of the market, and whether it is published accordingly.
+func (m *Market) SetStatus(status string) {
+	m.Status = status
+	m.IsPublished = status == MarketStatusPublished
 }
 
 func (Market) TableName() string {
@@ -55,6 +83,12 @@ func (marketDaoPG) FindPublishedMarkets() []*Market {
 	return markets
 }
 
+func (marketDaoPG) FindMarketsByStatus(status string) []*Market {
+	var markets []*Market
+	DB.Where("status = ?", status).Find(&markets)
+	return markets
+}
+
 func (marketDaoPG) FindAllMarkets() []*Market {
 	var markets []*Market
 	DB.Find(&markets)
@@ -77,3 +111,21 @@ func (marketDaoPG) InsertMarket(market *Market) error {
 func (marketDaoPG) UpdateMarket(market *Market) error {
 	return DB.Save(market).Error
 }
+
+// UpdateApprovingMarket saves the status, whether it is published and the approval error of a market,
+// only if it is still approving. It tells if the market was updated, it is not when it was changed by hand meanwhile.
+func (marketDaoPG) UpdateApprovingMarket(market *Market) (bool, error) {
+	res := DB.Exec(`update markets set status = ?, is_published = ?, approval_error = ? where id = ? and status = ?`,
+		market.Status, market.IsPublished, market.ApprovalError, market.ID, MarketStatusApproving)
+
+	return res.RowsAffected > 0, res.Error
+}
+
+// the weight of the latest observation in the rolling gas used estimation of a market
+const gasUsedEstimationWeight = 0.2
+
+// UpdateGasUsedEstimation moves the gas used estimation of a market towards the gas used by a match just mined.
+func (marketDaoPG) UpdateGasUsedEstimation(marketID string, gasUsed int64) error {
+	return DB.Exec(`update markets set gas_used_estimation = round(gas_used_estimation * ? + ? * ?) where id = ?`,
+		1-gasUsedEstimationWeight, gasUsed, gasUsedEstimationWeight, marketID).Error
+}
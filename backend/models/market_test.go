@@ -53,3 +53,28 @@ func Test_PG_MarketDao_FindAndInsertMarket(t *testing.T) {
 	assert.EqualValues(t, market.BaseTokenSymbol, dbMarket.BaseTokenSymbol)
 	assert.EqualValues(t, market.BaseTokenName, dbMarket.BaseTokenName)
 }
+
+func Test_PG_MarketDao_UpdateApprovingMarket(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	market := Market{ID: "HOT-WETH", MinOrderSize: utils.IntToDecimal(1), MakerFeeRate: utils.StringToDecimal("0.001"), TakerFeeRate: utils.StringToDecimal("0.001")}
+	market.SetStatus(MarketStatusApproving)
+	assert.Nil(t, MarketDaoPG.InsertMarket(&market))
+
+	market.SetStatus(MarketStatusPublished)
+	updated, err := MarketDaoPG.UpdateApprovingMarket(&market)
+	assert.Nil(t, err)
+	assert.True(t, updated)
+	assert.True(t, MarketDaoPG.FindMarketByID("HOT-WETH").IsPublished)
+
+	// unpublished by hand, it is not published again
+	market.SetStatus(MarketStatusUnpublished)
+	assert.Nil(t, MarketDaoPG.UpdateMarket(&market))
+
+	market.SetStatus(MarketStatusPublished)
+	updated, err = MarketDaoPG.UpdateApprovingMarket(&market)
+	assert.Nil(t, err)
+	assert.False(t, updated)
+	assert.EqualValues(t, MarketStatusUnpublished, MarketDaoPG.FindMarketByID("HOT-WETH").Status)
+}
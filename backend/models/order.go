@@ -11,7 +11,10 @@ import (
 type IOrderDao interface {
 	FindMarketPendingOrders(marketID string) []*Order
 	FindByAccount(trader, marketID, status string, offset, limit int) (int64, []*Order)
+	FindPendingByTrader(trader string) []*Order
 	FindByID(id string) *Order
+	FindByClientOrderID(trader, clientOrderID string) *Order
+	FindOrdersAfterID(id string, limit int) []*Order
 	InsertOrder(order *Order) error
 	UpdateOrder(order *Order) error
 	Count() int
@@ -27,6 +30,7 @@ func init() {
 
 type Order struct {
 	ID              string          `json:"id" db:"id" primaryKey:"true" gorm:"primary_key"`
+	ClientOrderID   string          `json:"clientOrderID" db:"client_order_id"`
 	TraderAddress   string          `json:"traderAddress" db:"trader_address"`
 	MarketID        string          `json:"marketID" db:"market_id"`
 	Side            string          `json:"side" db:"side"`
@@ -44,6 +48,7 @@ type Order struct {
 	MakerRebateRate decimal.Decimal `json:"makerRebateRate" db:"maker_rebate_rate"`
 	GasFeeAmount    decimal.Decimal `json:"gasFeeAmount" db:"gas_fee_amount"`
 	JSON            string          `json:"json" db:"json"`
+	CancelReason    string          `json:"cancelReason" db:"cancel_reason"`
 	CreatedAt       time.Time       `json:"createdAt" db:"created_at"`
 	UpdatedAt       time.Time       `json:"updatedAt" db:"updated_at"`
 }
@@ -60,6 +65,20 @@ func (o *Order) AutoSetStatusByAmounts() {
 	}
 }
 
+// Reasons recorded in Order.CancelReason when an order is canceled by the relayer instead of its trader.
+const (
+	OrderCancelReasonMarketClosed = "market_closed"
+
+	// the settlement of the order reverted in the simulation before sending
+	OrderCancelReasonSimulationFailed = "simulation_failed"
+
+	// the trader canceled the order on chain
+	OrderCancelReasonCanceledOnChain = "canceled_on_chain"
+
+	// the balance or allowance of the trader does not cover the order any more
+	OrderCancelReasonUnfunded = "unfunded"
+)
+
 type OrderJSON struct {
 	Trader                  string          `json:"trader"`
 	Relayer                 string          `json:"relayer"`
@@ -102,6 +121,12 @@ func (orderDaoPG) FindByAccount(trader, marketID, status string, offset, limit i
 	return
 }
 
+// FindPendingByTrader returns the pending orders of a trader in all markets, the newest first.
+func (orderDaoPG) FindPendingByTrader(trader string) (orders []*Order) {
+	DB.Where("status = 'pending' and trader_address = ?", trader).Order("created_at desc").Find(&orders)
+	return
+}
+
 func (orderDaoPG) FindByID(id string) *Order {
 	var order Order
 	DB.Where("id = ?", id).First(&order)
@@ -111,6 +136,21 @@ func (orderDaoPG) FindByID(id string) *Order {
 	return &order
 }
 
+func (orderDaoPG) FindByClientOrderID(trader, clientOrderID string) *Order {
+	var order Order
+	DB.Where("trader_address = ? and client_order_id = ?", trader, clientOrderID).First(&order)
+	if order.ID == "" {
+		return nil
+	}
+	return &order
+}
+
+// FindOrdersAfterID returns orders ordered by id, it is used to walk through the whole table in batches.
+func (orderDaoPG) FindOrdersAfterID(id string, limit int) (orders []*Order) {
+	DB.Where("id > ?", id).Order("id asc").Limit(limit).Find(&orders)
+	return
+}
+
 func (orderDaoPG) InsertOrder(order *Order) error {
 	return DB.Create(order).Error
 }
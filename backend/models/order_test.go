@@ -117,6 +117,44 @@ func Test_PG_InsertAndFindOneAndUpdateOrders(t *testing.T) {
 	assert.EqualValues(t, dbOrder.PendingAmount.String(), dbOrder2.PendingAmount.String())
 }
 
+func Test_PG_FindOrderByClientOrderID(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	order := NewOrder(TestUser1, "WETH-DAI", "buy", false)
+	order.ClientOrderID = "my-order-1"
+	assert.Nil(t, OrderDaoPG.InsertOrder(order))
+
+	dbOrder := OrderDaoPG.FindByClientOrderID(TestUser1, "my-order-1")
+	assert.EqualValues(t, order.ID, dbOrder.ID)
+	assert.Nil(t, OrderDaoPG.FindByClientOrderID(TestUser2, "my-order-1"))
+
+	// client order id is unique per address
+	duplicated := NewOrder(TestUser1, "WETH-DAI", "buy", false)
+	duplicated.ClientOrderID = "my-order-1"
+	assert.NotNil(t, OrderDaoPG.InsertOrder(duplicated))
+
+	other := NewOrder(TestUser2, "WETH-DAI", "buy", false)
+	other.ClientOrderID = "my-order-1"
+	assert.Nil(t, OrderDaoPG.InsertOrder(other))
+}
+
+func Test_PG_FindOrdersAfterID(t *testing.T) {
+	setEnvs()
+	InitTestDBPG()
+
+	for i := 0; i < 3; i++ {
+		assert.Nil(t, OrderDaoPG.InsertOrder(NewOrder(TestUser1, "WETH-DAI", "buy", false)))
+	}
+
+	batch1 := OrderDaoPG.FindOrdersAfterID("", 2)
+	assert.EqualValues(t, 2, len(batch1))
+
+	batch2 := OrderDaoPG.FindOrdersAfterID(batch1[1].ID, 2)
+	assert.EqualValues(t, 1, len(batch2))
+	assert.True(t, batch2[0].ID > batch1[1].ID)
+}
+
 func Test_PG_Order_GetOrderJson(t *testing.T) {
 	json := OrderJSON{
 		Trader:                  TestUser1,
@@ -0,0 +1,165 @@
+package models
+
+import (
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// The relayer pays the gas of every settlement it sends, and collects the trade fees and gas fees of the trades.
+// Both are recorded in the quote token of the market when the settlement is mined, so they can be compared per market and day.
+type IRelayerPnLDao interface {
+	// SaveSettlementCost saves the cost of a launch log, it replaces the cost of a launch log mined again after a reorg.
+	SaveSettlementCost(cost *SettlementCost) error
+	SaveTradeRevenues(revenues []*TradeRevenue) error
+
+	// DeleteSettlement takes back the cost and the revenues of a launch log whose block is removed.
+	DeleteSettlement(launchLogID, transactionID int64) error
+
+	// FindDailyPnL returns the result of each market and day between from and to, of a market if marketID isn't empty.
+	FindDailyPnL(marketID string, from, to time.Time) []*RelayerPnL
+}
+
+// SettlementCost is the gas the relayer paid for a mined settlement, reverted ones included.
+type SettlementCost struct {
+	LaunchLogID     int64  `json:"launchLogID"     db:"launch_log_id" gorm:"primary_key"`
+	TransactionID   int64  `json:"transactionID"   db:"transaction_id"`
+	MarketID        string `json:"marketID"        db:"market_id"`
+	TransactionHash string `json:"transactionHash" db:"transaction_hash"`
+	Status          string `json:"status"          db:"status"`
+
+	GasUsed           int64           `json:"gasUsed"           db:"gas_used"`
+	EffectiveGasPrice decimal.Decimal `json:"effectiveGasPrice" db:"effective_gas_price"`
+
+	// the price of ETH in the quote token when the settlement was mined, and the gas cost in quote token
+	EthPrice decimal.Decimal `json:"ethPrice" db:"eth_price"`
+	GasCost  decimal.Decimal `json:"gasCost"  db:"gas_cost"`
+
+	MinedAt   time.Time `json:"minedAt"   db:"mined_at"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+func (SettlementCost) TableName() string {
+	return "settlement_costs"
+}
+
+// TradeRevenue is what the relayer collected for a successful trade, in quote token.
+type TradeRevenue struct {
+	TradeID       int64  `json:"tradeID"       db:"trade_id" gorm:"primary_key"`
+	TransactionID int64  `json:"transactionID" db:"transaction_id"`
+	MarketID      string `json:"marketID"      db:"market_id"`
+
+	MakerFee    decimal.Decimal `json:"makerFee"    db:"maker_fee"`
+	TakerFee    decimal.Decimal `json:"takerFee"    db:"taker_fee"`
+	MakerRebate decimal.Decimal `json:"makerRebate" db:"maker_rebate"`
+	GasFee      decimal.Decimal `json:"gasFee"      db:"gas_fee"`
+
+	ExecutedAt time.Time `json:"executedAt" db:"executed_at"`
+	CreatedAt  time.Time `json:"createdAt"  db:"created_at"`
+}
+
+func (TradeRevenue) TableName() string {
+	return "trade_revenues"
+}
+
+// RelayerPnL is the result of the relayer in a market on a day, in the quote token of the market.
+type RelayerPnL struct {
+	Day         time.Time `json:"day"`
+	MarketID    string    `json:"marketID"`
+	Trades      int       `json:"trades"`
+	Settlements int       `json:"settlements"`
+
+	TradeFees    decimal.Decimal `json:"tradeFees"`
+	MakerRebates decimal.Decimal `json:"makerRebates"`
+	GasFees      decimal.Decimal `json:"gasFees"`
+	GasCost      decimal.Decimal `json:"gasCost"`
+
+	// the trade fees and gas fees collected, less the rebates and the gas cost
+	Net decimal.Decimal `json:"net"`
+}
+
+var RelayerPnLDao IRelayerPnLDao
+var RelayerPnLDaoPG IRelayerPnLDao
+
+func init() {
+	RelayerPnLDao = &relayerPnLDaoPG{}
+	RelayerPnLDaoPG = RelayerPnLDao
+}
+
+type relayerPnLDaoPG struct {
+}
+
+func (relayerPnLDaoPG) SaveSettlementCost(cost *SettlementCost) error {
+	cost.CreatedAt = time.Now().UTC()
+	return DB.Save(cost).Error
+}
+
+func (relayerPnLDaoPG) SaveTradeRevenues(revenues []*TradeRevenue) error {
+	tx := DB.Begin()
+
+	for _, revenue := range revenues {
+		revenue.CreatedAt = time.Now().UTC()
+
+		if err := tx.Save(revenue).Error; err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit().Error
+}
+
+func (relayerPnLDaoPG) DeleteSettlement(launchLogID, transactionID int64) error {
+	tx := DB.Begin()
+
+	if err := tx.Where("launch_log_id = ?", launchLogID).Delete(&SettlementCost{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	if err := tx.Where("transaction_id = ?", transactionID).Delete(&TradeRevenue{}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+func (relayerPnLDaoPG) FindDailyPnL(marketID string, from, to time.Time) []*RelayerPnL {
+	rows, err := DB.Raw(`with revenues as (
+			select date_trunc('day', executed_at) as day, market_id, count(*) as trades,
+				sum(maker_fee + taker_fee) as trade_fees, sum(maker_rebate) as maker_rebates, sum(gas_fee) as gas_fees
+			from trade_revenues where executed_at >= ? and executed_at < ? and (? = '' or market_id = ?)
+			group by 1, 2
+		), costs as (
+			select date_trunc('day', mined_at) as day, market_id, count(*) as settlements, sum(gas_cost) as gas_cost
+			from settlement_costs where mined_at >= ? and mined_at < ? and (? = '' or market_id = ?)
+			group by 1, 2
+		)
+		select coalesce(r.day, c.day), coalesce(r.market_id, c.market_id), coalesce(r.trades, 0), coalesce(c.settlements, 0),
+			coalesce(r.trade_fees, 0), coalesce(r.maker_rebates, 0), coalesce(r.gas_fees, 0), coalesce(c.gas_cost, 0)
+		from revenues r full outer join costs c on r.day = c.day and r.market_id = c.market_id
+		order by 1, 2`,
+		from, to, marketID, marketID, from, to, marketID, marketID).Rows()
+	if err != nil {
+		utils.Errorf("find daily pnl error: %v", err)
+		return nil
+	}
+
+	defer rows.Close()
+
+	results := []*RelayerPnL{}
+
+	for rows.Next() {
+		var pnl RelayerPnL
+		if err := rows.Scan(&pnl.Day, &pnl.MarketID, &pnl.Trades, &pnl.Settlements, &pnl.TradeFees, &pnl.MakerRebates, &pnl.GasFees, &pnl.GasCost); err != nil {
+			utils.Errorf("scan daily pnl error: %v", err)
+			return nil
+		}
+
+		pnl.Net = pnl.TradeFees.Sub(pnl.MakerRebates).Add(pnl.GasFees).Sub(pnl.GasCost)
+		results = append(results, &pnl)
+	}
+
+	return results
+}
@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"math/rand"
 	"os"
+	"path/filepath"
+	"sort"
 	"time"
 )
 
@@ -22,11 +24,24 @@ func (m *MMarketDao) UpdateMarket(market *Market) error {
 	panic("implement me")
 }
 
+func (m *MMarketDao) UpdateApprovingMarket(market *Market) (bool, error) {
+	args := m.Called(market)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MMarketDao) UpdateGasUsedEstimation(marketID string, gasUsed int64) error {
+	panic("implement me")
+}
+
 func (m *MMarketDao) FindPublishedMarkets() []*Market {
 	args := m.Called()
 	return args.Get(0).([]*Market)
 }
 
+func (m *MMarketDao) FindMarketsByStatus(status string) []*Market {
+	panic("implement me")
+}
+
 func (m *MMarketDao) FindAllMarkets() []*Market {
 	args := m.Called()
 	return args.Get(0).([]*Market)
@@ -81,7 +96,7 @@ func (m *MTradeDao) InsertTrade(trade *Trade) error {
 
 func (m *MTradeDao) UpdateTrade(trade *Trade) error {
 	args := m.Called(trade)
-	return args.Get(0).(error)
+	return args.Error(0)
 }
 
 func (m *MTradeDao) Count() int {
@@ -94,6 +109,21 @@ func (m *MTradeDao) FindTradeByTransactionID(transactionID int64) []*Trade {
 	return args.Get(0).([]*Trade)
 }
 
+func (m *MTradeDao) FindTradesByOrderIDs(orderIDs []string) []*Trade {
+	args := m.Called(orderIDs)
+	return args.Get(0).([]*Trade)
+}
+
+func (m *MTradeDao) FindTradesByTransactionIDs(transactionIDs []int64) []*Trade {
+	args := m.Called(transactionIDs)
+	return args.Get(0).([]*Trade)
+}
+
+func (m *MTradeDao) FindLastTradeBefore(marketID string, at time.Time) *Trade {
+	args := m.Called(marketID, at)
+	return args.Get(0).(*Trade)
+}
+
 type MErc20 struct {
 	mock.Mock
 }
@@ -293,21 +323,23 @@ func InitTestDBPG() {
 
 	Connect(os.Getenv("HSK_DATABASE_URL"))
 	DB.LogMode(true)
-	createSql, err := ioutil.ReadFile("../db/migrations/0001-init.up.sql")
-	cleanSql, err := ioutil.ReadFile("../db/migrations/0001-init.down.sql")
 
-	if err != nil {
-		panic(err)
-	}
+	// the migrations are cleaned in reverse order, and created in order
+	downFiles, _ := filepath.Glob("../db/migrations/*.down.sql")
+	upFiles, _ := filepath.Glob("../db/migrations/*.up.sql")
+	sort.Sort(sort.Reverse(sort.StringSlice(downFiles)))
+	sort.Strings(upFiles)
 
-	err = DB.Exec(string(cleanSql)).Error
-	if err != nil {
-		panic(err)
-	}
+	for _, file := range append(downFiles, upFiles...) {
+		migrationSql, err := ioutil.ReadFile(file)
+		if err != nil {
+			panic(err)
+		}
 
-	err = DB.Exec(string(createSql)).Error
-	if err != nil {
-		panic(err)
+		err = DB.Exec(string(migrationSql)).Error
+		if err != nil {
+			panic(err)
+		}
 	}
 }
 
@@ -6,6 +6,7 @@ type ITokenDao interface {
 	GetAllTokens() []*Token
 	InsertToken(*Token) error
 	FindTokenBySymbol(string) *Token
+	FindTokenByAddress(string) *Token
 }
 
 type Token struct {
@@ -51,6 +52,17 @@ func (tokenDaoPG) FindTokenBySymbol(symbol string) *Token {
 	return &token
 }
 
+func (tokenDaoPG) FindTokenByAddress(address string) *Token {
+	var token Token
+
+	DB.Where("lower(address) = lower(?)", address).Find(&token)
+	if token.Symbol == "" {
+		return nil
+	}
+
+	return &token
+}
+
 func GetBaseTokenSymbol(marketID string) string {
 	splits := strings.Split(marketID, "-")
 
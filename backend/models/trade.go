@@ -3,6 +3,7 @@ package models
 import (
 	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"github.com/shopspring/decimal"
+	"os"
 	"time"
 )
 
@@ -17,6 +18,34 @@ type ITradeDao interface {
 	UpdateTrade(trade *Trade) error
 	Count() int
 	FindTradeByTransactionID(transactionID int64) []*Trade
+	FindTradesByOrderIDs(orderIDs []string) []*Trade
+	FindTradesByTransactionIDs(transactionIDs []int64) []*Trade
+	FindLastTradeBefore(marketID string, at time.Time) *Trade
+}
+
+// A successful settlement is mined first, and its trades are finalized, with the status successful,
+// once it is HSK_CONFIRMATION_DEPTH blocks deep. With no depth, they are finalized as soon as it is mined.
+const TradeStatusMined = "mined"
+
+// TradeStatusesInStats returns the statuses of the trades counted in the trade history, candles and market stats.
+// HSK_STATS_FINALIZED_ONLY=true leaves out the trades which are mined but not finalized yet.
+func TradeStatusesInStats() []string {
+	if os.Getenv("HSK_STATS_FINALIZED_ONLY") == "true" {
+		return []string{common.STATUS_SUCCESSFUL}
+	}
+
+	return []string{TradeStatusMined, common.STATUS_SUCCESSFUL}
+}
+
+// IsTradeStatusInStats tells if the trades of the status are counted in the stats.
+func IsTradeStatusInStats(status string) bool {
+	for _, s := range TradeStatusesInStats() {
+		if status == s {
+			return true
+		}
+	}
+
+	return false
 }
 
 type Trade struct {
@@ -56,7 +85,7 @@ type tradeDaoPG struct {
 func (tradeDaoPG) FindTradesByMarket(marketID string, startTime time.Time, endTime time.Time) []*Trade {
 	var trades []*Trade
 
-	DB.Where("market_id = ? and status = ? and executed_at between ? and ? ", marketID, common.STATUS_SUCCESSFUL, startTime, endTime).Order("executed_at desc").Find(&trades)
+	DB.Where("market_id = ? and status in (?) and executed_at between ? and ? ", marketID, TradeStatusesInStats(), startTime, endTime).Order("executed_at desc").Find(&trades)
 	return trades
 }
 
@@ -64,7 +93,7 @@ func (tradeDaoPG) FindAllTrades(marketID string) (int64, []*Trade) {
 	var trades []*Trade
 	var count int64
 
-	DB.Where("market_id = ? and status = ?", marketID, common.STATUS_SUCCESSFUL).Order("created_at desc").Find(&trades).Count(&count)
+	DB.Where("market_id = ? and status in (?)", marketID, TradeStatusesInStats()).Order("created_at desc").Find(&trades).Count(&count)
 	return count, trades
 }
 
@@ -113,3 +142,29 @@ func (tradeDaoPG) FindTradeByTransactionID(transactionID int64) []*Trade {
 	DB.Where("transaction_id = ? ", transactionID).Order("created_at asc").Find(&trades)
 	return trades
 }
+
+func (tradeDaoPG) FindTradesByOrderIDs(orderIDs []string) []*Trade {
+	var trades []*Trade
+
+	DB.Where("maker_order_id in (?) or taker_order_id in (?)", orderIDs, orderIDs).Order("id asc").Find(&trades)
+	return trades
+}
+
+func (tradeDaoPG) FindTradesByTransactionIDs(transactionIDs []int64) []*Trade {
+	var trades []*Trade
+
+	DB.Where("transaction_id in (?)", transactionIDs).Order("id asc").Find(&trades)
+	return trades
+}
+
+// FindLastTradeBefore returns the last trade of the market counted in the stats which was executed before the time.
+func (tradeDaoPG) FindLastTradeBefore(marketID string, at time.Time) *Trade {
+	var trade Trade
+
+	DB.Where("market_id = ? and status in (?) and executed_at <= ?", marketID, TradeStatusesInStats(), at).Order("executed_at desc").Limit(1).Find(&trade)
+	if trade.Status == "" {
+		return nil
+	}
+
+	return &trade
+}
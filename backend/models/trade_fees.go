@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/shopspring/decimal"
+)
+
+// TradeFees is what the relayer collects for a trade, in quote token units, as the exchange contract computes it.
+// The fee rates are those of the orders, the discount of traders holding HOT is applied on chain and isn't known here.
+type TradeFees struct {
+	QuoteAmount decimal.Decimal
+
+	MakerFee decimal.Decimal
+	TakerFee decimal.Decimal
+
+	// paid by the relayer to the maker out of the taker fee, a maker with a rebate pays no fee
+	MakerRebate decimal.Decimal
+
+	// an order pays its gas fee with its first trade
+	MakerGasFee decimal.Decimal
+	TakerGasFee decimal.Decimal
+}
+
+// Revenue is what the relayer keeps of the fees.
+func (f *TradeFees) Revenue() decimal.Decimal {
+	return f.MakerFee.Add(f.TakerFee).Sub(f.MakerRebate).Add(f.MakerGasFee).Add(f.TakerGasFee)
+}
+
+// CalculateTradeFees returns the fees of the trades of a settlement, in the order of the trades.
+// gasFeePaid holds the orders which have paid their gas fee, it is updated with the orders of the trades.
+func CalculateTradeFees(trades []*Trade, orders map[string]*Order, market *Market, gasFeePaid map[string]bool) []*TradeFees {
+	quoteDecimals := int32(market.QuoteTokenDecimals)
+	fees := make([]*TradeFees, 0, len(trades))
+
+	for _, trade := range trades {
+		maker, taker := orders[trade.MakerOrderID], orders[trade.TakerOrderID]
+
+		fee := &TradeFees{QuoteAmount: trade.Amount.Mul(trade.Price).Truncate(quoteDecimals)}
+
+		fee.MakerFee = fee.QuoteAmount.Mul(maker.MakerFeeRate).Truncate(quoteDecimals)
+		fee.TakerFee = fee.QuoteAmount.Mul(taker.TakerFeeRate).Truncate(quoteDecimals)
+
+		fee.MakerRebate = fee.TakerFee.Mul(maker.MakerRebateRate).Truncate(quoteDecimals)
+		if fee.MakerRebate.IsPositive() {
+			fee.MakerFee = decimal.Zero
+		}
+
+		if !gasFeePaid[maker.ID] {
+			fee.MakerGasFee = maker.GasFeeAmount
+			gasFeePaid[maker.ID] = true
+		}
+
+		if !gasFeePaid[taker.ID] {
+			fee.TakerGasFee = taker.GasFeeAmount
+			gasFeePaid[taker.ID] = true
+		}
+
+		fees = append(fees, fee)
+	}
+
+	return fees
+}
+
+// Settlement is a transaction of trades with their orders and market.
+type Settlement struct {
+	Trades []*Trade
+	Orders map[string]*Order
+	Market *Market
+}
+
+// FindSettlement loads the trades of the transaction with their orders and market.
+func FindSettlement(transactionID int64) (*Settlement, error) {
+	trades := TradeDao.FindTradeByTransactionID(transactionID)
+	if len(trades) == 0 {
+		return nil, fmt.Errorf("transaction %d has no trades", transactionID)
+	}
+
+	market := MarketDao.FindMarketByID(trades[0].MarketID)
+	if market == nil {
+		return nil, fmt.Errorf("cannot find market by ID %s", trades[0].MarketID)
+	}
+
+	settlement := &Settlement{Trades: trades, Orders: make(map[string]*Order), Market: market}
+
+	for _, trade := range trades {
+		for _, id := range []string{trade.MakerOrderID, trade.TakerOrderID} {
+			if settlement.Orders[id] != nil {
+				continue
+			}
+
+			order := OrderDao.FindByID(id)
+			if order == nil {
+				return nil, fmt.Errorf("cannot find order by ID %s", id)
+			}
+
+			settlement.Orders[id] = order
+		}
+	}
+
+	return settlement, nil
+}
+
+// Fees returns the fees of the trades of the settlement, the orders which have paid their gas fee
+// in a trade before the settlement which didn't fail pay none.
+func (s *Settlement) Fees() []*TradeFees {
+	firstID := s.Trades[0].ID
+	for _, trade := range s.Trades {
+		if trade.ID < firstID {
+			firstID = trade.ID
+		}
+	}
+
+	orderIDs := make([]string, 0, len(s.Orders))
+	for id := range s.Orders {
+		orderIDs = append(orderIDs, id)
+	}
+
+	gasFeePaid := make(map[string]bool)
+
+	for _, trade := range TradeDao.FindTradesByOrderIDs(orderIDs) {
+		if trade.ID >= firstID || trade.Status == common.STATUS_FAILED {
+			continue
+		}
+
+		gasFeePaid[trade.MakerOrderID] = true
+		gasFeePaid[trade.TakerOrderID] = true
+	}
+
+	return CalculateTradeFees(s.Trades, s.Orders, s.Market, gasFeePaid)
+}
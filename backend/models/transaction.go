@@ -2,6 +2,7 @@ package models
 
 import (
 	"database/sql"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
 	"time"
 )
 
@@ -12,6 +13,9 @@ type ITransactionDao interface {
 	UpdateTransactionStatus(status, hash string) error
 	Count() int
 	FindTransactionByID(id int64) *Transaction
+	CountPendingByMarket(marketID string) int
+	FindTransactionsAfterID(id int64, limit int) []*Transaction
+	FindTransactionsByIDs(ids []int64) []*Transaction
 }
 
 type Transaction struct {
@@ -77,3 +81,22 @@ func (transactionDaoPG) FindTransactionByID(id int64) *Transaction {
 
 	return &transaction
 }
+
+func (transactionDaoPG) CountPendingByMarket(marketID string) int {
+	var count int
+	DB.Model(&Transaction{}).Where("market_id = ? and status = ?", marketID, common.STATUS_PENDING).Count(&count)
+	return count
+}
+
+// FindTransactionsAfterID returns transactions ordered by id, it is used to walk through the whole table in batches.
+func (transactionDaoPG) FindTransactionsAfterID(id int64, limit int) []*Transaction {
+	var transactions []*Transaction
+	DB.Where("id > ?", id).Order("id asc").Limit(limit).Find(&transactions)
+	return transactions
+}
+
+func (transactionDaoPG) FindTransactionsByIDs(ids []int64) []*Transaction {
+	var transactions []*Transaction
+	DB.Where("id in (?)", ids).Find(&transactions)
+	return transactions
+}
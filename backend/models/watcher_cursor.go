@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+type IWatcherCursorDao interface {
+	FindBlockNumber(name string) (uint64, bool)
+	SaveBlockNumber(name string, blockNumber uint64) error
+}
+
+// WatcherCursorName is the cursor of the watcher which follows the chain head.
+const WatcherCursorName = "watcher"
+
+// WatcherCursor is the last block a watcher has processed, the watcher goes on from there after a restart.
+type WatcherCursor struct {
+	Name        string    `json:"name"        db:"name" gorm:"primary_key"`
+	BlockNumber int64     `json:"blockNumber" db:"block_number"`
+	UpdatedAt   time.Time `json:"updatedAt"   db:"updated_at"`
+}
+
+func (WatcherCursor) TableName() string {
+	return "watcher_cursors"
+}
+
+var WatcherCursorDao IWatcherCursorDao
+var WatcherCursorDaoPG IWatcherCursorDao
+
+func init() {
+	WatcherCursorDao = &watcherCursorDaoPG{}
+	WatcherCursorDaoPG = WatcherCursorDao
+}
+
+type watcherCursorDaoPG struct {
+}
+
+// FindBlockNumber returns the block number of the cursor, false if the watcher has never saved it.
+func (watcherCursorDaoPG) FindBlockNumber(name string) (uint64, bool) {
+	var cursor WatcherCursor
+	DB.Where("name = ?", name).First(&cursor)
+	if cursor.Name == "" {
+		return 0, false
+	}
+
+	return uint64(cursor.BlockNumber), true
+}
+
+func (watcherCursorDaoPG) SaveBlockNumber(name string, blockNumber uint64) error {
+	return DB.Exec(`insert into watcher_cursors (name, block_number, updated_at) values (?, ?, ?)
+		on conflict (name) do update set block_number = excluded.block_number, updated_at = excluded.updated_at`,
+		name, blockNumber, time.Now().UTC()).Error
+}
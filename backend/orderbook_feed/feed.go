@@ -0,0 +1,133 @@
+package orderbook_feed
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/common"
+	"github.com/go-redis/redis"
+	"strconv"
+)
+
+// Delta is an incremental change of a market order book.
+// Its sequence is the sequence of the order book snapshot right after the change is applied.
+type Delta = common.WebsocketMarketOrderChangePayload
+
+// IOrderbookFeed keeps the latest order book deltas of each market,
+// so that a client who missed some websocket messages can catch up without fetching a new snapshot.
+type IOrderbookFeed interface {
+	// Reset drops all kept deltas of the market. Deltas before the sequence can't be replayed any more.
+	Reset(marketID string, sequence uint64) error
+
+	Append(marketID string, delta *Delta) error
+
+	// Since returns the deltas after the sequence.
+	// ok is false if some of them are not kept, the client should fetch a new snapshot then.
+	Since(marketID string, sequence uint64) (deltas []*Delta, ok bool, err error)
+}
+
+// How many deltas are kept for each market
+const DefaultMaxDeltas = 1000
+
+func getDeltasKey(marketID string) string {
+	return fmt.Sprintf("HYDRO_MARKET_ORDERBOOK_DELTAS:%s", marketID)
+}
+
+func getFloorKey(marketID string) string {
+	return fmt.Sprintf("HYDRO_MARKET_ORDERBOOK_DELTAS_FLOOR:%s", marketID)
+}
+
+type redisOrderbookFeed struct {
+	client    *redis.Client
+	maxDeltas int64
+}
+
+func NewRedisOrderbookFeed(client *redis.Client, maxDeltas int64) IOrderbookFeed {
+	return &redisOrderbookFeed{
+		client:    client,
+		maxDeltas: maxDeltas,
+	}
+}
+
+func (f *redisOrderbookFeed) Reset(marketID string, sequence uint64) error {
+	_, err := f.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.Del(getDeltasKey(marketID))
+		pipe.Set(getFloorKey(marketID), strconv.FormatUint(sequence, 10), 0)
+		return nil
+	})
+
+	return err
+}
+
+func (f *redisOrderbookFeed) Append(marketID string, delta *Delta) error {
+	bts, err := json.Marshal(delta)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.client.TxPipelined(func(pipe redis.Pipeliner) error {
+		pipe.RPush(getDeltasKey(marketID), bts)
+		pipe.LTrim(getDeltasKey(marketID), -f.maxDeltas, -1)
+		return nil
+	})
+
+	return err
+}
+
+func (f *redisOrderbookFeed) Since(marketID string, sequence uint64) ([]*Delta, bool, error) {
+	floorStr, err := f.client.Get(getFloorKey(marketID)).Result()
+	if err == redis.Nil {
+		// nothing is kept for this market yet
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	floor, err := strconv.ParseUint(floorStr, 10, 64)
+	if err != nil {
+		return nil, false, err
+	}
+
+	items, err := f.client.LRange(getDeltasKey(marketID), 0, -1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	deltas := make([]*Delta, 0, len(items))
+	for _, item := range items {
+		var delta Delta
+		if err := json.Unmarshal([]byte(item), &delta); err != nil {
+			return nil, false, err
+		}
+		deltas = append(deltas, &delta)
+	}
+
+	res, ok := selectDeltas(floor, deltas, sequence)
+	return res, ok, nil
+}
+
+// selectDeltas picks the deltas after the sequence from the kept ones.
+// floor is the sequence before the first delta which has ever been kept.
+func selectDeltas(floor uint64, deltas []*Delta, sequence uint64) ([]*Delta, bool) {
+	if len(deltas) > 0 && deltas[0].Sequence > floor+1 {
+		// older deltas have been trimmed
+		floor = deltas[0].Sequence - 1
+	}
+
+	head := floor
+	if len(deltas) > 0 {
+		head = deltas[len(deltas)-1].Sequence
+	}
+
+	if sequence < floor || sequence > head {
+		return nil, false
+	}
+
+	res := make([]*Delta, 0, len(deltas))
+	for _, delta := range deltas {
+		if delta.Sequence > sequence {
+			res = append(res, delta)
+		}
+	}
+
+	return res, true
+}
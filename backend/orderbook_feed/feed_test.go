@@ -0,0 +1,61 @@
+package orderbook_feed
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newDeltas(sequences ...uint64) []*Delta {
+	var deltas []*Delta
+	for _, sequence := range sequences {
+		deltas = append(deltas, &Delta{Sequence: sequence, Side: "buy", Price: "1", Amount: "1"})
+	}
+	return deltas
+}
+
+func TestSelectDeltas(t *testing.T) {
+	deltas := newDeltas(11, 12, 13)
+
+	res, ok := selectDeltas(10, deltas, 10)
+	assert.True(t, ok)
+	assert.EqualValues(t, 3, len(res))
+
+	res, ok = selectDeltas(10, deltas, 12)
+	assert.True(t, ok)
+	assert.EqualValues(t, 1, len(res))
+	assert.EqualValues(t, 13, res[0].Sequence)
+
+	// client is up to date
+	res, ok = selectDeltas(10, deltas, 13)
+	assert.True(t, ok)
+	assert.EqualValues(t, 0, len(res))
+
+	// deltas before the floor are not kept
+	_, ok = selectDeltas(10, deltas, 9)
+	assert.False(t, ok)
+
+	// client sequence is ahead of the book, which happens after the book is rebuilt
+	_, ok = selectDeltas(10, deltas, 14)
+	assert.False(t, ok)
+}
+
+func TestSelectDeltasAfterTrim(t *testing.T) {
+	// deltas 11 and 12 are trimmed
+	deltas := newDeltas(13, 14)
+
+	_, ok := selectDeltas(10, deltas, 11)
+	assert.False(t, ok)
+
+	res, ok := selectDeltas(10, deltas, 12)
+	assert.True(t, ok)
+	assert.EqualValues(t, 2, len(res))
+}
+
+func TestSelectDeltasWithoutDeltas(t *testing.T) {
+	res, ok := selectDeltas(10, nil, 10)
+	assert.True(t, ok)
+	assert.EqualValues(t, 0, len(res))
+
+	_, ok = selectDeltas(10, nil, 8)
+	assert.False(t, ok)
+}
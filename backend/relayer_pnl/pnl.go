@@ -0,0 +1,102 @@
+package relayer_pnl
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/metrics"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"time"
+)
+
+// Traders pay a gas fee per order, estimated from the gas a match used so far, and the relayer pays the real gas of every settlement.
+// The watcher records the cost of each mined settlement from its receipt, converted to the quote token of its market
+// at the price of ETH when it was mined, and the fees of its trades if it is successful. The admin api reports them per market and day.
+
+var recordErrorsCounter = metrics.NewCounter(
+	"hydro_relayer_pnl_record_errors_total",
+	"Settlements whose gas cost or trade revenues could not be recorded, by kind.",
+	"kind",
+)
+
+// RecordSettlementCost records the gas paid for a mined trade launch log, reverted or not, from the receipt fields saved on it.
+func RecordSettlementCost(launchLog *models.LaunchLog, transaction *models.Transaction, status string, minedAt time.Time) {
+	if !launchLog.GasUsed.Valid || !launchLog.EffectiveGasPrice.Valid {
+		utils.Errorf("launch log %d has no receipt, its gas cost is not recorded", launchLog.ID)
+		recordErrorsCounter.Inc("cost")
+		return
+	}
+
+	market := models.MarketDao.FindMarketByID(transaction.MarketID)
+	if market == nil {
+		utils.Errorf("cannot find market by ID %s, the gas cost of launch log %d is not recorded", transaction.MarketID, launchLog.ID)
+		recordErrorsCounter.Inc("cost")
+		return
+	}
+
+	ethPrice := EthPrice(market, minedAt)
+
+	cost := &models.SettlementCost{
+		LaunchLogID:       launchLog.ID,
+		TransactionID:     transaction.ID,
+		MarketID:          market.ID,
+		TransactionHash:   launchLog.Hash.String,
+		Status:            status,
+		GasUsed:           launchLog.GasUsed.Int64,
+		EffectiveGasPrice: launchLog.EffectiveGasPrice.Decimal,
+		EthPrice:          ethPrice,
+		GasCost:           gasCost(launchLog.GasUsed.Int64, launchLog.EffectiveGasPrice.Decimal, ethPrice, market.QuoteTokenDecimals),
+		MinedAt:           minedAt.UTC(),
+	}
+
+	if err := models.RelayerPnLDao.SaveSettlementCost(cost); err != nil {
+		utils.Errorf("save gas cost of launch log %d error: %v", launchLog.ID, err)
+		recordErrorsCounter.Inc("cost")
+	}
+}
+
+// gasCost converts the gas paid in wei to quote token units.
+func gasCost(gasUsed int64, effectiveGasPrice, ethPrice decimal.Decimal, quoteDecimals int) decimal.Decimal {
+	return decimal.New(gasUsed, 0).Mul(effectiveGasPrice).Div(decimal.New(1, 18)).Mul(ethPrice).Truncate(int32(quoteDecimals))
+}
+
+// RecordTradeRevenues records the fees collected for the trades of a successful settlement.
+func RecordTradeRevenues(transactionID int64, executedAt time.Time) {
+	settlement, err := models.FindSettlement(transactionID)
+	if err != nil {
+		utils.Errorf("trade revenues of transaction %d are not recorded: %v", transactionID, err)
+		recordErrorsCounter.Inc("revenue")
+		return
+	}
+
+	if err := models.RelayerPnLDao.SaveTradeRevenues(tradeRevenues(settlement.Trades, settlement.Fees(), executedAt)); err != nil {
+		utils.Errorf("save trade revenues of transaction %d error: %v", transactionID, err)
+		recordErrorsCounter.Inc("revenue")
+	}
+}
+
+func tradeRevenues(trades []*models.Trade, fees []*models.TradeFees, executedAt time.Time) []*models.TradeRevenue {
+	revenues := make([]*models.TradeRevenue, 0, len(trades))
+
+	for i, trade := range trades {
+		revenues = append(revenues, &models.TradeRevenue{
+			TradeID:       trade.ID,
+			TransactionID: trade.TransactionID,
+			MarketID:      trade.MarketID,
+			MakerFee:      fees[i].MakerFee,
+			TakerFee:      fees[i].TakerFee,
+			MakerRebate:   fees[i].MakerRebate,
+			GasFee:        fees[i].MakerGasFee.Add(fees[i].TakerGasFee),
+			ExecutedAt:    executedAt.UTC(),
+		})
+	}
+
+	return revenues
+}
+
+// RemoveSettlement takes back the cost and revenues of a launch log whose block is removed, it is recorded again when it is mined again.
+func RemoveSettlement(launchLog *models.LaunchLog) {
+	if err := models.RelayerPnLDao.DeleteSettlement(launchLog.ID, launchLog.ItemID); err != nil {
+		utils.Errorf("delete gas cost and trade revenues of launch log %d error: %v", launchLog.ID, err)
+		recordErrorsCounter.Inc("removal")
+	}
+}
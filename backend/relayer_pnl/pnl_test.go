@@ -0,0 +1,103 @@
+package relayer_pnl
+
+import (
+	"bytes"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGasCost(t *testing.T) {
+	// 200000 gas at 10 GWei is 0.002 ETH, which is 0.3 DAI at 150 DAI
+	assert.EqualValues(t, "0.3", gasCost(200000, decimal.New(10, 9), decimal.New(150, 0), 18).String())
+
+	// truncated to the decimals of the quote token
+	assert.EqualValues(t, "0.000001", gasCost(1, decimal.New(10, 9), decimal.New(150, 0), 6).String())
+}
+
+func TestTradeRevenues(t *testing.T) {
+	orders := map[string]*models.Order{
+		"maker": {ID: "maker", MakerFeeRate: decimal.RequireFromString("0.001"), GasFeeAmount: decimal.RequireFromString("0.5")},
+		"taker": {ID: "taker", TakerFeeRate: decimal.RequireFromString("0.003"), GasFeeAmount: decimal.RequireFromString("0.5")},
+	}
+
+	trades := []*models.Trade{
+		{ID: 1, TransactionID: 7, MarketID: "HOT-DAI", MakerOrderID: "maker", TakerOrderID: "taker", Amount: decimal.New(40, 0), Price: decimal.New(2, 0)},
+		{ID: 2, TransactionID: 7, MarketID: "HOT-DAI", MakerOrderID: "maker", TakerOrderID: "taker", Amount: decimal.New(60, 0), Price: decimal.New(2, 0)},
+	}
+
+	fees := models.CalculateTradeFees(trades, orders, &models.Market{QuoteTokenDecimals: 18}, map[string]bool{"maker": true})
+	revenues := tradeRevenues(trades, fees, time.Unix(1548892800, 0))
+
+	assert.Len(t, revenues, 2)
+
+	// the maker has paid its gas fee already, the taker pays it with its first trade
+	assert.EqualValues(t, "0.08", revenues[0].MakerFee.String())
+	assert.EqualValues(t, "0.24", revenues[0].TakerFee.String())
+	assert.EqualValues(t, "0.5", revenues[0].GasFee.String())
+	assert.True(t, revenues[1].GasFee.IsZero())
+	assert.EqualValues(t, "0.82", fees[0].Revenue().String())
+
+	assert.EqualValues(t, 7, revenues[1].TransactionID)
+	assert.EqualValues(t, "2019-01-31", revenues[1].ExecutedAt.Format("2006-01-02"))
+}
+
+func TestEthPrice(t *testing.T) {
+	marketDao := &models.MMarketDao{}
+	tradeDao := &models.MTradeDao{}
+	models.MarketDao = marketDao
+	models.TradeDao = tradeDao
+
+	wethDai := &models.Market{ID: "WETH-DAI", BaseTokenSymbol: "WETH", BaseTokenAddress: "0xweth", QuoteTokenSymbol: "DAI", QuoteTokenAddress: "0xdai"}
+	hotDai := &models.Market{ID: "HOT-DAI", BaseTokenSymbol: "HOT", BaseTokenAddress: "0xhot", QuoteTokenSymbol: "DAI", QuoteTokenAddress: "0xdai"}
+	hotWeth := &models.Market{ID: "HOT-WETH", BaseTokenSymbol: "HOT", BaseTokenAddress: "0xhot", QuoteTokenSymbol: "WETH", QuoteTokenAddress: "0xweth"}
+	hotUsdc := &models.Market{ID: "HOT-USDC", BaseTokenSymbol: "HOT", BaseTokenAddress: "0xhot", QuoteTokenSymbol: "USDC", QuoteTokenAddress: "0xusdc"}
+
+	at := time.Unix(1548892800, 0)
+
+	marketDao.On("FindAllMarkets").Return([]*models.Market{wethDai, hotDai, hotWeth, hotUsdc})
+	tradeDao.On("FindLastTradeBefore", "WETH-DAI", at).Return(&models.Trade{Price: decimal.New(120, 0)})
+	tradeDao.On("FindLastTradeBefore", mock.Anything, at).Return((*models.Trade)(nil))
+
+	assert.EqualValues(t, "1", EthPrice(hotWeth, at).String())
+	assert.EqualValues(t, "120", EthPrice(hotDai, at).String())
+
+	// without a WETH market of the quote token
+	assert.EqualValues(t, "150", EthPrice(hotUsdc, at).String())
+
+	_ = os.Setenv("HSK_PNL_ETH_PRICE", "110.5")
+	defer os.Unsetenv("HSK_PNL_ETH_PRICE")
+	assert.EqualValues(t, "110.5", EthPrice(hotUsdc, at).String())
+}
+
+func TestMarketTotalsAndCSV(t *testing.T) {
+	day := func(d string) time.Time {
+		parsed, _ := time.Parse("2006-01-02", d)
+		return parsed
+	}
+
+	pnls := []*models.RelayerPnL{
+		{Day: day("2019-01-30"), MarketID: "HOT-WETH", Trades: 2, Settlements: 1, TradeFees: decimal.RequireFromString("0.004"), GasFees: decimal.RequireFromString("0.001"), GasCost: decimal.RequireFromString("0.0008"), Net: decimal.RequireFromString("0.0042")},
+		{Day: day("2019-01-30"), MarketID: "HOT-DAI", Trades: 1, Settlements: 1, TradeFees: decimal.RequireFromString("1"), MakerRebates: decimal.RequireFromString("0.1"), GasFees: decimal.RequireFromString("0.5"), GasCost: decimal.RequireFromString("0.6"), Net: decimal.RequireFromString("0.8")},
+		{Day: day("2019-01-31"), MarketID: "HOT-DAI", Settlements: 1, GasCost: decimal.RequireFromString("0.3"), Net: decimal.RequireFromString("-0.3")},
+	}
+
+	totals := MarketTotals(pnls)
+	assert.Len(t, totals, 2)
+	assert.EqualValues(t, "HOT-DAI", totals[0].MarketID)
+	assert.EqualValues(t, 2, totals[0].Settlements)
+	assert.EqualValues(t, "0.9", totals[0].GasCost.String())
+	assert.EqualValues(t, "0.5", totals[0].Net.String())
+
+	var buf bytes.Buffer
+	assert.Nil(t, WriteCSV(&buf, pnls))
+	assert.EqualValues(t, `day,market_id,trades,settlements,trade_fees,maker_rebates,gas_fees,gas_cost,net
+2019-01-30,HOT-WETH,2,1,0.004,0,0.001,0.0008,0.0042
+2019-01-30,HOT-DAI,1,1,1,0.1,0.5,0.6,0.8
+2019-01-31,HOT-DAI,0,1,0,0,0,0.3,-0.3
+`, buf.String())
+}
@@ -0,0 +1,49 @@
+package relayer_pnl
+
+import (
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"github.com/shopspring/decimal"
+	"os"
+	"strings"
+	"time"
+)
+
+// the price of ETH in the quote token when no market tells it, like the assumption of the gas fee of orders
+const defaultEthPrice = 150
+
+// EthPrice returns the price of ETH in the quote token of the market at the time.
+// It is 1 in WETH markets, otherwise it is the price of the last trade before the time of a WETH market with the quote token,
+// as base or as quote token. Without such a trade it is HSK_PNL_ETH_PRICE, or 150.
+func EthPrice(market *models.Market, at time.Time) decimal.Decimal {
+	if isWeth(market.QuoteTokenSymbol) {
+		return decimal.New(1, 0)
+	}
+
+	for _, m := range models.MarketDao.FindAllMarkets() {
+		if isWeth(m.BaseTokenSymbol) && strings.EqualFold(m.QuoteTokenAddress, market.QuoteTokenAddress) {
+			if trade := models.TradeDao.FindLastTradeBefore(m.ID, at); trade != nil && trade.Price.IsPositive() {
+				return trade.Price
+			}
+		}
+
+		if isWeth(m.QuoteTokenSymbol) && strings.EqualFold(m.BaseTokenAddress, market.QuoteTokenAddress) {
+			if trade := models.TradeDao.FindLastTradeBefore(m.ID, at); trade != nil && trade.Price.IsPositive() {
+				return decimal.New(1, 0).DivRound(trade.Price, 18)
+			}
+		}
+	}
+
+	return fallbackEthPrice()
+}
+
+func fallbackEthPrice() decimal.Decimal {
+	if price, err := decimal.NewFromString(os.Getenv("HSK_PNL_ETH_PRICE")); err == nil && price.IsPositive() {
+		return price
+	}
+
+	return decimal.New(defaultEthPrice, 0)
+}
+
+func isWeth(symbol string) bool {
+	return strings.EqualFold(symbol, "WETH")
+}
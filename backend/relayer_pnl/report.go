@@ -0,0 +1,72 @@
+package relayer_pnl
+
+import (
+	"encoding/csv"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/models"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// MarketTotals sums the daily results of each market, in the order of the markets.
+func MarketTotals(pnls []*models.RelayerPnL) []*models.RelayerPnL {
+	totals := make(map[string]*models.RelayerPnL)
+
+	for _, pnl := range pnls {
+		total := totals[pnl.MarketID]
+		if total == nil {
+			total = &models.RelayerPnL{MarketID: pnl.MarketID}
+			totals[pnl.MarketID] = total
+		}
+
+		total.Trades += pnl.Trades
+		total.Settlements += pnl.Settlements
+		total.TradeFees = total.TradeFees.Add(pnl.TradeFees)
+		total.MakerRebates = total.MakerRebates.Add(pnl.MakerRebates)
+		total.GasFees = total.GasFees.Add(pnl.GasFees)
+		total.GasCost = total.GasCost.Add(pnl.GasCost)
+		total.Net = total.Net.Add(pnl.Net)
+	}
+
+	results := make([]*models.RelayerPnL, 0, len(totals))
+	for _, total := range totals {
+		results = append(results, total)
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].MarketID < results[j].MarketID
+	})
+
+	return results
+}
+
+var csvHeader = []string{"day", "market_id", "trades", "settlements", "trade_fees", "maker_rebates", "gas_fees", "gas_cost", "net"}
+
+// WriteCSV writes the daily results with a header, the amounts are in the quote token of each market.
+func WriteCSV(w io.Writer, pnls []*models.RelayerPnL) error {
+	writer := csv.NewWriter(w)
+
+	if err := writer.Write(csvHeader); err != nil {
+		return err
+	}
+
+	for _, pnl := range pnls {
+		err := writer.Write([]string{
+			pnl.Day.Format("2006-01-02"),
+			pnl.MarketID,
+			strconv.Itoa(pnl.Trades),
+			strconv.Itoa(pnl.Settlements),
+			pnl.TradeFees.String(),
+			pnl.MakerRebates.String(),
+			pnl.GasFees.String(),
+			pnl.GasCost.String(),
+			pnl.Net.String(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,97 @@
+package signer
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Where the keys of a service come from, set by <prefix>_SIGNER
+const (
+	TypePrivateKey = "pk"
+	TypeKeystore   = "keystore"
+	TypeRemote     = "remote"
+)
+
+// FromEnv builds the signers configured by the env vars with the prefix, e.g. HSK_RELAYER.
+//
+//	<prefix>_SIGNER                    pk (default), keystore or remote
+//	<prefix>_KEYSTORE_FILES            comma separated paths of encrypted JSON keystore files
+//	<prefix>_KEYSTORE_PASSPHRASE_FILE  file holding the passphrase of the keystore files
+//	<prefix>_REMOTE_SIGNER_URL         url of the remote signing service
+//	<prefix>_REMOTE_SIGNER_ADDRESSES   comma separated accounts to use, all accounts of the service if empty
+//	<prefix>_REMOTE_SIGNER_TOKEN_FILE  file holding the token of the remote signing service
+//
+// The pk signer uses the plain hex private keys passed in, it is for development only.
+func FromEnv(prefix string, privateKeys ...string) ([]Signer, error) {
+	var signers []Signer
+
+	switch signerType := os.Getenv(prefix + "_SIGNER"); signerType {
+	case "", TypePrivateKey:
+		for _, privateKey := range privateKeys {
+			if privateKey == "" {
+				continue
+			}
+
+			signer, err := NewPrivateKeySignerByHex(privateKey)
+			if err != nil {
+				return nil, err
+			}
+
+			signers = append(signers, signer)
+		}
+	case TypeKeystore:
+		passphraseFile := os.Getenv(prefix + "_KEYSTORE_PASSPHRASE_FILE")
+
+		for _, keystoreFile := range splitList(os.Getenv(prefix + "_KEYSTORE_FILES")) {
+			signer, err := NewKeystoreSigner(keystoreFile, passphraseFile)
+			if err != nil {
+				return nil, err
+			}
+
+			signers = append(signers, signer)
+		}
+	case TypeRemote:
+		url := os.Getenv(prefix + "_REMOTE_SIGNER_URL")
+
+		var token string
+		if tokenFile := os.Getenv(prefix + "_REMOTE_SIGNER_TOKEN_FILE"); tokenFile != "" {
+			var err error
+			if token, err = ReadSecretFile(tokenFile); err != nil {
+				return nil, err
+			}
+		}
+
+		addresses := splitList(os.Getenv(prefix + "_REMOTE_SIGNER_ADDRESSES"))
+		if len(addresses) == 0 {
+			var err error
+			if addresses, err = RemoteAddresses(url, token); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, address := range addresses {
+			signers = append(signers, NewRemoteSigner(url, address, token))
+		}
+	default:
+		return nil, fmt.Errorf("unknown signer %s", signerType)
+	}
+
+	if len(signers) == 0 {
+		return nil, fmt.Errorf("no signer is configured by %s_SIGNER", prefix)
+	}
+
+	return signers, nil
+}
+
+func splitList(list string) []string {
+	var items []string
+
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
@@ -0,0 +1,242 @@
+package signer
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/satori/go.uuid"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
+	"io/ioutil"
+	"strings"
+)
+
+// Encrypted JSON keystore files of the Web3 Secret Storage Definition (version 3),
+// the format written by geth, parity and most wallets.
+// https://github.com/ethereum/wiki/wiki/Web3-Secret-Storage-Definition
+
+// scrypt parameters of the keystores written by EncryptKey
+const (
+	StandardScryptN = 1 << 18
+	StandardScryptP = 1
+	LightScryptN    = 1 << 12
+	LightScryptP    = 6
+
+	scryptR     = 8
+	scryptDKLen = 32
+)
+
+type keystoreJSON struct {
+	Address string         `json:"address"`
+	Crypto  keystoreCrypto `json:"crypto"`
+	ID      string         `json:"id"`
+	Version int            `json:"version"`
+}
+
+type keystoreCrypto struct {
+	Cipher       string                 `json:"cipher"`
+	CipherText   string                 `json:"ciphertext"`
+	CipherParams keystoreCipherParams   `json:"cipherparams"`
+	KDF          string                 `json:"kdf"`
+	KDFParams    map[string]interface{} `json:"kdfparams"`
+	MAC          string                 `json:"mac"`
+}
+
+type keystoreCipherParams struct {
+	IV string `json:"iv"`
+}
+
+// NewKeystoreSigner decrypts a keystore file with the passphrase in passphraseFile.
+// The key is only kept in memory.
+func NewKeystoreSigner(keystoreFile, passphraseFile string) (Signer, error) {
+	keyJSON, err := ioutil.ReadFile(keystoreFile)
+	if err != nil {
+		return nil, err
+	}
+
+	passphrase, err := ReadSecretFile(passphraseFile)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKeyBytes, err := DecryptKey(keyJSON, passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("decrypt keystore %s error: %v", keystoreFile, err)
+	}
+
+	privateKey, err := crypto.NewPrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPrivateKeySigner(privateKey), nil
+}
+
+// ReadSecretFile returns the content of a file holding a passphrase or token, without the trailing line break.
+func ReadSecretFile(path string) (string, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(string(content), "\r\n"), nil
+}
+
+// DecryptKey returns the private key in a keystore.
+func DecryptKey(keyJSON []byte, passphrase string) ([]byte, error) {
+	var keystore keystoreJSON
+	if err := json.Unmarshal(keyJSON, &keystore); err != nil {
+		return nil, err
+	}
+
+	if keystore.Version != 3 {
+		return nil, fmt.Errorf("keystore version %d is not supported", keystore.Version)
+	}
+
+	if keystore.Crypto.Cipher != "aes-128-ctr" {
+		return nil, fmt.Errorf("cipher %s is not supported", keystore.Crypto.Cipher)
+	}
+
+	cipherText, err := hex.DecodeString(keystore.Crypto.CipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	iv, err := hex.DecodeString(keystore.Crypto.CipherParams.IV)
+	if err != nil {
+		return nil, err
+	}
+
+	mac, err := hex.DecodeString(keystore.Crypto.MAC)
+	if err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := deriveKey(keystore.Crypto, passphrase)
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.Equal(crypto.Keccak256(derivedKey[16:32], cipherText), mac) {
+		return nil, fmt.Errorf("wrong passphrase")
+	}
+
+	privateKey, err := aesCTR(derivedKey[:16], iv, cipherText)
+	if err != nil {
+		return nil, err
+	}
+
+	if keystore.Address != "" {
+		key, err := crypto.NewPrivateKey(privateKey)
+		if err != nil {
+			return nil, err
+		}
+
+		address := strings.TrimPrefix(strings.ToLower(crypto.PubKey2Address(key.PublicKey)), "0x")
+		if address != strings.TrimPrefix(strings.ToLower(keystore.Address), "0x") {
+			return nil, fmt.Errorf("keystore address %s doesn't match the key", keystore.Address)
+		}
+	}
+
+	return privateKey, nil
+}
+
+func deriveKey(c keystoreCrypto, passphrase string) ([]byte, error) {
+	salt, err := hex.DecodeString(kdfString(c.KDFParams, "salt"))
+	if err != nil {
+		return nil, err
+	}
+
+	dkLen := kdfInt(c.KDFParams, "dklen")
+	if dkLen < 32 {
+		return nil, fmt.Errorf("dklen %d is too short", dkLen)
+	}
+
+	switch c.KDF {
+	case "scrypt":
+		n, r, p := kdfInt(c.KDFParams, "n"), kdfInt(c.KDFParams, "r"), kdfInt(c.KDFParams, "p")
+		return scrypt.Key([]byte(passphrase), salt, n, r, p, dkLen)
+	case "pbkdf2":
+		if prf := kdfString(c.KDFParams, "prf"); prf != "hmac-sha256" {
+			return nil, fmt.Errorf("prf %s is not supported", prf)
+		}
+
+		return pbkdf2.Key([]byte(passphrase), salt, kdfInt(c.KDFParams, "c"), dkLen, sha256.New), nil
+	default:
+		return nil, fmt.Errorf("kdf %s is not supported", c.KDF)
+	}
+}
+
+func kdfInt(params map[string]interface{}, name string) int {
+	value, _ := params[name].(float64)
+	return int(value)
+}
+
+func kdfString(params map[string]interface{}, name string) string {
+	value, _ := params[name].(string)
+	return value
+}
+
+func aesCTR(key, iv, input []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	output := make([]byte, len(input))
+	cipher.NewCTR(block, iv).XORKeyStream(output, input)
+	return output, nil
+}
+
+// EncryptKey returns the keystore of a private key encrypted with the passphrase.
+func EncryptKey(privateKeyBytes []byte, passphrase string, scryptN, scryptP int) ([]byte, error) {
+	privateKey, err := crypto.NewPrivateKey(privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, 32)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	derivedKey, err := scrypt.Key([]byte(passphrase), salt, scryptN, scryptR, scryptP, scryptDKLen)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherText, err := aesCTR(derivedKey[:16], iv, privateKeyBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(keystoreJSON{
+		Address: strings.TrimPrefix(strings.ToLower(crypto.PubKey2Address(privateKey.PublicKey)), "0x"),
+		Crypto: keystoreCrypto{
+			Cipher:       "aes-128-ctr",
+			CipherText:   hex.EncodeToString(cipherText),
+			CipherParams: keystoreCipherParams{IV: hex.EncodeToString(iv)},
+			KDF:          "scrypt",
+			KDFParams: map[string]interface{}{
+				"n":     scryptN,
+				"r":     scryptR,
+				"p":     scryptP,
+				"dklen": scryptDKLen,
+				"salt":  hex.EncodeToString(salt),
+			},
+			MAC: hex.EncodeToString(crypto.Keccak256(derivedKey[16:32], cipherText)),
+		},
+		ID:      uuid.NewV4().String(),
+		Version: 3,
+	})
+}
@@ -0,0 +1,150 @@
+package signer
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// A remote signing service keeps the keys, e.g. in an HSM, and signs hashes for the accounts it holds.
+// The protocol is plain JSON over HTTP, NewServer is its reference implementation:
+//
+//   GET  /addresses  -> {"addresses": ["0x..."]}
+//   POST /sign       {"address": "0x...", "hash": "0x..."} -> {"signature": "0x..."}
+//
+// Errors are answered with a non 2xx status and {"error": "..."}.
+// When the service has a token, requests carry it in the "Authorization: Bearer <token>" header.
+
+const remoteSignerTimeout = 10 * time.Second
+
+type signRequest struct {
+	Address string `json:"address"`
+	Hash    string `json:"hash"`
+}
+
+type signResponse struct {
+	Signature string `json:"signature"`
+}
+
+type addressesResponse struct {
+	Addresses []string `json:"addresses"`
+}
+
+type errorResponse struct {
+	Error string `json:"error"`
+}
+
+type remoteSigner struct {
+	url     string
+	address string
+	token   string
+	client  *http.Client
+}
+
+func NewRemoteSigner(url, address, token string) Signer {
+	return &remoteSigner{
+		url:     strings.TrimRight(url, "/"),
+		address: strings.ToLower(address),
+		token:   token,
+		client:  &http.Client{Timeout: remoteSignerTimeout},
+	}
+}
+
+// RemoteAddresses returns the accounts held by the remote signing service.
+func RemoteAddresses(url, token string) ([]string, error) {
+	var res addressesResponse
+	err := remoteCall(&http.Client{Timeout: remoteSignerTimeout}, http.MethodGet, strings.TrimRight(url, "/")+"/addresses", token, nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	addresses := make([]string, 0, len(res.Addresses))
+	for _, address := range res.Addresses {
+		addresses = append(addresses, strings.ToLower(address))
+	}
+
+	return addresses, nil
+}
+
+func (s *remoteSigner) Address() string {
+	return s.address
+}
+
+// SignHash asks the service for the signature, and checks that it is signed by the account.
+func (s *remoteSigner) SignHash(hash []byte) ([]byte, error) {
+	var res signResponse
+	err := remoteCall(s.client, http.MethodPost, s.url+"/sign", s.token, &signRequest{
+		Address: s.address,
+		Hash:    utils.Bytes2HexP(hash),
+	}, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	signature := utils.Hex2Bytes(res.Signature)
+
+	// some services return V as 27 or 28
+	if len(signature) == 65 && signature[64] >= 27 {
+		signature[64] -= 27
+	}
+
+	address, err := recoverAddress(hash, signature)
+	if err != nil {
+		return nil, err
+	}
+
+	if address != s.address {
+		return nil, ConfigError{Err: fmt.Errorf("remote signer signed with %s instead of %s", address, s.address)}
+	}
+
+	return signature, nil
+}
+
+func remoteCall(client *http.Client, method, url, token string, body, result interface{}) error {
+	var reqBody []byte
+	if body != nil {
+		reqBody, _ = json.Marshal(body)
+	}
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return UnavailableError{Err: err}
+	}
+	defer res.Body.Close()
+
+	resBody, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return UnavailableError{Err: err}
+	}
+
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		var errRes errorResponse
+		_ = json.Unmarshal(resBody, &errRes)
+		err := fmt.Errorf("remote signer %s %s: %s %s", method, url, res.Status, errRes.Error)
+
+		// the service is down or overloaded, the request itself is fine
+		if res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests {
+			return UnavailableError{Err: err}
+		}
+
+		// e.g. 401 for a wrong token, or 404 for an account the service doesn't hold
+		return ConfigError{Err: err}
+	}
+
+	return json.Unmarshal(resBody, result)
+}
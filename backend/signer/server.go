@@ -0,0 +1,105 @@
+package signer
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"net/http"
+	"strings"
+)
+
+// server is the reference implementation of the remote signing service.
+// It is a local stand-in for an HSM backed service, so that the remote signer can be run and tested without one.
+type server struct {
+	token   string
+	signers map[string]Signer
+	order   []string
+}
+
+// NewServer returns the handler of a signing service which signs with the signers.
+// Requests must carry the token if it isn't empty.
+func NewServer(token string, signers ...Signer) http.Handler {
+	s := &server{
+		token:   token,
+		signers: make(map[string]Signer),
+	}
+
+	for _, signer := range signers {
+		if _, ok := s.signers[signer.Address()]; ok {
+			continue
+		}
+
+		s.signers[signer.Address()] = signer
+		s.order = append(s.order, signer.Address())
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/addresses", s.addressesHandler)
+	mux.HandleFunc("/sign", s.signHandler)
+
+	return s.authenticate(mux)
+}
+
+func (s *server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+				writeJSON(w, http.StatusUnauthorized, &errorResponse{Error: "unauthorized"})
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *server) addressesHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, &errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	writeJSON(w, http.StatusOK, &addressesResponse{Addresses: s.order})
+}
+
+func (s *server) signHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, &errorResponse{Error: "method not allowed"})
+		return
+	}
+
+	var req signRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Error: err.Error()})
+		return
+	}
+
+	signer := s.signers[strings.ToLower(req.Address)]
+	if signer == nil {
+		writeJSON(w, http.StatusNotFound, &errorResponse{Error: "unknown address " + req.Address})
+		return
+	}
+
+	hash := utils.Hex2Bytes(req.Hash)
+	if len(hash) != 32 {
+		writeJSON(w, http.StatusBadRequest, &errorResponse{Error: "hash is required to be exactly 32 bytes"})
+		return
+	}
+
+	signature, err := signer.SignHash(hash)
+	if err != nil {
+		writeJSON(w, http.StatusInternalServerError, &errorResponse{Error: err.Error()})
+		return
+	}
+
+	utils.Infof("signed hash %s for %s", req.Hash, signer.Address())
+
+	writeJSON(w, http.StatusOK, &signResponse{Signature: utils.Bytes2HexP(signature)})
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
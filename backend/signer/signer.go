@@ -0,0 +1,90 @@
+package signer
+
+import (
+	"crypto/ecdsa"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"strings"
+)
+
+// Signer signs hashes with the key of one account, without exposing the key.
+// Keys can be plain hex strings (for development only), encrypted JSON keystore files, or held by a remote signing service.
+type Signer interface {
+	// Address returns the lower case address of the account
+	Address() string
+
+	// SignHash returns the 65 bytes [R || S || V] signature of a 32 bytes hash, V is 0 or 1
+	SignHash(hash []byte) ([]byte, error)
+}
+
+// UnavailableError is returned when the signer can't be reached, signing can be retried later.
+type UnavailableError struct {
+	Err error
+}
+
+func (e UnavailableError) Error() string {
+	return fmt.Sprintf("signer unavailable: %v", e.Err)
+}
+
+// ConfigError is returned when the signer refuses to sign for the account, e.g. its token is wrong or it doesn't hold the account.
+// Signing fails the same way until the configuration is fixed by hand.
+type ConfigError struct {
+	Err error
+}
+
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("signer misconfigured: %v", e.Err)
+}
+
+type privateKeySigner struct {
+	address    string
+	privateKey *ecdsa.PrivateKey
+}
+
+func NewPrivateKeySigner(privateKey *ecdsa.PrivateKey) Signer {
+	return &privateKeySigner{
+		address:    strings.ToLower(crypto.PubKey2Address(privateKey.PublicKey)),
+		privateKey: privateKey,
+	}
+}
+
+// NewPrivateKeySignerByHex builds a signer from a plain hex private key, it should not be used in production.
+func NewPrivateKeySignerByHex(privateKeyHex string) (Signer, error) {
+	privateKey, err := crypto.NewPrivateKeyByHex(privateKeyHex)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewPrivateKeySigner(privateKey), nil
+}
+
+func (s *privateKeySigner) Address() string {
+	return s.address
+}
+
+func (s *privateKeySigner) SignHash(hash []byte) ([]byte, error) {
+	return crypto.Sign(hash, s.privateKey)
+}
+
+// PersonalSign signs a message the way eth_sign does.
+func PersonalSign(s Signer, message []byte) ([]byte, error) {
+	return s.SignHash(hashPersonalMessage(message))
+}
+
+func hashPersonalMessage(message []byte) []byte {
+	return crypto.Keccak256([]byte(fmt.Sprintf("\x19Ethereum Signed Message:\n%d%s", len(message), message)))
+}
+
+// recoverAddress returns the lower case address which signed the hash.
+func recoverAddress(hash, signature []byte) (string, error) {
+	if len(signature) != 65 || signature[64] > 1 {
+		return "", fmt.Errorf("invalid signature %x", signature)
+	}
+
+	publicKey, err := crypto.SigToPub(hash, signature)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(crypto.PubKey2Address(*publicKey)), nil
+}
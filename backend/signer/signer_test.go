@@ -0,0 +1,147 @@
+package signer
+
+import (
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testPrivateKey = "b7a0c9d2786fc4dd080ea5d619d36771aeb0c8c26c290afd3451b92ba2b7bc2c"
+const testAddress = "0x31ebd457b999bf99759602f5ece5aa5033cb56b3"
+
+var testHash = crypto.Keccak256([]byte("hello"))
+
+func newTestSigner(t *testing.T) Signer {
+	s, err := NewPrivateKeySignerByHex(testPrivateKey)
+	assert.Nil(t, err)
+	return s
+}
+
+func TestPrivateKeySigner(t *testing.T) {
+	s := newTestSigner(t)
+	assert.EqualValues(t, testAddress, s.Address())
+
+	signature, err := s.SignHash(testHash)
+	assert.Nil(t, err)
+
+	address, err := recoverAddress(testHash, signature)
+	assert.Nil(t, err)
+	assert.EqualValues(t, testAddress, address)
+}
+
+func TestPersonalSign(t *testing.T) {
+	s := newTestSigner(t)
+
+	signature, err := PersonalSign(s, []byte("HYDRO-AUTHENTICATION"))
+	assert.Nil(t, err)
+
+	expected, _ := crypto.PersonalSign([]byte("HYDRO-AUTHENTICATION"), testPrivateKey)
+	assert.EqualValues(t, expected, signature)
+}
+
+func TestKeystore(t *testing.T) {
+	dir, _ := ioutil.TempDir("", "keystore")
+	defer os.RemoveAll(dir)
+
+	keyJSON, err := EncryptKey(utils.Hex2Bytes(testPrivateKey), "secret", LightScryptN, LightScryptP)
+	assert.Nil(t, err)
+
+	keystoreFile := filepath.Join(dir, "key.json")
+	passphraseFile := filepath.Join(dir, "passphrase")
+	_ = ioutil.WriteFile(keystoreFile, keyJSON, 0600)
+	_ = ioutil.WriteFile(passphraseFile, []byte("secret\n"), 0600)
+
+	s, err := NewKeystoreSigner(keystoreFile, passphraseFile)
+	assert.Nil(t, err)
+	assert.EqualValues(t, testAddress, s.Address())
+
+	_, err = DecryptKey(keyJSON, "wrong")
+	assert.EqualError(t, err, "wrong passphrase")
+}
+
+// The pbkdf2 test vector of the Web3 Secret Storage Definition
+func TestDecryptKeyPBKDF2(t *testing.T) {
+	keyJSON := `{
+		"crypto": {
+			"cipher": "aes-128-ctr",
+			"cipherparams": {"iv": "6087dab2f9fdbbfaddc31a909735c1e6"},
+			"ciphertext": "5318b4d5bcd28de64ee5559e671353e16f075ecae9f99c7a79a38af5f869aa46",
+			"kdf": "pbkdf2",
+			"kdfparams": {
+				"c": 262144,
+				"dklen": 32,
+				"prf": "hmac-sha256",
+				"salt": "ae3cd4e7013836a3df6bd7241b12db061dbe2c6785853cce422d148a624ce0bd"
+			},
+			"mac": "517ead924a9d0dc3124507e3393d175ce3ff7c1e96529c6c555ce9e51205e9b2"
+		},
+		"id": "3198bc9c-6672-5ab3-d995-4942343ae5b6",
+		"version": 3
+	}`
+
+	privateKey, err := DecryptKey([]byte(keyJSON), "testpassword")
+	assert.Nil(t, err)
+	assert.EqualValues(t, "0x7a28b5ba57c53603b0b07b56bba752f7784bf506fa95edc395f5cf6c7514fe9d", utils.Bytes2HexP(privateKey))
+}
+
+func TestRemoteSigner(t *testing.T) {
+	server := httptest.NewServer(NewServer("token", newTestSigner(t)))
+	defer server.Close()
+
+	addresses, err := RemoteAddresses(server.URL, "token")
+	assert.Nil(t, err)
+	assert.EqualValues(t, []string{testAddress}, addresses)
+
+	s := NewRemoteSigner(server.URL, testAddress, "token")
+	signature, err := s.SignHash(testHash)
+	assert.Nil(t, err)
+
+	expected, _ := newTestSigner(t).SignHash(testHash)
+	assert.EqualValues(t, expected, signature)
+
+	// wrong token
+	_, err = NewRemoteSigner(server.URL, testAddress, "wrong").SignHash(testHash)
+	_, misconfigured := err.(ConfigError)
+	assert.True(t, misconfigured)
+
+	// unknown account
+	_, err = NewRemoteSigner(server.URL, "0x93388b4efe13b9b18ed480783c05462409851547", "token").SignHash(testHash)
+	_, misconfigured = err.(ConfigError)
+	assert.True(t, misconfigured)
+}
+
+func TestRemoteSignerChecksSignature(t *testing.T) {
+	// a service which signs with another key
+	other, _ := NewPrivateKeySignerByHex("95b0a982c0dfc5ab70bf915dcf9f4b790544d25bc5e6cff0f38a59d0bba58651")
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signature, _ := other.SignHash(testHash)
+		writeJSON(w, http.StatusOK, &signResponse{Signature: utils.Bytes2HexP(signature)})
+	}))
+	defer server.Close()
+
+	_, err := NewRemoteSigner(server.URL, testAddress, "").SignHash(testHash)
+	_, misconfigured := err.(ConfigError)
+	assert.True(t, misconfigured)
+}
+
+func TestRemoteSignerUnavailable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusServiceUnavailable, &errorResponse{Error: "hsm is busy"})
+	}))
+	defer server.Close()
+
+	_, err := NewRemoteSigner(server.URL, testAddress, "").SignHash(testHash)
+	_, unavailable := err.(UnavailableError)
+	assert.True(t, unavailable)
+
+	server.Close()
+	_, err = NewRemoteSigner(server.URL, testAddress, "").SignHash(testHash)
+	_, unavailable = err.(UnavailableError)
+	assert.True(t, unavailable)
+}
@@ -0,0 +1,526 @@
+package simchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/ethereum"
+	sdksigner "github.com/HydroProtocol/hydro-sdk-backend/sdk/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/types"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/onrik/ethrpc"
+	"github.com/shopspring/decimal"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Chain is an in-memory Ethereum chain which knows just enough to settle the scaffold's trades:
+// ether, ERC20 tokens which the proxy moves for the exchange, and the matchOrders method of the exchange.
+// It mines a block every block time with the transactions sent to it, and keeps every block,
+// transaction and receipt, so that the watcher can follow it. Blocks are never reorganized.
+//
+// Chain implements sdk.Hydro for the code which takes a chain in-process,
+// and Handler serves it over JSON-RPC for the code which takes an rpc url.
+type Chain struct {
+	*ethereum.EthereumHydroProtocol
+
+	chainID         int64
+	exchangeAddress string
+	proxyAddress    string
+	blockTime       time.Duration
+
+	lock         sync.Mutex
+	state        *state
+	tokens       map[string]*tokenInfo
+	blocks       []*block
+	transactions map[string]*transaction
+	receipts     map[string]*receipt
+
+	// sent transactions which are not mined yet, by sender and nonce
+	pool map[string]map[uint64]*transaction
+}
+
+var _ sdk.Hydro = (*Chain)(nil)
+
+type Config struct {
+	ChainID         int64
+	ExchangeAddress string
+	ProxyAddress    string
+	BlockTime       time.Duration
+	Genesis         *Genesis
+}
+
+const blockGasLimit = 10000000
+
+// the base fee of every block, 1 Gwei
+var baseFee = big.NewInt(1000000000)
+
+type block struct {
+	Number       uint64
+	Hash         string
+	ParentHash   string
+	Timestamp    uint64
+	GasUsed      uint64
+	Transactions []*transaction
+}
+
+type receiptLog struct {
+	Address  string
+	Topics   []string
+	Data     []byte
+	LogIndex int
+}
+
+type receipt struct {
+	Status            bool
+	GasUsed           uint64
+	CumulativeGasUsed uint64
+	EffectiveGasPrice *big.Int
+	Logs              []*receiptLog
+}
+
+func NewChain(config Config) (*Chain, error) {
+	if config.ExchangeAddress == "" || config.ProxyAddress == "" {
+		return nil, errors.New("simulated chain needs the exchange and proxy addresses")
+	}
+
+	if config.BlockTime <= 0 {
+		config.BlockTime = time.Second
+	}
+
+	c := &Chain{
+		EthereumHydroProtocol: &ethereum.EthereumHydroProtocol{},
+
+		chainID:         config.ChainID,
+		exchangeAddress: strings.ToLower(config.ExchangeAddress),
+		proxyAddress:    strings.ToLower(config.ProxyAddress),
+		blockTime:       config.BlockTime,
+
+		state:        newState(),
+		tokens:       make(map[string]*tokenInfo),
+		transactions: make(map[string]*transaction),
+		receipts:     make(map[string]*receipt),
+		pool:         make(map[string]map[uint64]*transaction),
+	}
+
+	if config.Genesis != nil {
+		tokens, err := config.Genesis.apply(c.state, c.proxyAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		c.tokens = tokens
+	}
+
+	c.appendBlock(nil, 0)
+
+	return c, nil
+}
+
+// Run mines a block every block time until the context is done.
+func (c *Chain) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.blockTime)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Mine()
+		}
+	}
+}
+
+// Mine mines a block with the transactions which are next in the nonce order of their senders, and returns its number.
+func (c *Chain) Mine() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	var senders []string
+	for from := range c.pool {
+		senders = append(senders, from)
+	}
+
+	sort.Strings(senders)
+
+	timestamp := uint64(time.Now().Unix())
+
+	var txs []*transaction
+	var gasUsed uint64
+
+	for _, from := range senders {
+		for {
+			tx := c.pool[from][c.state.nonces[from]]
+			if tx == nil || gasUsed+tx.Gas > blockGasLimit {
+				break
+			}
+
+			r := c.applyTransaction(tx, timestamp)
+
+			gasUsed += r.GasUsed
+			r.CumulativeGasUsed = gasUsed
+
+			tx.Index = len(txs)
+			txs = append(txs, tx)
+			c.receipts[tx.Hash] = r
+		}
+
+		// replaced transactions, and those whose nonce is used
+		for nonce := range c.pool[from] {
+			if nonce < c.state.nonces[from] {
+				delete(c.pool[from], nonce)
+			}
+		}
+
+		if len(c.pool[from]) == 0 {
+			delete(c.pool, from)
+		}
+	}
+
+	b := c.appendBlock(txs, timestamp)
+	b.GasUsed = gasUsed
+
+	logIndex := 0
+	for _, tx := range txs {
+		tx.BlockNumber = b.Number
+		tx.BlockHash = b.Hash
+
+		for _, log := range c.receipts[tx.Hash].Logs {
+			log.LogIndex = logIndex
+			logIndex++
+		}
+	}
+
+	return b.Number
+}
+
+func (c *Chain) appendBlock(txs []*transaction, timestamp uint64) *block {
+	b := &block{
+		Number:       uint64(len(c.blocks)),
+		ParentHash:   utils.Bytes2HexP(make([]byte, 32)),
+		Timestamp:    timestamp,
+		Transactions: txs,
+	}
+
+	if b.Number > 0 {
+		b.ParentHash = c.blocks[b.Number-1].Hash
+	}
+
+	seed := []byte(fmt.Sprintf("%d/%s/%d", b.Number, b.ParentHash, b.Timestamp))
+	for _, tx := range txs {
+		seed = append(seed, tx.Hash...)
+	}
+
+	b.Hash = utils.Bytes2HexP(crypto.Keccak256(seed))
+	c.blocks = append(c.blocks, b)
+
+	return b
+}
+
+// applyTransaction runs the transaction on the state. Its sender pays for the gas and uses its nonce even if it reverts.
+func (c *Chain) applyTransaction(tx *transaction, timestamp uint64) *receipt {
+	run := c.state.clone()
+	msg := &message{from: tx.From, to: tx.To, value: tx.Value, data: tx.Data, gas: tx.Gas}
+
+	_, logs, gasUsed, err := c.execute(run, msg, timestamp)
+
+	r := &receipt{
+		Status:            err == nil,
+		GasUsed:           gasUsed,
+		EffectiveGasPrice: tx.effectiveGasPrice(baseFee),
+	}
+
+	if err == nil {
+		c.state = run
+		r.Logs = logs
+	} else {
+		utils.Infof("simulated transaction %s reverted: %v", tx.Hash, err)
+	}
+
+	fee := new(big.Int).Mul(r.EffectiveGasPrice, new(big.Int).SetUint64(gasUsed))
+	c.state.ether[tx.From] = new(big.Int).Sub(c.state.etherOf(tx.From), fee)
+	c.state.nonces[tx.From] = tx.Nonce + 1
+
+	return r
+}
+
+// SendRawTransaction takes a signed transaction in hex, it is mined in the next block if its nonce is next.
+func (c *Chain) SendRawTransaction(raw interface{}) (string, error) {
+	rawHex, ok := raw.(string)
+	if !ok {
+		return "", errors.New("rlp: raw transaction should be a hex string")
+	}
+
+	tx, err := decodeRawTransaction(utils.Hex2Bytes(rawHex))
+	if err != nil {
+		return "", err
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if known := c.transactions[tx.Hash]; known != nil && !c.isMined(known) {
+		return "", errors.New("already known")
+	}
+
+	if tx.Nonce < c.state.nonces[tx.From] {
+		return "", errors.New("nonce too low")
+	}
+
+	if tx.Gas < intrinsicGas {
+		return "", errors.New("intrinsic gas too low")
+	}
+
+	if tx.Gas > blockGasLimit {
+		return "", errors.New("exceeds block gas limit")
+	}
+
+	if tx.GasPrice.Cmp(baseFee) < 0 {
+		return "", errors.New("max fee per gas less than block base fee")
+	}
+
+	cost := new(big.Int).Add(tx.Value, new(big.Int).Mul(tx.GasPrice, new(big.Int).SetUint64(tx.Gas)))
+	if c.state.etherOf(tx.From).Cmp(cost) < 0 {
+		return "", errors.New("insufficient funds for gas * price + value")
+	}
+
+	// a replacement has to pay 10% more, like geth requires
+	if pending := c.pool[tx.From][tx.Nonce]; pending != nil {
+		minPrice := new(big.Int).Div(new(big.Int).Mul(pending.GasPrice, big.NewInt(110)), big.NewInt(100))
+		if tx.GasPrice.Cmp(minPrice) < 0 {
+			return "", errors.New("replacement transaction underpriced")
+		}
+
+		delete(c.transactions, pending.Hash)
+	}
+
+	if c.pool[tx.From] == nil {
+		c.pool[tx.From] = make(map[uint64]*transaction)
+	}
+
+	c.pool[tx.From][tx.Nonce] = tx
+	c.transactions[tx.Hash] = tx
+
+	return tx.Hash, nil
+}
+
+// SendTransaction signs the transaction with the private key the way the sdk does, and sends it.
+func (c *Chain) SendTransaction(txAttributes map[string]interface{}, privateKey []byte) (string, error) {
+	key, err := crypto.NewPrivateKey(privateKey)
+	if err != nil {
+		return "", err
+	}
+
+	tx := types.NewTransaction(
+		txAttributes["nonce"].(uint64),
+		txAttributes["to"].(string),
+		utils.DecimalToBigInt(txAttributes["value"].(decimal.Decimal)),
+		txAttributes["gasLimit"].(uint64),
+		utils.DecimalToBigInt(txAttributes["gasPrice"].(decimal.Decimal)),
+		txAttributes["data"].([]byte),
+	)
+
+	if tx, err = sdksigner.SignTx(tx, key); err != nil {
+		return "", err
+	}
+
+	return c.SendRawTransaction(utils.Bytes2HexP(sdksigner.EncodeRlp(tx)))
+}
+
+// Call runs the call data at the latest block without changing the chain.
+func (c *Chain) Call(from, to string, value *big.Int, data []byte) ([]byte, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	result, _, _, err := c.execute(c.state.clone(), &message{from: from, to: to, value: value, data: data}, uint64(time.Now().Unix()))
+	return result, err
+}
+
+// EstimateGas returns the gas the call data uses at the latest block.
+func (c *Chain) EstimateGas(from, to string, value *big.Int, data []byte) (uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	_, _, gasUsed, err := c.execute(c.state.clone(), &message{from: from, to: to, value: value, data: data}, uint64(time.Now().Unix()))
+	return gasUsed, err
+}
+
+// Nonce returns the next nonce of the address, with the pending transactions if pending is true.
+func (c *Chain) Nonce(address string, pending bool) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	address = strings.ToLower(address)
+	nonce := c.state.nonces[address]
+
+	for pending && c.pool[address][nonce] != nil {
+		nonce++
+	}
+
+	return nonce
+}
+
+// EtherBalance returns the ether of the address in wei.
+func (c *Chain) EtherBalance(address string) *big.Int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.state.etherOf(address)
+}
+
+func (c *Chain) GetTokenBalance(tokenAddress, address string) decimal.Decimal {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return decimal.NewFromBigInt(c.state.balanceOf(tokenAddress, address), 0)
+}
+
+func (c *Chain) GetTokenAllowance(tokenAddress, proxyAddress, address string) decimal.Decimal {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return decimal.NewFromBigInt(c.state.allowanceOf(tokenAddress, address, proxyAddress), 0)
+}
+
+// GetHotFeeDiscount returns no discount, the simulated exchange doesn't know HOT holders.
+func (c *Chain) GetHotFeeDiscount(address string) decimal.Decimal {
+	return decimal.New(1, 0)
+}
+
+func (c *Chain) GetBlockNumber() (uint64, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return uint64(len(c.blocks) - 1), nil
+}
+
+func (c *Chain) GetBlockByNumber(blockNumber uint64) (sdk.Block, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if blockNumber >= uint64(len(c.blocks)) {
+		return nil, fmt.Errorf("block %d is not mined yet", blockNumber)
+	}
+
+	return &ethereum.EthereumBlock{Block: toEthrpcBlock(c.blocks[blockNumber])}, nil
+}
+
+func (c *Chain) GetTransaction(ID string) (sdk.Transaction, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	tx := c.transactions[strings.ToLower(ID)]
+	if tx == nil {
+		return nil, fmt.Errorf("transaction %s not found", ID)
+	}
+
+	return &ethereum.EthereumTransaction{Transaction: toEthrpcTransaction(tx, c.isMined(tx))}, nil
+}
+
+func (c *Chain) GetTransactionReceipt(ID string) (sdk.TransactionReceipt, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	tx := c.transactions[strings.ToLower(ID)]
+	if tx == nil || !c.isMined(tx) {
+		return nil, fmt.Errorf("receipt of %s not found", ID)
+	}
+
+	return &ethereum.EthereumTransactionReceipt{TransactionReceipt: toEthrpcReceipt(tx, c.receipts[tx.Hash])}, nil
+}
+
+func (c *Chain) GetTransactionAndReceipt(ID string) (sdk.Transaction, sdk.TransactionReceipt, error) {
+	tx, err := c.GetTransaction(ID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	receipt, err := c.GetTransactionReceipt(ID)
+	return tx, receipt, err
+}
+
+func (c *Chain) IsValidSignature(address string, message string, signature string) (bool, error) {
+	return ethereum.IsValidSignature(address, message, signature)
+}
+
+func (c *Chain) isMined(tx *transaction) bool {
+	_, ok := c.receipts[tx.Hash]
+	return ok
+}
+
+func toEthrpcTransaction(tx *transaction, mined bool) *ethrpc.Transaction {
+	t := &ethrpc.Transaction{
+		Hash:     tx.Hash,
+		Nonce:    int(tx.Nonce),
+		From:     tx.From,
+		To:       tx.To,
+		Value:    *tx.Value,
+		Gas:      int(tx.Gas),
+		GasPrice: *tx.GasPrice,
+		Input:    utils.Bytes2HexP(tx.Data),
+	}
+
+	if mined {
+		blockNumber, index := int(tx.BlockNumber), tx.Index
+		t.BlockHash = tx.BlockHash
+		t.BlockNumber = &blockNumber
+		t.TransactionIndex = &index
+	}
+
+	return t
+}
+
+func toEthrpcReceipt(tx *transaction, r *receipt) *ethrpc.TransactionReceipt {
+	status := "0x0"
+	if r.Status {
+		status = "0x1"
+	}
+
+	t := &ethrpc.TransactionReceipt{
+		TransactionHash:   tx.Hash,
+		TransactionIndex:  tx.Index,
+		BlockHash:         tx.BlockHash,
+		BlockNumber:       int(tx.BlockNumber),
+		CumulativeGasUsed: int(r.CumulativeGasUsed),
+		GasUsed:           int(r.GasUsed),
+		Status:            status,
+	}
+
+	for _, log := range r.Logs {
+		t.Logs = append(t.Logs, ethrpc.Log{
+			LogIndex:         log.LogIndex,
+			TransactionIndex: tx.Index,
+			TransactionHash:  tx.Hash,
+			BlockNumber:      int(tx.BlockNumber),
+			BlockHash:        tx.BlockHash,
+			Address:          log.Address,
+			Data:             utils.Bytes2HexP(log.Data),
+			Topics:           log.Topics,
+		})
+	}
+
+	return t
+}
+
+func toEthrpcBlock(b *block) *ethrpc.Block {
+	t := &ethrpc.Block{
+		Number:     int(b.Number),
+		Hash:       b.Hash,
+		ParentHash: b.ParentHash,
+		GasLimit:   blockGasLimit,
+		GasUsed:    int(b.GasUsed),
+		Timestamp:  int(b.Timestamp),
+	}
+
+	for _, tx := range b.Transactions {
+		t.Transactions = append(t.Transactions, *toEthrpcTransaction(tx, true))
+	}
+
+	return t
+}
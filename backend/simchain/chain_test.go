@@ -0,0 +1,287 @@
+package simchain
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/dex_launcher"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/exchange_logs"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/signer"
+	"github.com/HydroProtocol/hydro-sdk-backend/launcher"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const (
+	testExchange = "0x5c0286bef1434b07202a5ae3de38e66130d5280d"
+	testProxy    = "0x04f67e8b7c39a25e100847cb167460d715215feb"
+	testRelayer  = "0x00000000000000000000000000000000000000aa"
+	testHOT      = "0x4c4fa7e8ea4cfcfc93deae2c0cff142a1dd3a218"
+	testDAI      = "0xbc3524faa62d0763818636d5e400f112279d6cc0"
+
+	// the keys of the relayer and the maker bot of the ethereum-test-node
+	takerPk = "95b0a982c0dfc5ab70bf915dcf9f4b790544d25bc5e6cff0f38a59d0bba58651"
+	makerPk = "0xa6553a3cbade744d6c6f63e557345402abd93e25cd1f1dba8bb0d374de2fcf4f"
+)
+
+func addressOf(t *testing.T, pk string) string {
+	sg, err := signer.NewPrivateKeySignerByHex(pk)
+	assert.Nil(t, err)
+	return strings.ToLower(sg.Address())
+}
+
+func newTestChain(t *testing.T) *Chain {
+	taker, maker := addressOf(t, takerPk), addressOf(t, makerPk)
+
+	chain, err := NewChain(Config{
+		ChainID:         1337,
+		ExchangeAddress: testExchange,
+		ProxyAddress:    testProxy,
+		Genesis: &Genesis{
+			Ether: map[string]string{taker: "10", maker: "10"},
+			Tokens: []GenesisToken{
+				{Address: testHOT, Symbol: "HOT", Decimals: 18, Balances: map[string]string{taker: "100", maker: "100"}, Approved: []string{taker, maker}},
+				{Address: testDAI, Symbol: "DAI", Decimals: 18, Balances: map[string]string{taker: "100", maker: "100"}, Approved: []string{taker, maker}},
+			},
+		},
+	})
+
+	assert.Nil(t, err)
+	return chain
+}
+
+func units(amount string) *big.Int {
+	n, _ := toSmallestUnit(amount, 18)
+	return n
+}
+
+func tokenBalance(c *Chain, token, address string) *big.Int {
+	return utils.DecimalToBigInt(c.GetTokenBalance(token, address))
+}
+
+func newLaunchLog(from, to string, nonce int64, data []byte) *launcher.LaunchLog {
+	return &launcher.LaunchLog{
+		From:     from,
+		To:       to,
+		Value:    decimal.Zero,
+		GasLimit: 500000,
+		GasPrice: decimal.NullDecimal{Decimal: decimal.New(2, 9), Valid: true},
+		Nonce:    sql.NullInt64{Int64: nonce, Valid: true},
+		Data:     utils.Bytes2HexP(data),
+	}
+}
+
+func transferData(to string, amount *big.Int) []byte {
+	return append(utils.Hex2Bytes("a9059cbb"), encodeWords(addressWord(to), amount)...)
+}
+
+func TestSendRawTransactionAndMine(t *testing.T) {
+	c := newTestChain(t)
+	taker, maker := addressOf(t, takerPk), addressOf(t, makerPk)
+
+	launchLog := newLaunchLog(taker, testDAI, 0, transferData(maker, units("1")))
+	hash, err := c.SendRawTransaction(dex_launcher.NewLocalSignService(takerPk).Sign(launchLog))
+	assert.Nil(t, err)
+	assert.EqualValues(t, launchLog.Hash.String, hash)
+
+	// pending until mined
+	assert.EqualValues(t, 1, c.Nonce(taker, true))
+	assert.EqualValues(t, 0, c.Nonce(taker, false))
+	_, err = c.GetTransactionReceipt(hash)
+	assert.NotNil(t, err)
+
+	_, err = c.SendRawTransaction(dex_launcher.NewLocalSignService(takerPk).Sign(launchLog))
+	assert.EqualError(t, err, "already known")
+
+	assert.EqualValues(t, 1, c.Mine())
+
+	receipt, err := c.GetTransactionReceipt(hash)
+	assert.Nil(t, err)
+	assert.True(t, receipt.GetResult())
+	assert.EqualValues(t, 1, receipt.GetBlockNumber())
+	assert.Len(t, receipt.GetLogs(), 1)
+	assert.EqualValues(t, transferTopic, receipt.GetLogs()[0].GetTopics()[0])
+
+	assert.EqualValues(t, units("99"), tokenBalance(c, testDAI, taker))
+	assert.EqualValues(t, units("101"), tokenBalance(c, testDAI, maker))
+
+	_, err = c.SendRawTransaction(dex_launcher.NewLocalSignService(takerPk).Sign(newLaunchLog(taker, testDAI, 0, transferData(maker, units("1")))))
+	assert.EqualError(t, err, "nonce too low")
+}
+
+func TestSendDynamicFeeTransaction(t *testing.T) {
+	c := newTestChain(t)
+	taker, maker := addressOf(t, takerPk), addressOf(t, makerPk)
+
+	launchLog := newLaunchLog(taker, testDAI, 0, transferData(maker, units("1")))
+	raw := dex_launcher.NewLocalSignService(takerPk).SignDynamicFee(launchLog, 1337, decimal.New(5, 8))
+
+	hash, err := c.SendRawTransaction(raw)
+	assert.Nil(t, err)
+	assert.EqualValues(t, launchLog.Hash.String, hash)
+
+	c.Mine()
+
+	tx, err := c.GetTransaction(hash)
+	assert.Nil(t, err)
+	assert.EqualValues(t, taker, strings.ToLower(tx.GetFrom()))
+
+	// the base fee and the priority fee, under the max fee
+	receipt, _ := c.GetTransactionReceipt(hash)
+	assert.True(t, receipt.GetResult())
+	paid := new(big.Int).Mul(big.NewInt(1500000000), new(big.Int).SetUint64(c.receipts[hash].GasUsed))
+	assert.EqualValues(t, new(big.Int).Sub(units("10"), paid), c.EtherBalance(taker))
+}
+
+func TestRevertedTransaction(t *testing.T) {
+	c := newTestChain(t)
+	taker, maker := addressOf(t, takerPk), addressOf(t, makerPk)
+
+	_, err := c.Call(taker, testDAI, big.NewInt(0), transferData(maker, units("1000")))
+	assert.EqualError(t, err, "execution reverted: TRANSFER_FAILED")
+
+	hash, err := c.SendRawTransaction(dex_launcher.NewLocalSignService(takerPk).Sign(newLaunchLog(taker, testDAI, 0, transferData(maker, units("1000")))))
+	assert.Nil(t, err)
+
+	c.Mine()
+
+	// the gas is paid and the nonce is used, the balances are untouched
+	receipt, _ := c.GetTransactionReceipt(hash)
+	assert.False(t, receipt.GetResult())
+	assert.EqualValues(t, 1, c.Nonce(taker, false))
+	assert.EqualValues(t, units("100"), tokenBalance(c, testDAI, taker))
+	assert.True(t, c.EtherBalance(taker).Cmp(units("10")) < 0)
+}
+
+func signedOrder(t *testing.T, c *Chain, pk string, isSell bool, base, quote string) *sdk.Order {
+	order := &sdk.Order{
+		Trader:            addressOf(t, pk),
+		BaseTokenAmount:   units(base),
+		QuoteTokenAmount:  units(quote),
+		GasTokenAmount:    big.NewInt(0),
+		Relayer:           testRelayer,
+		BaseTokenAddress:  testHOT,
+		QuoteTokenAddress: testDAI,
+		Data:              c.GenerateOrderData(2, 4102444800, 1, decimal.New(1, -3), decimal.New(3, -3), decimal.Zero, isSell, false, false),
+	}
+
+	sg, err := signer.NewPrivateKeySignerByHex(pk)
+	assert.Nil(t, err)
+
+	signature, err := signer.PersonalSign(sg, c.GetOrderHash(order))
+	assert.Nil(t, err)
+
+	var config [96]byte
+	config[0] = signature[64] + 27
+	copy(config[32:], signature[:64])
+	order.Signature = utils.Bytes2HexP(config[:])
+
+	return order
+}
+
+func TestMatchOrders(t *testing.T) {
+	c := newTestChain(t)
+	taker, maker := addressOf(t, takerPk), addressOf(t, makerPk)
+
+	takerOrder := signedOrder(t, c, takerPk, true, "10", "20")
+	makerOrder := signedOrder(t, c, makerPk, false, "10", "20")
+
+	data := c.GetMatchOrderCallData(takerOrder, []*sdk.Order{makerOrder}, []*big.Int{units("4")})
+	hash, err := c.SendRawTransaction(dex_launcher.NewLocalSignService(takerPk).Sign(newLaunchLog(taker, testExchange, 0, data)))
+	assert.Nil(t, err)
+
+	c.Mine()
+
+	receipt, _ := c.GetTransactionReceipt(hash)
+	assert.True(t, receipt.GetResult())
+
+	// 4 HOT at 2 DAI, the taker pays 0.3% and the maker 0.1% of 8 DAI
+	assert.EqualValues(t, units("96"), tokenBalance(c, testHOT, taker))
+	assert.EqualValues(t, units("104"), tokenBalance(c, testHOT, maker))
+	assert.EqualValues(t, units("107.976"), tokenBalance(c, testDAI, taker))
+	assert.EqualValues(t, units("91.992"), tokenBalance(c, testDAI, maker))
+	assert.EqualValues(t, units("0.032"), tokenBalance(c, testDAI, testRelayer))
+
+	logs, err := exchange_logs.ParseReceiptLogs(receipt.GetLogs(), testExchange)
+	assert.Nil(t, err)
+	assert.Len(t, logs.Matches, 1)
+	assert.EqualValues(t, maker, logs.Matches[0].Maker)
+	assert.EqualValues(t, maker, logs.Matches[0].Buyer)
+	assert.True(t, decimal.New(4, 18).Equal(logs.Matches[0].BaseTokenFilledAmount))
+	assert.True(t, decimal.New(24, 15).Equal(logs.Matches[0].TakerFee))
+
+	// the rest of the orders can be matched, but no more
+	data = c.GetMatchOrderCallData(takerOrder, []*sdk.Order{makerOrder}, []*big.Int{units("7")})
+	_, err = c.Call(taker, testExchange, big.NewInt(0), data)
+	assert.EqualError(t, err, "execution reverted: ORDER_OVER_MATCH")
+
+	data = c.GetMatchOrderCallData(takerOrder, []*sdk.Order{makerOrder}, []*big.Int{units("6")})
+	_, err = c.Call(taker, testExchange, big.NewInt(0), data)
+	assert.Nil(t, err)
+}
+
+func TestMatchOrdersChecksOrders(t *testing.T) {
+	c := newTestChain(t)
+	taker := addressOf(t, takerPk)
+
+	takerOrder := signedOrder(t, c, takerPk, true, "10", "20")
+
+	// a maker which sells too
+	makerOrder := signedOrder(t, c, makerPk, true, "10", "20")
+	data := c.GetMatchOrderCallData(takerOrder, []*sdk.Order{makerOrder}, []*big.Int{units("1")})
+	_, err := c.Call(taker, testExchange, big.NewInt(0), data)
+	assert.EqualError(t, err, "execution reverted: INVALID_SIDE")
+
+	// a maker which buys below the price of the taker
+	makerOrder = signedOrder(t, c, makerPk, false, "10", "19")
+	data = c.GetMatchOrderCallData(takerOrder, []*sdk.Order{makerOrder}, []*big.Int{units("1")})
+	_, err = c.Call(taker, testExchange, big.NewInt(0), data)
+	assert.EqualError(t, err, "execution reverted: INVALID_MATCH")
+
+	// a maker order signed by another key
+	makerOrder = signedOrder(t, c, makerPk, false, "10", "20")
+	makerOrder.Trader = taker
+	data = c.GetMatchOrderCallData(takerOrder, []*sdk.Order{makerOrder}, []*big.Int{units("1")})
+	_, err = c.Call(taker, testExchange, big.NewInt(0), data)
+	assert.EqualError(t, err, "execution reverted: INVALID_ORDER_SIGNATURE")
+}
+
+func rpcCall(t *testing.T, c *Chain, method string, params ...interface{}) map[string]interface{} {
+	body, _ := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+
+	rec := httptest.NewRecorder()
+	c.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+
+	var res map[string]interface{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &res))
+	return res
+}
+
+func TestHandler(t *testing.T) {
+	c := newTestChain(t)
+	maker := addressOf(t, makerPk)
+
+	c.Mine()
+	assert.EqualValues(t, "0x1", rpcCall(t, c, "eth_blockNumber")["result"])
+	assert.EqualValues(t, "0x539", rpcCall(t, c, "eth_chainId")["result"])
+
+	// the way the erc20 service of the sdk reads a balance
+	res := rpcCall(t, c, "eth_call", map[string]string{"to": testDAI, "data": utils.Bytes2HexP(append(utils.Hex2Bytes("70a08231"), encodeWords(addressWord(maker))...))}, "latest")
+	assert.EqualValues(t, units("100"), utils.Hex2BigInt(res["result"].(string)))
+
+	res = rpcCall(t, c, "eth_getBlockByNumber", "0x1", true)
+	assert.EqualValues(t, "0x1", res["result"].(map[string]interface{})["number"])
+
+	res = rpcCall(t, c, "eth_call", map[string]string{"from": maker, "to": testDAI, "data": utils.Bytes2HexP(transferData(testRelayer, units("1000")))}, "latest")
+	assert.EqualValues(t, 3, res["error"].(map[string]interface{})["code"])
+
+	res = rpcCall(t, c, "eth_unknown")
+	assert.NotNil(t, res["error"])
+}
@@ -0,0 +1,480 @@
+package simchain
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/HydroProtocol/hydro-scaffold-dex/backend/exchange_logs"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"math/big"
+	"strings"
+)
+
+// Every address which is not the exchange is taken as an ERC20 token, balances of unknown tokens are 0.
+// The exchange only settles matches, which it does the way HybridExchange v1.1 does:
+// the proxy moves the tokens between the traders, and the fees to the relayer, and a Match log is emitted per maker order.
+
+const wordLength = 32
+
+// gas used by the calls, close to what the contracts use
+const (
+	intrinsicGas   = 21000
+	viewGas        = 25000
+	approveGas     = 46000
+	transferGas    = 52000
+	matchGas       = 120000
+	matchPerMaker  = 80000
+	feeRateBase    = 100000
+	rebateRateBase = 100
+)
+
+var (
+	selectorBalanceOf     = []byte{0x70, 0xa0, 0x82, 0x31}
+	selectorAllowance     = []byte{0xdd, 0x62, 0xed, 0x3e}
+	selectorApprove       = []byte{0x09, 0x5e, 0xa7, 0xb3}
+	selectorTransfer      = []byte{0xa9, 0x05, 0x9c, 0xbb}
+	selectorTransferFrom  = []byte{0x23, 0xb8, 0x72, 0xdd}
+	selectorSymbol        = []byte{0x95, 0xd8, 0x9b, 0x41}
+	selectorName          = []byte{0x06, 0xfd, 0xde, 0x03}
+	selectorDecimals      = []byte{0x31, 0x3c, 0xe5, 0x67}
+	selectorMatchOrders   = []byte{0x88, 0x4d, 0xad, 0x2e}
+	selectorDiscountedFee = []byte{0x43, 0x76, 0xab, 0xf1}
+)
+
+var transferTopic = utils.Bytes2HexP(crypto.Keccak256([]byte("Transfer(address,address,uint256)")))
+var approvalTopic = utils.Bytes2HexP(crypto.Keccak256([]byte("Approval(address,address,uint256)")))
+
+type revertError string
+
+func (e revertError) Error() string {
+	return "execution reverted: " + string(e)
+}
+
+type message struct {
+	from  string
+	to    string
+	value *big.Int
+	data  []byte
+
+	// the gas limit, 0 is unlimited
+	gas uint64
+}
+
+// execute runs the message on the state, and returns its result, logs and gas used.
+// The state is left half changed if it fails, it is a clone to be thrown away then.
+func (c *Chain) execute(s *state, msg *message, timestamp uint64) (result []byte, logs []*receiptLog, gasUsed uint64, err error) {
+	from, to := strings.ToLower(msg.from), strings.ToLower(msg.to)
+
+	if msg.value != nil && msg.value.Sign() > 0 {
+		if s.etherOf(from).Cmp(msg.value) < 0 {
+			return nil, nil, intrinsicGas, revertError("insufficient funds for transfer")
+		}
+
+		s.ether[from] = new(big.Int).Sub(s.etherOf(from), msg.value)
+		s.ether[to] = new(big.Int).Add(s.etherOf(to), msg.value)
+	}
+
+	if len(msg.data) == 0 {
+		return nil, nil, intrinsicGas, nil
+	}
+
+	if len(msg.data) < 4 {
+		return nil, nil, intrinsicGas, revertError("invalid call data")
+	}
+
+	if to == c.exchangeAddress {
+		result, logs, gasUsed, err = c.executeExchange(s, from, msg.data, timestamp)
+	} else {
+		result, logs, gasUsed, err = c.executeToken(s, from, to, msg.data)
+	}
+
+	if msg.gas > 0 && gasUsed > msg.gas {
+		return nil, nil, msg.gas, revertError("out of gas")
+	}
+
+	return result, logs, gasUsed, err
+}
+
+func (c *Chain) executeToken(s *state, from, token string, data []byte) ([]byte, []*receiptLog, uint64, error) {
+	selector, args := data[:4], toWords(data[4:])
+
+	switch {
+	case bytes.Equal(selector, selectorBalanceOf) && len(args) == 1:
+		return s.balanceOf(token, args.address(0)).FillBytes(make([]byte, wordLength)), nil, viewGas, nil
+	case bytes.Equal(selector, selectorAllowance) && len(args) == 2:
+		return s.allowanceOf(token, args.address(0), args.address(1)).FillBytes(make([]byte, wordLength)), nil, viewGas, nil
+	case bytes.Equal(selector, selectorDecimals):
+		return big.NewInt(int64(c.token(token).decimals)).FillBytes(make([]byte, wordLength)), nil, viewGas, nil
+	case bytes.Equal(selector, selectorSymbol):
+		return encodeString(c.token(token).symbol), nil, viewGas, nil
+	case bytes.Equal(selector, selectorName):
+		return encodeString(c.token(token).name), nil, viewGas, nil
+	case bytes.Equal(selector, selectorApprove) && len(args) == 2:
+		s.setAllowance(token, from, args.address(0), args.uint(1))
+		return trueWord(), []*receiptLog{tokenLog(token, approvalTopic, from, args.address(0), args.uint(1))}, approveGas, nil
+	case bytes.Equal(selector, selectorTransfer) && len(args) == 2:
+		if err := s.transfer(token, from, args.address(0), args.uint(1)); err != nil {
+			return nil, nil, transferGas, err
+		}
+
+		return trueWord(), []*receiptLog{tokenLog(token, transferTopic, from, args.address(0), args.uint(1))}, transferGas, nil
+	case bytes.Equal(selector, selectorTransferFrom) && len(args) == 3:
+		if err := s.transferFrom(token, from, args.address(0), args.address(1), args.uint(2)); err != nil {
+			return nil, nil, transferGas, err
+		}
+
+		return trueWord(), []*receiptLog{tokenLog(token, transferTopic, args.address(0), args.address(1), args.uint(2))}, transferGas, nil
+	}
+
+	return nil, nil, intrinsicGas, revertError("unsupported token method")
+}
+
+// token returns what the genesis says about the token, an unknown token has 18 decimals.
+func (c *Chain) token(address string) *tokenInfo {
+	if t := c.tokens[address]; t != nil {
+		return t
+	}
+
+	return &tokenInfo{decimals: 18}
+}
+
+func (c *Chain) executeExchange(s *state, from string, data []byte, timestamp uint64) ([]byte, []*receiptLog, uint64, error) {
+	selector := data[:4]
+
+	switch {
+	case bytes.Equal(selector, selectorDiscountedFee):
+		// no discount, in percent
+		return big.NewInt(100).FillBytes(make([]byte, wordLength)), nil, viewGas, nil
+	case bytes.Equal(selector, selectorMatchOrders):
+		match, err := decodeMatchOrders(data[4:])
+		if err != nil {
+			return nil, nil, matchGas, revertError(err.Error())
+		}
+
+		gasUsed := uint64(matchGas + matchPerMaker*len(match.makers))
+		logs, err := c.matchOrders(s, match, timestamp)
+
+		return nil, logs, gasUsed, err
+	}
+
+	return nil, nil, intrinsicGas, revertError("unsupported exchange method")
+}
+
+type lightOrder struct {
+	trader           string
+	baseTokenAmount  *big.Int
+	quoteTokenAmount *big.Int
+	gasTokenAmount   *big.Int
+	data             []byte
+	signature        []byte
+}
+
+func (o *lightOrder) isSell() bool {
+	return o.data[1] == 1
+}
+
+func (o *lightOrder) isMarket() bool {
+	return o.data[2] == 1
+}
+
+func (o *lightOrder) expiredAt() uint64 {
+	return new(big.Int).SetBytes(o.data[3:8]).Uint64()
+}
+
+func (o *lightOrder) rate(from, to int) *big.Int {
+	return new(big.Int).SetBytes(o.data[from:to])
+}
+
+type matchOrders struct {
+	taker             *lightOrder
+	makers            []*lightOrder
+	baseFilledAmounts []*big.Int
+
+	baseToken  string
+	quoteToken string
+	relayer    string
+}
+
+// decodeMatchOrders decodes the arguments of matchOrders the way sdk GetMatchOrderCallData encodes them:
+// the taker order, the offsets of the maker orders and the filled amounts, the address set,
+// then the length prefixed maker orders and filled amounts.
+func decodeMatchOrders(data []byte) (*matchOrders, error) {
+	args := toWords(data)
+	if len(args) < 14 {
+		return nil, fmt.Errorf("INVALID_CALL_DATA")
+	}
+
+	match := &matchOrders{
+		taker:      args.order(0),
+		baseToken:  args.address(10),
+		quoteToken: args.address(11),
+		relayer:    args.address(12),
+	}
+
+	makersAt := int(args.uint(8).Int64() / wordLength)
+	fillsAt := int(args.uint(9).Int64() / wordLength)
+
+	if makersAt >= len(args) || fillsAt >= len(args) {
+		return nil, fmt.Errorf("INVALID_CALL_DATA")
+	}
+
+	makerCount := int(args.uint(makersAt).Int64())
+	fillCount := int(args.uint(fillsAt).Int64())
+
+	if makerCount != fillCount || makersAt+1+makerCount*8 > len(args) || fillsAt+1+fillCount > len(args) {
+		return nil, fmt.Errorf("INVALID_CALL_DATA")
+	}
+
+	for i := 0; i < makerCount; i++ {
+		match.makers = append(match.makers, args.order(makersAt+1+i*8))
+		match.baseFilledAmounts = append(match.baseFilledAmounts, args.uint(fillsAt+1+i))
+	}
+
+	return match, nil
+}
+
+func (c *Chain) orderHash(order *lightOrder, match *matchOrders) []byte {
+	return c.GetOrderHash(&sdk.Order{
+		Trader:            order.trader,
+		Relayer:           match.relayer,
+		BaseTokenAddress:  match.baseToken,
+		QuoteTokenAddress: match.quoteToken,
+		BaseTokenAmount:   order.baseTokenAmount,
+		QuoteTokenAmount:  order.quoteTokenAmount,
+		GasTokenAmount:    order.gasTokenAmount,
+		Data:              utils.Bytes2HexP(order.data),
+	})
+}
+
+// checkOrder checks the signature and expiry of an order, and returns its hash.
+func (c *Chain) checkOrder(order *lightOrder, match *matchOrders, timestamp uint64) (string, error) {
+	hash := utils.Bytes2HexP(c.orderHash(order, match))
+
+	if len(order.signature) != 96 {
+		return "", revertError("INVALID_ORDER_SIGNATURE")
+	}
+
+	if !c.IsValidOrderSignature(order.trader, hash, utils.Bytes2HexP(order.signature)) {
+		return "", revertError("INVALID_ORDER_SIGNATURE")
+	}
+
+	if expiredAt := order.expiredAt(); expiredAt > 0 && expiredAt < timestamp {
+		return "", revertError("ORDER_IS_NOT_FILLABLE")
+	}
+
+	return hash, nil
+}
+
+// fill adds the amount to what is filled of the order, which can't be more than the order amount.
+func fill(s *state, hash string, amount, limit *big.Int) error {
+	filled := new(big.Int).Add(amountOf(s.filled, hash), amount)
+	if filled.Cmp(limit) > 0 {
+		return revertError("ORDER_OVER_MATCH")
+	}
+
+	s.filled[hash] = filled
+	return nil
+}
+
+func (c *Chain) matchOrders(s *state, match *matchOrders, timestamp uint64) ([]*receiptLog, error) {
+	taker := match.taker
+
+	takerHash, err := c.checkOrder(taker, match, timestamp)
+	if err != nil {
+		return nil, err
+	}
+
+	// the first match charges the gas fee of the taker
+	takerGasFee := taker.gasTokenAmount
+	if amountOf(s.filled, takerHash).Sign() > 0 {
+		takerGasFee = big.NewInt(0)
+	}
+
+	var logs []*receiptLog
+
+	for i, maker := range match.makers {
+		baseFilled := match.baseFilledAmounts[i]
+
+		makerHash, err := c.checkOrder(maker, match, timestamp)
+		if err != nil {
+			return nil, err
+		}
+
+		if maker.isSell() == taker.isSell() || maker.isMarket() {
+			return nil, revertError("INVALID_SIDE")
+		}
+
+		// at the price of the maker
+		quoteFilled := new(big.Int).Div(new(big.Int).Mul(baseFilled, maker.quoteTokenAmount), maker.baseTokenAmount)
+
+		if !taker.isMarket() {
+			// a taker buys at its price or lower, and sells at its price or higher
+			makerPrice := new(big.Int).Mul(maker.quoteTokenAmount, taker.baseTokenAmount)
+			takerPrice := new(big.Int).Mul(taker.quoteTokenAmount, maker.baseTokenAmount)
+
+			if (taker.isSell() && makerPrice.Cmp(takerPrice) < 0) || (!taker.isSell() && makerPrice.Cmp(takerPrice) > 0) {
+				return nil, revertError("INVALID_MATCH")
+			}
+		}
+
+		makerGasFee := maker.gasTokenAmount
+		if amountOf(s.filled, makerHash).Sign() > 0 {
+			makerGasFee = big.NewInt(0)
+		}
+
+		if err := fill(s, makerHash, baseFilled, maker.baseTokenAmount); err != nil {
+			return nil, err
+		}
+
+		if taker.isMarket() && !taker.isSell() {
+			err = fill(s, takerHash, quoteFilled, taker.quoteTokenAmount)
+		} else {
+			err = fill(s, takerHash, baseFilled, taker.baseTokenAmount)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		makerFee := percentOf(quoteFilled, maker.rate(8, 10), feeRateBase)
+		takerFee := percentOf(quoteFilled, taker.rate(10, 12), feeRateBase)
+
+		// the rebate is a part of the taker fee, a maker with a rebate pays no fee
+		makerRebate := percentOf(takerFee, maker.rate(12, 14), rebateRateBase)
+		if makerRebate.Sign() > 0 {
+			makerFee = big.NewInt(0)
+		}
+
+		seller, buyer := taker.trader, maker.trader
+		sellerFee, buyerFee := new(big.Int).Add(takerFee, takerGasFee), new(big.Int).Add(makerFee, makerGasFee)
+		if !taker.isSell() {
+			seller, buyer = maker.trader, taker.trader
+			sellerFee, buyerFee = new(big.Int).Add(makerFee, makerGasFee), new(big.Int).Add(takerFee, takerGasFee)
+		}
+
+		// the maker gets its rebate out of the fees whichever side it is on
+		buyerPays := new(big.Int).Add(quoteFilled, buyerFee)
+		sellerGets := new(big.Int).Sub(quoteFilled, sellerFee)
+		if taker.isSell() {
+			buyerPays.Sub(buyerPays, makerRebate)
+		} else {
+			sellerGets.Add(sellerGets, makerRebate)
+		}
+
+		if sellerGets.Sign() < 0 || buyerPays.Sign() < 0 {
+			return nil, revertError("FEE_EXCEEDS_AMOUNT")
+		}
+
+		transfers := []struct {
+			token, from, to string
+			amount          *big.Int
+		}{
+			{match.baseToken, seller, buyer, baseFilled},
+			{match.quoteToken, buyer, seller, sellerGets},
+			{match.quoteToken, buyer, match.relayer, new(big.Int).Sub(buyerPays, sellerGets)},
+		}
+
+		for _, t := range transfers {
+			if t.amount.Sign() == 0 {
+				continue
+			}
+
+			if err := s.transferFrom(t.token, c.proxyAddress, t.from, t.to, t.amount); err != nil {
+				return nil, err
+			}
+
+			logs = append(logs, tokenLog(t.token, transferTopic, t.from, t.to, t.amount))
+		}
+
+		logs = append(logs, &receiptLog{
+			Address: c.exchangeAddress,
+			Topics:  []string{exchange_logs.MatchTopic},
+			Data: encodeWords(
+				addressWord(match.baseToken), addressWord(match.quoteToken), addressWord(match.relayer),
+				addressWord(maker.trader), addressWord(taker.trader), addressWord(buyer),
+				makerFee, makerRebate, takerFee, makerGasFee, takerGasFee, baseFilled, quoteFilled,
+			),
+		})
+
+		takerGasFee = big.NewInt(0)
+	}
+
+	return logs, nil
+}
+
+func percentOf(amount, rate *big.Int, base int64) *big.Int {
+	return new(big.Int).Div(new(big.Int).Mul(amount, rate), big.NewInt(base))
+}
+
+// words are the 32 bytes words of abi encoded arguments.
+type words [][]byte
+
+func toWords(data []byte) words {
+	var ws words
+	for i := 0; i+wordLength <= len(data); i += wordLength {
+		ws = append(ws, data[i:i+wordLength])
+	}
+
+	return ws
+}
+
+func (ws words) uint(i int) *big.Int {
+	return new(big.Int).SetBytes(ws[i])
+}
+
+func (ws words) address(i int) string {
+	return strings.ToLower(utils.Bytes2HexP(ws[i][wordLength-20:]))
+}
+
+func (ws words) order(i int) *lightOrder {
+	signature := make([]byte, 0, 3*wordLength)
+	signature = append(append(append(signature, ws[i+5]...), ws[i+6]...), ws[i+7]...)
+
+	return &lightOrder{
+		trader:           ws.address(i),
+		baseTokenAmount:  ws.uint(i + 1),
+		quoteTokenAmount: ws.uint(i + 2),
+		gasTokenAmount:   ws.uint(i + 3),
+		data:             ws[i+4],
+		signature:        signature,
+	}
+}
+
+func addressWord(address string) *big.Int {
+	return new(big.Int).SetBytes(utils.Hex2Bytes(address))
+}
+
+func encodeWords(values ...*big.Int) []byte {
+	data := make([]byte, 0, len(values)*wordLength)
+	for _, value := range values {
+		data = append(data, value.FillBytes(make([]byte, wordLength))...)
+	}
+
+	return data
+}
+
+func trueWord() []byte {
+	return encodeWords(big.NewInt(1))
+}
+
+func encodeString(str string) []byte {
+	data := encodeWords(big.NewInt(wordLength), big.NewInt(int64(len(str))))
+	padded := make([]byte, (len(str)+wordLength-1)/wordLength*wordLength)
+	copy(padded, str)
+
+	return append(data, padded...)
+}
+
+// tokenLog is a Transfer or Approval log, both have two indexed addresses and the amount as data.
+func tokenLog(token, topic, from, to string, amount *big.Int) *receiptLog {
+	return &receiptLog{
+		Address: token,
+		Topics: []string{
+			topic,
+			utils.Bytes2HexP(addressWord(from).FillBytes(make([]byte, wordLength))),
+			utils.Bytes2HexP(addressWord(to).FillBytes(make([]byte, wordLength))),
+		},
+		Data: encodeWords(amount),
+	}
+}
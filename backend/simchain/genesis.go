@@ -0,0 +1,92 @@
+package simchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"github.com/shopspring/decimal"
+	"io/ioutil"
+	"math/big"
+	"strings"
+)
+
+// Genesis is the state the chain starts with. Amounts are in ether and in token units,
+// e.g. "1.5" of a token with 18 decimals is 1.5 * 10^18 in its smallest unit.
+type Genesis struct {
+	Ether  map[string]string `json:"ether"`
+	Tokens []GenesisToken    `json:"tokens"`
+}
+
+type GenesisToken struct {
+	Address  string            `json:"address"`
+	Symbol   string            `json:"symbol"`
+	Name     string            `json:"name"`
+	Decimals int               `json:"decimals"`
+	Balances map[string]string `json:"balances"`
+
+	// addresses which have approved the proxy to spend the token without limit
+	Approved []string `json:"approved"`
+}
+
+// LoadGenesis reads a genesis json file.
+func LoadGenesis(path string) (*Genesis, error) {
+	bts, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var genesis Genesis
+	if err := json.Unmarshal(bts, &genesis); err != nil {
+		return nil, fmt.Errorf("invalid genesis %s: %v", path, err)
+	}
+
+	return &genesis, nil
+}
+
+type tokenInfo struct {
+	symbol   string
+	name     string
+	decimals int
+}
+
+// apply puts the genesis into the state, and returns the tokens by their lower cased addresses.
+func (g *Genesis) apply(s *state, proxyAddress string) (map[string]*tokenInfo, error) {
+	tokens := make(map[string]*tokenInfo)
+
+	for address, amount := range g.Ether {
+		wei, err := toSmallestUnit(amount, 18)
+		if err != nil {
+			return nil, fmt.Errorf("ether of %s: %v", address, err)
+		}
+
+		s.ether[strings.ToLower(address)] = wei
+	}
+
+	for _, token := range g.Tokens {
+		tokens[strings.ToLower(token.Address)] = &tokenInfo{token.Symbol, token.Name, token.Decimals}
+
+		for owner, amount := range token.Balances {
+			balance, err := toSmallestUnit(amount, token.Decimals)
+			if err != nil {
+				return nil, fmt.Errorf("%s balance of %s: %v", token.Symbol, owner, err)
+			}
+
+			s.setBalance(token.Address, owner, balance)
+		}
+
+		for _, owner := range token.Approved {
+			s.setAllowance(token.Address, owner, proxyAddress, new(big.Int).Set(unlimitedAllowance))
+		}
+	}
+
+	return tokens, nil
+}
+
+func toSmallestUnit(amount string, decimals int) (*big.Int, error) {
+	d, err := decimal.NewFromString(amount)
+	if err != nil {
+		return nil, err
+	}
+
+	return utils.DecimalToBigInt(d.Shift(int32(decimals))), nil
+}
@@ -0,0 +1,341 @@
+package simchain
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"math/big"
+	"net/http"
+	"strings"
+)
+
+// The JSON-RPC methods the scaffold calls, through the sdk, nights-watch, ethrpc and the launcher.
+
+type rpcRequest struct {
+	ID      json.RawMessage   `json:"id"`
+	JSONRPC string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Params  []json.RawMessage `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	ID      json.RawMessage `json:"id"`
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// callArgs are the arguments of eth_call and eth_estimateGas.
+type callArgs struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Value string `json:"value"`
+	Data  string `json:"data"`
+	Input string `json:"input"`
+}
+
+// Handler serves the chain over JSON-RPC.
+func (c *Chain) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req rpcRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		res := rpcResponse{ID: req.ID, JSONRPC: "2.0"}
+
+		result, err := c.serve(req.Method, req.Params)
+		if err != nil {
+			res.Error = &rpcError{Code: -32000, Message: err.Error()}
+			if _, ok := err.(revertError); ok {
+				res.Error.Code = 3
+			}
+		} else {
+			res.Result = result
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(&res)
+	})
+}
+
+func (c *Chain) serve(method string, params []json.RawMessage) (interface{}, error) {
+	param := func(i int, v interface{}) error {
+		if i >= len(params) {
+			return fmt.Errorf("%s: missing param %d", method, i)
+		}
+
+		return json.Unmarshal(params[i], v)
+	}
+
+	switch method {
+	case "eth_chainId":
+		return hexUint(uint64(c.chainID)), nil
+	case "net_version":
+		return fmt.Sprintf("%d", c.chainID), nil
+	case "eth_blockNumber":
+		number, _ := c.GetBlockNumber()
+		return hexUint(number), nil
+	case "eth_gasPrice":
+		return hexBig(baseFee), nil
+	case "eth_feeHistory":
+		return c.feeHistory(params)
+	case "eth_getBlockByNumber":
+		var tag string
+		var full bool
+		if err := param(0, &tag); err != nil {
+			return nil, err
+		}
+		if err := param(1, &full); err != nil {
+			return nil, err
+		}
+
+		return c.blockJSON(tag, full), nil
+	case "eth_getTransactionByHash":
+		var hash string
+		if err := param(0, &hash); err != nil {
+			return nil, err
+		}
+
+		return c.transactionJSON(hash), nil
+	case "eth_getTransactionReceipt":
+		var hash string
+		if err := param(0, &hash); err != nil {
+			return nil, err
+		}
+
+		return c.receiptJSON(hash), nil
+	case "eth_getTransactionCount":
+		var address, tag string
+		if err := param(0, &address); err != nil {
+			return nil, err
+		}
+		_ = param(1, &tag)
+
+		return hexUint(c.Nonce(address, tag == "pending")), nil
+	case "eth_getBalance":
+		var address string
+		if err := param(0, &address); err != nil {
+			return nil, err
+		}
+
+		return hexBig(c.EtherBalance(address)), nil
+	case "eth_call", "eth_estimateGas":
+		var args callArgs
+		if err := param(0, &args); err != nil {
+			return nil, err
+		}
+
+		data := args.Data
+		if data == "" {
+			data = args.Input
+		}
+
+		value := utils.Hex2BigInt(args.Value)
+
+		if method == "eth_estimateGas" {
+			gas, err := c.EstimateGas(args.From, args.To, value, utils.Hex2Bytes(data))
+			return hexUint(gas), err
+		}
+
+		result, err := c.Call(args.From, args.To, value, utils.Hex2Bytes(data))
+		return utils.Bytes2HexP(result), err
+	case "eth_sendRawTransaction":
+		var raw string
+		if err := param(0, &raw); err != nil {
+			return nil, err
+		}
+
+		return c.SendRawTransaction(raw)
+	}
+
+	return nil, fmt.Errorf("the method %s does not exist/is not available", method)
+}
+
+// feeHistory reports the constant base fee, and a priority fee of 1 Gwei.
+func (c *Chain) feeHistory(params []json.RawMessage) (interface{}, error) {
+	var count string
+	if len(params) == 0 || json.Unmarshal(params[0], &count) != nil {
+		return nil, fmt.Errorf("eth_feeHistory: invalid block count")
+	}
+
+	blocks := int(utils.Hex2BigInt(count).Int64())
+	head, _ := c.GetBlockNumber()
+	if uint64(blocks) > head+1 {
+		blocks = int(head + 1)
+	}
+
+	history := map[string]interface{}{
+		"oldestBlock": hexUint(head + 1 - uint64(blocks)),
+	}
+
+	var baseFees []string
+	var rewards [][]string
+	var ratios []float64
+
+	for i := 0; i < blocks; i++ {
+		baseFees = append(baseFees, hexBig(baseFee))
+		rewards = append(rewards, []string{hexBig(baseFee)})
+		ratios = append(ratios, 0)
+	}
+
+	history["baseFeePerGas"] = append(baseFees, hexBig(baseFee))
+	history["reward"] = rewards
+	history["gasUsedRatio"] = ratios
+
+	return history, nil
+}
+
+func (c *Chain) blockJSON(tag string, full bool) interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	number := uint64(len(c.blocks) - 1)
+	if tag != "latest" && tag != "pending" {
+		number = utils.Hex2BigInt(tag).Uint64()
+	}
+
+	if number >= uint64(len(c.blocks)) {
+		return nil
+	}
+
+	b := c.blocks[number]
+
+	txs := make([]interface{}, 0, len(b.Transactions))
+	for _, tx := range b.Transactions {
+		if full {
+			txs = append(txs, txJSON(tx, true))
+		} else {
+			txs = append(txs, tx.Hash)
+		}
+	}
+
+	return map[string]interface{}{
+		"number":           hexUint(b.Number),
+		"hash":             b.Hash,
+		"parentHash":       b.ParentHash,
+		"nonce":            "0x0000000000000000",
+		"sha3Uncles":       emptyHash,
+		"logsBloom":        "0x",
+		"transactionsRoot": emptyHash,
+		"stateRoot":        emptyHash,
+		"miner":            "0x0000000000000000000000000000000000000000",
+		"difficulty":       "0x0",
+		"totalDifficulty":  "0x0",
+		"extraData":        "0x",
+		"size":             "0x0",
+		"gasLimit":         hexUint(blockGasLimit),
+		"gasUsed":          hexUint(b.GasUsed),
+		"timestamp":        hexUint(b.Timestamp),
+		"baseFeePerGas":    hexBig(baseFee),
+		"uncles":           []string{},
+		"transactions":     txs,
+	}
+}
+
+func (c *Chain) transactionJSON(hash string) interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	tx := c.transactions[strings.ToLower(hash)]
+	if tx == nil {
+		return nil
+	}
+
+	return txJSON(tx, c.isMined(tx))
+}
+
+func (c *Chain) receiptJSON(hash string) interface{} {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	tx := c.transactions[strings.ToLower(hash)]
+	if tx == nil || !c.isMined(tx) {
+		return nil
+	}
+
+	r := c.receipts[tx.Hash]
+
+	status := "0x0"
+	if r.Status {
+		status = "0x1"
+	}
+
+	logs := make([]interface{}, 0, len(r.Logs))
+	for _, log := range r.Logs {
+		logs = append(logs, map[string]interface{}{
+			"removed":          false,
+			"logIndex":         hexUint(uint64(log.LogIndex)),
+			"transactionIndex": hexUint(uint64(tx.Index)),
+			"transactionHash":  tx.Hash,
+			"blockNumber":      hexUint(tx.BlockNumber),
+			"blockHash":        tx.BlockHash,
+			"address":          log.Address,
+			"data":             utils.Bytes2HexP(log.Data),
+			"topics":           log.Topics,
+		})
+	}
+
+	return map[string]interface{}{
+		"transactionHash":   tx.Hash,
+		"transactionIndex":  hexUint(uint64(tx.Index)),
+		"blockHash":         tx.BlockHash,
+		"blockNumber":       hexUint(tx.BlockNumber),
+		"from":              tx.From,
+		"to":                tx.To,
+		"cumulativeGasUsed": hexUint(r.CumulativeGasUsed),
+		"gasUsed":           hexUint(r.GasUsed),
+		"effectiveGasPrice": hexBig(r.EffectiveGasPrice),
+		"logs":              logs,
+		"logsBloom":         "0x",
+		"status":            status,
+		"type":              hexUint(uint64(tx.Type)),
+	}
+}
+
+func txJSON(tx *transaction, mined bool) map[string]interface{} {
+	t := map[string]interface{}{
+		"hash":             tx.Hash,
+		"nonce":            hexUint(tx.Nonce),
+		"blockHash":        nil,
+		"blockNumber":      nil,
+		"transactionIndex": nil,
+		"from":             tx.From,
+		"to":               tx.To,
+		"value":            hexBig(tx.Value),
+		"gas":              hexUint(tx.Gas),
+		"gasPrice":         hexBig(tx.GasPrice),
+		"input":            utils.Bytes2HexP(tx.Data),
+		"type":             hexUint(uint64(tx.Type)),
+	}
+
+	if tx.Type == dynamicFeeTxType {
+		t["maxFeePerGas"] = hexBig(tx.GasPrice)
+		t["maxPriorityFeePerGas"] = hexBig(tx.MaxPriorityFeePerGas)
+		t["gasPrice"] = hexBig(tx.effectiveGasPrice(baseFee))
+	}
+
+	if mined {
+		t["blockHash"] = tx.BlockHash
+		t["blockNumber"] = hexUint(tx.BlockNumber)
+		t["transactionIndex"] = hexUint(uint64(tx.Index))
+	}
+
+	return t
+}
+
+var emptyHash = utils.Bytes2HexP(make([]byte, 32))
+
+func hexUint(n uint64) string {
+	return fmt.Sprintf("0x%x", n)
+}
+
+func hexBig(n *big.Int) string {
+	return fmt.Sprintf("0x%x", n)
+}
@@ -0,0 +1,122 @@
+package simchain
+
+import (
+	"context"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// A binary runs against the simulated chain when HSK_BLOCKCHAIN is simulated.
+// The chain lives in the process which starts first, it serves the chain at the address of HSK_BLOCKCHAIN_RPC_URL,
+// and the others find it there like they find a node. The chain is gone when that process exits.
+
+// IsEnabled tells if the binaries run against the simulated chain.
+func IsEnabled() bool {
+	return os.Getenv("HSK_BLOCKCHAIN") == "simulated"
+}
+
+// ConfigFromEnv builds the config of the chain:
+// HSK_SIMULATED_CHAIN_ID, 1337 by default, HSK_SIMULATED_BLOCK_TIME, 1s by default,
+// and HSK_SIMULATED_GENESIS, the path of a genesis json file, see genesis.example.json.
+func ConfigFromEnv() (Config, error) {
+	config := Config{
+		ChainID:         1337,
+		ExchangeAddress: os.Getenv("HSK_HYBRID_EXCHANGE_ADDRESS"),
+		ProxyAddress:    os.Getenv("HSK_PROXY_ADDRESS"),
+		BlockTime:       time.Second,
+	}
+
+	if chainID := os.Getenv("HSK_SIMULATED_CHAIN_ID"); chainID != "" {
+		id, err := strconv.ParseInt(chainID, 10, 64)
+		if err != nil {
+			return config, fmt.Errorf("invalid HSK_SIMULATED_CHAIN_ID %s", chainID)
+		}
+
+		config.ChainID = id
+	}
+
+	if blockTime := os.Getenv("HSK_SIMULATED_BLOCK_TIME"); blockTime != "" {
+		d, err := time.ParseDuration(blockTime)
+		if err != nil {
+			return config, fmt.Errorf("invalid HSK_SIMULATED_BLOCK_TIME %s", blockTime)
+		}
+
+		config.BlockTime = d
+	}
+
+	if path := os.Getenv("HSK_SIMULATED_GENESIS"); path != "" {
+		genesis, err := LoadGenesis(path)
+		if err != nil {
+			return config, err
+		}
+
+		config.Genesis = genesis
+	}
+
+	return config, nil
+}
+
+// Serve starts the chain from the env and serves it at the rpc url until the context is done.
+// It returns nil if another process serves at the rpc url already.
+func Serve(ctx context.Context, rpcURL string) (*Chain, error) {
+	u, err := url.Parse(rpcURL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("invalid rpc url %s", rpcURL)
+	}
+
+	listener, err := net.Listen("tcp", u.Host)
+	if isAddressInUse(err) {
+		utils.Infof("simulated chain is served by another process at %s", rpcURL)
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	config, err := ConfigFromEnv()
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	chain, err := NewChain(config)
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+
+	server := &http.Server{Handler: chain.Handler()}
+
+	go chain.Run(ctx)
+
+	go func() {
+		<-ctx.Done()
+		_ = server.Close()
+	}()
+
+	go func() {
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			utils.Errorf("simulated chain server error: %v", err)
+		}
+	}()
+
+	utils.Infof("simulated chain %d is served at %s, a block every %s", config.ChainID, rpcURL, config.BlockTime)
+
+	return chain, nil
+}
+
+func isAddressInUse(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+
+	syscallErr, ok := opErr.Err.(*os.SyscallError)
+	return ok && syscallErr.Err == syscall.EADDRINUSE
+}
@@ -0,0 +1,132 @@
+package simchain
+
+import (
+	"math/big"
+	"strings"
+)
+
+// an allowance from this amount on is never spent, like the approvals of the relayer
+var unlimitedAllowance = new(big.Int).Lsh(big.NewInt(1), 255)
+
+type holding struct {
+	token string
+	owner string
+}
+
+type allowanceKey struct {
+	token   string
+	owner   string
+	spender string
+}
+
+// state is what transactions change. Addresses are lower cased, amounts are in the smallest unit.
+// Amounts are never changed in place, so that a cloned state shares them safely.
+type state struct {
+	ether      map[string]*big.Int
+	nonces     map[string]uint64
+	balances   map[holding]*big.Int
+	allowances map[allowanceKey]*big.Int
+
+	// amounts filled by order hash, in the base token, or in the quote token for market buy orders
+	filled map[string]*big.Int
+}
+
+func newState() *state {
+	return &state{
+		ether:      make(map[string]*big.Int),
+		nonces:     make(map[string]uint64),
+		balances:   make(map[holding]*big.Int),
+		allowances: make(map[allowanceKey]*big.Int),
+		filled:     make(map[string]*big.Int),
+	}
+}
+
+// clone returns a copy to run a transaction on, which replaces the state if the transaction succeeds.
+func (s *state) clone() *state {
+	c := newState()
+
+	for k, v := range s.ether {
+		c.ether[k] = v
+	}
+	for k, v := range s.nonces {
+		c.nonces[k] = v
+	}
+	for k, v := range s.balances {
+		c.balances[k] = v
+	}
+	for k, v := range s.allowances {
+		c.allowances[k] = v
+	}
+	for k, v := range s.filled {
+		c.filled[k] = v
+	}
+
+	return c
+}
+
+func amountOf(amounts map[string]*big.Int, key string) *big.Int {
+	if amount, ok := amounts[key]; ok {
+		return amount
+	}
+
+	return big.NewInt(0)
+}
+
+func (s *state) etherOf(address string) *big.Int {
+	return amountOf(s.ether, strings.ToLower(address))
+}
+
+func (s *state) balanceOf(token, owner string) *big.Int {
+	if balance, ok := s.balances[holding{strings.ToLower(token), strings.ToLower(owner)}]; ok {
+		return balance
+	}
+
+	return big.NewInt(0)
+}
+
+func (s *state) allowanceOf(token, owner, spender string) *big.Int {
+	if allowance, ok := s.allowances[allowanceKey{strings.ToLower(token), strings.ToLower(owner), strings.ToLower(spender)}]; ok {
+		return allowance
+	}
+
+	return big.NewInt(0)
+}
+
+func (s *state) setBalance(token, owner string, amount *big.Int) {
+	s.balances[holding{strings.ToLower(token), strings.ToLower(owner)}] = amount
+}
+
+func (s *state) setAllowance(token, owner, spender string, amount *big.Int) {
+	s.allowances[allowanceKey{strings.ToLower(token), strings.ToLower(owner), strings.ToLower(spender)}] = amount
+}
+
+// transfer moves tokens, it fails if the owner doesn't have them.
+func (s *state) transfer(token, from, to string, amount *big.Int) error {
+	balance := s.balanceOf(token, from)
+	if balance.Cmp(amount) < 0 {
+		return revertError("TRANSFER_FAILED")
+	}
+
+	s.setBalance(token, from, new(big.Int).Sub(balance, amount))
+	s.setBalance(token, to, new(big.Int).Add(s.balanceOf(token, to), amount))
+
+	return nil
+}
+
+// transferFrom moves tokens of the owner on behalf of the spender, which spends its allowance.
+func (s *state) transferFrom(token, spender, from, to string, amount *big.Int) error {
+	allowance := s.allowanceOf(token, from, spender)
+	if allowance.Cmp(amount) < 0 {
+		return revertError("TRANSFER_FROM_FAILED")
+	}
+
+	if err := s.transfer(token, from, to, amount); err != nil {
+		return revertError("TRANSFER_FROM_FAILED")
+	}
+
+	if allowance.Cmp(unlimitedAllowance) < 0 {
+		s.setAllowance(token, from, spender, new(big.Int).Sub(allowance, amount))
+	}
+
+	return nil
+}
@@ -0,0 +1,237 @@
+package simchain
+
+import (
+	"errors"
+	"fmt"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/crypto"
+	"github.com/HydroProtocol/hydro-sdk-backend/sdk/rlp"
+	"github.com/HydroProtocol/hydro-sdk-backend/utils"
+	"math/big"
+	"strings"
+)
+
+// The chain accepts the two kinds of transactions the launcher signs:
+// legacy transactions signed with the homestead hash, and EIP-1559 (type 2) transactions.
+
+const dynamicFeeTxType = 0x02
+
+type transaction struct {
+	Type  byte
+	Hash  string
+	From  string
+	To    string
+	Nonce uint64
+	Value *big.Int
+	Gas   uint64
+	Data  []byte
+
+	// the gas price of a legacy transaction, or the max fee per gas of a dynamic fee transaction
+	GasPrice             *big.Int
+	MaxPriorityFeePerGas *big.Int
+
+	// set once the transaction is mined
+	BlockNumber uint64
+	BlockHash   string
+	Index       int
+}
+
+// effectiveGasPrice is what the transaction pays per gas in a block with the base fee.
+func (tx *transaction) effectiveGasPrice(baseFee *big.Int) *big.Int {
+	if tx.Type != dynamicFeeTxType {
+		return tx.GasPrice
+	}
+
+	price := new(big.Int).Add(baseFee, tx.MaxPriorityFeePerGas)
+	if price.Cmp(tx.GasPrice) > 0 {
+		return tx.GasPrice
+	}
+
+	return price
+}
+
+// decodeRawTransaction decodes a signed raw transaction, and recovers its sender.
+func decodeRawTransaction(raw []byte) (*transaction, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("rlp: empty transaction")
+	}
+
+	if raw[0] == dynamicFeeTxType {
+		return decodeDynamicFeeTx(raw)
+	}
+
+	if raw[0] < 0xc0 {
+		return nil, fmt.Errorf("rlp: unsupported transaction type %d", raw[0])
+	}
+
+	return decodeLegacyTx(raw)
+}
+
+func decodeLegacyTx(raw []byte) (*transaction, error) {
+	fields, err := decodeRlpList(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) != 9 {
+		return nil, fmt.Errorf("rlp: legacy transaction has %d fields", len(fields))
+	}
+
+	tx := &transaction{
+		Nonce:    bytesToUint64(fields[0]),
+		GasPrice: new(big.Int).SetBytes(fields[1]),
+		Gas:      bytesToUint64(fields[2]),
+		To:       bytesToAddress(fields[3]),
+		Value:    new(big.Int).SetBytes(fields[4]),
+		Data:     fields[5],
+	}
+
+	// homestead signatures have v 27 or 28
+	v := bytesToUint64(fields[6])
+	if v != 27 && v != 28 {
+		return nil, fmt.Errorf("invalid sender: unsupported v %d", v)
+	}
+
+	hash := crypto.Keccak256(rlp.Encode([]interface{}{fields[0], fields[1], fields[2], fields[3], fields[4], fields[5]}))
+	if tx.From, err = recoverSender(hash, fields[7], fields[8], byte(v-27)); err != nil {
+		return nil, err
+	}
+
+	tx.Hash = utils.Bytes2HexP(crypto.Keccak256(raw))
+	return tx, nil
+}
+
+func decodeDynamicFeeTx(raw []byte) (*transaction, error) {
+	fields, err := decodeRlpList(raw[1:])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(fields) != 12 {
+		return nil, fmt.Errorf("rlp: dynamic fee transaction has %d fields", len(fields))
+	}
+
+	tx := &transaction{
+		Type:                 dynamicFeeTxType,
+		Nonce:                bytesToUint64(fields[1]),
+		MaxPriorityFeePerGas: new(big.Int).SetBytes(fields[2]),
+		GasPrice:             new(big.Int).SetBytes(fields[3]),
+		Gas:                  bytesToUint64(fields[4]),
+		To:                   bytesToAddress(fields[5]),
+		Value:                new(big.Int).SetBytes(fields[6]),
+		Data:                 fields[7],
+	}
+
+	// the access list is not decoded, it has to be empty
+	if len(fields[8]) > 0 {
+		return nil, errors.New("rlp: access lists are not supported")
+	}
+
+	unsigned := []interface{}{fields[0], fields[1], fields[2], fields[3], fields[4], fields[5], fields[6], fields[7], []interface{}{}}
+	hash := crypto.Keccak256([]byte{dynamicFeeTxType}, rlp.Encode(unsigned))
+
+	if tx.From, err = recoverSender(hash, fields[10], fields[11], byte(bytesToUint64(fields[9]))); err != nil {
+		return nil, err
+	}
+
+	tx.Hash = utils.Bytes2HexP(crypto.Keccak256(raw))
+	return tx, nil
+}
+
+func recoverSender(hash, r, s []byte, v byte) (string, error) {
+	if len(r) > 32 || len(s) > 32 || v > 1 {
+		return "", errors.New("invalid sender: malformed signature")
+	}
+
+	signature := make([]byte, 65)
+	copy(signature[32-len(r):32], r)
+	copy(signature[64-len(s):64], s)
+	signature[64] = v
+
+	publicKey, err := crypto.SigToPub(hash, signature)
+	if err != nil || publicKey == nil {
+		return "", fmt.Errorf("invalid sender: %v", err)
+	}
+
+	return strings.ToLower(crypto.PubKey2Address(*publicKey)), nil
+}
+
+func bytesToUint64(bts []byte) uint64 {
+	return new(big.Int).SetBytes(bts).Uint64()
+}
+
+func bytesToAddress(bts []byte) string {
+	if len(bts) == 0 {
+		return ""
+	}
+
+	return strings.ToLower(utils.Bytes2HexP(bts))
+}
+
+// decodeRlpList decodes a list of byte strings, a nested list is returned as its raw encoding.
+// https://github.com/ethereum/wiki/wiki/RLP
+func decodeRlpList(data []byte) ([][]byte, error) {
+	payload, rest, isList, err := decodeRlpItem(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isList || len(rest) > 0 {
+		return nil, errors.New("rlp: transaction should be a single list")
+	}
+
+	var items [][]byte
+	for len(payload) > 0 {
+		item, next, _, err := decodeRlpItem(payload)
+		if err != nil {
+			return nil, err
+		}
+
+		items = append(items, item)
+		payload = next
+	}
+
+	return items, nil
+}
+
+// decodeRlpItem returns the payload of the first item, the bytes after it, and if it is a list.
+func decodeRlpItem(data []byte) (payload, rest []byte, isList bool, err error) {
+	if len(data) == 0 {
+		return nil, nil, false, errors.New("rlp: unexpected end of input")
+	}
+
+	prefix := data[0]
+
+	var offset, length int
+	switch {
+	case prefix < 0x80:
+		return data[:1], data[1:], false, nil
+	case prefix <= 0xb7:
+		offset, length = 1, int(prefix-0x80)
+	case prefix < 0xc0:
+		offset, length, err = longLength(data, int(prefix-0xb7))
+	case prefix <= 0xf7:
+		offset, length, isList = 1, int(prefix-0xc0), true
+	default:
+		offset, length, err = longLength(data, int(prefix-0xf7))
+		isList = true
+	}
+
+	if err != nil {
+		return nil, nil, false, err
+	}
+
+	if len(data) < offset+length {
+		return nil, nil, false, errors.New("rlp: value size exceeds available input length")
+	}
+
+	return data[offset : offset+length], data[offset+length:], isList, nil
+}
+
+func longLength(data []byte, lengthOfLength int) (int, int, error) {
+	if lengthOfLength > 4 || len(data) < 1+lengthOfLength {
+		return 0, 0, errors.New("rlp: invalid length")
+	}
+
+	length := int(new(big.Int).SetBytes(data[1 : 1+lengthOfLength]).Int64())
+	return 1 + lengthOfLength, length, nil
+}
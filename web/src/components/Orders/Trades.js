@@ -66,6 +66,9 @@ class Trades extends React.PureComponent {
                 if (trade.status === 'successful') {
                   status = <i className="fa fa-check" aria-hidden="true" />;
                   className += 'text-success';
+                } else if (trade.status === 'mined') {
+                  // mined, waiting for confirmations
+                  status = <i className="fa fa-check" aria-hidden="true" />;
                 } else if (trade.status === 'pending') {
                   status = <i className="fa fa-circle-o-notch fa-spin" aria-hidden="true" />;
                 } else {